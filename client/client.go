@@ -0,0 +1,222 @@
+package client
+
+// Client for the docker-scaler HTTP api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/server"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+// Client talks to a running docker-scaler
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	cluster    string
+}
+
+// APIError is returned when docker-scaler responds with an error status
+type APIError struct {
+	StatusCode int
+	Response   server.Response
+}
+
+func (e *APIError) Error() string {
+	if len(e.Response.ErrorCode) > 0 {
+		return fmt.Sprintf("docker-scaler responded with %d (%s): %s",
+			e.StatusCode, e.Response.ErrorCode, e.Response.Message)
+	}
+	return fmt.Sprintf("docker-scaler responded with %d: %s",
+		e.StatusCode, e.Response.Message)
+}
+
+// New creates a Client for docker-scaler at `baseURL`, for example
+// `http://scaler:8080`. `baseURL` includes the server prefix when one is
+// used. When `httpClient` is nil, `http.DefaultClient` is used
+func New(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: httpClient,
+	}
+}
+
+// ForCluster returns a copy of `c` that sends its requests to cluster
+// `name`. Operations are listed for that cluster only. An empty `name`
+// selects the default cluster
+func (c *Client) ForCluster(name string) *Client {
+	cc := *c
+	cc.cluster = name
+	return &cc
+}
+
+// ScaleService scales `serviceName` in `direction` by `by` replicas. When
+// `by` is zero, the service labels or server defaults are used
+func (c *Client) ScaleService(ctx context.Context, serviceName string, direction service.ScaleDirection, by uint64) (server.Response, error) {
+	q := url.Values{}
+	q.Set("service", serviceName)
+	q.Set("scale", string(direction))
+	if by > 0 {
+		q.Set("by", strconv.FormatUint(by, 10))
+	}
+	return c.respond(ctx, "POST", "/scale-service", q)
+}
+
+// ScaleNodes scales nodes of `nodeType` in `direction` by `by` nodes
+func (c *Client) ScaleNodes(ctx context.Context, direction service.ScaleDirection, nodeType cloud.NodeType, by uint64) (server.Response, error) {
+	q := url.Values{}
+	q.Set("scale", string(direction))
+	q.Set("type", string(nodeType))
+	q.Set("by", strconv.FormatUint(by, 10))
+	return c.respond(ctx, "POST", "/scale-nodes", q)
+}
+
+// RescheduleAll reschedules all services with the reschedule label
+func (c *Client) RescheduleAll(ctx context.Context) (server.Response, error) {
+	return c.respond(ctx, "POST", "/reschedule-services", nil)
+}
+
+// RescheduleService reschedules `serviceName`
+func (c *Client) RescheduleService(ctx context.Context, serviceName string) (server.Response, error) {
+	q := url.Values{}
+	q.Set("service", serviceName)
+	return c.respond(ctx, "POST", "/reschedule-service", q)
+}
+
+// Ping checks that docker-scaler is running
+func (c *Client) Ping(ctx context.Context) error {
+	resp, err := c.do(ctx, "GET", "/ping", nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return &APIError{StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// Ready runs the readiness checks of docker-scaler. The checks are returned
+// without an error when docker-scaler is not ready
+func (c *Client) Ready(ctx context.Context) (server.HealthResponse, error) {
+	var r server.HealthResponse
+	err := c.decode(ctx, "GET", "/health/ready", nil, &r, http.StatusServiceUnavailable)
+	return r, err
+}
+
+// Operations lists recent operations, newest first
+func (c *Client) Operations(ctx context.Context) ([]service.Operation, error) {
+	var r server.OperationsResponse
+	err := c.decode(ctx, "GET", "/operations", nil, &r)
+	return r.Operations, err
+}
+
+// Operation gets operation `id`
+func (c *Client) Operation(ctx context.Context, id string) (service.Operation, error) {
+	var r server.OperationResponse
+	err := c.decode(ctx, "GET", "/operations/"+url.PathEscape(id), nil, &r)
+	return r.Operation, err
+}
+
+// CancelOperation stops operation `id` from waiting for nodes
+func (c *Client) CancelOperation(ctx context.Context, id string) (server.Response, error) {
+	return c.respond(ctx, "DELETE", "/operations/"+url.PathEscape(id), nil)
+}
+
+// Approvals lists the changes that need approval, newest first
+func (c *Client) Approvals(ctx context.Context) ([]service.Approval, error) {
+	var r server.ApprovalsResponse
+	err := c.decode(ctx, "GET", "/approvals", nil, &r)
+	return r.Approvals, err
+}
+
+// Approve carries out the change of approval `id`
+func (c *Client) Approve(ctx context.Context, id string) (server.Response, error) {
+	return c.respond(ctx, "POST", "/approvals/"+url.PathEscape(id)+"/approve", nil)
+}
+
+// Reject rejects the change of approval `id`
+func (c *Client) Reject(ctx context.Context, id string) (server.Response, error) {
+	return c.respond(ctx, "POST", "/approvals/"+url.PathEscape(id)+"/reject", nil)
+}
+
+// respond sends a request and decodes its response. An APIError is
+// returned with the decoded response when the status is not 2xx
+func (c *Client) respond(ctx context.Context, method, path string, q url.Values) (server.Response, error) {
+	var r server.Response
+	err := c.decode(ctx, method, path, q, &r)
+	return r, err
+}
+
+// decode sends a request and decodes its body into `v`. An APIError is
+// returned when the status is not 2xx or one of `okCodes`
+func (c *Client) decode(ctx context.Context, method, path string, q url.Values, v interface{}, okCodes ...int) error {
+	resp, err := c.do(ctx, method, path, q)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read response from %s", path)
+	}
+
+	ok := resp.StatusCode >= 200 && resp.StatusCode <= 299
+	for _, code := range okCodes {
+		ok = ok || resp.StatusCode == code
+	}
+
+	if ok {
+		err = json.Unmarshal(body, v)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to decode response from %s", path)
+		}
+		return nil
+	}
+
+	// Error responses are always a `server.Response`, except when the
+	// route does not exist
+	var r server.Response
+	if json.Unmarshal(body, &r) != nil {
+		r = server.Response{Status: "NOK", Message: strings.TrimSpace(string(body))}
+	}
+	if sr, isResponse := v.(*server.Response); isResponse {
+		*sr = r
+	}
+	return &APIError{StatusCode: resp.StatusCode, Response: r}
+}
+
+func (c *Client) do(ctx context.Context, method, path string, q url.Values) (*http.Response, error) {
+	u := c.baseURL + "/v1" + path
+	if len(c.cluster) > 0 {
+		if q == nil {
+			q = url.Values{}
+		}
+		q.Set("cluster", c.cluster)
+	}
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+	req, err := http.NewRequest(method, u, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to create request to %s", path)
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to reach docker-scaler at %s", c.baseURL)
+	}
+	return resp, nil
+}
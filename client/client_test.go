@@ -0,0 +1,289 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/server"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+var errBoom = errors.New("boom")
+
+type scalerMock struct {
+	mock.Mock
+}
+
+func (m *scalerMock) Scale(ctx context.Context, serviceName string, by uint64, direction service.ScaleDirection) (service.ScaleResult, error) {
+	args := m.Called(serviceName, by, direction)
+	return args.Get(0).(service.ScaleResult), args.Error(1)
+}
+
+type alerterMock struct{}
+
+func (m alerterMock) Send(ctx context.Context, alertName string, serviceName string, request string, status string, message string) error {
+	return nil
+}
+
+type nodeScalerMock struct {
+	mock.Mock
+}
+
+func (m *nodeScalerMock) Scale(ctx context.Context, by uint64, direction service.ScaleDirection, nodeType cloud.NodeType, serviceName string) (uint64, uint64, error) {
+	args := m.Called(by, direction, nodeType)
+	return args.Get(0).(uint64), args.Get(1).(uint64), args.Error(2)
+}
+
+func (m *nodeScalerMock) String() string {
+	return "mock"
+}
+
+type reschedulerMock struct {
+	mock.Mock
+}
+
+func (m *reschedulerMock) RescheduleService(ctx context.Context, serviceID, value string) error {
+	args := m.Called(serviceID)
+	return args.Error(0)
+}
+
+func (m *reschedulerMock) RescheduleServicesWaitForNodes(ctx context.Context, manager bool, targetNodeCnt int, value string, timeOut time.Duration, tickerC chan<- time.Time, errorC chan<- error, statusC chan<- string) {
+}
+
+func (m *reschedulerMock) RescheduleAll(ctx context.Context, value string) (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *reschedulerMock) IsWaitingToReschedule() bool {
+	return false
+}
+
+func (m *reschedulerMock) CancelWait(value string) bool {
+	return false
+}
+
+func (m *reschedulerMock) Stop() {}
+
+type ClientTestSuite struct {
+	suite.Suite
+	m   *scalerMock
+	nsm *nodeScalerMock
+	rsm *reschedulerMock
+	ts  *httptest.Server
+	c   *Client
+	ctx context.Context
+}
+
+func TestClientUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ClientTestSuite))
+}
+
+func (s *ClientTestSuite) SetupTest() {
+	s.m = new(scalerMock)
+	s.nsm = new(nodeScalerMock)
+	s.rsm = new(reschedulerMock)
+	srv := server.NewServer(s.m, alerterMock{}, s.nsm, s.rsm, logging.Discard(),
+		false, false, false, false)
+	s.ts = httptest.NewServer(srv.MakeRouter("/scaler"))
+	s.c = New(s.ts.URL+"/scaler/", nil)
+	s.ctx = context.Background()
+}
+
+func (s *ClientTestSuite) TearDownTest() {
+	s.ts.Close()
+}
+
+func (s *ClientTestSuite) Test_Ping() {
+	s.NoError(s.c.Ping(s.ctx))
+}
+
+func (s *ClientTestSuite) Test_Ping_Unreachable() {
+	c := New("http://127.0.0.1:1", nil)
+	s.Error(c.Ping(s.ctx))
+}
+
+func (s *ClientTestSuite) Test_ScaleService() {
+	s.m.On("Scale", "web", uint64(2), service.ScaleUpDirection).
+		Return(service.ScaleResult{
+			Message:  "Scaling web from 1 to 3 replicas (min: 1, max: 4)",
+			Previous: 1, Current: 3, Min: 1, Max: 4}, nil)
+
+	r, err := s.c.ScaleService(s.ctx, "web", service.ScaleUpDirection, 2)
+	s.Require().NoError(err)
+	s.Equal("OK", r.Status)
+	s.Equal("Scaling web from 1 to 3 replicas (min: 1, max: 4)", r.Message)
+	s.NotEmpty(r.OperationID)
+	s.Equal("web", r.Service)
+	s.Equal("up", r.Direction)
+	s.Equal(uint64(1), *r.Previous)
+	s.Equal(uint64(3), *r.Current)
+	s.False(*r.AtBound)
+	s.m.AssertExpectations(s.T())
+}
+
+func (s *ClientTestSuite) Test_ScaleService_DefaultBy() {
+	s.m.On("Scale", "web", uint64(0), service.ScaleDownDirection).
+		Return(service.ScaleResult{Message: "web is already descaled to the minimum number of 1 replicas", AtBound: true}, nil)
+
+	r, err := s.c.ScaleService(s.ctx, "web", service.ScaleDownDirection, 0)
+	s.Require().NoError(err)
+	s.True(*r.AtBound)
+	s.m.AssertExpectations(s.T())
+}
+
+func (s *ClientTestSuite) Test_ScaleService_Error() {
+	s.m.On("Scale", "web", uint64(1), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, errBoom)
+
+	r, err := s.c.ScaleService(s.ctx, "web", service.ScaleUpDirection, 1)
+	s.Require().Error(err)
+	apiErr, ok := err.(*APIError)
+	s.Require().True(ok)
+	s.Equal(http.StatusInternalServerError, apiErr.StatusCode)
+	s.Equal(server.ErrorCodeScaleFailed, apiErr.Response.ErrorCode)
+	s.Equal("NOK", r.Status)
+	s.Equal("boom", r.Message)
+}
+
+func (s *ClientTestSuite) Test_ScaleService_InvalidDirection() {
+	r, err := s.c.ScaleService(s.ctx, "web", service.ScaleDirection("sideways"), 1)
+	s.Require().Error(err)
+	s.Equal(server.ErrorCodeInvalidDirection, r.ErrorCode)
+	s.Contains(err.Error(), "400")
+}
+
+func (s *ClientTestSuite) Test_ScaleNodes() {
+	s.nsm.On("Scale", uint64(1), service.ScaleDownDirection, cloud.NodeWorkerType).
+		Return(uint64(3), uint64(2), nil)
+
+	r, err := s.c.ScaleNodes(s.ctx, service.ScaleDownDirection, cloud.NodeWorkerType, 1)
+	s.Require().NoError(err)
+	s.Equal("OK", r.Status)
+	s.Equal("worker", r.NodeType)
+	s.Equal(uint64(3), *r.NodesBefore)
+	s.Equal(uint64(2), *r.NodesAfter)
+	s.nsm.AssertExpectations(s.T())
+}
+
+func (s *ClientTestSuite) Test_RescheduleAll() {
+	s.rsm.On("RescheduleAll").Return("Rescheduled all services", nil)
+
+	r, err := s.c.RescheduleAll(s.ctx)
+	s.Require().NoError(err)
+	s.Equal("OK", r.Status)
+	s.Equal("Rescheduled all services", r.Message)
+	s.rsm.AssertExpectations(s.T())
+}
+
+func (s *ClientTestSuite) Test_RescheduleService() {
+	s.rsm.On("RescheduleService", "web").Return(nil)
+
+	r, err := s.c.RescheduleService(s.ctx, "web")
+	s.Require().NoError(err)
+	s.Equal("OK", r.Status)
+	s.Equal("web", r.Service)
+	s.rsm.AssertExpectations(s.T())
+}
+
+func (s *ClientTestSuite) Test_RescheduleService_Error() {
+	s.rsm.On("RescheduleService", "web").Return(errBoom)
+
+	r, err := s.c.RescheduleService(s.ctx, "web")
+	s.Require().Error(err)
+	s.Equal(server.ErrorCodeRescheduleFailed, r.ErrorCode)
+}
+
+func (s *ClientTestSuite) Test_Ready() {
+	r, err := s.c.Ready(s.ctx)
+	s.Require().NoError(err)
+	s.Equal("OK", r.Status)
+}
+
+func (s *ClientTestSuite) Test_Operations() {
+	s.rsm.On("RescheduleService", "web").Return(nil)
+	resp, err := s.c.RescheduleService(s.ctx, "web")
+	s.Require().NoError(err)
+
+	ops, err := s.c.Operations(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Len(ops, 1)
+	s.Equal(resp.OperationID, ops[0].ID)
+	s.Equal("reschedule_service", ops[0].Kind)
+
+	op, err := s.c.Operation(s.ctx, resp.OperationID)
+	s.Require().NoError(err)
+	s.Equal(service.OperationDone, op.State)
+	s.Equal("web", op.Target)
+}
+
+func (s *ClientTestSuite) Test_Operation_NotFound() {
+	_, err := s.c.Operation(s.ctx, "missing")
+	s.Require().Error(err)
+	apiErr, ok := err.(*APIError)
+	s.Require().True(ok)
+	s.Equal(http.StatusNotFound, apiErr.StatusCode)
+	s.Equal(server.ErrorCodeOperationNotFound, apiErr.Response.ErrorCode)
+}
+
+func (s *ClientTestSuite) Test_CancelOperation_NotWaiting() {
+	s.rsm.On("RescheduleService", "web").Return(nil)
+	resp, err := s.c.RescheduleService(s.ctx, "web")
+	s.Require().NoError(err)
+
+	r, err := s.c.CancelOperation(s.ctx, resp.OperationID)
+	s.Require().Error(err)
+	s.Equal(server.ErrorCodeOperationNotWaiting, r.ErrorCode)
+}
+
+func (s *ClientTestSuite) Test_ScaleNodes_NotConfigured() {
+	srv := server.NewServer(s.m, alerterMock{}, nil, s.rsm, logging.Discard(),
+		false, false, false, false)
+	ts := httptest.NewServer(srv.MakeRouter("/"))
+	defer ts.Close()
+
+	r, err := New(ts.URL, nil).ScaleNodes(s.ctx, service.ScaleUpDirection, cloud.NodeWorkerType, 1)
+	s.Require().Error(err)
+	s.Equal("NOK", r.Status)
+	s.Equal("404 page not found", r.Message)
+}
+
+func (s *ClientTestSuite) Test_ForCluster() {
+	em := new(scalerMock)
+	ersm := new(reschedulerMock)
+	srv := server.NewServer(s.m, alerterMock{}, s.nsm, s.rsm, logging.Discard(),
+		false, false, false, false)
+	srv.AddCluster("eu-west", server.Cluster{ServiceScaler: em, Rescheduler: ersm})
+	ts := httptest.NewServer(srv.MakeRouter("/"))
+	defer ts.Close()
+
+	c := New(ts.URL, nil)
+	eu := c.ForCluster("eu-west")
+	em.On("Scale", "web", uint64(1), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 1 to 2 replicas"}, nil)
+	s.rsm.On("RescheduleService", "web").Return(nil)
+
+	_, err := eu.ScaleService(s.ctx, "web", service.ScaleUpDirection, 1)
+	s.Require().NoError(err)
+	_, err = c.RescheduleService(s.ctx, "web")
+	s.Require().NoError(err)
+	em.AssertExpectations(s.T())
+	s.m.AssertNotCalled(s.T(), "Scale", mock.Anything, mock.Anything, mock.Anything)
+
+	ops, err := eu.Operations(s.ctx)
+	s.Require().NoError(err)
+	s.Require().Len(ops, 1)
+	s.Equal("eu-west", ops[0].Cluster)
+
+	_, err = c.ForCluster("ap-south").RescheduleAll(s.ctx)
+	s.Require().Error(err)
+	s.Equal(server.ErrorCodeUnknownCluster, err.(*APIError).Response.ErrorCode)
+}
@@ -0,0 +1,511 @@
+package cmd
+
+// Subcommands that talk to a running docker-scaler over HTTP, or check the
+// local configuration
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/client"
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/server"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+const (
+	defaultURL     = "http://localhost:8080"
+	requestTimeout = 30 * time.Second
+)
+
+// errUsage is returned when a subcommand is called with incorrect arguments
+var errUsage = errors.New("incorrect usage")
+
+// options are the flags shared by subcommands
+type options struct {
+	by uint64
+}
+
+type command struct {
+	name        string
+	args        string
+	description string
+	scales      bool
+	local       bool // runs without talking to docker-scaler
+	run         func(ctx context.Context, c *client.Client, args []string, opts options, p printer) error
+}
+
+var commands = []command{
+	{
+		name:        "scale-service",
+		args:        "SERVICE up|down",
+		description: "Scale a service up or down",
+		scales:      true,
+		run:         runScaleService,
+	},
+	{
+		name:        "scale-nodes",
+		args:        "manager|worker up|down",
+		description: "Scale manager or worker nodes up or down",
+		scales:      true,
+		run:         runScaleNodes,
+	},
+	{
+		name:        "reschedule",
+		args:        "[SERVICE]",
+		description: "Reschedule one service, or all services when no service is given",
+		run:         runReschedule,
+	},
+	{
+		name:        "status",
+		args:        "",
+		description: "Show the readiness checks of docker-scaler",
+		run:         runStatus,
+	},
+	{
+		name:        "history",
+		args:        "[OPERATION_ID]",
+		description: "List recent operations, or show one operation",
+		run:         runHistory,
+	},
+	{
+		name:        "approvals",
+		args:        "",
+		description: "List the scaling changes that need approval",
+		run:         runApprovals,
+	},
+	{
+		name:        "approve",
+		args:        "APPROVAL_ID",
+		description: "Approve and carry out a scaling change",
+		run:         runApprove,
+	},
+	{
+		name:        "reject",
+		args:        "APPROVAL_ID",
+		description: "Reject a scaling change",
+		run:         runReject,
+	},
+	{
+		name:        "config",
+		args:        "check",
+		description: "Validate the configuration and print the effective settings",
+		local:       true,
+		run:         runConfig,
+	},
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+// runCommand runs the subcommand named in args[0] and returns the exit
+// code
+func runCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		printUsage(stderr)
+		return 2
+	}
+	if args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(stdout)
+		return 0
+	}
+	cmd, ok := findCommand(args[0])
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown command: %s\n\n", args[0])
+		printUsage(stderr)
+		return 2
+	}
+
+	fs := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	var url, cluster *string
+	if !cmd.local {
+		url = fs.String("url", defaultScalerURL(), "URL of docker-scaler, including SERVER_PREFIX")
+		cluster = fs.String("cluster", "", "Cluster to act on (defaults to the default cluster)")
+	}
+	output := fs.String("output", "table", "Output format: table or json")
+	var opts options
+	if cmd.scales {
+		fs.Uint64Var(&opts.by, "by", 0, "Number to scale by (defaults to service labels or configuration)")
+	}
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: docker-scaler %s [OPTIONS] %s\n\n%s\n\nOptions:\n",
+			cmd.name, cmd.args, cmd.description)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args[1:]); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+
+	p, err := newPrinter(*output, stdout)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancel()
+
+	var c *client.Client
+	if !cmd.local {
+		c = client.New(*url, nil).ForCluster(*cluster)
+	}
+	err = cmd.run(ctx, c, fs.Args(), opts, p)
+	if err == errUsage {
+		fs.Usage()
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s error: %s\n", cmd.name, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: docker-scaler [COMMAND] [OPTIONS]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Without a command, docker-scaler starts the server.")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, c := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", c.name, c.description)
+	}
+	tw.Flush()
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'docker-scaler COMMAND -h' for the options of a command.")
+}
+
+// defaultScalerURL points to docker-scaler in the same container, unless
+// DOCKER_SCALER_URL is set
+func defaultScalerURL() string {
+	if u := os.Getenv("DOCKER_SCALER_URL"); len(u) > 0 {
+		return u
+	}
+	prefix := os.Getenv("SERVER_PREFIX")
+	if len(prefix) == 0 || prefix == "/" {
+		return defaultURL
+	}
+	return defaultURL + path.Join("/", prefix)
+}
+
+func parseDirection(s string) (service.ScaleDirection, error) {
+	switch s {
+	case "up":
+		return service.ScaleUpDirection, nil
+	case "down":
+		return service.ScaleDownDirection, nil
+	}
+	return "", errUsage
+}
+
+func runScaleService(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	direction, err := parseDirection(args[1])
+	if err != nil {
+		return err
+	}
+	return p.response(c.ScaleService(ctx, args[0], direction, opts.by))
+}
+
+func runScaleNodes(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	nodeType := cloud.NodeType(args[0])
+	if nodeType != cloud.NodeManagerType && nodeType != cloud.NodeWorkerType {
+		return errUsage
+	}
+	direction, err := parseDirection(args[1])
+	if err != nil {
+		return err
+	}
+	return p.response(c.ScaleNodes(ctx, direction, nodeType, opts.by))
+}
+
+func runReschedule(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	switch len(args) {
+	case 0:
+		return p.response(c.RescheduleAll(ctx))
+	case 1:
+		return p.response(c.RescheduleService(ctx, args[0]))
+	}
+	return errUsage
+}
+
+func runStatus(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	r, err := c.Ready(ctx)
+	if err != nil {
+		return err
+	}
+	p.health(r)
+	if r.Status != "OK" {
+		return errors.New("docker-scaler is not ready")
+	}
+	return nil
+}
+
+func runHistory(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	switch len(args) {
+	case 0:
+		ops, err := c.Operations(ctx)
+		if err != nil {
+			return err
+		}
+		p.operations(ops)
+		return nil
+	case 1:
+		op, err := c.Operation(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		p.operation(op)
+		return nil
+	}
+	return errUsage
+}
+
+func runApprovals(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	approvals, err := c.Approvals(ctx)
+	if err != nil {
+		return err
+	}
+	p.approvals(approvals)
+	return nil
+}
+
+func runApprove(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return p.response(c.Approve(ctx, args[0]))
+}
+
+func runReject(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	return p.response(c.Reject(ctx, args[0]))
+}
+
+// runConfig reads the configuration the same way docker-scaler does when
+// it starts, prints the effective settings and validates them
+func runConfig(ctx context.Context, c *client.Client, args []string, opts options, p printer) error {
+	if len(args) != 1 || args[0] != "check" {
+		return errUsage
+	}
+	cfg, _, err := readConfig(os.Getenv("CONFIG_FILE"))
+	if _, invalid := err.(config.ValidationError); err != nil && !invalid {
+		return err
+	}
+	p.settings(cfg.Settings())
+	return err
+}
+
+// printer writes responses as tables or json
+type printer struct {
+	w    io.Writer
+	json bool
+}
+
+func newPrinter(format string, w io.Writer) (printer, error) {
+	switch format {
+	case "table":
+		return printer{w: w}, nil
+	case "json":
+		return printer{w: w, json: true}, nil
+	}
+	return printer{}, fmt.Errorf("Unknown output format: %s, format can only be table or json", format)
+}
+
+func (p printer) writeJSON(v interface{}) {
+	b, _ := json.MarshalIndent(v, "", "  ")
+	fmt.Fprintln(p.w, string(b))
+}
+
+// response prints `r` even when err is an error response from
+// docker-scaler
+func (p printer) response(r server.Response, err error) error {
+	if _, isAPIError := err.(*client.APIError); err != nil && !isAPIError {
+		return err
+	}
+	if p.json {
+		p.writeJSON(r)
+		return err
+	}
+
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	row := func(key, value string) {
+		if len(value) > 0 {
+			fmt.Fprintf(tw, "%s\t%s\n", key, value)
+		}
+	}
+	u := func(v *uint64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatUint(*v, 10)
+	}
+	row("STATUS", r.Status)
+	row("MESSAGE", r.Message)
+	row("ERROR CODE", r.ErrorCode)
+	row("OPERATION", r.OperationID)
+	row("APPROVAL", r.ApprovalID)
+	row("SERVICE", r.Service)
+	row("NODE TYPE", r.NodeType)
+	row("DIRECTION", r.Direction)
+	row("PREVIOUS", u(r.Previous))
+	row("CURRENT", u(r.Current))
+	row("MIN", u(r.Min))
+	row("MAX", u(r.Max))
+	row("NODES BEFORE", u(r.NodesBefore))
+	row("NODES AFTER", u(r.NodesAfter))
+	if r.AtBound != nil {
+		row("AT BOUND", strconv.FormatBool(*r.AtBound))
+	}
+	for _, pr := range r.Preempted {
+		row("PREEMPTED", fmt.Sprintf("%s %d -> %d", pr.Service, pr.Previous, pr.Current))
+	}
+	tw.Flush()
+	return err
+}
+
+func (p printer) health(r server.HealthResponse) {
+	if p.json {
+		p.writeJSON(r)
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tMESSAGE")
+	for _, name := range sortedChecks(r.Checks) {
+		check := r.Checks[name]
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", name, check.Status, check.Message)
+	}
+	tw.Flush()
+}
+
+func (p printer) operations(ops []service.Operation) {
+	if p.json {
+		p.writeJSON(ops)
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tKIND\tTARGET\tSTATE\tCREATED\tMESSAGE")
+	for _, op := range ops {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			op.ID, op.Kind, op.Target, op.State,
+			op.CreatedAt.Format(time.RFC3339), operationMessage(op))
+	}
+	tw.Flush()
+}
+
+func (p printer) operation(op service.Operation) {
+	if p.json {
+		p.writeJSON(op)
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "ID\t%s\n", op.ID)
+	fmt.Fprintf(tw, "KIND\t%s\n", op.Kind)
+	if len(op.Target) > 0 {
+		fmt.Fprintf(tw, "TARGET\t%s\n", op.Target)
+	}
+	fmt.Fprintf(tw, "STATE\t%s\n", op.State)
+	fmt.Fprintf(tw, "CREATED\t%s\n", op.CreatedAt.Format(time.RFC3339))
+	if op.FinishedAt != nil {
+		fmt.Fprintf(tw, "FINISHED\t%s\n", op.FinishedAt.Format(time.RFC3339))
+	}
+	if msg := operationMessage(op); len(msg) > 0 {
+		fmt.Fprintf(tw, "MESSAGE\t%s\n", msg)
+	}
+	tw.Flush()
+
+	if len(op.Results) == 0 {
+		return
+	}
+	fmt.Fprintln(p.w)
+	tw = tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSTATUS\tMESSAGE")
+	for _, r := range op.Results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", r.Time.Format(time.RFC3339), r.Status, r.Message)
+	}
+	tw.Flush()
+}
+
+func (p printer) approvals(approvals []service.Approval) {
+	if p.json {
+		p.writeJSON(approvals)
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tKIND\tTARGET\tCHANGE\tSTATE\tEXPIRES\tREASON")
+	for _, a := range approvals {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d -> %d\t%s\t%s\t%s\n",
+			a.ID, a.Kind, a.Target, a.Previous, a.Requested, a.State,
+			a.ExpiresAt.Format(time.RFC3339), a.Reason)
+	}
+	tw.Flush()
+}
+
+func (p printer) settings(settings []config.Setting) {
+	if p.json {
+		m := make(map[string]interface{}, len(settings))
+		for _, s := range settings {
+			m[s.Key] = s.Value
+		}
+		p.writeJSON(m)
+		return
+	}
+	tw := tabwriter.NewWriter(p.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE")
+	for _, s := range settings {
+		fmt.Fprintf(tw, "%s\t%s\n", s.Key, s)
+	}
+	tw.Flush()
+}
+
+func sortedChecks(checks map[string]server.HealthCheckResult) []string {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func operationMessage(op service.Operation) string {
+	if len(op.Error) > 0 {
+		return op.Error
+	}
+	return strings.Replace(op.Message, "\n", " ", -1)
+}
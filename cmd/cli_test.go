@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/server"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type CLITestSuite struct {
+	suite.Suite
+	mux    *http.ServeMux
+	ts     *httptest.Server
+	stdout *bytes.Buffer
+	stderr *bytes.Buffer
+}
+
+func TestCLIUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(CLITestSuite))
+}
+
+func (s *CLITestSuite) SetupTest() {
+	s.mux = http.NewServeMux()
+	s.ts = httptest.NewServer(s.mux)
+	s.stdout = new(bytes.Buffer)
+	s.stderr = new(bytes.Buffer)
+}
+
+func (s *CLITestSuite) TearDownTest() {
+	s.ts.Close()
+}
+
+func (s *CLITestSuite) respondWith(path string, code int, v interface{}) {
+	s.mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(v)
+	})
+}
+
+func (s *CLITestSuite) run(args ...string) int {
+	return runCommand(args, s.stdout, s.stderr)
+}
+
+func (s *CLITestSuite) Test_ScaleService_Table() {
+	var query string
+	current, previous := uint64(3), uint64(2)
+	s.mux.HandleFunc("/v1/scale-service", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		json.NewEncoder(w).Encode(server.Response{
+			Status: "OK", Message: "Scaling web from 2 to 3 replicas (min: 1, max: 4)",
+			Service: "web", Direction: "up", Previous: &previous, Current: &current})
+	})
+
+	code := s.run("scale-service", "-url", s.ts.URL, "-by", "1", "web", "up")
+	s.Require().Equal(0, code, s.stderr.String())
+	s.Equal("by=1&scale=up&service=web", query)
+	s.Equal("STATUS     OK\n"+
+		"MESSAGE    Scaling web from 2 to 3 replicas (min: 1, max: 4)\n"+
+		"SERVICE    web\n"+
+		"DIRECTION  up\n"+
+		"PREVIOUS   2\n"+
+		"CURRENT    3\n", s.stdout.String())
+}
+
+func (s *CLITestSuite) Test_ScaleService_Preempted() {
+	s.respondWith("/v1/scale-service", http.StatusOK, server.Response{
+		Status: "OK", Message: "Scaling web from 6 to 8 replicas",
+		Preempted: []service.Preemption{{Service: "batch", Priority: 1, Previous: 4, Current: 2}},
+	})
+
+	code := s.run("scale-service", "-url", s.ts.URL, "web", "up")
+	s.Require().Equal(0, code, s.stderr.String())
+	s.Contains(s.stdout.String(), "PREEMPTED  batch 4 -> 2\n")
+}
+
+func (s *CLITestSuite) Test_ScaleService_JSON() {
+	s.respondWith("/v1/scale-service", http.StatusOK,
+		server.Response{Status: "OK", Message: "hello"})
+
+	code := s.run("scale-service", "-url", s.ts.URL, "-output", "json", "web", "down")
+	s.Require().Equal(0, code, s.stderr.String())
+
+	var r server.Response
+	s.Require().NoError(json.Unmarshal(s.stdout.Bytes(), &r))
+	s.Equal("hello", r.Message)
+}
+
+func (s *CLITestSuite) Test_ScaleService_ErrorResponse() {
+	s.respondWith("/v1/scale-service", http.StatusInternalServerError,
+		server.Response{Status: "NOK", Message: "boom", ErrorCode: server.ErrorCodeScaleFailed})
+
+	code := s.run("scale-service", "-url", s.ts.URL, "web", "up")
+	s.Equal(1, code)
+	s.Contains(s.stdout.String(), "ERROR CODE  scale_failed")
+	s.Contains(s.stderr.String(), "scale-service error: docker-scaler responded with 500 (scale_failed): boom")
+}
+
+func (s *CLITestSuite) Test_ScaleService_Usage() {
+	s.Equal(2, s.run("scale-service", "-url", s.ts.URL, "web"))
+	s.Equal(2, s.run("scale-service", "-url", s.ts.URL, "web", "sideways"))
+	s.Contains(s.stderr.String(), "Usage: docker-scaler scale-service [OPTIONS] SERVICE up|down")
+}
+
+func (s *CLITestSuite) Test_ScaleNodes() {
+	var query string
+	s.mux.HandleFunc("/v1/scale-nodes", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		json.NewEncoder(w).Encode(server.Response{Status: "OK", NodeType: "worker"})
+	})
+
+	code := s.run("scale-nodes", "-url", s.ts.URL, "-by", "2", "worker", "up")
+	s.Require().Equal(0, code, s.stderr.String())
+	s.Equal("by=2&scale=up&type=worker", query)
+	s.Equal(2, s.run("scale-nodes", "-url", s.ts.URL, "node", "up"))
+}
+
+func (s *CLITestSuite) Test_Cluster() {
+	var query string
+	s.mux.HandleFunc("/v1/reschedule-services", func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		json.NewEncoder(w).Encode(server.Response{Status: "OK"})
+	})
+
+	s.Require().Equal(0, s.run("reschedule", "-url", s.ts.URL, "-cluster", "eu-west"), s.stderr.String())
+	s.Equal("cluster=eu-west", query)
+	s.Require().Equal(0, s.run("reschedule", "-url", s.ts.URL))
+	s.Empty(query)
+}
+
+func (s *CLITestSuite) Test_Reschedule() {
+	s.respondWith("/v1/reschedule-services", http.StatusOK,
+		server.Response{Status: "OK", Message: "all"})
+	s.respondWith("/v1/reschedule-service", http.StatusOK,
+		server.Response{Status: "OK", Message: "one"})
+
+	s.Equal(0, s.run("reschedule", "-url", s.ts.URL))
+	s.Contains(s.stdout.String(), "MESSAGE  all")
+	s.Equal(0, s.run("reschedule", "-url", s.ts.URL, "web"))
+	s.Contains(s.stdout.String(), "MESSAGE  one")
+	s.Equal(2, s.run("reschedule", "-url", s.ts.URL, "web", "db"))
+}
+
+func (s *CLITestSuite) Test_Status_NotReady() {
+	s.respondWith("/v1/health/ready", http.StatusServiceUnavailable,
+		server.HealthResponse{Status: "NOK", Checks: map[string]server.HealthCheckResult{
+			"docker": {Status: "OK"},
+			"cloud":  {Status: "NOK", Message: "invalid credentials"},
+		}})
+
+	code := s.run("status", "-url", s.ts.URL)
+	s.Equal(1, code)
+	s.Equal("CHECK   STATUS  MESSAGE\n"+
+		"cloud   NOK     invalid credentials\n"+
+		"docker  OK      \n", s.stdout.String())
+	s.Contains(s.stderr.String(), "status error: docker-scaler is not ready")
+}
+
+func (s *CLITestSuite) Test_History() {
+	created := time.Date(2018, 7, 10, 12, 0, 0, 0, time.UTC)
+	s.respondWith("/v1/operations", http.StatusOK, server.OperationsResponse{
+		Status: "OK",
+		Operations: []service.Operation{
+			{ID: "abc", Kind: "scale_service", Target: "web", State: service.OperationDone,
+				Message: "Scaled", CreatedAt: created},
+			{ID: "def", Kind: "reschedule_services", State: service.OperationFailed,
+				Error: "boom", CreatedAt: created},
+		},
+	})
+
+	code := s.run("history", "-url", s.ts.URL)
+	s.Require().Equal(0, code, s.stderr.String())
+	s.Equal("ID   KIND                 TARGET  STATE   CREATED               MESSAGE\n"+
+		"abc  scale_service        web     done    2018-07-10T12:00:00Z  Scaled\n"+
+		"def  reschedule_services          failed  2018-07-10T12:00:00Z  boom\n", s.stdout.String())
+}
+
+func (s *CLITestSuite) Test_History_Operation() {
+	created := time.Date(2018, 7, 10, 12, 0, 0, 0, time.UTC)
+	s.respondWith("/v1/operations/abc", http.StatusOK, server.OperationResponse{
+		Status: "OK",
+		Operation: service.Operation{
+			ID: "abc", Kind: "scale_nodes", Target: "worker",
+			State: service.OperationWaitingForNodes, CreatedAt: created,
+			Results: []service.OperationResult{
+				{Time: created, Status: "success", Message: "Changing the number of worker nodes"},
+			},
+		},
+	})
+
+	code := s.run("history", "-url", s.ts.URL, "abc")
+	s.Require().Equal(0, code, s.stderr.String())
+	s.Contains(s.stdout.String(), "STATE    waiting-for-nodes\n")
+	s.Contains(s.stdout.String(), "2018-07-10T12:00:00Z  success  Changing the number of worker nodes\n")
+}
+
+func (s *CLITestSuite) Test_Approvals() {
+	expires := time.Date(2018, 7, 10, 13, 0, 0, 0, time.UTC)
+	s.respondWith("/v1/approvals", http.StatusOK, server.ApprovalsResponse{
+		Status: "OK",
+		Approvals: []service.Approval{
+			{ID: "abc", Kind: "scale_service", Target: "web", Previous: 2, Requested: 10,
+				State: service.ApprovalPending, ExpiresAt: expires,
+				Reason: "10 replicas are more than APPROVAL_MAX_REPLICA_FACTOR of 2 times 2 replicas"},
+		},
+	})
+
+	code := s.run("approvals", "-url", s.ts.URL)
+	s.Require().Equal(0, code, s.stderr.String())
+	s.Equal("ID   KIND           TARGET  CHANGE   STATE              EXPIRES               REASON\n"+
+		"abc  scale_service  web     2 -> 10  awaiting-approval  2018-07-10T13:00:00Z  "+
+		"10 replicas are more than APPROVAL_MAX_REPLICA_FACTOR of 2 times 2 replicas\n", s.stdout.String())
+}
+
+func (s *CLITestSuite) Test_ApproveAndReject() {
+	s.respondWith("/v1/approvals/abc/approve", http.StatusOK,
+		server.Response{Status: "OK", Message: "Scaled web", ApprovalID: "abc", OperationID: "op2"})
+	s.respondWith("/v1/approvals/def/reject", http.StatusConflict,
+		server.Response{Status: "NOK", Message: "Approval def is already expired",
+			ErrorCode: "approval_not_pending", ApprovalID: "def"})
+
+	s.Require().Equal(0, s.run("approve", "-url", s.ts.URL, "abc"), s.stderr.String())
+	s.Contains(s.stdout.String(), "APPROVAL   abc\n")
+	s.Equal(1, s.run("reject", "-url", s.ts.URL, "def"))
+	s.Contains(s.stdout.String(), "ERROR CODE  approval_not_pending\n")
+	s.Equal(2, s.run("approve", "-url", s.ts.URL))
+}
+
+func (s *CLITestSuite) Test_ConfigCheck() {
+	path := filepath.Join(s.T().TempDir(), "config.yml")
+	s.Require().NoError(os.WriteFile(path, []byte("default_max_replicas: 10\n"), 0644))
+	s.T().Setenv("CONFIG_FILE", path)
+	s.T().Setenv("LOG_FORMAT", "json")
+
+	code := s.run("config", "check")
+	s.Require().Equal(0, code, s.stderr.String())
+	s.Contains(s.stdout.String(), "SETTING ")
+	s.Regexp(`default_max_replicas +10\n`, s.stdout.String())
+	s.Regexp(`log_format +"json"\n`, s.stdout.String())
+}
+
+func (s *CLITestSuite) Test_ConfigCheck_JSON() {
+	s.T().Setenv("CONFIG_FILE", "")
+	s.Require().Equal(0, s.run("config", "-output", "json", "check"), s.stderr.String())
+
+	var settings map[string]interface{}
+	s.Require().NoError(json.Unmarshal(s.stdout.Bytes(), &settings))
+	s.Equal(float64(5), settings["default_max_replicas"])
+	s.Equal("com.df.scaleMin", settings["min_scale_label"])
+}
+
+func (s *CLITestSuite) Test_ConfigCheck_Invalid() {
+	s.T().Setenv("CONFIG_FILE", "")
+	s.T().Setenv("DEFAULT_MIN_REPLICAS", "6")
+	s.T().Setenv("RESCHEDULE_FILTER_LABEL", "reschedule")
+
+	s.Equal(1, s.run("config", "check"))
+	s.Regexp(`default_min_replicas +6\n`, s.stdout.String())
+	s.Contains(s.stderr.String(), "config error: Invalid configuration:\n"+
+		"  - DEFAULT_MIN_REPLICAS (6) is greater than DEFAULT_MAX_REPLICAS (5)\n"+
+		`  - RESCHEDULE_FILTER_LABEL ("reschedule") does not have form key=value`)
+}
+
+func (s *CLITestSuite) Test_ConfigCheck_Usage() {
+	s.Equal(2, s.run("config"))
+	s.Contains(s.stderr.String(), "Usage: docker-scaler config [OPTIONS] check")
+	s.NotContains(s.stderr.String(), "-url")
+}
+
+func (s *CLITestSuite) Test_UnknownCommand() {
+	s.Equal(2, s.run("scale"))
+	s.Contains(s.stderr.String(), "Unknown command: scale")
+}
+
+func (s *CLITestSuite) Test_UnknownOutput() {
+	s.Equal(2, s.run("history", "-url", s.ts.URL, "-output", "yaml"))
+	s.Contains(s.stderr.String(), "Unknown output format: yaml")
+}
+
+func (s *CLITestSuite) Test_DefaultScalerURL() {
+	defer os.Unsetenv("DOCKER_SCALER_URL")
+	defer os.Unsetenv("SERVER_PREFIX")
+
+	os.Setenv("SERVER_PREFIX", "/")
+	s.Equal("http://localhost:8080", defaultScalerURL())
+	os.Setenv("SERVER_PREFIX", "scaler")
+	s.Equal("http://localhost:8080/scaler", defaultScalerURL())
+	os.Setenv("DOCKER_SCALER_URL", "http://scaler:8080")
+	s.Equal("http://scaler:8080", defaultScalerURL())
+}
@@ -0,0 +1,449 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/server"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+// resolveOptionsSetter is implemented by service scalers whose labels and
+// defaults can change while they run
+type resolveOptionsSetter interface {
+	SetResolveOptions(opts service.ResolveDeltaOptions)
+}
+
+// freezesSetter is implemented by service scalers and reschedulers that
+// reject changes during freezes
+type freezesSetter interface {
+	SetFreezes(f *service.Freezes)
+}
+
+// updatePolicySetter is implemented by service scalers that skip scaling
+// while swarm updates a service
+type updatePolicySetter interface {
+	SetUpdatePolicy(opts service.UpdatePolicyOptions)
+}
+
+// replicaQuotaSetter is implemented by service scalers that keep the
+// replicas within a quota
+type replicaQuotaSetter interface {
+	SetReplicaQuota(opts service.ReplicaQuotaOptions)
+}
+
+// preserveLabelSetter is implemented by service scalers that restore the
+// replicas they decided
+type preserveLabelSetter interface {
+	SetPreserveLabel(label string)
+}
+
+// replicasFileSetter is implemented by service scalers that save the
+// replicas they decided
+type replicasFileSetter interface {
+	SetReplicasFile(path string, onError func(err error)) error
+}
+
+// approvalsSetter is implemented by service scalers that park large
+// changes until they are approved
+type approvalsSetter interface {
+	SetApprovals(a *service.Approvals)
+}
+
+// reschedulerOptionsSetter is implemented by reschedulers whose options can
+// change while they run
+type reschedulerOptionsSetter interface {
+	SetOptions(filterLabel, envKey string, tickerInterval, timeOut time.Duration) error
+}
+
+// clusterComponents are the components of docker-scaler that act on one
+// swarm cluster
+type clusterComponents struct {
+	name        string
+	client      service.DockerClient
+	cloud       cloud.Cloud
+	scaler      service.ScalerServicer
+	nodeScaler  service.NodeScaling
+	rescheduler service.ReschedulerServicer
+}
+
+// reloader applies a new configuration to a running docker-scaler, either
+// from the config file or from defaults changed through the api.
+// Operations in flight keep the options they started with
+type reloader struct {
+	path      string
+	logger    *slog.Logger
+	server    *server.Server
+	clusters  []clusterComponents
+	freezes   *service.Freezes
+	budget    *service.NodeBudget
+	approvals *service.Approvals
+
+	// mu guards config and overrides, since the config file and the
+	// defaults can change at the same time
+	mu        sync.Mutex
+	config    config.Config
+	overrides config.Overrides
+}
+
+// reload reads the configuration again, logs what changed and applies it.
+// The running configuration is kept when the new one can not be read, is
+// invalid or can not be applied
+func (r *reloader) reload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, err := loadConfig(r.path, r.overrides)
+	if err != nil {
+		r.logger.Error(fmt.Sprintf("Unable to reload config: %s", err))
+		return
+	}
+
+	changes := config.Diff(r.config, c)
+	if len(changes) == 0 {
+		r.logger.Info("Reloaded config: nothing changed")
+		return
+	}
+
+	// Clusters added to the config file are only served after a restart
+	kept := c.KeepRestartSettings(r.config)
+	if err := r.apply(kept); err != nil {
+		r.logger.Error(fmt.Sprintf("Unable to reload config: %s", err))
+		return
+	}
+
+	for _, change := range changes {
+		if change.Restart {
+			r.logger.Warn(fmt.Sprintf("Config changed: %s (restart docker-scaler to apply)", change),
+				"setting", change.Key)
+			continue
+		}
+		r.logger.Info(fmt.Sprintf("Config changed: %s", change), "setting", change.Key)
+	}
+
+	r.config = kept
+}
+
+// Defaults returns the defaults in use by `cluster`
+func (r *reloader) Defaults(cluster string) config.Defaults {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	c, _ := r.config.Cluster(cluster)
+	return c.Defaults()
+}
+
+// UpdateDefaults applies `d` to `cluster` and keeps it as overrides of the
+// config file and env variables. The overrides are saved when
+// DEFAULTS_FILE is set, after reading it again so the defaults another
+// replica saved there are kept
+func (r *reloader) UpdateDefaults(cluster string, d config.Defaults) ([]config.Change, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.readDefaultsFile(); err != nil {
+		return nil, err
+	}
+	old, err := r.config.Cluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+	c, err := r.config.WithClusterDefaults(cluster, d)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	updated, err := c.Cluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+	changes := config.Diff(old, updated)
+	if len(changes) == 0 {
+		return nil, nil
+	}
+
+	overrides := config.Overrides{}
+	for k, v := range r.overrides {
+		overrides[k] = v
+	}
+	overrides.Set(cluster, changes)
+	if len(r.config.DefaultsFile) > 0 {
+		if err := overrides.Save(r.config.DefaultsFile); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.apply(c); err != nil {
+		return nil, err
+	}
+
+	r.overrides = overrides
+	r.config = c
+	return changes, nil
+}
+
+// ReloadDefaults reads DEFAULTS_FILE again and applies the defaults saved
+// there, for a replica that starts leading after another one changed them
+func (r *reloader) ReloadDefaults() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.readDefaultsFile()
+}
+
+// readDefaultsFile applies the overrides saved in DEFAULTS_FILE when they
+// differ from the overrides in use. It must be called with the lock held
+func (r *reloader) readDefaultsFile() error {
+	if len(r.config.DefaultsFile) == 0 {
+		return nil
+	}
+	overrides, err := config.ReadOverrides(r.config.DefaultsFile)
+	if err != nil {
+		return err
+	}
+	if (len(overrides) == 0 && len(r.overrides) == 0) || reflect.DeepEqual(overrides, r.overrides) {
+		return nil
+	}
+	c, err := loadConfig(r.path, overrides)
+	if err != nil {
+		return err
+	}
+	kept := c.KeepRestartSettings(r.config)
+	if err := r.apply(kept); err != nil {
+		return err
+	}
+	for _, change := range config.Diff(r.config, kept) {
+		r.logger.Info(fmt.Sprintf("Default changed in %s: %s", r.config.DefaultsFile, change), "setting", change.Key)
+	}
+	r.config = kept
+	r.overrides = overrides
+	return nil
+}
+
+// apply passes the settings of `c` that can change while docker-scaler
+// runs to the components of every cluster
+func (r *reloader) apply(c config.Config) error {
+	configs := make([]config.Config, len(r.clusters))
+	for i, cc := range r.clusters {
+		clusterConfig, err := c.Cluster(cc.name)
+		if err != nil {
+			return err
+		}
+		configs[i] = clusterConfig
+	}
+
+	checkers := alertmanagerHealthCheckers(c)
+	for i, cc := range r.clusters {
+		if err := cc.apply(configs[i]); err != nil {
+			return err
+		}
+		r.server.SetAlertBounds(cc.name, configs[i].AlertScaleMin, configs[i].AlertScaleMax,
+			configs[i].AlertNodeMin, configs[i].AlertNodeMax)
+		checkers = append(checkers, cc.healthCheckers()...)
+	}
+	r.server.SetAlerter(newAlerter(c))
+	r.server.SetHealthCheckers(checkers...)
+	if r.freezes != nil {
+		r.freezes.SetConfigured(serviceFreezes(c.Freezes))
+	}
+	if r.budget != nil {
+		r.budget.SetLimits(c.NodeBudgetMaxNodes, c.NodeBudgetMaxHourlyCost)
+		for i, cc := range r.clusters {
+			if cc.cloud != nil {
+				r.budget.SetPool(cc.name, cc.cloud,
+					configs[i].ManagerNodeHourlyPrice, configs[i].WorkerNodeHourlyPrice)
+			}
+		}
+	}
+	if r.approvals != nil {
+		for i, cc := range r.clusters {
+			r.approvals.SetPolicy(cc.name, service.ApprovalPolicy{
+				MaxNodeChange:    configs[i].ApprovalMaxNodeChange,
+				MaxReplicaFactor: configs[i].ApprovalMaxReplicaFactor,
+				Timeout:          time.Duration(configs[i].ApprovalTimeout) * time.Second,
+			})
+		}
+	}
+	return nil
+}
+
+// apply passes the settings of `c`, the configuration of the cluster, to
+// its components
+func (cc clusterComponents) apply(c config.Config) error {
+	if rs, ok := cc.rescheduler.(reschedulerOptionsSetter); ok {
+		err := rs.SetOptions(c.RescheduleFilterLabel, c.RescheduleEnvKey,
+			time.Duration(c.RescheduleTickerInterval)*time.Second,
+			time.Duration(c.RescheduleTimeOut)*time.Second)
+		if err != nil {
+			return err
+		}
+	}
+	if ss, ok := cc.scaler.(resolveOptionsSetter); ok {
+		ss.SetResolveOptions(serviceResolveOptions(c))
+	}
+	if us, ok := cc.scaler.(updatePolicySetter); ok {
+		us.SetUpdatePolicy(service.UpdatePolicyOptions{
+			Label:        c.ScaleDuringUpdateLabel,
+			Default:      service.UpdatePolicy(c.ScaleDuringUpdate),
+			QueueTimeout: time.Duration(c.ScaleDuringUpdateTimeout) * time.Second,
+		})
+	}
+	if qs, ok := cc.scaler.(replicaQuotaSetter); ok {
+		qs.SetReplicaQuota(service.ReplicaQuotaOptions{
+			Max:           c.ReplicaQuota,
+			Scope:         service.QuotaScope(c.ReplicaQuotaScope),
+			PriorityLabel: c.ScalePriorityLabel,
+		})
+	}
+	if ps, ok := cc.scaler.(preserveLabelSetter); ok {
+		ps.SetPreserveLabel(c.ScalePreserveLabel)
+	}
+	if ns, ok := cc.nodeScaler.(*service.NodeScaler); ok {
+		ns.SetResolveOptions(managerResolveOptions(c), workerResolveOptions(c))
+		ns.SetMinAvailableLabel(c.MinAvailableLabel)
+	}
+	return nil
+}
+
+// healthCheckers checks the docker and cloud endpoints of the cluster.
+// Checks of a named cluster are prefixed with its name
+func (cc clusterComponents) healthCheckers() []service.HealthChecker {
+	checkers := []service.HealthChecker{
+		service.NewDockerHealthChecker(cc.client),
+	}
+	if cc.cloud != nil {
+		checkers = append(checkers, service.NewCloudHealthChecker(cc.cloud))
+	}
+	if cc.name == config.DefaultCluster {
+		return checkers
+	}
+	for i, checker := range checkers {
+		checkers[i] = clusterHealthChecker{cluster: cc.name, HealthChecker: checker}
+	}
+	return checkers
+}
+
+// clusterHealthChecker names a check after the cluster it checks
+type clusterHealthChecker struct {
+	service.HealthChecker
+	cluster string
+}
+
+func (h clusterHealthChecker) Name() string {
+	return fmt.Sprintf("%s/%s", h.cluster, h.HealthChecker.Name())
+}
+
+// readConfig reads the configuration when docker-scaler starts, together
+// with the overrides saved in its DEFAULTS_FILE. The configuration is
+// returned with a config.ValidationError when it is invalid
+func readConfig(path string) (config.Config, config.Overrides, error) {
+	c, err := config.Load(path)
+	if err != nil {
+		return c, nil, err
+	}
+	overrides, err := config.ReadOverrides(c.DefaultsFile)
+	if err != nil {
+		return c, nil, err
+	}
+	c, err = applyOverrides(c, overrides)
+	return c, overrides, err
+}
+
+// loadConfig reads the configuration again, keeping the `overrides` of
+// defaults changed through the api
+func loadConfig(path string, overrides config.Overrides) (config.Config, error) {
+	c, err := config.Load(path)
+	if err != nil {
+		return c, err
+	}
+	return applyOverrides(c, overrides)
+}
+
+func applyOverrides(c config.Config, overrides config.Overrides) (config.Config, error) {
+	c, err := overrides.Apply(c)
+	if err != nil {
+		return c, err
+	}
+	return c, c.Validate()
+}
+
+func newAlerter(c config.Config) service.AlertServicer {
+	if len(c.AlertmanagerAddress) == 0 {
+		return service.NewSilentAlertService()
+	}
+	return service.NewAlertService(c.AlertmanagerAddress,
+		time.Duration(c.AlertTimeout)*time.Second)
+}
+
+func alertmanagerHealthCheckers(c config.Config) []service.HealthChecker {
+	if len(c.AlertmanagerAddress) == 0 {
+		return nil
+	}
+	return []service.HealthChecker{service.NewAlertmanagerHealthChecker(
+		c.AlertmanagerAddress, time.Duration(c.AlertTimeout)*time.Second)}
+}
+
+// serviceFreezes converts the freezes of the config file
+func serviceFreezes(freezes []config.Freeze) []service.Freeze {
+	converted := make([]service.Freeze, 0, len(freezes))
+	for _, f := range freezes {
+		sf := service.Freeze{
+			Name:     f.Name,
+			Start:    f.Start,
+			End:      f.End,
+			Scope:    service.FreezeScope(f.Scope),
+			Selector: f.Selector,
+			Policy:   service.FreezePolicy(f.Policy),
+			Cluster:  f.Cluster,
+			Reason:   f.Reason,
+		}
+		// Fills in the default scope and policy, the freeze is already
+		// validated with the config
+		sf.Validate()
+		converted = append(converted, sf)
+	}
+	return converted
+}
+
+func serviceResolveOptions(c config.Config) service.ResolveDeltaOptions {
+	return service.ResolveDeltaOptions{
+		MinLabel:           c.MinScaleLabel,
+		MaxLabel:           c.MaxScaleLabel,
+		ScaleDownByLabel:   c.ScaleDownByLabel,
+		ScaleUpByLabel:     c.ScaleUpByLabel,
+		DefaultMin:         c.DefaultMinReplicas,
+		DefaultMax:         c.DefaultMaxReplicas,
+		DefaultScaleDownBy: c.DefaultScaleServiceDownBy,
+		DefaultScaleUpBy:   c.DefaultScaleServiceUpBy,
+	}
+}
+
+func managerResolveOptions(c config.Config) service.ResolveDeltaOptions {
+	return service.ResolveDeltaOptions{
+		MinLabel:           c.MinScaleManagerNodeLabel,
+		MaxLabel:           c.MaxScaleManagerNodeLabel,
+		ScaleDownByLabel:   c.ScaleManagerNodeDownByLabel,
+		ScaleUpByLabel:     c.ScaleManagerNodeUpByLabel,
+		DefaultMin:         c.DefaultMinManagerNodes,
+		DefaultMax:         c.DefaultMaxManagerNodes,
+		DefaultScaleDownBy: c.DefaultScaleManagerNodeDownBy,
+		DefaultScaleUpBy:   c.DefaultScaleManagerNodeUpBy,
+	}
+}
+
+func workerResolveOptions(c config.Config) service.ResolveDeltaOptions {
+	return service.ResolveDeltaOptions{
+		MinLabel:           c.MinScaleWorkerNodeLabel,
+		MaxLabel:           c.MaxScaleWorkerNodeLabel,
+		ScaleDownByLabel:   c.ScaleWorkerNodeDownByLabel,
+		ScaleUpByLabel:     c.ScaleWorkerNodeUpByLabel,
+		DefaultMin:         c.DefaultMinWorkerNodes,
+		DefaultMax:         c.DefaultMaxWorkerNodes,
+		DefaultScaleDownBy: c.DefaultScaleWorkerNodeDownBy,
+		DefaultScaleUpBy:   c.DefaultScaleWorkerNodeUpBy,
+	}
+}
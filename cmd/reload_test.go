@@ -0,0 +1,296 @@
+package cmd
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/server"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type ReloadTestSuite struct {
+	suite.Suite
+	path string
+	b    *bytes.Buffer
+	r    *reloader
+}
+
+func TestReloadUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ReloadTestSuite))
+}
+
+func (s *ReloadTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "config.yml")
+	s.b = new(bytes.Buffer)
+	logger := slog.New(slog.NewTextHandler(s.b, nil))
+
+	c := config.Default()
+	rescheduler, err := service.NewReschedulerService(nil,
+		c.RescheduleFilterLabel, c.RescheduleEnvKey, time.Minute, time.Hour)
+	s.Require().NoError(err)
+	scaler := service.NewScalerService(nil, serviceResolveOptions(c))
+	srv := server.NewServer(scaler, newAlerter(c), nil, rescheduler, logger,
+		c.AlertScaleMin, c.AlertScaleMax, c.AlertNodeMin, c.AlertNodeMax)
+
+	s.r = &reloader{
+		path:   s.path,
+		config: c,
+		logger: logger,
+		server: srv,
+		clusters: []clusterComponents{{
+			name:        config.DefaultCluster,
+			scaler:      scaler,
+			rescheduler: rescheduler,
+		}},
+		freezes: service.NewFreezes(),
+	}
+}
+
+func (s *ReloadTestSuite) writeConfig(content string) {
+	s.Require().NoError(os.WriteFile(s.path, []byte(content), 0644))
+}
+
+func (s *ReloadTestSuite) Test_Reload_LogsChanges() {
+	s.writeConfig("default_max_replicas: 10\ngrpc_port: 9090\n")
+	s.r.reload()
+
+	s.Contains(s.b.String(), `level=INFO msg="Config changed: default_max_replicas: 5 -> 10"`)
+	s.Contains(s.b.String(), `level=WARN msg="Config changed: grpc_port: 0 -> 9090 (restart docker-scaler to apply)"`)
+	s.Equal(uint64(10), s.r.config.DefaultMaxReplicas)
+	s.Equal(uint16(0), s.r.config.GRPCPort)
+
+	s.b.Reset()
+	s.r.reload()
+	s.NotContains(s.b.String(), "default_max_replicas")
+	s.Contains(s.b.String(), "grpc_port: 0 -> 9090")
+}
+
+func (s *ReloadTestSuite) Test_Reload_Freezes() {
+	s.writeConfig(`
+freezes:
+  - name: vendor
+    start: 2026-10-20T22:00:00Z
+    end: 2026-10-21T02:00:00Z
+    scope: nodes
+`)
+	s.r.reload()
+	s.Contains(s.b.String(), `Config changed: freezes.vendor: none -> \"nodes from 2026-10-20T22:00:00Z to 2026-10-21T02:00:00Z\"`)
+	freezes := s.r.freezes.List()
+	s.Require().Len(freezes, 1)
+	s.Equal("vendor", freezes[0].Name)
+	s.Equal(service.FreezeNodes, freezes[0].Scope)
+	s.Equal(service.FreezeReject, freezes[0].Policy)
+	s.Equal("config", freezes[0].Source)
+
+	s.writeConfig("")
+	s.r.reload()
+	s.Empty(s.r.freezes.List())
+}
+
+func (s *ReloadTestSuite) Test_Reload_NothingChanged() {
+	s.writeConfig("")
+	s.r.reload()
+	s.Contains(s.b.String(), "Reloaded config: nothing changed")
+}
+
+func (s *ReloadTestSuite) Test_Reload_InvalidFile() {
+	s.writeConfig("default_max_replicas: ten\n")
+	s.r.reload()
+	s.Contains(s.b.String(), `level=ERROR msg="Unable to reload config: Unable to parse config file`)
+	s.Equal(config.Default(), s.r.config)
+}
+
+func (s *ReloadTestSuite) Test_Reload_InvalidRescheduleLabel() {
+	s.writeConfig("default_max_replicas: 10\nreschedule_filter_label: reschedule\n")
+	s.r.reload()
+	s.Contains(s.b.String(), "Unable to reload config: Invalid configuration")
+	s.Contains(s.b.String(), `RESCHEDULE_FILTER_LABEL (\"reschedule\") does not have form key=value`)
+	s.NotContains(s.b.String(), "Config changed")
+	s.Equal(config.Default(), s.r.config)
+}
+
+func (s *ReloadTestSuite) Test_Reload_InvalidConfig() {
+	s.writeConfig("default_max_replicas: 10\ndefault_min_replicas: 20\n")
+	s.r.reload()
+	s.Contains(s.b.String(), "DEFAULT_MIN_REPLICAS (20) is greater than DEFAULT_MAX_REPLICAS (10)")
+	s.NotContains(s.b.String(), "Config changed")
+	s.Equal(config.Default(), s.r.config)
+}
+
+func (s *ReloadTestSuite) Test_UpdateDefaults() {
+	d := s.r.Defaults(config.DefaultCluster)
+	d.Service.Max = 20
+	d.Reschedule.TickerInterval = 30
+
+	changes, err := s.r.UpdateDefaults(config.DefaultCluster, d)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 2)
+	s.Equal("default_max_replicas: 5 -> 20", changes[0].String())
+	s.Equal(uint64(20), s.r.config.DefaultMaxReplicas)
+	s.Equal(config.Overrides{"default_max_replicas": uint64(20), "reschedule_ticker_interval": int64(30)},
+		s.r.overrides)
+
+	changes, err = s.r.UpdateDefaults(config.DefaultCluster, d)
+	s.Require().NoError(err)
+	s.Empty(changes)
+}
+
+func (s *ReloadTestSuite) Test_UpdateDefaults_Invalid() {
+	d := s.r.Defaults(config.DefaultCluster)
+	d.Worker.Min = 10
+
+	_, err := s.r.UpdateDefaults(config.DefaultCluster, d)
+	s.Require().Error(err)
+	s.IsType(config.ValidationError{}, err)
+	s.Equal(config.Default(), s.r.config)
+	s.Empty(s.r.overrides)
+}
+
+func (s *ReloadTestSuite) Test_UpdateDefaults_KeptOnReload() {
+	d := s.r.Defaults(config.DefaultCluster)
+	d.Service.Max = 20
+	_, err := s.r.UpdateDefaults(config.DefaultCluster, d)
+	s.Require().NoError(err)
+
+	s.writeConfig("default_max_replicas: 10\ndefault_min_replicas: 2\n")
+	s.r.reload()
+	s.NotContains(s.b.String(), "default_max_replicas")
+	s.Contains(s.b.String(), "Config changed: default_min_replicas: 1 -> 2")
+	s.Equal(uint64(20), s.r.config.DefaultMaxReplicas)
+}
+
+func (s *ReloadTestSuite) Test_UpdateDefaults_Saved() {
+	path := filepath.Join(s.T().TempDir(), "defaults.yml")
+	s.r.config.DefaultsFile = path
+	d := s.r.Defaults(config.DefaultCluster)
+	d.Service.Max = 20
+	_, err := s.r.UpdateDefaults(config.DefaultCluster, d)
+	s.Require().NoError(err)
+
+	s.T().Setenv("DEFAULTS_FILE", path)
+	s.T().Setenv("DEFAULT_MAX_REPLICAS", "10")
+	c, overrides, err := readConfig("")
+	s.Require().NoError(err)
+	s.Equal(uint64(20), c.DefaultMaxReplicas)
+	s.Len(overrides, 1)
+}
+
+func (s *ReloadTestSuite) Test_UpdateDefaults_SaveFails() {
+	s.r.config.DefaultsFile = filepath.Join(s.T().TempDir(), "missing", "defaults.yml")
+	d := s.r.Defaults(config.DefaultCluster)
+	d.Service.Max = 20
+
+	_, err := s.r.UpdateDefaults(config.DefaultCluster, d)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to save defaults")
+	s.Equal(uint64(5), s.r.config.DefaultMaxReplicas)
+	s.Empty(s.r.overrides)
+}
+
+func (s *ReloadTestSuite) Test_ReloadDefaults() {
+	s.writeConfig("")
+	path := filepath.Join(s.T().TempDir(), "defaults.yml")
+	s.r.config.DefaultsFile = path
+	s.Require().NoError(config.Overrides{"default_max_replicas": uint64(20)}.Save(path))
+
+	s.Require().NoError(s.r.ReloadDefaults())
+	s.Equal(uint64(20), s.r.config.DefaultMaxReplicas)
+	s.Equal(path, s.r.config.DefaultsFile)
+	s.Contains(s.b.String(), "default_max_replicas: 5 -> 20")
+
+	s.b.Reset()
+	s.Require().NoError(s.r.ReloadDefaults())
+	s.Empty(s.b.String())
+}
+
+func (s *ReloadTestSuite) Test_UpdateDefaults_KeepsSavedByOtherReplica() {
+	s.addCluster("eu-west")
+	s.writeConfig("clusters:\n" +
+		"  eu-west:\n" +
+		"    docker_manager_hosts: tcp://eu-west:2376\n")
+	path := filepath.Join(s.T().TempDir(), "defaults.yml")
+	s.r.config.DefaultsFile = path
+	s.Require().NoError(config.Overrides{"clusters": map[string]interface{}{
+		"eu-west": map[string]interface{}{"default_max_replicas": uint64(20)}}}.Save(path))
+
+	d := s.r.Defaults(config.DefaultCluster)
+	d.Service.Max = 10
+	_, err := s.r.UpdateDefaults(config.DefaultCluster, d)
+	s.Require().NoError(err)
+	s.Equal(uint64(10), s.r.Defaults(config.DefaultCluster).Service.Max)
+	s.Equal(uint64(20), s.r.Defaults("eu-west").Service.Max)
+
+	overrides, err := config.ReadOverrides(path)
+	s.Require().NoError(err)
+	s.Len(overrides, 2)
+}
+
+func (s *ReloadTestSuite) addCluster(name string) {
+	c := config.Default()
+	rescheduler, err := service.NewReschedulerService(nil,
+		c.RescheduleFilterLabel, c.RescheduleEnvKey, time.Minute, time.Hour)
+	s.Require().NoError(err)
+	cc := clusterComponents{
+		name:        name,
+		scaler:      service.NewScalerService(nil, serviceResolveOptions(c)),
+		rescheduler: rescheduler,
+	}
+	s.r.server.AddCluster(name, server.Cluster{ServiceScaler: cc.scaler, Rescheduler: cc.rescheduler})
+	s.r.clusters = append(s.r.clusters, cc)
+	if s.r.config.Clusters == nil {
+		s.r.config.Clusters = map[string]map[string]interface{}{}
+	}
+	s.r.config.Clusters[name] = map[string]interface{}{"docker_manager_hosts": "tcp://" + name + ":2376"}
+}
+
+func (s *ReloadTestSuite) Test_Reload_Clusters() {
+	s.addCluster("eu-west")
+	s.writeConfig("clusters:\n" +
+		"  eu-west:\n" +
+		"    docker_manager_hosts: tcp://eu-west:2376\n" +
+		"    default_max_replicas: 10\n" +
+		"  us-east:\n" +
+		"    docker_manager_hosts: tcp://us-east:2376\n")
+	s.r.reload()
+
+	s.Contains(s.b.String(), `level=INFO msg="Config changed: clusters.eu-west.default_max_replicas: none -> 10"`)
+	s.Contains(s.b.String(), `msg="Config changed: clusters.us-east: none -> map[docker_manager_hosts:tcp://us-east:2376] (restart docker-scaler to apply)"`)
+	s.Equal([]string{config.DefaultCluster, "eu-west"}, s.r.config.ClusterNames())
+	s.Equal(uint64(10), s.r.Defaults("eu-west").Service.Max)
+	s.Equal(uint64(5), s.r.Defaults(config.DefaultCluster).Service.Max)
+}
+
+func (s *ReloadTestSuite) Test_UpdateDefaults_Cluster() {
+	s.addCluster("eu-west")
+	d := s.r.Defaults("eu-west")
+	d.Service.Max = 20
+
+	changes, err := s.r.UpdateDefaults("eu-west", d)
+	s.Require().NoError(err)
+	s.Require().Len(changes, 1)
+	s.Equal("default_max_replicas: 5 -> 20", changes[0].String())
+	s.Equal(uint64(20), s.r.Defaults("eu-west").Service.Max)
+	s.Equal(uint64(5), s.r.Defaults(config.DefaultCluster).Service.Max)
+	s.Equal(config.Overrides{"clusters": map[string]interface{}{
+		"eu-west": map[string]interface{}{"default_max_replicas": uint64(20)}}}, s.r.overrides)
+
+	_, err = s.r.UpdateDefaults("ap-south", d)
+	s.EqualError(err, "Unknown cluster: ap-south, cluster can only be default, eu-west")
+}
+
+func (s *ReloadTestSuite) Test_HealthCheckers_Cluster() {
+	s.addCluster("eu-west")
+	var names []string
+	for _, cc := range s.r.clusters {
+		for _, checker := range cc.healthCheckers() {
+			names = append(names, checker.Name())
+		}
+	}
+	s.Equal([]string{"docker", "eu-west/docker"}, names)
+}
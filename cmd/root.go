@@ -1,152 +1,344 @@
 package cmd
 
-// Reads configuration from environment to create and run scaling service
+// Reads configuration from a config file and environment to create and run
+// scaling service
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"log/slog"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
-	"github.com/kelseyhightower/envconfig"
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/logging"
 	"github.com/thomasjpfan/docker-scaler/server"
 	"github.com/thomasjpfan/docker-scaler/service"
 	"github.com/thomasjpfan/docker-scaler/service/cloud"
+	"github.com/thomasjpfan/docker-scaler/tracing"
 )
 
-type specification struct {
-	ServerPrefix              string `envconfig:"SERVER_PREFIX"`
-	MinScaleLabel             string `envconfig:"MIN_SCALE_LABEL"`
-	MaxScaleLabel             string `envconfig:"MAX_SCALE_LABEL"`
-	AlertScaleMin             bool   `envconfig:"ALERT_SCALE_MIN"`
-	AlertScaleMax             bool   `envconfig:"ALERT_SCALE_MAX"`
-	DefaultMinReplicas        uint64 `envconfig:"DEFAULT_MIN_REPLICAS"`
-	DefaultMaxReplicas        uint64 `envconfig:"DEFAULT_MAX_REPLICAS"`
-	ScaleDownByLabel          string `envconfig:"SCALE_DOWN_BY_LABEL"`
-	ScaleUpByLabel            string `envconfig:"SCALE_UP_BY_LABEL"`
-	DefaultScaleServiceDownBy uint64 `envconfig:"DEFAULT_SCALE_SERVICE_DOWN_BY"`
-	DefaultScaleServiceUpBy   uint64 `envconfig:"DEFAULT_SCALE_SERVICE_UP_BY"`
-	AlertmanagerAddress       string `envconfig:"ALERTMANAGER_ADDRESS"`
-	AlertTimeout              int64  `envconfig:"ALERT_TIMEOUT"`
-	RescheduleFilterLabel     string `envconfig:"RESCHEDULE_FILTER_LABEL"`
-	RescheduleTickerInterval  int64  `envconfig:"RESCHEDULE_TICKER_INTERVAL"`
-	RescheduleTimeOut         int64  `envconfig:"RESCHEDULE_TIMEOUT"`
-	RescheduleEnvKey          string `envconfig:"RESCHEDULE_ENV_KEY"`
-	NodeScalerBackend         string `envconfig:"NODE_SCALER_BACKEND"`
-	AlertNodeMin              bool   `envconfig:"ALERT_NODE_MIN"`
-	AlertNodeMax              bool   `envconfig:"ALERT_NODE_MAX"`
-
-	AwsEnvFile                  string `envconfig:"AWS_ENV_FILE"`
-	MinScaleManagerNodeLabel    string `envconfig:"MIN_SCALE_MANAGER_NODE_LABEL"`
-	MaxScaleManagerNodeLabel    string `envconfig:"MAX_SCALE_MANAGER_NODE_LABEL"`
-	ScaleManagerNodeDownByLabel string `envconfig:"SCALE_MANAGER_NODE_DOWN_BY_LABEL"`
-	ScaleManagerNodeUpByLabel   string `envconfig:"SCALE_MANAGER_NODE_UP_BY_LABEL"`
-	MinScaleWorkerNodeLabel     string `envconfig:"MIN_SCALE_WORKER_NODE_LABEL"`
-	MaxScaleWorkerNodeLabel     string `envconfig:"MAX_SCALE_WORKER_NODE_LABEL"`
-	ScaleWorkerNodeDownByLabel  string `envconfig:"SCALE_WORKER_NODE_DOWN_BY_LABEL"`
-	ScaleWorkerNodeUpByLabel    string `envconfig:"SCALE_WORKER_NODE_UP_BY_LABEL"`
-
-	DefaultMinManagerNodes uint64 `envconfig:"DEFAULT_MIN_MANAGER_NODES"`
-	DefaultMaxManagerNodes uint64 `envconfig:"DEFAULT_MAX_MANAGER_NODES"`
-	DefaultMinWorkerNodes  uint64 `envconfig:"DEFAULT_MIN_WORKER_NODES"`
-	DefaultMaxWorkerNodes  uint64 `envconfig:"DEFAULT_MAX_WORKER_NODES"`
-
-	DefaultScaleManagerNodeDownBy uint64 `envconfig:"DEFAULT_SCALE_MANAGER_NODE_DOWN_BY"`
-	DefaultScaleManagerNodeUpBy   uint64 `envconfig:"DEFAULT_SCALE_MANAGER_NODE_UP_BY"`
-	DefaultScaleWorkerNodeDownBy  uint64 `envconfig:"DEFAULT_SCALE_WORKER_NODE_DOWN_BY"`
-	DefaultScaleWorkerNodeUpBy    uint64 `envconfig:"DEFAULT_SCALE_WORKER_NODE_UP_BY"`
-}
+// tracingFlushTimeout is how long to wait for spans to be exported after
+// shutting down
+const tracingFlushTimeout = 5 * time.Second
+
+// configWatchInterval is how often the config file is checked for changes
+const configWatchInterval = 10 * time.Second
 
-// Run starts docker-scaler service
+const (
+	// stateFileName is the file in STATE_DIR the operations are saved to
+	stateFileName = "operations.json"
+	// freezesFileName is the file in STATE_DIR the freezes added through
+	// the api are saved to
+	freezesFileName = "freezes.json"
+	// approvalsFileName is the file in STATE_DIR the changes waiting for
+	// approval are saved to
+	approvalsFileName = "approvals.json"
+	// replicasFileName is the file in STATE_DIR the replicas decided for
+	// the services of the default cluster are saved to. Other clusters use
+	// `replicas-<cluster>.json`
+	replicasFileName = "replicas.json"
+)
+
+// Run starts docker-scaler service, or runs a subcommand against a running
+// docker-scaler when one is given
 func Run() {
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:], os.Stdout, os.Stderr))
+	}
 
-	var spec specification
-	err := envconfig.Process("", &spec)
+	configFile := os.Getenv("CONFIG_FILE")
+	spec, overrides, err := readConfig(configFile)
 	if err != nil {
-		logger.Panic(err)
+		log.Fatal(err)
+	}
+
+	logger, err := logging.New(os.Stdout, spec.LogFormat, spec.LogLevel)
+	if err != nil {
+		log.Panic(err)
+	}
+	if len(configFile) > 0 {
+		logger.Info(fmt.Sprintf("Using config file: %s", configFile))
+	}
+	if len(overrides) > 0 {
+		logger.Info(fmt.Sprintf("Using defaults changed through the api from: %s", spec.DefaultsFile))
 	}
 
-	client, err := service.NewDockerClientFromEnv()
+	shutdownTracing, err := tracing.Setup(
+		context.Background(), spec.TracingExporter, os.Stdout)
 	if err != nil {
-		logger.Panic(err)
+		exit(logger, err)
+	}
+	if len(spec.TracingExporter) > 0 {
+		logger.Info(fmt.Sprintf("Exporting traces to: %s", spec.TracingExporter))
 	}
-	defer client.Close()
 
-	var alerter service.AlertServicer
+	alerter := newAlerter(spec)
 	if len(spec.AlertmanagerAddress) != 0 {
-		url := spec.AlertmanagerAddress
-		alerter = service.NewAlertService(
-			url, time.Duration(spec.AlertTimeout)*time.Second)
-		logger.Printf("Using alertmanager at: %s", url)
+		logger.Info(fmt.Sprintf("Using alertmanager at: %s", spec.AlertmanagerAddress))
 	} else {
-		alerter = service.NewSilentAlertService()
-		logger.Printf("Using a stubbed alertmanager")
+		logger.Info("Using a stubbed alertmanager")
+	}
+
+	events := service.NewEventBus()
+	var clusters []clusterComponents
+	for _, name := range spec.ClusterNames() {
+		cc, err := newClusterComponents(logger, spec, name, events)
+		if err != nil {
+			exit(logger, err)
+		}
+		defer cc.client.Close()
+		clusters = append(clusters, cc)
+	}
+
+	logger.Info("Starting Docker Scaler")
+
+	d := clusters[0]
+	s := server.NewServer(d.scaler, alerter, d.nodeScaler,
+		d.rescheduler, logger,
+		spec.AlertScaleMin, spec.AlertScaleMax,
+		spec.AlertNodeMin, spec.AlertNodeMax)
+	s.SetGRPCPort(spec.GRPCPort)
+	s.SetEventBus(events)
+	for _, cc := range clusters[1:] {
+		s.AddCluster(cc.name, server.Cluster{
+			ServiceScaler: cc.scaler,
+			NodeScaler:    cc.nodeScaler,
+			Rescheduler:   cc.rescheduler,
+		})
 	}
 
-	cloudOptions := cloud.NewCloudOptions{
-		AWSEnvFile: spec.AwsEnvFile,
+	freezes, err := newFreezes(spec)
+	if err != nil {
+		exit(logger, err)
+	}
+	s.SetFreezes(freezes)
+	for _, cc := range clusters {
+		if fs, ok := cc.scaler.(freezesSetter); ok {
+			fs.SetFreezes(freezes)
+		}
+		if fs, ok := cc.rescheduler.(freezesSetter); ok {
+			fs.SetFreezes(freezes)
+		}
+	}
+
+	budget := service.NewNodeBudget()
+	for _, cc := range clusters {
+		if ns, ok := cc.nodeScaler.(*service.NodeScaler); ok {
+			ns.SetBudget(budget)
+		}
+	}
+
+	approvals, err := newApprovals(spec)
+	if err != nil {
+		exit(logger, err)
+	}
+	s.SetApprovals(approvals)
+	for _, cc := range clusters {
+		if as, ok := cc.scaler.(approvalsSetter); ok {
+			as.SetApprovals(approvals)
+		}
+		if ns, ok := cc.nodeScaler.(*service.NodeScaler); ok {
+			ns.SetApprovals(approvals)
+		}
+	}
+
+	for _, cc := range clusters {
+		rs, ok := cc.scaler.(replicasFileSetter)
+		if !ok || len(spec.StateDir) == 0 {
+			continue
+		}
+		err := rs.SetReplicasFile(replicasFile(spec.StateDir, cc.name), func(err error) {
+			logger.Error(err.Error())
+		})
+		if err != nil {
+			exit(logger, err)
+		}
 	}
 
-	cloud, err := cloud.NewCloud(spec.NodeScalerBackend, cloudOptions)
+	r := &reloader{
+		path:      configFile,
+		config:    spec,
+		overrides: overrides,
+		logger:    logger,
+		server:    s,
+		clusters:  clusters,
+		freezes:   freezes,
+		budget:    budget,
+		approvals: approvals,
+	}
+	if err := r.apply(spec); err != nil {
+		exit(logger, err)
+	}
+
+	elector, err := newElector(spec, clusters[0].client)
 	if err != nil {
-		logger.Printf("No cloud provider for node scaling configured")
+		exit(logger, err)
+	}
+	if elector != nil {
+		s.SetElector(elector)
+		logger.Info(fmt.Sprintf("Electing a leader with a %s lock as %s", spec.LeaderElection, elector.ID()))
+	}
+	if len(spec.StateDir) > 0 {
+		store, err := newOperationStore(spec)
+		if err != nil {
+			exit(logger, err)
+		}
+		s.SetOperationStore(store, rescheduleTimeouts(spec))
+		logger.Info(fmt.Sprintf("Keeping operations in: %s", spec.StateDir))
+	}
+	s.SetDefaultsUpdater(r)
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	hupC := make(chan os.Signal, 1)
+	signal.Notify(hupC, syscall.SIGHUP)
+	go config.Watch(watchCtx, configFile, configWatchInterval, hupC, r.reload)
+
+	s.Run(8080, spec.ServerPrefix,
+		time.Duration(spec.ShutdownGracePeriod)*time.Second)
+
+	signal.Stop(hupC)
+	stopWatch()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+	defer cancel()
+	err = shutdownTracing(ctx)
+	if err != nil {
+		logger.Error(fmt.Sprintf("Unable to flush traces: %s", err))
+	}
+}
+
+// newClusterComponents connects to the docker and cloud endpoints of
+// cluster `name` and creates its scalers
+func newClusterComponents(logger *slog.Logger, spec config.Config, name string, events *service.EventBus) (clusterComponents, error) {
+	cc := clusterComponents{name: name}
+	c, err := spec.Cluster(name)
+	if err != nil {
+		return cc, err
+	}
+	if name != config.DefaultCluster {
+		logger = logger.With(logging.ClusterKey, name)
+	}
+
+	cc.client, err = service.NewDockerClient(c.ManagerHosts())
+	if err != nil {
+		return cc, err
+	}
+	logger.Info(fmt.Sprintf("Using docker hosts: %s", strings.Join(cc.client.Hosts(), ", ")))
+
+	if len(c.NodeScalerBackend) == 0 {
+		logger.Info("No cloud provider for node scaling configured")
 	} else {
-		logger.Printf("Using node-scaling backend: %s", spec.NodeScalerBackend)
-	}
-
-	managerResolveOpts := service.ResolveDeltaOptions{
-		MinLabel:           spec.MinScaleManagerNodeLabel,
-		MaxLabel:           spec.MaxScaleManagerNodeLabel,
-		ScaleDownByLabel:   spec.ScaleManagerNodeDownByLabel,
-		ScaleUpByLabel:     spec.ScaleManagerNodeUpByLabel,
-		DefaultMin:         spec.DefaultMinManagerNodes,
-		DefaultMax:         spec.DefaultMaxManagerNodes,
-		DefaultScaleDownBy: spec.DefaultScaleManagerNodeDownBy,
-		DefaultScaleUpBy:   spec.DefaultScaleManagerNodeUpBy,
-	}
-	workerResolveOpts := service.ResolveDeltaOptions{
-		MinLabel:           spec.MinScaleWorkerNodeLabel,
-		MaxLabel:           spec.MaxScaleWorkerNodeLabel,
-		ScaleDownByLabel:   spec.ScaleWorkerNodeDownByLabel,
-		ScaleUpByLabel:     spec.ScaleWorkerNodeUpByLabel,
-		DefaultMin:         spec.DefaultMinWorkerNodes,
-		DefaultMax:         spec.DefaultMaxWorkerNodes,
-		DefaultScaleDownBy: spec.DefaultScaleWorkerNodeDownBy,
-		DefaultScaleUpBy:   spec.DefaultScaleWorkerNodeUpBy,
-	}
-
-	nodeScaler := service.NewNodeScaler(
-		cloud, client, managerResolveOpts, workerResolveOpts)
-
-	rescheduler, err := service.NewReschedulerService(
-		client,
-		spec.RescheduleFilterLabel,
-		spec.RescheduleEnvKey,
-		time.Duration(spec.RescheduleTickerInterval)*time.Second,
-		time.Duration(spec.RescheduleTimeOut)*time.Second)
+		cc.cloud, err = cloud.NewCloud(c.NodeScalerBackend, cloud.NewCloudOptions{
+			AWSEnvFile: c.AwsEnvFile,
+		})
+		if err != nil {
+			cc.client.Close()
+			return cc, err
+		}
+		logger.Info(fmt.Sprintf("Using node-scaling backend: %s", c.NodeScalerBackend))
+	}
 
+	cc.nodeScaler = service.NewNodeScaler(
+		cc.cloud, cc.client, managerResolveOptions(c), workerResolveOptions(c), events)
+	cc.rescheduler, err = service.NewReschedulerService(
+		cc.client,
+		c.RescheduleFilterLabel,
+		c.RescheduleEnvKey,
+		time.Duration(c.RescheduleTickerInterval)*time.Second,
+		time.Duration(c.RescheduleTimeOut)*time.Second)
 	if err != nil {
-		logger.Panic(err)
+		cc.client.Close()
+		return cc, err
 	}
+	cc.scaler = service.NewScalerService(cc.client, serviceResolveOptions(c))
+	return cc, nil
+}
 
-	logger.Print("Starting Docker Scaler")
+// newElector creates the elector of this replica, or nil when leader
+// election is disabled. The docker lock is kept in the default cluster
+func newElector(c config.Config, client service.DockerClient) (*service.Elector, error) {
+	var lock service.Lock
+	switch c.LeaderElection {
+	case "":
+		return nil, nil
+	case "file":
+		lock = service.NewFileLock(c.LeaderLockFile)
+	case "docker":
+		lock = service.NewDockerLock(client, c.LeaderLockService)
+	}
 
-	resolveScalerDetlaOpts := service.ResolveDeltaOptions{
-		MinLabel:           spec.MinScaleLabel,
-		MaxLabel:           spec.MaxScaleLabel,
-		ScaleDownByLabel:   spec.ScaleDownByLabel,
-		ScaleUpByLabel:     spec.ScaleUpByLabel,
-		DefaultMin:         spec.DefaultMinReplicas,
-		DefaultMax:         spec.DefaultMaxReplicas,
-		DefaultScaleDownBy: spec.DefaultScaleServiceDownBy,
-		DefaultScaleUpBy:   spec.DefaultScaleServiceUpBy,
+	id := c.LeaderAdvertiseURL
+	if len(id) == 0 {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("Unable to find the url to advertise to other replicas: %s", err)
+		}
+		id = fmt.Sprintf("http://%s:8080", hostname)
 	}
+	return service.NewElector(lock, id, time.Duration(c.LeaderLeaseDuration)*time.Second), nil
+}
 
-	scalerService := service.NewScalerService(
-		client, resolveScalerDetlaOpts)
-	s := server.NewServer(scalerService, alerter, nodeScaler,
-		rescheduler, logger,
-		spec.AlertScaleMin, spec.AlertScaleMax,
-		spec.AlertNodeMin, spec.AlertNodeMax)
-	s.Run(8080, spec.ServerPrefix)
+// newOperationStore opens the operations saved in STATE_DIR
+func newOperationStore(c config.Config) (*service.OperationStore, error) {
+	if err := os.MkdirAll(c.StateDir, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create STATE_DIR %s: %s", c.StateDir, err)
+	}
+	return service.OpenOperationStore(filepath.Join(c.StateDir, stateFileName), server.OperationHistorySize)
+}
+
+// newFreezes creates the freezes, which are saved to STATE_DIR when it is
+// set
+func newFreezes(c config.Config) (*service.Freezes, error) {
+	if len(c.StateDir) == 0 {
+		return service.NewFreezes(), nil
+	}
+	if err := os.MkdirAll(c.StateDir, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create STATE_DIR %s: %s", c.StateDir, err)
+	}
+	return service.OpenFreezes(filepath.Join(c.StateDir, freezesFileName))
+}
+
+// newApprovals creates the approvals, which are saved to STATE_DIR when it
+// is set
+func newApprovals(c config.Config) (*service.Approvals, error) {
+	if len(c.StateDir) == 0 {
+		return service.NewApprovals(), nil
+	}
+	if err := os.MkdirAll(c.StateDir, 0755); err != nil {
+		return nil, fmt.Errorf("Unable to create STATE_DIR %s: %s", c.StateDir, err)
+	}
+	return service.OpenApprovals(filepath.Join(c.StateDir, approvalsFileName))
+}
+
+// replicasFile returns the file in `stateDir` the decided replicas of
+// `cluster` are saved to
+func replicasFile(stateDir, cluster string) string {
+	if cluster == config.DefaultCluster {
+		return filepath.Join(stateDir, replicasFileName)
+	}
+	return filepath.Join(stateDir, fmt.Sprintf("replicas-%s.json", cluster))
+}
+
+// rescheduleTimeouts returns the RESCHEDULE_TIMEOUT of each cluster
+func rescheduleTimeouts(c config.Config) map[string]time.Duration {
+	timeouts := map[string]time.Duration{}
+	for _, name := range c.ClusterNames() {
+		cc, err := c.Cluster(name)
+		if err != nil {
+			continue
+		}
+		timeouts[name] = time.Duration(cc.RescheduleTimeOut) * time.Second
+	}
+	return timeouts
+}
+
+// exit logs `err` and exits
+func exit(logger *slog.Logger, err error) {
+	logger.Error(err.Error())
+	os.Exit(1)
 }
@@ -0,0 +1,161 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultCluster is the cluster configured by the top-level settings.
+// Requests that do not name a cluster are sent to it
+const DefaultCluster = "default"
+
+// clusterName is the form of cluster names, which are used in urls,
+// alert labels and metrics
+var clusterName = regexp.MustCompile(`^[a-z0-9]([a-z0-9_-]*[a-z0-9])?$`)
+
+// ClusterNames returns the default cluster followed by the named clusters,
+// sorted by name
+func (c Config) ClusterNames() []string {
+	return append([]string{DefaultCluster}, sortedKeys(c.Clusters)...)
+}
+
+// Cluster returns the configuration of cluster `name`: the top-level
+// settings with the settings of the cluster applied
+func (c Config) Cluster(name string) (Config, error) {
+	names := c.ClusterNames()
+	settings, ok := c.Clusters[name]
+	c.Clusters = nil
+	if name == DefaultCluster {
+		return c, nil
+	}
+	if !ok {
+		return c, fmt.Errorf("Unknown cluster: %s, cluster can only be %s",
+			name, strings.Join(names, ", "))
+	}
+	c, err := applySettings(c, settings)
+	return c, errors.Wrapf(err, "Unable to read settings of cluster %s", name)
+}
+
+// WithClusterDefaults returns `c` with the defaults of cluster `name`
+// replaced by `d`. The defaults of a named cluster are kept in its
+// settings, so the other clusters keep theirs
+func (c Config) WithClusterDefaults(name string, d Defaults) (Config, error) {
+	if name == DefaultCluster {
+		return c.WithDefaults(d), nil
+	}
+	cc, err := c.Cluster(name)
+	if err != nil {
+		return c, err
+	}
+	settings := map[string]interface{}{}
+	for key, value := range c.Clusters[name] {
+		settings[key] = value
+	}
+	for _, change := range Diff(cc, cc.WithDefaults(d)) {
+		settings[change.Key] = change.New
+	}
+	clusters := map[string]map[string]interface{}{}
+	for n, s := range c.Clusters {
+		clusters[n] = s
+	}
+	clusters[name] = settings
+	c.Clusters = clusters
+	return c, nil
+}
+
+// validateClusters checks the names and settings of the named clusters.
+// Problems of a cluster are prefixed with clusters.<cluster>, except for
+// those it inherits from the top-level settings in `inherited`
+func (c Config) validateClusters(inherited ValidationError) ValidationError {
+	var errs ValidationError
+	known := map[string]bool{}
+	for _, e := range inherited {
+		known[e] = true
+	}
+
+	for _, name := range sortedKeys(c.Clusters) {
+		prefix := clusterKey(name, "")
+		if name == DefaultCluster || !clusterName.MatchString(name) {
+			errs = append(errs, fmt.Sprintf(
+				"%s: cluster names must be lowercase letters, digits, - or _, and can not be %s",
+				prefix, DefaultCluster))
+			continue
+		}
+		settings := c.Clusters[name]
+		for _, key := range sortedKeys(settings) {
+			if serverSettings[key] {
+				errs = append(errs, fmt.Sprintf(
+					"%s: %s applies to every cluster and can only be set at the top level", prefix, key))
+			}
+		}
+		if _, ok := settings["docker_manager_hosts"]; !ok {
+			errs = append(errs, fmt.Sprintf(
+				"%s: docker_manager_hosts must be set, so the cluster has its own Docker endpoint", prefix))
+		}
+
+		cc, err := c.Cluster(name)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if verr, ok := cc.Validate().(ValidationError); ok {
+			for _, e := range verr {
+				if !known[e] {
+					errs = append(errs, fmt.Sprintf("%s: %s", prefix, e))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// serverSettings are the config file names of the settings that apply to
+// every cluster
+var serverSettings = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("scope") == "server" {
+			keys[settingKey(t.Field(i))] = true
+		}
+	}
+	return keys
+}()
+
+// applySettings returns `c` with `settings`, given by their config file
+// name, applied
+func applySettings(c Config, settings map[string]interface{}) (Config, error) {
+	if len(settings) == 0 {
+		return c, nil
+	}
+	b, err := yaml.Marshal(settings)
+	if err != nil {
+		return c, err
+	}
+	err = decode(b, &c)
+	return c, err
+}
+
+// clusterKey is the name of `key` of cluster `name` in the config file, or
+// of the cluster itself when `key` is empty
+func clusterKey(name, key string) string {
+	if len(key) == 0 {
+		return fmt.Sprintf("clusters.%s", name)
+	}
+	return fmt.Sprintf("clusters.%s.%s", name, key)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
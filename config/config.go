@@ -0,0 +1,424 @@
+package config
+
+// Configuration of docker-scaler, read from a config file and env variables
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Config configures docker-scaler. Every setting can be set in the config
+// file with the lowercase name of its env variable. Settings tagged with
+// `reload:"restart"` are only applied when docker-scaler starts, and
+// settings tagged with `scope:"server"` can not be set for one cluster
+type Config struct {
+	ServerPrefix              string `envconfig:"SERVER_PREFIX" yaml:"server_prefix" reload:"restart" scope:"server"`
+	MinScaleLabel             string `envconfig:"MIN_SCALE_LABEL" yaml:"min_scale_label"`
+	MaxScaleLabel             string `envconfig:"MAX_SCALE_LABEL" yaml:"max_scale_label"`
+	AlertScaleMin             bool   `envconfig:"ALERT_SCALE_MIN" yaml:"alert_scale_min"`
+	AlertScaleMax             bool   `envconfig:"ALERT_SCALE_MAX" yaml:"alert_scale_max"`
+	DefaultMinReplicas        uint64 `envconfig:"DEFAULT_MIN_REPLICAS" yaml:"default_min_replicas"`
+	DefaultMaxReplicas        uint64 `envconfig:"DEFAULT_MAX_REPLICAS" yaml:"default_max_replicas"`
+	ScaleDownByLabel          string `envconfig:"SCALE_DOWN_BY_LABEL" yaml:"scale_down_by_label"`
+	ScaleUpByLabel            string `envconfig:"SCALE_UP_BY_LABEL" yaml:"scale_up_by_label"`
+	DefaultScaleServiceDownBy uint64 `envconfig:"DEFAULT_SCALE_SERVICE_DOWN_BY" yaml:"default_scale_service_down_by"`
+	DefaultScaleServiceUpBy   uint64 `envconfig:"DEFAULT_SCALE_SERVICE_UP_BY" yaml:"default_scale_service_up_by"`
+	ScaleDuringUpdate         string `envconfig:"SCALE_DURING_UPDATE" yaml:"scale_during_update"`
+	ScaleDuringUpdateLabel    string `envconfig:"SCALE_DURING_UPDATE_LABEL" yaml:"scale_during_update_label"`
+	ScaleDuringUpdateTimeout  int64  `envconfig:"SCALE_DURING_UPDATE_TIMEOUT" yaml:"scale_during_update_timeout"`
+	ReplicaQuota              uint64 `envconfig:"REPLICA_QUOTA" yaml:"replica_quota"`
+	ReplicaQuotaScope         string `envconfig:"REPLICA_QUOTA_SCOPE" yaml:"replica_quota_scope"`
+	ScalePriorityLabel        string `envconfig:"SCALE_PRIORITY_LABEL" yaml:"scale_priority_label"`
+	ScalePreserveLabel        string `envconfig:"SCALE_PRESERVE_LABEL" yaml:"scale_preserve_label"`
+	AlertmanagerAddress       string `envconfig:"ALERTMANAGER_ADDRESS" yaml:"alertmanager_address" scope:"server"`
+	AlertTimeout              int64  `envconfig:"ALERT_TIMEOUT" yaml:"alert_timeout" scope:"server"`
+	RescheduleFilterLabel     string `envconfig:"RESCHEDULE_FILTER_LABEL" yaml:"reschedule_filter_label"`
+	RescheduleTickerInterval  int64  `envconfig:"RESCHEDULE_TICKER_INTERVAL" yaml:"reschedule_ticker_interval"`
+	RescheduleTimeOut         int64  `envconfig:"RESCHEDULE_TIMEOUT" yaml:"reschedule_timeout"`
+	RescheduleEnvKey          string `envconfig:"RESCHEDULE_ENV_KEY" yaml:"reschedule_env_key"`
+	NodeScalerBackend         string `envconfig:"NODE_SCALER_BACKEND" yaml:"node_scaler_backend" reload:"restart"`
+	AlertNodeMin              bool   `envconfig:"ALERT_NODE_MIN" yaml:"alert_node_min"`
+	AlertNodeMax              bool   `envconfig:"ALERT_NODE_MAX" yaml:"alert_node_max"`
+
+	AwsEnvFile                  string `envconfig:"AWS_ENV_FILE" yaml:"aws_env_file" reload:"restart"`
+	MinScaleManagerNodeLabel    string `envconfig:"MIN_SCALE_MANAGER_NODE_LABEL" yaml:"min_scale_manager_node_label"`
+	MaxScaleManagerNodeLabel    string `envconfig:"MAX_SCALE_MANAGER_NODE_LABEL" yaml:"max_scale_manager_node_label"`
+	ScaleManagerNodeDownByLabel string `envconfig:"SCALE_MANAGER_NODE_DOWN_BY_LABEL" yaml:"scale_manager_node_down_by_label"`
+	ScaleManagerNodeUpByLabel   string `envconfig:"SCALE_MANAGER_NODE_UP_BY_LABEL" yaml:"scale_manager_node_up_by_label"`
+	MinScaleWorkerNodeLabel     string `envconfig:"MIN_SCALE_WORKER_NODE_LABEL" yaml:"min_scale_worker_node_label"`
+	MaxScaleWorkerNodeLabel     string `envconfig:"MAX_SCALE_WORKER_NODE_LABEL" yaml:"max_scale_worker_node_label"`
+	ScaleWorkerNodeDownByLabel  string `envconfig:"SCALE_WORKER_NODE_DOWN_BY_LABEL" yaml:"scale_worker_node_down_by_label"`
+	ScaleWorkerNodeUpByLabel    string `envconfig:"SCALE_WORKER_NODE_UP_BY_LABEL" yaml:"scale_worker_node_up_by_label"`
+
+	DefaultMinManagerNodes uint64 `envconfig:"DEFAULT_MIN_MANAGER_NODES" yaml:"default_min_manager_nodes"`
+	DefaultMaxManagerNodes uint64 `envconfig:"DEFAULT_MAX_MANAGER_NODES" yaml:"default_max_manager_nodes"`
+	DefaultMinWorkerNodes  uint64 `envconfig:"DEFAULT_MIN_WORKER_NODES" yaml:"default_min_worker_nodes"`
+	DefaultMaxWorkerNodes  uint64 `envconfig:"DEFAULT_MAX_WORKER_NODES" yaml:"default_max_worker_nodes"`
+
+	DefaultScaleManagerNodeDownBy uint64 `envconfig:"DEFAULT_SCALE_MANAGER_NODE_DOWN_BY" yaml:"default_scale_manager_node_down_by"`
+	DefaultScaleManagerNodeUpBy   uint64 `envconfig:"DEFAULT_SCALE_MANAGER_NODE_UP_BY" yaml:"default_scale_manager_node_up_by"`
+	DefaultScaleWorkerNodeDownBy  uint64 `envconfig:"DEFAULT_SCALE_WORKER_NODE_DOWN_BY" yaml:"default_scale_worker_node_down_by"`
+	DefaultScaleWorkerNodeUpBy    uint64 `envconfig:"DEFAULT_SCALE_WORKER_NODE_UP_BY" yaml:"default_scale_worker_node_up_by"`
+
+	ManagerNodeHourlyPrice  float64 `envconfig:"MANAGER_NODE_HOURLY_PRICE" yaml:"manager_node_hourly_price"`
+	WorkerNodeHourlyPrice   float64 `envconfig:"WORKER_NODE_HOURLY_PRICE" yaml:"worker_node_hourly_price"`
+	NodeBudgetMaxNodes      uint64  `envconfig:"NODE_BUDGET_MAX_NODES" yaml:"node_budget_max_nodes" scope:"server"`
+	NodeBudgetMaxHourlyCost float64 `envconfig:"NODE_BUDGET_MAX_HOURLY_COST" yaml:"node_budget_max_hourly_cost" scope:"server"`
+	MinAvailableLabel       string  `envconfig:"MIN_AVAILABLE_LABEL" yaml:"min_available_label"`
+
+	ApprovalMaxNodeChange    uint64  `envconfig:"APPROVAL_MAX_NODE_CHANGE" yaml:"approval_max_node_change"`
+	ApprovalMaxReplicaFactor float64 `envconfig:"APPROVAL_MAX_REPLICA_FACTOR" yaml:"approval_max_replica_factor"`
+	ApprovalTimeout          int64   `envconfig:"APPROVAL_TIMEOUT" yaml:"approval_timeout"`
+
+	ShutdownGracePeriod int64  `envconfig:"SHUTDOWN_GRACE_PERIOD" yaml:"shutdown_grace_period" reload:"restart" scope:"server"`
+	GRPCPort            uint16 `envconfig:"GRPC_PORT" yaml:"grpc_port" reload:"restart" scope:"server"`
+
+	LogFormat string `envconfig:"LOG_FORMAT" yaml:"log_format" reload:"restart" scope:"server"`
+	LogLevel  string `envconfig:"LOG_LEVEL" yaml:"log_level" reload:"restart" scope:"server"`
+
+	TracingExporter string `envconfig:"TRACING_EXPORTER" yaml:"tracing_exporter" reload:"restart" scope:"server"`
+
+	DefaultsFile string `envconfig:"DEFAULTS_FILE" yaml:"defaults_file" reload:"restart" scope:"server"`
+	StateDir     string `envconfig:"STATE_DIR" yaml:"state_dir" reload:"restart" scope:"server"`
+
+	DockerManagerHosts string `envconfig:"DOCKER_MANAGER_HOSTS" yaml:"docker_manager_hosts" reload:"restart"`
+
+	LeaderElection      string `envconfig:"LEADER_ELECTION" yaml:"leader_election" reload:"restart" scope:"server"`
+	LeaderLockFile      string `envconfig:"LEADER_LOCK_FILE" yaml:"leader_lock_file" reload:"restart" scope:"server"`
+	LeaderLockService   string `envconfig:"LEADER_LOCK_SERVICE" yaml:"leader_lock_service" reload:"restart" scope:"server"`
+	LeaderLeaseDuration int64  `envconfig:"LEADER_LEASE_DURATION" yaml:"leader_lease_duration" reload:"restart" scope:"server"`
+	LeaderAdvertiseURL  string `envconfig:"LEADER_ADVERTISE_URL" yaml:"leader_advertise_url" reload:"restart" scope:"server"`
+
+	// Clusters are the settings of each named cluster, by the config file
+	// name of the setting. A cluster uses the top-level settings it does
+	// not set
+	Clusters map[string]map[string]interface{} `ignored:"true" yaml:"clusters" scope:"server"`
+
+	// Freezes are the windows in which scaling is rejected
+	Freezes []Freeze `ignored:"true" yaml:"freezes" scope:"server"`
+}
+
+// Default returns the configuration used when a setting is neither in the
+// config file nor in the env variables
+func Default() Config {
+	return Config{
+		ServerPrefix:              "/",
+		MinScaleLabel:             "com.df.scaleMin",
+		MaxScaleLabel:             "com.df.scaleMax",
+		AlertScaleMin:             false,
+		AlertScaleMax:             true,
+		DefaultMinReplicas:        1,
+		DefaultMaxReplicas:        5,
+		ScaleDownByLabel:          "com.df.scaleDownBy",
+		ScaleUpByLabel:            "com.df.scaleUpBy",
+		DefaultScaleServiceDownBy: 1,
+		DefaultScaleServiceUpBy:   1,
+		ScaleDuringUpdate:         "proceed",
+		ScaleDuringUpdateLabel:    "com.df.scaleDuringUpdate",
+		ScaleDuringUpdateTimeout:  600,
+		ReplicaQuotaScope:         "cluster",
+		ScalePriorityLabel:        "com.df.scalePriority",
+		ScalePreserveLabel:        "com.df.scalePreserve",
+		AlertTimeout:              10,
+		RescheduleFilterLabel:     "com.df.reschedule=true",
+		RescheduleTickerInterval:  60,
+		RescheduleTimeOut:         1000,
+		RescheduleEnvKey:          "RESCHEDULE_DATE",
+		AlertNodeMin:              false,
+		AlertNodeMax:              true,
+
+		AwsEnvFile:                  "/run/secrets/aws",
+		MinScaleManagerNodeLabel:    "com.df.scaleManagerNodeMin",
+		MaxScaleManagerNodeLabel:    "com.df.scaleManagerNodeMax",
+		ScaleManagerNodeDownByLabel: "com.df.scaleManagerNodeDownBy",
+		ScaleManagerNodeUpByLabel:   "com.df.scaleManagerNodeUpBy",
+		MinScaleWorkerNodeLabel:     "com.df.scaleWorkerNodeMin",
+		MaxScaleWorkerNodeLabel:     "com.df.scaleWorkerNodeMax",
+		ScaleWorkerNodeDownByLabel:  "com.df.scaleWorkerNodeDownBy",
+		ScaleWorkerNodeUpByLabel:    "com.df.scaleWorkerNodeUpBy",
+
+		DefaultMinManagerNodes: 3,
+		DefaultMaxManagerNodes: 7,
+		DefaultMinWorkerNodes:  0,
+		DefaultMaxWorkerNodes:  5,
+
+		DefaultScaleManagerNodeDownBy: 1,
+		DefaultScaleManagerNodeUpBy:   1,
+		DefaultScaleWorkerNodeDownBy:  1,
+		DefaultScaleWorkerNodeUpBy:    1,
+
+		MinAvailableLabel: "com.df.minAvailable",
+
+		ApprovalTimeout: 3600,
+
+		ShutdownGracePeriod: 8,
+
+		LogFormat: "logfmt",
+		LogLevel:  "info",
+
+		LeaderLeaseDuration: 15,
+	}
+}
+
+// Load reads the configuration. Settings in the YAML or JSON file at
+// `path` override the defaults, and env variables override the file. No
+// file is read when `path` is empty
+func Load(path string) (Config, error) {
+	c := Default()
+	if len(path) > 0 {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return c, errors.Wrap(err, "Unable to read config file")
+		}
+		err = decode(b, &c)
+		if err != nil {
+			return c, errors.Wrapf(err, "Unable to parse config file %s", path)
+		}
+	}
+
+	err := envconfig.Process("", &c)
+	if err != nil {
+		return c, errors.Wrap(err, "Unable to read env variables")
+	}
+	return c, nil
+}
+
+// decode decodes the YAML or JSON document in `b` into `c`, rejecting
+// unknown settings
+func decode(b []byte, c *Config) error {
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	d.KnownFields(true)
+	err := d.Decode(c)
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// Change is a setting that differs between two configurations
+type Change struct {
+	Key string
+	Old interface{}
+	New interface{}
+
+	// Restart is true when the setting is only applied when docker-scaler
+	// starts
+	Restart bool
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %s -> %s", c.Key, formatValue(c.Old), formatValue(c.New))
+}
+
+func formatValue(v interface{}) string {
+	if v == nil {
+		return "none"
+	}
+	if s, ok := v.(string); ok {
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// ManagerHosts returns the docker hosts in DOCKER_MANAGER_HOSTS
+func (c Config) ManagerHosts() []string {
+	var hosts []string
+	for _, h := range strings.Split(c.DockerManagerHosts, ",") {
+		if h = strings.TrimSpace(h); len(h) > 0 {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// Setting is one resolved setting of a configuration
+type Setting struct {
+	Key   string
+	Value interface{}
+}
+
+func (s Setting) String() string {
+	return formatValue(s.Value)
+}
+
+// Settings returns every setting of `c`, in the order they are defined in
+// Config, followed by the settings of each cluster as
+// clusters.<cluster>.<setting> and the freezes as freezes.<name>
+func (c Config) Settings() []Setting {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	settings := make([]Setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if isStructured(t.Field(i)) {
+			continue
+		}
+		settings = append(settings, Setting{
+			Key:   settingKey(t.Field(i)),
+			Value: v.Field(i).Interface(),
+		})
+	}
+	for _, name := range c.ClusterNames()[1:] {
+		cluster := c.Clusters[name]
+		for _, key := range sortedKeys(cluster) {
+			settings = append(settings, Setting{
+				Key:   clusterKey(name, key),
+				Value: cluster[key],
+			})
+		}
+	}
+	for _, f := range c.Freezes {
+		settings = append(settings, Setting{Key: freezeKey(f.Name), Value: f.String()})
+	}
+	return settings
+}
+
+// settingKey is the name of a setting in the config file
+func settingKey(f reflect.StructField) string {
+	if key := f.Tag.Get("envconfig"); len(key) > 0 {
+		return strings.ToLower(key)
+	}
+	return f.Tag.Get("yaml")
+}
+
+// isStructured returns true for the clusters and freezes, which are listed
+// and compared one by one
+func isStructured(f reflect.StructField) bool {
+	return f.Name == "Clusters" || f.Name == "Freezes"
+}
+
+// Diff returns the settings that changed from `old` to `new`, in the order
+// they are defined in Config, followed by the clusters and freezes that
+// changed
+func Diff(old, new Config) []Change {
+	var changes []Change
+	ov, nv := reflect.ValueOf(old), reflect.ValueOf(new)
+	t := ov.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if isStructured(f) {
+			continue
+		}
+		o, n := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if o == n {
+			continue
+		}
+		changes = append(changes, Change{
+			Key:     settingKey(f),
+			Old:     o,
+			New:     n,
+			Restart: isRestartSetting(f),
+		})
+	}
+	changes = append(changes, diffClusters(old.Clusters, new.Clusters)...)
+	return append(changes, diffFreezes(old.Freezes, new.Freezes)...)
+}
+
+// diffClusters returns the settings of clusters that changed. Adding or
+// removing a cluster is only applied when docker-scaler starts
+func diffClusters(old, new map[string]map[string]interface{}) []Change {
+	var changes []Change
+	names := sortedKeys(old)
+	for _, name := range sortedKeys(new) {
+		if _, ok := old[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		o, inOld := old[name]
+		n, inNew := new[name]
+		if !inOld || !inNew {
+			change := Change{Key: clusterKey(name, ""), Restart: true}
+			if inOld {
+				change.Old = o
+			} else {
+				change.New = n
+			}
+			changes = append(changes, change)
+			continue
+		}
+		keys := sortedKeys(o)
+		for _, key := range sortedKeys(n) {
+			if _, ok := o[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if reflect.DeepEqual(o[key], n[key]) {
+				continue
+			}
+			changes = append(changes, Change{
+				Key:     clusterKey(name, key),
+				Old:     o[key],
+				New:     n[key],
+				Restart: restartSettings[key],
+			})
+		}
+	}
+	return changes
+}
+
+// KeepRestartSettings returns `c` with the settings that are only applied
+// when docker-scaler starts taken from `running`, including the clusters
+// that were added or removed
+func (c Config) KeepRestartSettings(running Config) Config {
+	cv, rv := reflect.ValueOf(&c).Elem(), reflect.ValueOf(running)
+	t := cv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if isRestartSetting(t.Field(i)) {
+			cv.Field(i).Set(rv.Field(i))
+		}
+	}
+
+	var clusters map[string]map[string]interface{}
+	for name, settings := range running.Clusters {
+		if clusters == nil {
+			clusters = map[string]map[string]interface{}{}
+		}
+		kept := map[string]interface{}{}
+		for key, value := range c.Clusters[name] {
+			if !restartSettings[key] {
+				kept[key] = value
+			}
+		}
+		for key, value := range settings {
+			if restartSettings[key] {
+				kept[key] = value
+			}
+		}
+		if _, ok := c.Clusters[name]; !ok {
+			kept = settings
+		}
+		clusters[name] = kept
+	}
+	c.Clusters = clusters
+	return c
+}
+
+func isRestartSetting(f reflect.StructField) bool {
+	return f.Tag.Get("reload") == "restart"
+}
+
+// restartSettings are the config file names of the settings that are
+// only applied when docker-scaler starts
+var restartSettings = func() map[string]bool {
+	keys := map[string]bool{}
+	t := reflect.TypeOf(Config{})
+	for i := 0; i < t.NumField(); i++ {
+		if isRestartSetting(t.Field(i)) {
+			keys[settingKey(t.Field(i))] = true
+		}
+	}
+	return keys
+}()
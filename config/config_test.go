@@ -0,0 +1,534 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ConfigTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestConfigUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigTestSuite))
+}
+
+func (s *ConfigTestSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+func (s *ConfigTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(s.dir, name)
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func (s *ConfigTestSuite) Test_Load_Defaults() {
+	c, err := Load("")
+	s.Require().NoError(err)
+	s.Equal(Default(), c)
+	s.Equal("com.df.scaleMin", c.MinScaleLabel)
+	s.Equal(uint64(5), c.DefaultMaxReplicas)
+}
+
+func (s *ConfigTestSuite) Test_Load_YAML() {
+	path := s.writeFile("config.yml", `
+default_max_replicas: 10
+alert_scale_max: false
+reschedule_filter_label: com.df.reschedule=yes
+`)
+	c, err := Load(path)
+	s.Require().NoError(err)
+	s.Equal(uint64(10), c.DefaultMaxReplicas)
+	s.False(c.AlertScaleMax)
+	s.Equal("com.df.reschedule=yes", c.RescheduleFilterLabel)
+	s.Equal(uint64(1), c.DefaultMinReplicas)
+}
+
+func (s *ConfigTestSuite) Test_Load_JSON() {
+	path := s.writeFile("config.json", `{"default_max_replicas": 10, "log_format": "json"}`)
+	c, err := Load(path)
+	s.Require().NoError(err)
+	s.Equal(uint64(10), c.DefaultMaxReplicas)
+	s.Equal("json", c.LogFormat)
+}
+
+func (s *ConfigTestSuite) Test_Load_EmptyFile() {
+	c, err := Load(s.writeFile("config.yml", ""))
+	s.Require().NoError(err)
+	s.Equal(Default(), c)
+}
+
+func (s *ConfigTestSuite) Test_Load_EnvOverridesFile() {
+	s.T().Setenv("DEFAULT_MAX_REPLICAS", "20")
+	s.T().Setenv("ALERTMANAGER_ADDRESS", "")
+	path := s.writeFile("config.yml", `
+default_max_replicas: 10
+default_min_replicas: 2
+alertmanager_address: http://alertmanager:9093
+`)
+	c, err := Load(path)
+	s.Require().NoError(err)
+	s.Equal(uint64(20), c.DefaultMaxReplicas)
+	s.Equal(uint64(2), c.DefaultMinReplicas)
+	s.Empty(c.AlertmanagerAddress)
+}
+
+func (s *ConfigTestSuite) Test_Load_UnknownSetting() {
+	path := s.writeFile("config.yml", "default_max_replica: 10\n")
+	_, err := Load(path)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to parse config file "+path)
+	s.Contains(err.Error(), "field default_max_replica not found")
+}
+
+func (s *ConfigTestSuite) Test_Load_MissingFile() {
+	_, err := Load(filepath.Join(s.dir, "missing.yml"))
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to read config file")
+}
+
+func (s *ConfigTestSuite) Test_Diff() {
+	old := Default()
+	new := Default()
+	new.DefaultMaxReplicas = 10
+	new.MinScaleLabel = "scaleMin"
+	new.GRPCPort = 9090
+
+	changes := Diff(old, new)
+	s.Require().Len(changes, 3)
+	s.Equal(`min_scale_label: "com.df.scaleMin" -> "scaleMin"`, changes[0].String())
+	s.False(changes[0].Restart)
+	s.Equal("default_max_replicas: 5 -> 10", changes[1].String())
+	s.Equal("grpc_port", changes[2].Key)
+	s.True(changes[2].Restart)
+
+	s.Empty(Diff(old, Default()))
+}
+
+func (s *ConfigTestSuite) Test_KeepRestartSettings() {
+	running := Default()
+	c := Default()
+	c.GRPCPort = 9090
+	c.DefaultMaxReplicas = 10
+
+	c = c.KeepRestartSettings(running)
+	s.Equal(uint16(0), c.GRPCPort)
+	s.Equal(uint64(10), c.DefaultMaxReplicas)
+}
+
+func (s *ConfigTestSuite) Test_Settings() {
+	c := Default()
+	c.DefaultMaxReplicas = 10
+
+	settings := c.Settings()
+	// Clusters and freezes are listed one by one
+	s.Len(settings, reflect.TypeOf(c).NumField()-2)
+	s.Equal("server_prefix", settings[0].Key)
+	s.Equal(`"/"`, settings[0].String())
+	s.Contains(settings, Setting{Key: "default_max_replicas", Value: uint64(10)})
+}
+
+func (s *ConfigTestSuite) Test_Validate_Default() {
+	s.NoError(Default().Validate())
+}
+
+func (s *ConfigTestSuite) Test_Validate_CollectsErrors() {
+	c := Default()
+	c.DefaultMinReplicas = 6
+	c.DefaultMinManagerNodes = 2
+	c.RescheduleFilterLabel = "com.df.reschedule"
+	c.NodeScalerBackend = "gcp"
+	c.DefaultScaleServiceUpBy = 0
+
+	err := c.Validate()
+	s.Require().Error(err)
+	verr, ok := err.(ValidationError)
+	s.Require().True(ok)
+	s.Equal(ValidationError{
+		"DEFAULT_MIN_REPLICAS (6) is greater than DEFAULT_MAX_REPLICAS (5)",
+		"DEFAULT_SCALE_SERVICE_UP_BY must be at least 1",
+		"DEFAULT_MIN_MANAGER_NODES (2) must be odd, so the managers keep a quorum",
+		`RESCHEDULE_FILTER_LABEL ("com.df.reschedule") does not have form key=value`,
+		`NODE_SCALER_BACKEND ("gcp") can only be aws or empty`,
+	}, verr)
+	s.Contains(err.Error(), "Invalid configuration:\n  - DEFAULT_MIN_REPLICAS")
+}
+
+func (s *ConfigTestSuite) Test_Validate_NodeBudget() {
+	c := Default()
+	c.WorkerNodeHourlyPrice = -0.1
+	c.NodeBudgetMaxHourlyCost = -1
+	s.Equal(ValidationError{
+		"WORKER_NODE_HOURLY_PRICE must not be negative",
+		"NODE_BUDGET_MAX_HOURLY_COST must not be negative",
+	}, c.Validate())
+
+	c = Default()
+	c.NodeBudgetMaxNodes = 20
+	c.Clusters = map[string]map[string]interface{}{
+		"eu-west": {
+			"docker_manager_hosts":      "tcp://eu-west-manager-1:2376",
+			"worker_node_hourly_price":  0.2,
+			"node_budget_max_nodes":     10,
+			"manager_node_hourly_price": 0.4,
+		},
+	}
+	s.Equal(ValidationError{
+		"clusters.eu-west: node_budget_max_nodes applies to every cluster and can only be set at the top level",
+	}, c.Validate())
+}
+
+func (s *ConfigTestSuite) Test_Validate_Approvals() {
+	c := Default()
+	c.ApprovalMaxReplicaFactor = 0.5
+	c.ApprovalTimeout = 0
+	s.Equal(ValidationError{
+		"APPROVAL_MAX_REPLICA_FACTOR (0.5) must be 0 or greater than 1",
+		"APPROVAL_TIMEOUT must be at least 1",
+	}, c.Validate())
+
+	c = Default()
+	c.ApprovalMaxReplicaFactor = 4
+	c.Clusters = map[string]map[string]interface{}{
+		"eu-west": {
+			"docker_manager_hosts":     "tcp://eu-west-manager-1:2376",
+			"approval_max_node_change": 2,
+			"approval_timeout":         600,
+		},
+	}
+	s.Nil(c.Validate())
+}
+
+func (s *ConfigTestSuite) Test_Validate_ScaleDuringUpdate() {
+	c := Default()
+	c.ScaleDuringUpdate = "wait"
+	c.ScaleDuringUpdateTimeout = 0
+	s.Equal(ValidationError{
+		"SCALE_DURING_UPDATE_TIMEOUT must be at least 1",
+		`SCALE_DURING_UPDATE ("wait") can only be proceed, reject or queue`,
+	}, c.Validate())
+}
+
+func (s *ConfigTestSuite) Test_Validate_ReplicaQuota() {
+	c := Default()
+	c.ReplicaQuota = 20
+	c.ReplicaQuotaScope = "service"
+	s.Equal(ValidationError{
+		`REPLICA_QUOTA_SCOPE ("service") can only be cluster or stack`,
+	}, c.Validate())
+}
+
+func (s *ConfigTestSuite) Test_Validate_LeaderElection() {
+	c := Default()
+	c.LeaderElection = "file"
+	c.LeaderAdvertiseURL = "scaler-1:8080"
+	s.Equal(ValidationError{
+		"LEADER_LOCK_FILE must be set when LEADER_ELECTION is file",
+		`LEADER_ADVERTISE_URL ("scaler-1:8080") must have form scheme://host:port, like http://scaler-1:8080`,
+	}, c.Validate())
+
+	c = Default()
+	c.LeaderElection = "docker"
+	c.LeaderLeaseDuration = 0
+	s.Equal(ValidationError{
+		"LEADER_LOCK_SERVICE must be set when LEADER_ELECTION is docker",
+		"LEADER_LEASE_DURATION must be at least 1",
+	}, c.Validate())
+
+	c.LeaderElection = "etcd"
+	c.LeaderLeaseDuration = 15
+	s.Equal(ValidationError{`LEADER_ELECTION ("etcd") can only be docker, file or empty`}, c.Validate())
+}
+
+func (s *ConfigTestSuite) Test_WithDefaults() {
+	c := Default()
+	d := c.Defaults()
+	s.Equal(c, c.WithDefaults(d))
+
+	d.Worker.Max = 10
+	d.Alerts.NodeMin = true
+	d.Reschedule.TimeOut = 600
+	c = c.WithDefaults(d)
+	s.Equal(uint64(10), c.DefaultMaxWorkerNodes)
+	s.True(c.AlertNodeMin)
+	s.Equal(int64(600), c.RescheduleTimeOut)
+}
+
+func (s *ConfigTestSuite) Test_Overrides() {
+	path := filepath.Join(s.dir, "defaults.yml")
+	o, err := ReadOverrides(path)
+	s.Require().NoError(err)
+	s.Empty(o)
+
+	o.Set(DefaultCluster, []Change{{Key: "default_max_replicas", Old: uint64(5), New: uint64(20)}})
+	s.Require().NoError(o.Save(path))
+
+	o, err = ReadOverrides(path)
+	s.Require().NoError(err)
+	c, err := o.Apply(Default())
+	s.Require().NoError(err)
+	s.Equal(uint64(20), c.DefaultMaxReplicas)
+	s.Equal(uint64(1), c.DefaultMinReplicas)
+}
+
+func (s *ConfigTestSuite) Test_Overrides_UnknownSetting() {
+	_, err := Overrides{"default_max_replica": 20}.Apply(Default())
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to apply defaults")
+}
+
+func (s *ConfigTestSuite) Test_ManagerHosts() {
+	c := Default()
+	s.Empty(c.ManagerHosts())
+
+	c.DockerManagerHosts = "tcp://manager-1:2376, tcp://manager-2:2376,"
+	s.Equal([]string{"tcp://manager-1:2376", "tcp://manager-2:2376"}, c.ManagerHosts())
+	s.NoError(c.Validate())
+
+	c.DockerManagerHosts = "tcp://manager-1:2376,manager-2"
+	s.EqualError(c.Validate(), "Invalid configuration:\n  - "+
+		`DOCKER_MANAGER_HOSTS has "manager-2", hosts must have form proto://address, like tcp://manager-1:2376`)
+}
+
+func (s *ConfigTestSuite) Test_Clusters() {
+	path := s.writeFile("config.yml", `
+default_max_replicas: 10
+clusters:
+  us-east:
+    docker_manager_hosts: tcp://us-east-manager:2376
+  eu-west:
+    docker_manager_hosts: tcp://eu-west-manager:2376
+    default_max_replicas: 20
+    node_scaler_backend: aws
+`)
+	c, err := Load(path)
+	s.Require().NoError(err)
+	s.NoError(c.Validate())
+	s.Equal([]string{"default", "eu-west", "us-east"}, c.ClusterNames())
+
+	eu, err := c.Cluster("eu-west")
+	s.Require().NoError(err)
+	s.Equal("tcp://eu-west-manager:2376", eu.DockerManagerHosts)
+	s.Equal(uint64(20), eu.DefaultMaxReplicas)
+	s.Equal("aws", eu.NodeScalerBackend)
+	s.Equal(uint64(1), eu.DefaultMinReplicas)
+	s.Nil(eu.Clusters)
+
+	us, err := c.Cluster("us-east")
+	s.Require().NoError(err)
+	s.Equal(uint64(10), us.DefaultMaxReplicas)
+
+	def, err := c.Cluster(DefaultCluster)
+	s.Require().NoError(err)
+	s.Empty(def.DockerManagerHosts)
+	s.Nil(def.Clusters)
+
+	_, err = c.Cluster("ap-south")
+	s.EqualError(err, "Unknown cluster: ap-south, cluster can only be default, eu-west, us-east")
+
+	settings := c.Settings()
+	s.Contains(settings, Setting{Key: "clusters.eu-west.default_max_replicas", Value: 20})
+	s.Equal("clusters.us-east.docker_manager_hosts", settings[len(settings)-1].Key)
+}
+
+func (s *ConfigTestSuite) Test_Validate_Clusters() {
+	c := Default()
+	c.DefaultMinReplicas = 6
+	c.Clusters = map[string]map[string]interface{}{
+		"default": {"docker_manager_hosts": "tcp://manager:2376"},
+		"eu-west": {
+			"docker_manager_hosts": "tcp://eu-west-manager:2376",
+			"default_max_replicas": 2,
+			"log_level":            "debug",
+		},
+		"us-east": {"default_max_replica": 2},
+	}
+
+	err := c.Validate()
+	s.Require().Error(err)
+	s.Equal(ValidationError{
+		"DEFAULT_MIN_REPLICAS (6) is greater than DEFAULT_MAX_REPLICAS (5)",
+		"clusters.default: cluster names must be lowercase letters, digits, - or _, and can not be default",
+		"clusters.eu-west: log_level applies to every cluster and can only be set at the top level",
+		"clusters.eu-west: DEFAULT_MIN_REPLICAS (6) is greater than DEFAULT_MAX_REPLICAS (2)",
+		"clusters.us-east: docker_manager_hosts must be set, so the cluster has its own Docker endpoint",
+	}, err.(ValidationError)[:5])
+	s.Contains(err.(ValidationError)[5], "Unable to read settings of cluster us-east")
+}
+
+func (s *ConfigTestSuite) Test_Diff_Clusters() {
+	old := Default()
+	old.Clusters = map[string]map[string]interface{}{
+		"eu-west": {"docker_manager_hosts": "tcp://eu-west-manager:2376", "default_max_replicas": 10},
+	}
+	new := Default()
+	new.Clusters = map[string]map[string]interface{}{
+		"eu-west": {"docker_manager_hosts": "tcp://eu-west-2:2376", "default_max_replicas": 20},
+		"us-east": {"docker_manager_hosts": "tcp://us-east-manager:2376"},
+	}
+
+	changes := Diff(old, new)
+	s.Require().Len(changes, 3)
+	s.Equal("clusters.eu-west.default_max_replicas: 10 -> 20", changes[0].String())
+	s.False(changes[0].Restart)
+	s.Equal(`clusters.eu-west.docker_manager_hosts: "tcp://eu-west-manager:2376" -> "tcp://eu-west-2:2376"`, changes[1].String())
+	s.True(changes[1].Restart)
+	s.Equal("clusters.us-east", changes[2].Key)
+	s.Nil(changes[2].Old)
+	s.True(changes[2].Restart)
+
+	c := new.KeepRestartSettings(old)
+	s.Equal(old.Clusters["eu-west"]["docker_manager_hosts"], c.Clusters["eu-west"]["docker_manager_hosts"])
+	s.Equal(20, c.Clusters["eu-west"]["default_max_replicas"])
+	s.NotContains(c.Clusters, "us-east")
+}
+
+func (s *ConfigTestSuite) Test_Overrides_Cluster() {
+	c := Default()
+	c.Clusters = map[string]map[string]interface{}{
+		"eu-west": {"docker_manager_hosts": "tcp://eu-west-manager:2376", "default_max_replicas": 10},
+	}
+	eu, err := c.Cluster("eu-west")
+	s.Require().NoError(err)
+	d := eu.Defaults()
+	d.Service.Max = 20
+	d.Alerts.ScaleMin = true
+
+	changed, err := c.WithClusterDefaults("eu-west", d)
+	s.Require().NoError(err)
+	s.Equal(uint64(20), changed.Clusters["eu-west"]["default_max_replicas"])
+	s.Equal(true, changed.Clusters["eu-west"]["alert_scale_min"])
+	s.Equal(10, c.Clusters["eu-west"]["default_max_replicas"])
+	s.Equal(uint64(5), changed.DefaultMaxReplicas)
+
+	path := filepath.Join(s.dir, "defaults.yml")
+	o := Overrides{}
+	o.Set("eu-west", Diff(eu, eu.WithDefaults(d)))
+	o.Set(DefaultCluster, []Change{{Key: "default_max_replicas", New: uint64(8)}})
+	s.Require().NoError(o.Save(path))
+
+	o, err = ReadOverrides(path)
+	s.Require().NoError(err)
+	c.Clusters["us-east"] = map[string]interface{}{"docker_manager_hosts": "tcp://us-east-manager:2376"}
+	applied, err := o.Apply(c)
+	s.Require().NoError(err)
+	s.Equal(uint64(8), applied.DefaultMaxReplicas)
+	eu, err = applied.Cluster("eu-west")
+	s.Require().NoError(err)
+	s.Equal(uint64(20), eu.DefaultMaxReplicas)
+	s.True(eu.AlertScaleMin)
+	us, err := applied.Cluster("us-east")
+	s.Require().NoError(err)
+	s.Equal(uint64(8), us.DefaultMaxReplicas)
+	s.False(us.AlertScaleMin)
+}
+
+func (s *ConfigTestSuite) Test_Watch_FileChange() {
+	path := s.writeFile("config.yml", "default_max_replicas: 10\n")
+	reloaded := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, path, 10*time.Millisecond, nil, func() {
+		reloaded <- struct{}{}
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	s.Len(reloaded, 0)
+
+	s.writeFile("config.yml", "default_max_replicas: 11\n")
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		s.Fail("config was not reloaded")
+	}
+}
+
+func (s *ConfigTestSuite) Test_Watch_Signal() {
+	reloadC := make(chan os.Signal, 1)
+	reloaded := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go Watch(ctx, "", time.Second, reloadC, func() {
+		reloaded <- struct{}{}
+	})
+
+	reloadC <- syscall.SIGHUP
+	select {
+	case <-reloaded:
+	case <-time.After(time.Second):
+		s.Fail("config was not reloaded")
+	}
+}
+
+func (s *ConfigTestSuite) Test_Load_Freezes() {
+	path := s.writeFile("config.yml", `
+freezes:
+  - name: db-migration
+    start: 2026-10-20T22:00:00Z
+    end: 2026-10-21T02:00:00Z
+    scope: services
+    selector: com.df.tier=db
+    policy: allow-scale-up
+    reason: Database migration
+`)
+	c, err := Load(path)
+	s.Require().NoError(err)
+	s.Require().Len(c.Freezes, 1)
+	f := c.Freezes[0]
+	s.Equal("db-migration", f.Name)
+	s.Equal(time.Date(2026, 10, 20, 22, 0, 0, 0, time.UTC), f.Start.UTC())
+	s.Equal("services", f.Scope)
+	s.NoError(c.Validate())
+	s.Contains(c.Settings(), Setting{Key: "freezes.db-migration",
+		Value: "services from 2026-10-20T22:00:00Z to 2026-10-21T02:00:00Z of com.df.tier=db, allow-scale-up"})
+}
+
+func (s *ConfigTestSuite) Test_Validate_Freezes() {
+	c := Default()
+	start := time.Date(2026, 10, 20, 22, 0, 0, 0, time.UTC)
+	c.Freezes = []Freeze{
+		{Name: "a", Start: start, End: start.Add(time.Hour), Scope: "pools", Selector: "tier=db"},
+		{Name: "a", Start: start, End: start, Policy: "allow", Cluster: "eu-west"},
+		{Start: start, End: start.Add(time.Hour)},
+	}
+	err := c.Validate()
+	s.Require().Error(err)
+	verr := err.(ValidationError)
+	s.Equal(ValidationError{
+		`freezes.a: scope ("pools") can only be all, nodes or services`,
+		"freezes.a: selector needs scope services",
+		"freezes.a: name is used by another freeze",
+		"freezes.a: end must be after start",
+		`freezes.a: policy ("allow") can only be reject or allow-scale-up`,
+		"freezes.a: cluster eu-west is not configured",
+		"freezes[2]: name must be set",
+	}, verr)
+}
+
+func (s *ConfigTestSuite) Test_Diff_Freezes() {
+	start := time.Date(2026, 10, 20, 22, 0, 0, 0, time.UTC)
+	old, new := Default(), Default()
+	old.Freezes = []Freeze{
+		{Name: "a", Start: start, End: start.Add(time.Hour), Scope: "all"},
+		{Name: "b", Start: start, End: start.Add(time.Hour), Scope: "all"},
+	}
+	new.Freezes = []Freeze{
+		{Name: "b", Start: start, End: start.Add(2 * time.Hour), Scope: "all"},
+		{Name: "c", Start: start, End: start.Add(time.Hour), Scope: "nodes"},
+	}
+	changes := Diff(old, new)
+	s.Require().Len(changes, 3)
+	s.Equal(`freezes.a: "all from 2026-10-20T22:00:00Z to 2026-10-20T23:00:00Z" -> none`, changes[0].String())
+	s.Equal("freezes.b", changes[1].Key)
+	s.Equal(`freezes.c: none -> "nodes from 2026-10-20T22:00:00Z to 2026-10-20T23:00:00Z"`, changes[2].String())
+	s.False(changes[2].Restart)
+}
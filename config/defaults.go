@@ -0,0 +1,233 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults are the settings that can be changed through the api while
+// docker-scaler runs
+type Defaults struct {
+	Service    ScaleDefaults      `json:"service"`
+	Manager    ScaleDefaults      `json:"manager"`
+	Worker     ScaleDefaults      `json:"worker"`
+	Alerts     AlertDefaults      `json:"alerts"`
+	Reschedule RescheduleDefaults `json:"reschedule"`
+}
+
+// ScaleDefaults are used when a service or node does not have scaling
+// labels
+type ScaleDefaults struct {
+	Min         uint64 `json:"min"`
+	Max         uint64 `json:"max"`
+	ScaleDownBy uint64 `json:"scaleDownBy"`
+	ScaleUpBy   uint64 `json:"scaleUpBy"`
+}
+
+// AlertDefaults select whether alerts are sent when scaling reaches a bound
+type AlertDefaults struct {
+	ScaleMin bool `json:"alertScaleMin"`
+	ScaleMax bool `json:"alertScaleMax"`
+	NodeMin  bool `json:"alertNodeMin"`
+	NodeMax  bool `json:"alertNodeMax"`
+}
+
+// RescheduleDefaults are the intervals of the rescheduler, in seconds
+type RescheduleDefaults struct {
+	TickerInterval int64 `json:"tickerInterval"`
+	TimeOut        int64 `json:"timeout"`
+}
+
+// Defaults returns the defaults of `c`
+func (c Config) Defaults() Defaults {
+	return Defaults{
+		Service: ScaleDefaults{
+			Min:         c.DefaultMinReplicas,
+			Max:         c.DefaultMaxReplicas,
+			ScaleDownBy: c.DefaultScaleServiceDownBy,
+			ScaleUpBy:   c.DefaultScaleServiceUpBy,
+		},
+		Manager: ScaleDefaults{
+			Min:         c.DefaultMinManagerNodes,
+			Max:         c.DefaultMaxManagerNodes,
+			ScaleDownBy: c.DefaultScaleManagerNodeDownBy,
+			ScaleUpBy:   c.DefaultScaleManagerNodeUpBy,
+		},
+		Worker: ScaleDefaults{
+			Min:         c.DefaultMinWorkerNodes,
+			Max:         c.DefaultMaxWorkerNodes,
+			ScaleDownBy: c.DefaultScaleWorkerNodeDownBy,
+			ScaleUpBy:   c.DefaultScaleWorkerNodeUpBy,
+		},
+		Alerts: AlertDefaults{
+			ScaleMin: c.AlertScaleMin,
+			ScaleMax: c.AlertScaleMax,
+			NodeMin:  c.AlertNodeMin,
+			NodeMax:  c.AlertNodeMax,
+		},
+		Reschedule: RescheduleDefaults{
+			TickerInterval: c.RescheduleTickerInterval,
+			TimeOut:        c.RescheduleTimeOut,
+		},
+	}
+}
+
+// WithDefaults returns `c` with its defaults replaced by `d`
+func (c Config) WithDefaults(d Defaults) Config {
+	c.DefaultMinReplicas = d.Service.Min
+	c.DefaultMaxReplicas = d.Service.Max
+	c.DefaultScaleServiceDownBy = d.Service.ScaleDownBy
+	c.DefaultScaleServiceUpBy = d.Service.ScaleUpBy
+
+	c.DefaultMinManagerNodes = d.Manager.Min
+	c.DefaultMaxManagerNodes = d.Manager.Max
+	c.DefaultScaleManagerNodeDownBy = d.Manager.ScaleDownBy
+	c.DefaultScaleManagerNodeUpBy = d.Manager.ScaleUpBy
+
+	c.DefaultMinWorkerNodes = d.Worker.Min
+	c.DefaultMaxWorkerNodes = d.Worker.Max
+	c.DefaultScaleWorkerNodeDownBy = d.Worker.ScaleDownBy
+	c.DefaultScaleWorkerNodeUpBy = d.Worker.ScaleUpBy
+
+	c.AlertScaleMin = d.Alerts.ScaleMin
+	c.AlertScaleMax = d.Alerts.ScaleMax
+	c.AlertNodeMin = d.Alerts.NodeMin
+	c.AlertNodeMax = d.Alerts.NodeMax
+
+	c.RescheduleTickerInterval = d.Reschedule.TickerInterval
+	c.RescheduleTimeOut = d.Reschedule.TimeOut
+	return c
+}
+
+// Overrides are settings changed through the api, by their config file
+// name. They take precedence over the config file and env variables. The
+// overrides of named clusters are kept under clusters.<cluster>
+type Overrides map[string]interface{}
+
+// ReadOverrides reads the overrides saved at `path`. There are no
+// overrides when `path` is empty or the file does not exist
+func ReadOverrides(path string) (Overrides, error) {
+	o := Overrides{}
+	if len(path) == 0 {
+		return o, nil
+	}
+	b, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return o, nil
+	}
+	if err != nil {
+		return o, errors.Wrap(err, "Unable to read defaults file")
+	}
+	err = yaml.Unmarshal(b, &o)
+	if err != nil {
+		return o, errors.Wrapf(err, "Unable to parse defaults file %s", path)
+	}
+	return o, nil
+}
+
+// Save writes the overrides to `path`, replacing the file at once so it is
+// never partially written
+func (o Overrides) Save(path string) error {
+	b, err := yaml.Marshal(o)
+	if err != nil {
+		return errors.Wrap(err, "Unable to save defaults")
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "Unable to save defaults")
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	return errors.Wrapf(err, "Unable to save defaults to %s", path)
+}
+
+// Set records the new values of `changes` to the settings of `cluster`
+func (o Overrides) Set(cluster string, changes []Change) {
+	if cluster == DefaultCluster {
+		for _, change := range changes {
+			o[change.Key] = change.New
+		}
+		return
+	}
+
+	// The maps of clusters are copied, so copies of `o` are not changed
+	clusters := map[string]interface{}{}
+	for name, settings := range o.clusters() {
+		clusters[name] = settings
+	}
+	settings := map[string]interface{}{}
+	for key, value := range o.clusters()[cluster] {
+		settings[key] = value
+	}
+	for _, change := range changes {
+		settings[change.Key] = change.New
+	}
+	clusters[cluster] = settings
+	o["clusters"] = clusters
+}
+
+// Apply returns `c` with the overrides. Overrides of clusters that are no
+// longer configured are ignored
+func (o Overrides) Apply(c Config) (Config, error) {
+	if len(o) == 0 {
+		return c, nil
+	}
+	top := map[string]interface{}{}
+	for key, value := range o {
+		if key != "clusters" {
+			top[key] = value
+		}
+	}
+	c, err := applySettings(c, top)
+	if err != nil {
+		return c, errors.Wrap(err, "Unable to apply defaults")
+	}
+
+	overrides := o.clusters()
+	if len(overrides) == 0 {
+		return c, nil
+	}
+	clusters := map[string]map[string]interface{}{}
+	for name, settings := range c.Clusters {
+		merged := map[string]interface{}{}
+		for key, value := range settings {
+			merged[key] = value
+		}
+		for key, value := range overrides[name] {
+			merged[key] = value
+		}
+		clusters[name] = merged
+	}
+	c.Clusters = clusters
+	return c, nil
+}
+
+// clusters returns the overrides of each named cluster
+func (o Overrides) clusters() map[string]map[string]interface{} {
+	clusters := map[string]map[string]interface{}{}
+	for name, settings := range asMap(o["clusters"]) {
+		clusters[name] = asMap(settings)
+	}
+	return clusters
+}
+
+// asMap returns the settings in `v`. Maps read from a defaults file are
+// Overrides, while maps set in memory are not
+func asMap(v interface{}) map[string]interface{} {
+	switch m := v.(type) {
+	case Overrides:
+		return m
+	case map[string]interface{}:
+		return m
+	}
+	return nil
+}
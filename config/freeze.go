@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Freeze is a window in which scaling is rejected, declared in the config
+// file. Freezes can only be declared in the config file, not in env
+// variables
+type Freeze struct {
+	Name     string    `yaml:"name"`
+	Start    time.Time `yaml:"start"`
+	End      time.Time `yaml:"end"`
+	Scope    string    `yaml:"scope"`
+	Selector string    `yaml:"selector"`
+	Policy   string    `yaml:"policy"`
+	Cluster  string    `yaml:"cluster"`
+	Reason   string    `yaml:"reason"`
+}
+
+func (f Freeze) String() string {
+	s := fmt.Sprintf("%s from %s to %s", f.Scope, f.Start.UTC().Format(time.RFC3339), f.End.UTC().Format(time.RFC3339))
+	if len(f.Selector) > 0 {
+		s += fmt.Sprintf(" of %s", f.Selector)
+	}
+	if len(f.Cluster) > 0 {
+		s += fmt.Sprintf(" in %s", f.Cluster)
+	}
+	if len(f.Policy) > 0 {
+		s += fmt.Sprintf(", %s", f.Policy)
+	}
+	return s
+}
+
+// validateFreezes checks the freezes. Problems of a freeze are prefixed
+// with freezes.<name>
+func (c Config) validateFreezes() []string {
+	var errs []string
+	seen := map[string]bool{}
+	for i, f := range c.Freezes {
+		if len(f.Name) == 0 {
+			errs = append(errs, fmt.Sprintf("freezes[%d]: name must be set", i))
+			continue
+		}
+		prefix := freezeKey(f.Name)
+		check := func(ok bool, format string, args ...interface{}) {
+			if !ok {
+				errs = append(errs, prefix+": "+fmt.Sprintf(format, args...))
+			}
+		}
+		check(!seen[f.Name], "name is used by another freeze")
+		seen[f.Name] = true
+		check(!f.Start.IsZero() && !f.End.IsZero(), "start and end must be set")
+		check(f.End.After(f.Start), "end must be after start")
+		check(oneOf(f.Scope, "", "all", "nodes", "services"),
+			"scope (%q) can only be all, nodes or services", f.Scope)
+		check(oneOf(f.Policy, "", "reject", "allow-scale-up"),
+			"policy (%q) can only be reject or allow-scale-up", f.Policy)
+		check(len(f.Selector) == 0 || f.Scope == "services",
+			"selector needs scope services")
+		_, cluster := c.Clusters[f.Cluster]
+		check(len(f.Cluster) == 0 || f.Cluster == DefaultCluster || cluster,
+			"cluster %s is not configured", f.Cluster)
+	}
+	return errs
+}
+
+// diffFreezes returns the freezes that were added, removed or changed
+func diffFreezes(old, new []Freeze) []Change {
+	o, n := freezesByName(old), freezesByName(new)
+	names := sortedKeys(o)
+	for _, name := range sortedKeys(n) {
+		if _, ok := o[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []Change
+	for _, name := range names {
+		of, inOld := o[name]
+		nf, inNew := n[name]
+		if inOld && inNew && reflect.DeepEqual(of, nf) {
+			continue
+		}
+		change := Change{Key: freezeKey(name)}
+		if inOld {
+			change.Old = of.String()
+		}
+		if inNew {
+			change.New = nf.String()
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
+
+func freezesByName(freezes []Freeze) map[string]Freeze {
+	m := map[string]Freeze{}
+	for _, f := range freezes {
+		m[f.Name] = f
+	}
+	return m
+}
+
+// freezeKey is the name of freeze `name` in the config file
+func freezeKey(name string) string {
+	return fmt.Sprintf("freezes.%s", strings.TrimSpace(name))
+}
@@ -0,0 +1,109 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationError lists every invalid setting of a configuration
+type ValidationError []string
+
+func (e ValidationError) Error() string {
+	return "Invalid configuration:\n  - " + strings.Join(e, "\n  - ")
+}
+
+// Validate checks that the settings of `c` and of each of its clusters are
+// valid and agree with each other. It returns a ValidationError listing
+// every problem
+func (c Config) Validate() error {
+	var errs ValidationError
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(strings.HasPrefix(c.ServerPrefix, "/"),
+		"SERVER_PREFIX (%q) must start with /", c.ServerPrefix)
+
+	check(c.DefaultMinReplicas <= c.DefaultMaxReplicas,
+		"DEFAULT_MIN_REPLICAS (%d) is greater than DEFAULT_MAX_REPLICAS (%d)",
+		c.DefaultMinReplicas, c.DefaultMaxReplicas)
+	check(c.DefaultScaleServiceDownBy > 0, "DEFAULT_SCALE_SERVICE_DOWN_BY must be at least 1")
+	check(c.DefaultScaleServiceUpBy > 0, "DEFAULT_SCALE_SERVICE_UP_BY must be at least 1")
+
+	check(c.DefaultMinManagerNodes%2 == 1,
+		"DEFAULT_MIN_MANAGER_NODES (%d) must be odd, so the managers keep a quorum",
+		c.DefaultMinManagerNodes)
+	check(c.DefaultMinManagerNodes <= c.DefaultMaxManagerNodes,
+		"DEFAULT_MIN_MANAGER_NODES (%d) is greater than DEFAULT_MAX_MANAGER_NODES (%d)",
+		c.DefaultMinManagerNodes, c.DefaultMaxManagerNodes)
+	check(c.DefaultMinWorkerNodes <= c.DefaultMaxWorkerNodes,
+		"DEFAULT_MIN_WORKER_NODES (%d) is greater than DEFAULT_MAX_WORKER_NODES (%d)",
+		c.DefaultMinWorkerNodes, c.DefaultMaxWorkerNodes)
+	check(c.DefaultScaleManagerNodeDownBy > 0, "DEFAULT_SCALE_MANAGER_NODE_DOWN_BY must be at least 1")
+	check(c.DefaultScaleManagerNodeUpBy > 0, "DEFAULT_SCALE_MANAGER_NODE_UP_BY must be at least 1")
+	check(c.DefaultScaleWorkerNodeDownBy > 0, "DEFAULT_SCALE_WORKER_NODE_DOWN_BY must be at least 1")
+	check(c.DefaultScaleWorkerNodeUpBy > 0, "DEFAULT_SCALE_WORKER_NODE_UP_BY must be at least 1")
+	check(c.ManagerNodeHourlyPrice >= 0, "MANAGER_NODE_HOURLY_PRICE must not be negative")
+	check(c.WorkerNodeHourlyPrice >= 0, "WORKER_NODE_HOURLY_PRICE must not be negative")
+	check(c.NodeBudgetMaxHourlyCost >= 0, "NODE_BUDGET_MAX_HOURLY_COST must not be negative")
+	check(c.ApprovalMaxReplicaFactor == 0 || c.ApprovalMaxReplicaFactor > 1,
+		"APPROVAL_MAX_REPLICA_FACTOR (%g) must be 0 or greater than 1", c.ApprovalMaxReplicaFactor)
+	check(c.ApprovalTimeout > 0, "APPROVAL_TIMEOUT must be at least 1")
+
+	kv := strings.Split(c.RescheduleFilterLabel, "=")
+	check(len(kv) == 2 && len(kv[0]) > 0,
+		"RESCHEDULE_FILTER_LABEL (%q) does not have form key=value", c.RescheduleFilterLabel)
+	check(len(c.RescheduleEnvKey) > 0, "RESCHEDULE_ENV_KEY must not be empty")
+	check(c.RescheduleTickerInterval > 0, "RESCHEDULE_TICKER_INTERVAL must be at least 1")
+	check(c.RescheduleTimeOut > 0, "RESCHEDULE_TIMEOUT must be at least 1")
+	check(c.AlertTimeout > 0, "ALERT_TIMEOUT must be at least 1")
+	check(c.ScaleDuringUpdateTimeout > 0, "SCALE_DURING_UPDATE_TIMEOUT must be at least 1")
+	check(c.ShutdownGracePeriod >= 0, "SHUTDOWN_GRACE_PERIOD must not be negative")
+
+	for _, host := range c.ManagerHosts() {
+		check(strings.Contains(host, "://"),
+			"DOCKER_MANAGER_HOSTS has %q, hosts must have form proto://address, like tcp://manager-1:2376", host)
+	}
+
+	check(oneOf(c.NodeScalerBackend, "", "aws"),
+		"NODE_SCALER_BACKEND (%q) can only be aws or empty", c.NodeScalerBackend)
+	check(oneOf(c.ScaleDuringUpdate, "proceed", "reject", "queue"),
+		"SCALE_DURING_UPDATE (%q) can only be proceed, reject or queue", c.ScaleDuringUpdate)
+	check(oneOf(c.ReplicaQuotaScope, "cluster", "stack"),
+		"REPLICA_QUOTA_SCOPE (%q) can only be cluster or stack", c.ReplicaQuotaScope)
+	check(oneOf(c.LogFormat, "json", "logfmt"),
+		"LOG_FORMAT (%q) can only be json or logfmt", c.LogFormat)
+	check(oneOf(strings.ToLower(c.LogLevel), "debug", "info", "warn", "error"),
+		"LOG_LEVEL (%q) can only be debug, info, warn or error", c.LogLevel)
+	check(oneOf(c.TracingExporter, "", "otlp", "stdout"),
+		"TRACING_EXPORTER (%q) can only be otlp, stdout or empty", c.TracingExporter)
+
+	check(oneOf(c.LeaderElection, "", "docker", "file"),
+		"LEADER_ELECTION (%q) can only be docker, file or empty", c.LeaderElection)
+	check(c.LeaderElection != "file" || len(c.LeaderLockFile) > 0,
+		"LEADER_LOCK_FILE must be set when LEADER_ELECTION is file")
+	check(c.LeaderElection != "docker" || len(c.LeaderLockService) > 0,
+		"LEADER_LOCK_SERVICE must be set when LEADER_ELECTION is docker")
+	check(c.LeaderLeaseDuration > 0, "LEADER_LEASE_DURATION must be at least 1")
+	check(len(c.LeaderAdvertiseURL) == 0 || strings.Contains(c.LeaderAdvertiseURL, "://"),
+		"LEADER_ADVERTISE_URL (%q) must have form scheme://host:port, like http://scaler-1:8080", c.LeaderAdvertiseURL)
+
+	errs = append(errs, c.validateFreezes()...)
+	errs = append(errs, c.validateClusters(errs)...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func oneOf(value string, values ...string) bool {
+	for _, v := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,57 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"time"
+)
+
+// Watch calls `reload` when a signal is received on `reloadC`, or when the
+// contents of the file at `path` change. The file is checked every
+// `interval`, and is not watched when `path` is empty. `reload` is never
+// called concurrently. Watch returns when `ctx` is done
+func Watch(ctx context.Context, path string, interval time.Duration,
+	reloadC <-chan os.Signal, reload func()) {
+	var tickerC <-chan time.Time
+	var sum []byte
+	if len(path) > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tickerC = ticker.C
+		sum = checksum(path)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-reloadC:
+			sum = checksum(path)
+			reload()
+		case <-tickerC:
+			newSum := checksum(path)
+			// The file can be missing while it is being replaced
+			if newSum == nil || bytes.Equal(sum, newSum) {
+				continue
+			}
+			sum = newSum
+			reload()
+		}
+	}
+}
+
+// checksum returns the checksum of the file at `path`, or nil when it can
+// not be read
+func checksum(path string) []byte {
+	if len(path) == 0 {
+		return nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	s := sha256.Sum256(b)
+	return s[:]
+}
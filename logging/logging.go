@@ -0,0 +1,129 @@
+package logging
+
+// Leveled, structured logging with request ids
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats of log output
+const (
+	FormatJSON   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+// Attributes holding the request id, cluster and trace of log records
+const (
+	RequestIDKey = "request_id"
+	ClusterKey   = "cluster"
+	TraceIDKey   = "trace_id"
+	SpanIDKey    = "span_id"
+)
+
+type requestIDKey struct{}
+
+// contextAttr reads the value of an attribute from a context
+type contextAttr struct {
+	key   string
+	value func(context.Context) string
+}
+
+var (
+	contextAttrsMu sync.RWMutex
+	contextAttrs   []contextAttr
+)
+
+// AddContextAttr adds the attribute `key` to records logged with a context
+// for which `value` returns a non-empty string. Packages carrying values in
+// a context register them, so logging does not depend on those packages
+func AddContextAttr(key string, value func(context.Context) string) {
+	contextAttrsMu.Lock()
+	defer contextAttrsMu.Unlock()
+	contextAttrs = append(contextAttrs, contextAttr{key, value})
+}
+
+// New creates a logger writing records of at least `level` to `w` in
+// `format`. `level` is one of debug, info, warn or error
+func New(w io.Writer, format, level string) (*slog.Logger, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(level))
+	if err != nil {
+		return nil, fmt.Errorf("Unknown log level: %s, level can only be debug, info, warn or error", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: l}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case FormatLogfmt:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("Unknown log format: %s, format can only be json or logfmt", format)
+	}
+	return slog.New(contextHandler{h}), nil
+}
+
+// Discard creates a logger that drops every record
+func Discard() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
+
+// contextHandler adds the request id, registered attributes and span of
+// the context to records
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); len(id) > 0 {
+		r.AddAttrs(slog.String(RequestIDKey, id))
+	}
+	contextAttrsMu.RLock()
+	for _, a := range contextAttrs {
+		if v := a.value(ctx); len(v) > 0 {
+			r.AddAttrs(slog.String(a.key, v))
+		}
+	}
+	contextAttrsMu.RUnlock()
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String(TraceIDKey, sc.TraceID().String()),
+			slog.String(SpanIDKey, sc.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
+
+// NewRequestID creates a random request id
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID returns a copy of `ctx` carrying request id `id`
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request id carried by `ctx`, or an empty string
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
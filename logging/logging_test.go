@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type LoggingTestSuite struct {
+	suite.Suite
+}
+
+func TestLoggingUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggingTestSuite))
+}
+
+func (s *LoggingTestSuite) Test_New_JSON() {
+	b := new(bytes.Buffer)
+	l, err := New(b, "json", "info")
+	s.Require().NoError(err)
+
+	ctx := WithRequestID(context.Background(), "abc")
+	l.InfoContext(ctx, "scale-service success", "service", "web")
+
+	var record map[string]interface{}
+	s.Require().NoError(json.Unmarshal(b.Bytes(), &record))
+	s.Equal("INFO", record["level"])
+	s.Equal("scale-service success", record["msg"])
+	s.Equal("web", record["service"])
+	s.Equal("abc", record[RequestIDKey])
+}
+
+func (s *LoggingTestSuite) Test_New_Logfmt() {
+	b := new(bytes.Buffer)
+	l, err := New(b, "logfmt", "info")
+	s.Require().NoError(err)
+
+	l.With("service", "web").WarnContext(WithRequestID(context.Background(), "abc"), "alert failed")
+	s.Contains(b.String(), `level=WARN msg="alert failed" service=web request_id=abc`)
+}
+
+func (s *LoggingTestSuite) Test_New_DropsLowerLevels() {
+	b := new(bytes.Buffer)
+	l, err := New(b, "logfmt", "warn")
+	s.Require().NoError(err)
+
+	l.Info("hidden")
+	l.Error("shown")
+	s.NotContains(b.String(), "hidden")
+	s.Contains(b.String(), "shown")
+}
+
+func (s *LoggingTestSuite) Test_New_WithoutRequestID() {
+	b := new(bytes.Buffer)
+	l, err := New(b, "logfmt", "info")
+	s.Require().NoError(err)
+
+	l.Info("started")
+	s.NotContains(b.String(), RequestIDKey)
+}
+
+type tenantKey struct{}
+
+func (s *LoggingTestSuite) Test_New_AddsContextAttrs() {
+	AddContextAttr("tenant", func(ctx context.Context) string {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return tenant
+	})
+	b := new(bytes.Buffer)
+	l, err := New(b, "logfmt", "info")
+	s.Require().NoError(err)
+
+	ctx := context.WithValue(WithRequestID(context.Background(), "abc"), tenantKey{}, "eu-west")
+	l.InfoContext(ctx, "scale-service success")
+	s.Contains(b.String(), `request_id=abc tenant=eu-west`)
+
+	b.Reset()
+	l.InfoContext(context.Background(), "scale-service success")
+	s.NotContains(b.String(), "tenant")
+}
+
+func (s *LoggingTestSuite) Test_New_Invalid() {
+	_, err := New(new(bytes.Buffer), "xml", "info")
+	s.EqualError(err, "Unknown log format: xml, format can only be json or logfmt")
+
+	_, err = New(new(bytes.Buffer), "json", "verbose")
+	s.EqualError(err, "Unknown log level: verbose, level can only be debug, info, warn or error")
+}
+
+func (s *LoggingTestSuite) Test_RequestID() {
+	s.Empty(RequestID(context.Background()))
+	s.Len(NewRequestID(), 16)
+	s.NotEqual(NewRequestID(), NewRequestID())
+}
+
+func (s *LoggingTestSuite) Test_New_AddsTrace() {
+	b := new(bytes.Buffer)
+	l, err := New(b, "json", "info")
+	s.Require().NoError(err)
+
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: trace.TraceID{1},
+		SpanID:  trace.SpanID{2},
+	})
+	l.InfoContext(trace.ContextWithSpanContext(context.Background(), sc), "started")
+
+	var record map[string]interface{}
+	s.Require().NoError(json.Unmarshal(b.Bytes(), &record))
+	s.Equal(sc.TraceID().String(), record[TraceIDKey])
+	s.Equal(sc.SpanID().String(), record[SpanIDKey])
+}
@@ -0,0 +1,152 @@
+package metrics
+
+// Prometheus metrics describing docker-scaler itself
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "docker_scaler"
+
+var (
+	// ScaleRequests counts scaling requests by endpoint, direction,
+	// outcome and whether the target was already at its bound
+	ScaleRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "scale_requests_total",
+			Help:      "Number of scale requests received",
+		},
+		[]string{"endpoint", "direction", "outcome", "at_bound"},
+	)
+
+	// DockerCallDuration observes the latency of docker api calls
+	DockerCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "docker_call_duration_seconds",
+			Help:      "Latency of docker api calls",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"call"},
+	)
+
+	// DockerFailovers counts switches to another docker host after the
+	// active one could not be reached or was no longer a manager
+	DockerFailovers = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "docker_failovers_total",
+			Help:      "Number of switches to another docker host",
+		},
+		[]string{"host"},
+	)
+
+	// CloudCallDuration observes the latency of cloud provider calls
+	CloudCallDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "cloud_call_duration_seconds",
+			Help:      "Latency of cloud provider calls",
+			Buckets:   []float64{.05, .1, .25, .5, 1, 2.5, 5, 10, 30},
+		},
+		[]string{"backend", "call"},
+	)
+
+	// PendingRescheduleWaits is the number of reschedules waiting for
+	// nodes to come online
+	PendingRescheduleWaits = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "pending_reschedule_waits",
+			Help:      "Number of reschedules waiting for nodes to come online",
+		},
+	)
+
+	// AlertSendFailures counts alerts alertmanager did not receive
+	AlertSendFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "alert_send_failures_total",
+			Help:      "Number of alerts that failed to send to alertmanager",
+		},
+	)
+
+	// ServiceReplicas is the last known number of replicas of a scaled
+	// service
+	ServiceReplicas = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "service_replicas",
+			Help:      "Last known number of replicas for a scaled service",
+		},
+		[]string{"service"},
+	)
+
+	// Leader is 1 while this replica is the leader
+	Leader = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "leader",
+			Help:      "Whether this replica is the leader",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(
+		ScaleRequests,
+		DockerCallDuration,
+		DockerFailovers,
+		CloudCallDuration,
+		PendingRescheduleWaits,
+		AlertSendFailures,
+		ServiceReplicas,
+		Leader,
+	)
+}
+
+// Handler serves the registered metrics in the prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// CountScaleRequest increments ScaleRequests
+func CountScaleRequest(endpoint, direction, outcome string, atBound bool) {
+	ScaleRequests.WithLabelValues(
+		endpoint, direction, outcome, strconv.FormatBool(atBound)).Inc()
+}
+
+// ObserveDockerCall records the time since `start` for a docker api call
+func ObserveDockerCall(call string, start time.Time) {
+	DockerCallDuration.WithLabelValues(call).Observe(time.Since(start).Seconds())
+}
+
+// CountDockerFailover increments DockerFailovers for the new docker host
+func CountDockerFailover(host string) {
+	DockerFailovers.WithLabelValues(host).Inc()
+}
+
+// ObserveCloudCall records the time since `start` for a cloud provider call
+func ObserveCloudCall(backend, call string, start time.Time) {
+	CloudCallDuration.WithLabelValues(backend, call).Observe(time.Since(start).Seconds())
+}
+
+// SetServiceReplicas records the number of replicas for `service`
+func SetServiceReplicas(service string, replicas uint64) {
+	ServiceReplicas.WithLabelValues(service).Set(float64(replicas))
+}
+
+// SetLeader sets Leader to 1 when `leading`, otherwise to 0
+func SetLeader(leading bool) {
+	if leading {
+		Leader.Set(1)
+		return
+	}
+	Leader.Set(0)
+}
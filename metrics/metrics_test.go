@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+}
+
+func TestMetricsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
+
+func (s *MetricsTestSuite) Test_Handler_ExposesMetrics() {
+	CountScaleRequest("scale_nodes", "down", "error", false)
+	ObserveDockerCall("service_inspect", time.Now())
+	ObserveCloudCall("aws", "get_nodes", time.Now())
+	SetServiceReplicas("web", 3)
+	PendingRescheduleWaits.Set(1)
+	AlertSendFailures.Inc()
+
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	s.Contains(body, `docker_scaler_scale_requests_total{at_bound="false",direction="down",endpoint="scale_nodes",outcome="error"} 1`)
+	s.Contains(body, `docker_scaler_docker_call_duration_seconds_count{call="service_inspect"} 1`)
+	s.Contains(body, `docker_scaler_cloud_call_duration_seconds_count{backend="aws",call="get_nodes"} 1`)
+	s.Contains(body, `docker_scaler_service_replicas{service="web"} 3`)
+	s.Contains(body, "docker_scaler_pending_reschedule_waits 1")
+	s.Contains(body, "docker_scaler_alert_send_failures_total 1")
+}
@@ -22,5 +22,6 @@ docker run --rm \
 	-e "TARGET_SERVICE=test_web1" \
 	-e "FALSE_RESCHEDULE_SERVICE=test_web2" \
 	-e "ALERTMANAGER_ADDRESS=http://alertmanager:9093" \
-	golang:1.10.0-alpine3.7 \
+	-e "GO111MODULE=off" \
+	golang:1.22.5-alpine3.20 \
 	go test github.com/thomasjpfan/docker-scaler/integration -v
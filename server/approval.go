@@ -0,0 +1,235 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thomasjpfan/docker-scaler/metrics"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// ApprovalResponse returns one approval to HTTP clients
+type ApprovalResponse struct {
+	Status   string           `json:"status"`
+	Approval service.Approval `json:"approval"`
+}
+
+// ApprovalsResponse returns approvals to HTTP clients
+type ApprovalsResponse struct {
+	Status    string             `json:"status"`
+	Approvals []service.Approval `json:"approvals"`
+}
+
+// SetApprovals serves the changes parked in `a` on /approvals. The
+// scalers park the changes themselves, since they know how large a change
+// is. It must be called before the router is made
+func (s *Server) SetApprovals(a *service.Approvals) {
+	a.SetErrorHandler(func(err error) {
+		s.logger.Error(err.Error())
+	})
+	s.approvals = a
+}
+
+// awaitApproval parks operation `opID` until the change of `e` is
+// approved, rejected or expires, and announces it through the alerter.
+// Approval alerts name the service or node type to scale. A change that
+// already awaits approval finishes `opID`, and the earlier operation keeps
+// waiting
+func (s *Server) awaitApproval(ctx context.Context, logger *slog.Logger, opID string,
+	e *service.ApprovalRequiredError, requestMessage string) Response {
+	a := e.Approval
+	if e.Existing {
+		return s.alreadyAwaitingApproval(ctx, logger, opID, a)
+	}
+	message := e.Error()
+	s.approvals.SetOperation(a.ID, opID)
+	s.operations.SetState(opID, service.OperationAwaitingApproval, message)
+	logger.WarnContext(ctx, fmt.Sprintf("%s awaiting approval: %s", a.Kind, message), "approval_id", a.ID)
+	s.sendAlert(ctx, "approval", a.Target, requestMessage, "pending", message)
+	s.publish(ctx, service.EventApproval, approvalService(a), opID, "pending", message)
+	metrics.CountScaleRequest(a.Kind, string(a.Direction), "awaiting_approval", false)
+
+	s.expireApprovalLater(ctx, a)
+
+	return approvalResponse(opID, message, a)
+}
+
+// alreadyAwaitingApproval finishes operation `opID`, whose change already
+// awaits approval `a`
+func (s *Server) alreadyAwaitingApproval(ctx context.Context, logger *slog.Logger, opID string, a service.Approval) Response {
+	message := fmt.Sprintf("%s already awaits approval %s by operation %s", a.Change(), a.ID, a.OperationID)
+	logger.InfoContext(ctx, fmt.Sprintf("%s awaiting approval: %s", a.Kind, message), "approval_id", a.ID)
+	s.publish(ctx, service.EventApproval, approvalService(a), opID, "success", message)
+	metrics.CountScaleRequest(a.Kind, string(a.Direction), "awaiting_approval", false)
+	s.operations.Finish(opID, message, nil)
+
+	return approvalResponse(opID, message, a)
+}
+
+// expireApprovalLater expires approval `a` once its expiry is reached. The
+// expiry outlives the request, but keeps its request id
+func (s *Server) expireApprovalLater(ctx context.Context, a service.Approval) {
+	expireCtx := context.WithoutCancel(ctx)
+	time.AfterFunc(time.Until(a.ExpiresAt), func() {
+		s.expireApproval(expireCtx, a.ID)
+	})
+}
+
+// pendingApproval returns the approval operation `opID` waits for
+func (s *Server) pendingApproval(opID string) (service.Approval, bool) {
+	for _, a := range s.approvals.List() {
+		if a.OperationID == opID && a.State == service.ApprovalPending {
+			return a, true
+		}
+	}
+	return service.Approval{}, false
+}
+
+// expireApproval fails the operation of approval `id` when the approval
+// is still waiting
+func (s *Server) expireApproval(ctx context.Context, id string) {
+	a, ok := s.approvals.Expire(id)
+	if !ok {
+		return
+	}
+	err := fmt.Errorf("Approval %s expired: %s was not approved within %d seconds",
+		id, a.Change(), int(a.ExpiresAt.Sub(a.CreatedAt).Seconds()))
+	s.logger.WarnContext(ctx, fmt.Sprintf("expire-approval: %s", err), "approval_id", id, "operation_id", a.OperationID)
+	s.sendAlert(ctx, "approval", a.Target, fmt.Sprintf("Approval %s", id), "error", err.Error())
+	s.publish(ctx, service.EventApproval, approvalService(a), a.OperationID, "error", err.Error())
+	s.operations.Finish(a.OperationID, "", err)
+}
+
+// ListApprovals returns the approvals, newest first. With
+// `state=awaiting-approval`, only the changes still waiting are returned
+func (s *Server) ListApprovals(w http.ResponseWriter, r *http.Request) {
+	approvals := s.approvals.List()
+	if state := r.URL.Query().Get("state"); len(state) > 0 {
+		selected := []service.Approval{}
+		for _, a := range approvals {
+			if string(a.State) == state {
+				selected = append(selected, a)
+			}
+		}
+		approvals = selected
+	}
+	respondWithJSON(w, http.StatusOK, ApprovalsResponse{Status: "OK", Approvals: approvals})
+}
+
+// GetApproval returns one approval
+func (s *Server) GetApproval(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	a, ok := s.approvals.Get(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, ErrorCodeApprovalNotFound, fmt.Sprintf("Approval %s does not exist", id))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, ApprovalResponse{Status: "OK", Approval: a})
+}
+
+// ApproveChange carries out the change of an approval. The change is
+// requested again, so it scales from the replicas or nodes there are now.
+// The parked operation fails when the change fails or is rejected again,
+// by a freeze, a budget, a quota or an update in progress
+func (s *Server) ApproveChange(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	a, ok := s.decideApproval(w, r, id, true)
+	if !ok {
+		return
+	}
+	message := fmt.Sprintf("Approved %s: %s", id, a.Change())
+	s.logger.InfoContext(r.Context(), fmt.Sprintf("approve success: %s", message), "approval_id", id, "operation_id", a.OperationID)
+	s.sendAlert(r.Context(), "approval", a.Target, fmt.Sprintf("Approval %s", id), "success", message)
+	s.publish(r.Context(), service.EventApproval, approvalService(a), a.OperationID, "success", message)
+
+	ctx := service.WithApproval(r.Context(), id)
+	var resp Response
+	var code int
+	if a.Kind == "scale_nodes" {
+		resp, code = s.scaleNodes(ctx, a.Cluster, a.Service, string(a.Direction), a.By, a.Target)
+	} else {
+		resp, code = s.scaleService(ctx, a.Cluster, a.Target, string(a.Direction), a.By)
+	}
+	if resp.Status != "OK" || code >= http.StatusBadRequest {
+		s.operations.Finish(a.OperationID, "",
+			fmt.Errorf("%s, but operation %s failed: %s", message, resp.OperationID, resp.Message))
+	} else {
+		s.operations.Finish(a.OperationID,
+			fmt.Sprintf("%s, carried out by operation %s", message, resp.OperationID), nil)
+	}
+	resp.ApprovalID = id
+	respondWithJSON(w, code, resp)
+}
+
+// RejectChange rejects the change of an approval
+func (s *Server) RejectChange(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	a, ok := s.decideApproval(w, r, id, false)
+	if !ok {
+		return
+	}
+	message := fmt.Sprintf("Rejected %s: %s", id, a.Change())
+	s.logger.InfoContext(r.Context(), fmt.Sprintf("reject success: %s", message), "approval_id", id, "operation_id", a.OperationID)
+	s.sendAlert(r.Context(), "approval", a.Target, fmt.Sprintf("Approval %s", id), "success", message)
+	s.publish(r.Context(), service.EventApproval, approvalService(a), a.OperationID, "success", message)
+	s.operations.Finish(a.OperationID, "", fmt.Errorf("%s", message))
+	respondWithJSON(w, http.StatusOK, Response{
+		Status:      "OK",
+		Message:     message,
+		OperationID: a.OperationID,
+		ApprovalID:  id,
+	})
+}
+
+// decideApproval approves or rejects approval `id`, and responds with an
+// error when it does not exist or was already decided
+func (s *Server) decideApproval(w http.ResponseWriter, r *http.Request, id string, approve bool) (service.Approval, bool) {
+	a, found, err := s.approvals.Decide(id, approve)
+	if !found {
+		respondWithError(w, http.StatusNotFound, ErrorCodeApprovalNotFound, fmt.Sprintf("Approval %s does not exist", id))
+		return a, false
+	}
+	if err != nil {
+		s.logger.WarnContext(r.Context(), fmt.Sprintf("decide-approval error: %s", err), "approval_id", id)
+		respondWithJSON(w, http.StatusConflict, Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeApprovalNotPending,
+			OperationID: a.OperationID,
+			ApprovalID:  id,
+		})
+		return a, false
+	}
+	return a, true
+}
+
+// approvalResponse responds to a request whose operation `opID` waits
+// for approval `a`
+func approvalResponse(opID, message string, a service.Approval) Response {
+	resp := Response{
+		Status:      "OK",
+		Message:     message,
+		OperationID: opID,
+		ApprovalID:  a.ID,
+		Direction:   string(a.Direction),
+	}
+	if a.Kind == "scale_nodes" {
+		resp.NodeType = a.Target
+		resp.Service = a.Service
+	} else {
+		resp.Service = a.Target
+	}
+	return resp
+}
+
+// approvalService is the service of the events of approval `a`
+func approvalService(a service.Approval) string {
+	if a.Kind == "scale_nodes" {
+		return a.Service
+	}
+	return a.Target
+}
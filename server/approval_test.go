@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type ApprovalTestSuite struct {
+	suite.Suite
+	serverFixture
+	a *service.Approvals
+}
+
+func TestApprovalUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ApprovalTestSuite))
+}
+
+func (s *ApprovalTestSuite) SetupTest() {
+	s.a = service.NewApprovals()
+	s.a.SetPolicy("default", service.ApprovalPolicy{MaxReplicaFactor: 2, Timeout: time.Hour})
+	s.setUpServer(func(srv *Server) {
+		srv.SetApprovals(s.a)
+	})
+}
+
+// park scales web from 2 to 10 replicas, which the scaler parks
+func (s *ApprovalTestSuite) park() Response {
+	ctx := service.WithCluster(context.Background(), "default")
+	err := s.a.Check(ctx, service.ApprovalChange{
+		Kind: "scale_service", Target: "web", Direction: service.ScaleUpDirection,
+		By: 8, Previous: 2, Requested: 10,
+	})
+	s.Require().Error(err)
+	s.m.On("Scale", mock.Anything, "web", uint64(8), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, err).Once()
+
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=up&by=8", "")
+	s.Require().Equal(http.StatusAccepted, rec.Code, rec.Body.String())
+	return resp
+}
+
+func (s *ApprovalTestSuite) Test_ScaleService_AwaitsApproval() {
+	resp := s.park()
+	s.Equal("OK", resp.Status)
+	s.NotEmpty(resp.ApprovalID)
+	s.Equal("web", resp.Service)
+	s.Contains(resp.Message, "Scaling web from 2 to 10 replicas awaits approval "+resp.ApprovalID)
+
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationAwaitingApproval, op.State)
+	a, ok := s.a.Get(resp.ApprovalID)
+	s.Require().True(ok)
+	s.Equal(resp.OperationID, a.OperationID)
+	s.am.AssertCalled(s.T(), "Send", "approval", "web", "Scale service up: web", "pending", resp.Message)
+
+	rec, _ := s.request("GET", "/v1/approvals?state=awaiting-approval", "")
+	var list ApprovalsResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &list))
+	s.Require().Len(list.Approvals, 1)
+	s.Equal(resp.ApprovalID, list.Approvals[0].ID)
+}
+
+func (s *ApprovalTestSuite) Test_ScaleService_AlreadyAwaitsApproval() {
+	first := s.park()
+	resp := s.park()
+	s.Equal(first.ApprovalID, resp.ApprovalID)
+	s.NotEqual(first.OperationID, resp.OperationID)
+	s.Equal("Scaling web from 2 to 10 replicas already awaits approval "+first.ApprovalID+
+		" by operation "+first.OperationID, resp.Message)
+
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationDone, op.State)
+	a, _ := s.a.Get(first.ApprovalID)
+	s.Equal(first.OperationID, a.OperationID)
+	s.Len(s.a.List(), 1)
+}
+
+func (s *ApprovalTestSuite) Test_Approve() {
+	parked := s.park()
+	approved := mock.MatchedBy(func(ctx context.Context) bool {
+		return service.ApprovalID(ctx) == parked.ApprovalID
+	})
+	s.m.On("Scale", approved, "web", uint64(8), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 2 to 10 replicas"}, nil)
+
+	rec, resp := s.request("POST", "/v1/approvals/"+parked.ApprovalID+"/approve", "")
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal(parked.ApprovalID, resp.ApprovalID)
+	s.NotEqual(parked.OperationID, resp.OperationID)
+	s.m.AssertExpectations(s.T())
+
+	op, _ := s.s.operations.Get(parked.OperationID)
+	s.Equal(service.OperationDone, op.State)
+	s.Equal("Approved "+parked.ApprovalID+": Scaling web from 2 to 10 replicas, carried out by operation "+
+		resp.OperationID, op.Message)
+	a, _ := s.a.Get(parked.ApprovalID)
+	s.Equal(service.ApprovalApproved, a.State)
+
+	rec, resp = s.request("POST", "/v1/approvals/"+parked.ApprovalID+"/reject", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeApprovalNotPending, resp.ErrorCode)
+	s.Equal("Approval "+parked.ApprovalID+" is already approved", resp.Message)
+}
+
+func (s *ApprovalTestSuite) Test_Approve_Fails() {
+	parked := s.park()
+	frozen := &service.FrozenError{Freeze: service.Freeze{Name: "db", End: time.Now()}}
+	s.m.On("Scale", mock.Anything, "web", uint64(8), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, frozen)
+
+	rec, resp := s.request("POST", "/v1/approvals/"+parked.ApprovalID+"/approve", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeFrozen, resp.ErrorCode)
+
+	op, _ := s.s.operations.Get(parked.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal("Approved "+parked.ApprovalID+": Scaling web from 2 to 10 replicas, but operation "+
+		resp.OperationID+" failed: "+frozen.Error(), op.Error)
+}
+
+func (s *ApprovalTestSuite) Test_Reject() {
+	parked := s.park()
+	rec, resp := s.request("POST", "/v1/approvals/"+parked.ApprovalID+"/reject", "")
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal("Rejected "+parked.ApprovalID+": Scaling web from 2 to 10 replicas", resp.Message)
+	s.m.AssertNumberOfCalls(s.T(), "Scale", 1)
+
+	op, _ := s.s.operations.Get(parked.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal(resp.Message, op.Error)
+}
+
+func (s *ApprovalTestSuite) Test_Expire() {
+	s.a.SetPolicy("default", service.ApprovalPolicy{MaxReplicaFactor: 2, Timeout: 10 * time.Millisecond})
+	parked := s.park()
+
+	var op service.Operation
+	for i := 0; i < 100; i++ {
+		op, _ = s.s.operations.Get(parked.OperationID)
+		if op.State != service.OperationAwaitingApproval {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.Equal(service.OperationFailed, op.State)
+	s.Contains(op.Error, "Approval "+parked.ApprovalID+" expired: Scaling web from 2 to 10 replicas")
+
+	rec, resp := s.request("POST", "/v1/approvals/"+parked.ApprovalID+"/approve", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal("Approval "+parked.ApprovalID+" is already expired", resp.Message)
+}
+
+func (s *ApprovalTestSuite) Test_UnknownApproval() {
+	rec, resp := s.request("GET", "/v1/approvals/nope", "")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Equal(ErrorCodeApprovalNotFound, resp.ErrorCode)
+
+	rec, resp = s.request("POST", "/v1/approvals/nope/approve", "")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Equal(ErrorCodeApprovalNotFound, resp.ErrorCode)
+}
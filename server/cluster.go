@@ -0,0 +1,89 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// Cluster holds the scalers of one swarm cluster. NodeScaler is nil when
+// node scaling is not configured for the cluster
+type Cluster struct {
+	ServiceScaler service.ScalerServicer
+	NodeScaler    service.NodeScaling
+	Rescheduler   service.ReschedulerServicer
+}
+
+// AddCluster serves cluster `name` next to the default cluster given to
+// NewServer. Requests select it with the `cluster` parameter. It must be
+// called before the router is made
+func (s *Server) AddCluster(name string, c Cluster) {
+	s.clusters[name] = c
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alertBounds[name] = s.alertBounds[config.DefaultCluster]
+}
+
+// cluster returns cluster `name`, or the default cluster when `name` is
+// empty, with a copy of `ctx` carrying the name of the cluster
+func (s *Server) cluster(ctx context.Context, name string) (context.Context, Cluster, error) {
+	if len(name) == 0 {
+		name = config.DefaultCluster
+	}
+	c, ok := s.clusters[name]
+	if !ok {
+		return ctx, c, fmt.Errorf("Unknown cluster: %s, cluster can only be %s",
+			name, strings.Join(s.clusterNames(), ", "))
+	}
+	return service.WithCluster(ctx, name), c, nil
+}
+
+// clusterNames returns the default cluster followed by the named
+// clusters, sorted by name
+func (s *Server) clusterNames() []string {
+	var names []string
+	for name := range s.clusters {
+		if name != config.DefaultCluster {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{config.DefaultCluster}, names...)
+}
+
+// scalesNodes returns true when node scaling is configured for at least
+// one cluster
+func (s *Server) scalesNodes() bool {
+	for _, c := range s.clusters {
+		if c.NodeScaler != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// createOperation starts an operation for the request and cluster of
+// `ctx`
+func (s *Server) createOperation(ctx context.Context, kind, target string) service.Operation {
+	op := s.operations.Create(kind, target, logging.RequestID(ctx))
+	if cluster := service.ClusterFrom(ctx); len(cluster) > 0 {
+		s.operations.SetCluster(op.ID, cluster)
+		op.Cluster = cluster
+	}
+	return op
+}
+
+// getCluster returns the cluster named in the query, or in the group
+// labels of the alert that sent the request
+func getCluster(q url.Values, ssReq ScaleRequest) string {
+	if cluster := q.Get("cluster"); len(cluster) > 0 {
+		return cluster
+	}
+	return ssReq.GroupLabels.Cluster
+}
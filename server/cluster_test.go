@@ -0,0 +1,155 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+type ClusterTestSuite struct {
+	suite.Suite
+	serverFixture
+	em   *ScalerServicerMock
+	ersm *ReschedulerServiceMock
+}
+
+func TestClusterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ClusterTestSuite))
+}
+
+func (s *ClusterTestSuite) SetupTest() {
+	s.em = new(ScalerServicerMock)
+	s.ersm = new(ReschedulerServiceMock)
+	s.setUpServer(func(srv *Server) {
+		srv.AddCluster("eu-west", Cluster{
+			ServiceScaler: s.em,
+			Rescheduler:   s.ersm,
+		})
+	})
+}
+
+func (s *ClusterTestSuite) Test_ScaleService_GroupLabels() {
+	events, unsubscribe := s.s.events.Subscribe(4, service.EventFilter{Clusters: []string{"eu-west"}})
+	defer unsubscribe()
+	s.em.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 1 to 2 replicas"}, nil)
+
+	rec, resp := s.request("POST", "/v1/scale-service",
+		`{"groupLabels":{"service": "web", "scale": "up", "cluster": "eu-west"}}`)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal("Scaling web from 1 to 2 replicas", resp.Message)
+	s.em.AssertExpectations(s.T())
+	s.m.AssertNotCalled(s.T(), "Scale", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	op, ok := s.s.operations.Get(resp.OperationID)
+	s.Require().True(ok)
+	s.Equal("eu-west", op.Cluster)
+
+	e := <-events
+	s.Equal("eu-west", e.Cluster)
+	s.Equal(resp.OperationID, e.OperationID)
+}
+
+func (s *ClusterTestSuite) Test_ScaleService_DefaultCluster() {
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleDownDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 2 to 1 replicas"}, nil)
+
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=down", "")
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.m.AssertExpectations(s.T())
+
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal("default", op.Cluster)
+}
+
+func (s *ClusterTestSuite) Test_UnknownCluster() {
+	for _, url := range []string{
+		"/v1/scale-service?service=web&scale=up&cluster=ap-south",
+		"/v1/scale-nodes?type=worker&scale=up&cluster=ap-south",
+		"/v1/reschedule-services?cluster=ap-south",
+		"/v1/reschedule-service?service=web&cluster=ap-south",
+	} {
+		rec, resp := s.request("POST", url, "")
+		s.Equal(http.StatusNotFound, rec.Code, url)
+		s.Equal(ErrorCodeUnknownCluster, resp.ErrorCode, url)
+		s.Equal("Unknown cluster: ap-south, cluster can only be default, eu-west", resp.Message, url)
+	}
+	s.Empty(s.s.operations.List())
+}
+
+func (s *ClusterTestSuite) Test_ScaleNodes_NotConfiguredForCluster() {
+	rec, resp := s.request("POST", "/v1/scale-nodes?type=worker&scale=up&cluster=eu-west", "")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Equal(ErrorCodeNodeScalingNotConfigured, resp.ErrorCode)
+	s.Equal("Node scaling is not configured for cluster eu-west", resp.Message)
+	s.nsm.AssertNotCalled(s.T(), "Scale", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ClusterTestSuite) Test_ScaleNodes_DefaultCluster() {
+	s.nsm.On("Scale", mock.Anything, uint64(1), service.ScaleDownDirection, cloud.NodeWorkerType, "").
+		Return(uint64(3), uint64(2), nil)
+	s.rsm.On("IsWaitingToReschedule").Return(false)
+
+	rec, _ := s.request("POST", "/v1/scale-nodes?type=worker&scale=down&by=1", "")
+	s.Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.nsm.AssertExpectations(s.T())
+}
+
+func (s *ClusterTestSuite) Test_Reschedule() {
+	s.ersm.On("RescheduleAll", mock.AnythingOfType("string")).Return("Rescheduled: web", nil)
+	s.ersm.On("RescheduleService", "web", mock.AnythingOfType("string")).Return(nil)
+
+	rec, _ := s.request("POST", "/v1/reschedule-services?cluster=eu-west", "")
+	s.Equal(http.StatusOK, rec.Code)
+	rec, _ = s.request("POST", "/v1/reschedule-service?service=web&cluster=eu-west", "")
+	s.Equal(http.StatusOK, rec.Code)
+	s.ersm.AssertExpectations(s.T())
+	s.rsm.AssertNotCalled(s.T(), "RescheduleAll", mock.Anything)
+}
+
+func (s *ClusterTestSuite) Test_ListOperations_Cluster() {
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 1 to 2 replicas"}, nil)
+	s.em.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 1 to 2 replicas"}, nil)
+	s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	_, eu := s.request("POST", "/v1/scale-service?service=web&scale=up&cluster=eu-west", "")
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/operations?cluster=eu-west", nil)
+	s.r.ServeHTTP(rec, req)
+	var resp OperationsResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Require().Len(resp.Operations, 1)
+	s.Equal(eu.OperationID, resp.Operations[0].ID)
+	s.Len(s.s.operations.List(), 2)
+}
+
+func (s *ClusterTestSuite) Test_AlertBounds() {
+	s.s.SetAlertBounds("eu-west", false, false, false, false)
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "web is already scaled to the maximum", AtBound: true}, nil)
+	s.em.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "web is already scaled to the maximum", AtBound: true}, nil)
+
+	s.request("POST", "/v1/scale-service?service=web&scale=up&cluster=eu-west", "")
+	s.am.AssertNotCalled(s.T(), "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	s.am.AssertNumberOfCalls(s.T(), "Send", 1)
+}
+
+func (s *ClusterTestSuite) Test_Shutdown_StopsEveryRescheduler() {
+	s.rsm.On("Stop")
+	s.ersm.On("Stop")
+	s.s.shutdown(context.Background(), &http.Server{}, nil)
+	s.rsm.AssertExpectations(s.T())
+	s.ersm.AssertExpectations(s.T())
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// DefaultsUpdater reads and changes the defaults of the clusters of a
+// running docker-scaler
+type DefaultsUpdater interface {
+	Defaults(cluster string) config.Defaults
+	// UpdateDefaults applies `d` to `cluster` and returns the settings that
+	// changed. It returns a config.ValidationError when `d` is invalid
+	UpdateDefaults(cluster string, d config.Defaults) ([]config.Change, error)
+}
+
+// defaultsReloader is implemented by DefaultsUpdaters that save the
+// defaults to a file other replicas change too
+type defaultsReloader interface {
+	ReloadDefaults() error
+}
+
+// reloadDefaults reads the defaults again, so a replica that starts leading
+// uses the defaults the previous leader changed
+func (s *Server) reloadDefaults() {
+	r, ok := s.defaults.(defaultsReloader)
+	if !ok {
+		return
+	}
+	if err := r.ReloadDefaults(); err != nil {
+		s.logger.Error(fmt.Sprintf("Unable to reload defaults: %s", err))
+	}
+}
+
+// DefaultsResponse returns the defaults to HTTP clients
+type DefaultsResponse struct {
+	Status      string          `json:"status"`
+	Message     string          `json:"message,omitempty"`
+	ErrorCode   string          `json:"errorCode,omitempty"`
+	OperationID string          `json:"operationId,omitempty"`
+	Cluster     string          `json:"cluster,omitempty"`
+	Changes     []string        `json:"changes,omitempty"`
+	Defaults    config.Defaults `json:"defaults"`
+}
+
+// SetDefaultsUpdater serves the defaults of `updater` on /config/defaults.
+// It must be called before the router is made
+func (s *Server) SetDefaultsUpdater(updater DefaultsUpdater) {
+	s.defaults = updater
+}
+
+// GetDefaults returns the defaults of a cluster used for services and
+// nodes without scaling labels, alerts at bounds and the rescheduler
+func (s *Server) GetDefaults(w http.ResponseWriter, r *http.Request) {
+	ctx, _, err := s.cluster(r.Context(), r.URL.Query().Get("cluster"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, ErrorCodeUnknownCluster, err.Error())
+		return
+	}
+	cluster := service.ClusterFrom(ctx)
+	respondWithJSON(w, http.StatusOK, DefaultsResponse{
+		Status:   "OK",
+		Cluster:  cluster,
+		Defaults: s.defaults.Defaults(cluster),
+	})
+}
+
+// UpdateDefaults changes the defaults of a cluster. Fields missing from
+// the body keep their current values
+func (s *Server) UpdateDefaults(w http.ResponseWriter, r *http.Request) {
+	ctx, _, err := s.cluster(r.Context(), r.URL.Query().Get("cluster"))
+	if err != nil {
+		s.logger.ErrorContext(ctx, fmt.Sprintf("update-defaults error: %s", err))
+		respondWithError(w, http.StatusNotFound, ErrorCodeUnknownCluster, err.Error())
+		return
+	}
+	cluster := service.ClusterFrom(ctx)
+	d := s.defaults.Defaults(cluster)
+	if r.Body != nil {
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&d); err != nil {
+			message := fmt.Sprintf("Unable to recognize PUT body: %s", err)
+			s.logger.ErrorContext(ctx, fmt.Sprintf("update-defaults error: %s", message))
+			respondWithError(w, http.StatusBadRequest, ErrorCodeInvalidBody, message)
+			return
+		}
+	}
+
+	op := s.createOperation(ctx, "update_defaults", "")
+	logger := s.logger.With("operation_id", op.ID)
+
+	changes, err := s.defaults.UpdateDefaults(cluster, d)
+	if err != nil {
+		message := err.Error()
+		s.operations.Finish(op.ID, "", err)
+		logger.ErrorContext(ctx, fmt.Sprintf("update-defaults error: %s", message))
+		s.publish(ctx, service.EventUpdateDefaults, "", op.ID, "error", message)
+
+		code, errorCode := http.StatusInternalServerError, ErrorCodeUpdateDefaultsFailed
+		if _, ok := err.(config.ValidationError); ok {
+			code, errorCode = http.StatusBadRequest, ErrorCodeInvalidDefaults
+		}
+		respondWithJSON(w, code, DefaultsResponse{
+			Status:      "NOK",
+			Message:     message,
+			ErrorCode:   errorCode,
+			OperationID: op.ID,
+			Cluster:     cluster,
+			Defaults:    s.defaults.Defaults(cluster),
+		})
+		return
+	}
+
+	changed := make([]string, 0, len(changes))
+	for _, change := range changes {
+		changed = append(changed, change.String())
+		logger.InfoContext(ctx, fmt.Sprintf("Default changed: %s", change), "setting", change.Key)
+	}
+	message := "Defaults did not change"
+	if len(changed) > 0 {
+		message = fmt.Sprintf("Changed defaults: %s", strings.Join(changed, ", "))
+	}
+	logger.InfoContext(ctx, fmt.Sprintf("update-defaults success: %s", message))
+	s.publish(ctx, service.EventUpdateDefaults, "", op.ID, "success", message)
+	s.operations.Finish(op.ID, message, nil)
+	respondWithJSON(w, http.StatusOK, DefaultsResponse{
+		Status:      "OK",
+		Message:     message,
+		OperationID: op.ID,
+		Cluster:     cluster,
+		Changes:     changed,
+		Defaults:    s.defaults.Defaults(cluster),
+	})
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type DefaultsUpdaterStub struct {
+	config  config.Config
+	err     error
+	reloads int
+}
+
+func (u *DefaultsUpdaterStub) ReloadDefaults() error {
+	u.reloads++
+	return u.err
+}
+
+func (u *DefaultsUpdaterStub) Defaults(cluster string) config.Defaults {
+	c, _ := u.config.Cluster(cluster)
+	return c.Defaults()
+}
+
+func (u *DefaultsUpdaterStub) UpdateDefaults(cluster string, d config.Defaults) ([]config.Change, error) {
+	if u.err != nil {
+		return nil, u.err
+	}
+	old, err := u.config.Cluster(cluster)
+	if err != nil {
+		return nil, err
+	}
+	c, err := u.config.WithClusterDefaults(cluster, d)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	new, _ := c.Cluster(cluster)
+	u.config = c
+	return config.Diff(old, new), nil
+}
+
+type DefaultsTestSuite struct {
+	suite.Suite
+	serverFixture
+	u *DefaultsUpdaterStub
+}
+
+func TestDefaultsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DefaultsTestSuite))
+}
+
+func (s *DefaultsTestSuite) SetupTest() {
+	s.u = &DefaultsUpdaterStub{config: config.Default()}
+	s.setUpServer(func(srv *Server) {
+		srv.SetDefaultsUpdater(s.u)
+	})
+}
+
+func (s *DefaultsTestSuite) requestDefaults(method, body string) (*httptest.ResponseRecorder, DefaultsResponse) {
+	return s.requestDefaultsURL(method, "/v1/config/defaults", body)
+}
+
+func (s *DefaultsTestSuite) requestDefaultsURL(method, url, body string) (*httptest.ResponseRecorder, DefaultsResponse) {
+	rec, _ := s.request(method, url, body)
+	var resp DefaultsResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	return rec, resp
+}
+
+func (s *DefaultsTestSuite) Test_GetDefaults() {
+	rec, resp := s.requestDefaults("GET", "")
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("OK", resp.Status)
+	s.Equal("default", resp.Cluster)
+	s.Equal(config.Default().Defaults(), resp.Defaults)
+	s.Contains(rec.Body.String(), `"service":{"min":1,"max":5,"scaleDownBy":1,"scaleUpBy":1}`)
+	s.Contains(rec.Body.String(), `"alertScaleMax":true`)
+}
+
+func (s *DefaultsTestSuite) Test_UpdateDefaults_KeepsMissingFields() {
+	rec, resp := s.requestDefaults("PUT", `{"service": {"max": 20}, "alerts": {"alertScaleMin": true}}`)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal("Changed defaults: alert_scale_min: false -> true, default_max_replicas: 5 -> 20", resp.Message)
+	s.Equal([]string{"alert_scale_min: false -> true", "default_max_replicas: 5 -> 20"}, resp.Changes)
+	s.Equal(uint64(20), resp.Defaults.Service.Max)
+	s.Equal(uint64(1), resp.Defaults.Service.Min)
+	s.Equal(uint64(7), resp.Defaults.Manager.Max)
+	s.Equal(uint64(20), s.u.config.DefaultMaxReplicas)
+
+	op, ok := s.s.operations.Get(resp.OperationID)
+	s.Require().True(ok)
+	s.Equal("update_defaults", op.Kind)
+	s.Equal(service.OperationDone, op.State)
+	s.Contains(s.b.String(), "Default changed: default_max_replicas: 5 -> 20")
+}
+
+func (s *DefaultsTestSuite) Test_UpdateDefaults_NothingChanged() {
+	rec, resp := s.requestDefaults("PUT", `{}`)
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("Defaults did not change", resp.Message)
+	s.Empty(resp.Changes)
+}
+
+func (s *DefaultsTestSuite) Test_UpdateDefaults_Invalid() {
+	rec, resp := s.requestDefaults("PUT", `{"service": {"min": 6}, "manager": {"min": 2}}`)
+	s.Equal(http.StatusBadRequest, rec.Code)
+	s.Equal("NOK", resp.Status)
+	s.Equal(ErrorCodeInvalidDefaults, resp.ErrorCode)
+	s.Contains(resp.Message, "DEFAULT_MIN_REPLICAS (6) is greater than DEFAULT_MAX_REPLICAS (5)")
+	s.Contains(resp.Message, "DEFAULT_MIN_MANAGER_NODES (2) must be odd")
+	s.Equal(config.Default().Defaults(), resp.Defaults)
+	s.Equal(config.Default(), s.u.config)
+
+	op, ok := s.s.operations.Get(resp.OperationID)
+	s.Require().True(ok)
+	s.Equal(service.OperationFailed, op.State)
+}
+
+func (s *DefaultsTestSuite) Test_UpdateDefaults_Failed() {
+	s.u.err = errors.New("Unable to save defaults to /data/defaults.yml")
+	rec, resp := s.requestDefaults("PUT", `{"service": {"max": 20}}`)
+	s.Equal(http.StatusInternalServerError, rec.Code)
+	s.Equal(ErrorCodeUpdateDefaultsFailed, resp.ErrorCode)
+	s.Equal("Unable to save defaults to /data/defaults.yml", resp.Message)
+}
+
+func (s *DefaultsTestSuite) Test_UpdateDefaults_Cluster() {
+	s.u.config.Clusters = map[string]map[string]interface{}{
+		"eu-west": {"docker_manager_hosts": "tcp://eu-west-manager:2376", "default_max_replicas": 10},
+	}
+	s.setUpServer(func(srv *Server) {
+		srv.AddCluster("eu-west", Cluster{
+			ServiceScaler: new(ScalerServicerMock),
+			Rescheduler:   new(ReschedulerServiceMock),
+		})
+		srv.SetDefaultsUpdater(s.u)
+	})
+
+	rec, resp := s.requestDefaultsURL("GET", "/v1/config/defaults?cluster=eu-west", "")
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("eu-west", resp.Cluster)
+	s.Equal(uint64(10), resp.Defaults.Service.Max)
+
+	rec, resp = s.requestDefaultsURL("PUT", "/v1/config/defaults?cluster=eu-west", `{"service": {"max": 20}}`)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal("Changed defaults: default_max_replicas: 10 -> 20", resp.Message)
+	s.Equal(uint64(20), resp.Defaults.Service.Max)
+	s.Equal(uint64(5), s.u.config.DefaultMaxReplicas)
+
+	op, ok := s.s.operations.Get(resp.OperationID)
+	s.Require().True(ok)
+	s.Equal("eu-west", op.Cluster)
+
+	rec, resp = s.requestDefaultsURL("PUT", "/v1/config/defaults?cluster=ap-south", `{"service": {"max": 20}}`)
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Equal(ErrorCodeUnknownCluster, resp.ErrorCode)
+	s.Equal("Unknown cluster: ap-south, cluster can only be default, eu-west", resp.Message)
+}
+
+func (s *DefaultsTestSuite) Test_UpdateDefaults_UnknownField() {
+	rec, _ := s.request("PUT", "/v1/config/defaults", `{"service": {"maximum": 20}}`)
+	s.Equal(http.StatusBadRequest, rec.Code)
+	s.Contains(rec.Body.String(), `"errorCode":"invalid_body"`)
+	s.Contains(rec.Body.String(), `unknown field \"maximum\"`)
+	s.Empty(s.s.operations.List())
+}
+
+func (s *DefaultsTestSuite) Test_ReloadDefaults() {
+	s.s.reloadDefaults()
+	s.Equal(1, s.u.reloads)
+
+	s.u.err = errors.New("Unable to parse defaults file /data/defaults.yml")
+	s.s.reloadDefaults()
+	s.Equal(2, s.u.reloads)
+	s.Contains(s.b.String(), "Unable to reload defaults: Unable to parse defaults file /data/defaults.yml")
+}
+
+func (s *DefaultsTestSuite) Test_Routes_NotServedWithoutUpdater() {
+	s.setUpServer(nil)
+	rec, _ := s.request("GET", "/v1/config/defaults", "")
+	s.Equal(http.StatusNotFound, rec.Code)
+}
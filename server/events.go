@@ -0,0 +1,105 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// eventBufferSize is the number of events buffered for each watcher
+const eventBufferSize = 64
+
+// eventKeepAlive is how often a comment is sent on idle event streams, so
+// proxies do not close them
+var eventKeepAlive = 15 * time.Second
+
+// SetEventBus publishes events to `events`, so services publishing to the
+// same bus are streamed together with the server's events
+func (s *Server) SetEventBus(events *service.EventBus) {
+	s.events = events
+}
+
+// EventsHandler streams events as Server-Sent Events until the client
+// disconnects or docker-scaler shuts down
+func (s *Server) EventsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter, err := eventFilter(q["cluster"], q["service"], q["kind"])
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, ErrorCodeInvalidEventKind, err.Error())
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondWithJSON(w, http.StatusInternalServerError, Response{
+			Status: "NOK", Message: "Streaming is not supported"})
+		return
+	}
+
+	events, unsubscribe := s.events.Subscribe(eventBufferSize, filter)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprint(w, ": connected\n\n")
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case e := <-events:
+			b, err := json.Marshal(e)
+			if err != nil {
+				s.logger.ErrorContext(r.Context(), fmt.Sprintf("events error: %s", err))
+				continue
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Kind, b)
+			flusher.Flush()
+		}
+	}
+}
+
+// eventFilter creates a filter from lists of clusters, services and
+// kinds. Values can also be comma separated
+func eventFilter(clusters, services, kinds []string) (service.EventFilter, error) {
+	filter := service.EventFilter{
+		Clusters: splitValues(clusters),
+		Services: splitValues(services),
+		Kinds:    splitValues(kinds),
+	}
+	for _, kind := range filter.Kinds {
+		if !service.IsEventKind(kind) {
+			return filter, fmt.Errorf("Unknown event kind: %s, kind can only be %s",
+				kind, strings.Join(service.EventKinds, ", "))
+		}
+	}
+	return filter, nil
+}
+
+func splitValues(values []string) []string {
+	var out []string
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			part = strings.TrimSpace(part)
+			if len(part) > 0 {
+				out = append(out, part)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+type EventsTestSuite struct {
+	suite.Suite
+	m   *ScalerServicerMock
+	am  *AlertServicerMock
+	nsm *NodeScalerMock
+	rsm *ReschedulerServiceMock
+	s   *Server
+	ts  *httptest.Server
+	ctx context.Context
+}
+
+func TestEventsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventsTestSuite))
+}
+
+func (s *EventsTestSuite) SetupTest() {
+	s.m = new(ScalerServicerMock)
+	s.am = new(AlertServicerMock)
+	s.nsm = new(NodeScalerMock)
+	s.rsm = new(ReschedulerServiceMock)
+	s.s = NewServer(s.m, s.am, s.nsm, s.rsm, logging.Discard(),
+		false, true, false, true)
+	s.ts = httptest.NewServer(s.s.MakeRouter("/"))
+	s.ctx = context.Background()
+}
+
+func (s *EventsTestSuite) TearDownTest() {
+	s.ts.Close()
+}
+
+func (s *EventsTestSuite) Test_Events_FiltersByServiceAndKind() {
+	s.am.On("Send", "scale_service", mock.Anything, mock.Anything, "success", mock.Anything).Return(nil)
+	s.m.On("Scale", mock.Anything, "db", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling db from 1 to 2 replicas (min: 1, max: 4)"}, nil)
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 1 to 2 replicas (min: 1, max: 4)"}, nil)
+
+	r := s.stream("/v1/events?service=web&kind=reschedule,scale_service")
+	defer r.Close()
+
+	s.post("/v1/scale-service?service=db&scale=up")
+	resp := s.post("/v1/scale-service?service=web&scale=up")
+
+	kind, e := s.readEvent(r)
+	s.Equal(service.EventScaleService, kind)
+	s.Equal("web", e.Service)
+	s.Equal(resp.OperationID, e.OperationID)
+	s.Equal("success", e.Status)
+	s.Equal("Scaling web from 1 to 2 replicas (min: 1, max: 4)", e.Message)
+}
+
+func (s *EventsTestSuite) Test_Events_InvalidKind() {
+	resp, err := http.Get(s.ts.URL + "/v1/events?kind=scale")
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	var r Response
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&r))
+	s.Equal(ErrorCodeInvalidEventKind, r.ErrorCode)
+}
+
+func (s *EventsTestSuite) Test_Events_AlertFailure() {
+	s.am.On("Send", "scale_service", "web", mock.Anything, "success", mock.Anything).
+		Return(errors.New("connection refused"))
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 1 to 2 replicas (min: 1, max: 4)"}, nil)
+
+	r := s.stream("/v1/events?kind=alert_failure")
+	defer r.Close()
+
+	s.post("/v1/scale-service?service=web&scale=up")
+
+	kind, e := s.readEvent(r)
+	s.Equal(service.EventAlertFailure, kind)
+	s.Equal("web", e.Service)
+	s.Equal("error", e.Status)
+	s.Equal("Alertmanager did not receive scale_service alert: connection refused", e.Message)
+}
+
+func (s *EventsTestSuite) Test_Events_RescheduleTick() {
+	s.am.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s.nsm.On("Scale", mock.Anything, uint64(1), service.ScaleUpDirection, cloud.NodeWorkerType, "").
+		Return(uint64(3), uint64(4), nil)
+	waitCalled := make(chan mock.Arguments, 1)
+	s.rsm.On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"), time.Duration(0),
+		mock.Anything, mock.Anything, mock.Anything).Return().Run(func(args mock.Arguments) {
+		waitCalled <- args
+	})
+
+	r := s.stream("/v1/events?kind=reschedule_tick")
+	defer r.Close()
+
+	resp := s.post("/v1/scale-nodes?type=worker&scale=up&by=1")
+	args := <-waitCalled
+	args.Get(4).(chan<- time.Time) <- time.Now().Add(time.Minute)
+
+	kind, e := s.readEvent(r)
+	s.Equal(service.EventRescheduleTick, kind)
+	s.Equal(resp.OperationID, e.OperationID)
+	s.Equal("pending", e.Status)
+	s.Contains(e.Message, "for a total of 4 worker nodes to come online")
+
+	args.Get(6).(chan<- string) <- "4 worker nodes are online"
+	s.s.waits.Wait()
+}
+
+func (s *EventsTestSuite) Test_Events_EndsOnShutdown() {
+	s.rsm.On("Stop").Return()
+	r := s.stream("/v1/events")
+	defer r.Close()
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	s.s.shutdown(ctx, &http.Server{}, nil)
+
+	_, err := r.ReadString('\n')
+	s.Equal(io.EOF, err)
+}
+
+// stream connects to the event stream at `path` and waits until it is
+// subscribed
+func (s *EventsTestSuite) stream(path string) *eventStream {
+	resp, err := http.Get(s.ts.URL + path)
+	s.Require().NoError(err)
+	s.Require().Equal(http.StatusOK, resp.StatusCode)
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	r := &eventStream{Reader: bufio.NewReader(resp.Body), Closer: resp.Body}
+	for _, expected := range []string{": connected\n", "\n"} {
+		line, err := r.ReadString('\n')
+		s.Require().NoError(err)
+		s.Require().Equal(expected, line)
+	}
+	return r
+}
+
+func (s *EventsTestSuite) post(path string) Response {
+	resp, err := http.Post(s.ts.URL+path, "application/json", nil)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	var r Response
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&r))
+	return r
+}
+
+type eventStream struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// readEvent reads the next event from `r`, skipping comments
+func (s *EventsTestSuite) readEvent(r *eventStream) (string, service.Event) {
+	var kind string
+	var e service.Event
+	for {
+		line, err := r.ReadString('\n')
+		s.Require().NoError(err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			kind = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			s.Require().NoError(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+		case len(line) == 0 && len(kind) > 0:
+			return kind, e
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// FreezeResponse returns one freeze to HTTP clients
+type FreezeResponse struct {
+	Status  string         `json:"status"`
+	Message string         `json:"message,omitempty"`
+	Freeze  service.Freeze `json:"freeze"`
+}
+
+// FreezesResponse returns freezes to HTTP clients
+type FreezesResponse struct {
+	Status  string           `json:"status"`
+	Freezes []service.Freeze `json:"freezes"`
+}
+
+// SetFreezes rejects scaling nodes and rescheduling during the freezes in
+// `f`, and serves them on /freezes. The service scalers check the freezes
+// themselves, since they know the labels of the services. It must be
+// called before the router is made
+func (s *Server) SetFreezes(f *service.Freezes) {
+	s.freezes = f
+}
+
+// reloadFreezes reads the freezes saved to STATE_DIR again, so a replica
+// that starts leading uses the freezes the previous leader added
+func (s *Server) reloadFreezes() {
+	if err := s.freezes.Reload(); err != nil {
+		s.logger.Error(fmt.Sprintf("Unable to reload freezes: %s", err))
+	}
+}
+
+// ListFreezes returns the freezes, ordered by start. With `active=true`,
+// only the freezes in effect are returned
+func (s *Server) ListFreezes(w http.ResponseWriter, r *http.Request) {
+	freezes := s.freezes.List()
+	if r.URL.Query().Get("active") == "true" {
+		active := []service.Freeze{}
+		now := time.Now()
+		for _, f := range freezes {
+			if f.Active(now) {
+				active = append(active, f)
+			}
+		}
+		freezes = active
+	}
+	respondWithJSON(w, http.StatusOK, FreezesResponse{Status: "OK", Freezes: freezes})
+}
+
+// AddFreeze adds a freeze, or replaces the freeze with the same name that
+// was added through the api
+func (s *Server) AddFreeze(w http.ResponseWriter, r *http.Request) {
+	var f service.Freeze
+	if r.Body != nil {
+		defer r.Body.Close()
+		dec := json.NewDecoder(r.Body)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&f); err != nil {
+			message := fmt.Sprintf("Unable to recognize POST body: %s", err)
+			s.logger.ErrorContext(r.Context(), fmt.Sprintf("add-freeze error: %s", message))
+			respondWithError(w, http.StatusBadRequest, ErrorCodeInvalidBody, message)
+			return
+		}
+	}
+	if err := f.Validate(); err != nil {
+		s.logger.ErrorContext(r.Context(), fmt.Sprintf("add-freeze error: %s", err))
+		respondWithError(w, http.StatusBadRequest, ErrorCodeInvalidFreeze, err.Error())
+		return
+	}
+	if len(f.Cluster) > 0 {
+		if _, _, err := s.cluster(r.Context(), f.Cluster); err != nil {
+			s.logger.ErrorContext(r.Context(), fmt.Sprintf("add-freeze error: %s", err))
+			respondWithError(w, http.StatusNotFound, ErrorCodeUnknownCluster, err.Error())
+			return
+		}
+	}
+	if s.isConfiguredFreeze(f.Name) {
+		message := fmt.Sprintf("Freeze %s is declared in the config file and can only be changed there", f.Name)
+		s.logger.ErrorContext(r.Context(), fmt.Sprintf("add-freeze error: %s", message))
+		respondWithError(w, http.StatusConflict, ErrorCodeFreezeConfigured, message)
+		return
+	}
+
+	f, err := s.freezes.Add(f)
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), fmt.Sprintf("add-freeze error: %s", err))
+		respondWithError(w, http.StatusInternalServerError, ErrorCodeSaveFreezeFailed, err.Error())
+		return
+	}
+	message := fmt.Sprintf("Added freeze %s of %s from %s to %s", f.Name, f.Scope,
+		f.Start.UTC().Format(time.RFC3339), f.End.UTC().Format(time.RFC3339))
+	s.logger.InfoContext(r.Context(), fmt.Sprintf("add-freeze success: %s", message))
+	s.sendAlert(r.Context(), "freeze", f.Name, "Add freeze", "success", message)
+	respondWithJSON(w, http.StatusOK, FreezeResponse{Status: "OK", Message: message, Freeze: f})
+}
+
+// DeleteFreeze removes a freeze added through the api
+func (s *Server) DeleteFreeze(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	if s.isConfiguredFreeze(name) {
+		message := fmt.Sprintf("Freeze %s is declared in the config file and can only be removed there", name)
+		s.logger.ErrorContext(r.Context(), fmt.Sprintf("delete-freeze error: %s", message))
+		respondWithError(w, http.StatusConflict, ErrorCodeFreezeConfigured, message)
+		return
+	}
+	found, err := s.freezes.Delete(name)
+	if !found {
+		respondWithError(w, http.StatusNotFound, ErrorCodeFreezeNotFound, fmt.Sprintf("Freeze %s does not exist", name))
+		return
+	}
+	if err != nil {
+		s.logger.ErrorContext(r.Context(), fmt.Sprintf("delete-freeze error: %s", err))
+		respondWithError(w, http.StatusInternalServerError, ErrorCodeSaveFreezeFailed, err.Error())
+		return
+	}
+	message := fmt.Sprintf("Removed freeze %s", name)
+	s.logger.InfoContext(r.Context(), fmt.Sprintf("delete-freeze success: %s", message))
+	s.sendAlert(r.Context(), "freeze", name, "Remove freeze", "success", message)
+	respondWithJSON(w, http.StatusOK, Response{Status: "OK", Message: message})
+}
+
+func (s *Server) isConfiguredFreeze(name string) bool {
+	for _, f := range s.freezes.List() {
+		if f.Name == name && f.Source == "config" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type FreezeTestSuite struct {
+	suite.Suite
+	serverFixture
+	f    *service.Freezes
+	path string
+}
+
+func TestFreezeUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(FreezeTestSuite))
+}
+
+func (s *FreezeTestSuite) SetupTest() {
+	var err error
+	s.path = filepath.Join(s.T().TempDir(), "freezes.json")
+	s.f, err = service.OpenFreezes(s.path)
+	s.Require().NoError(err)
+	s.f.SetConfigured([]service.Freeze{{
+		Name: "vendor", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour),
+		Scope: service.FreezeNodes, Policy: service.FreezeAllowScaleUp, Reason: "AWS maintenance",
+	}})
+	s.setUpServer(func(srv *Server) {
+		srv.SetFreezes(s.f)
+	})
+}
+
+func (s *FreezeTestSuite) Test_ScaleNodes_Frozen() {
+	rec, resp := s.request("POST", "/v1/scale-nodes?scale=down&type=worker", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeFrozen, resp.ErrorCode)
+	s.Contains(resp.Message, "Rejected by freeze vendor until ")
+	s.Contains(resp.Message, ": AWS maintenance")
+	s.nsm.AssertNotCalled(s.T(), "Scale", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	s.am.AssertCalled(s.T(), "Send", "scale_nodes", "mock", "Scale nodes down on: mock, by: 0, type: worker", "error", resp.Message)
+
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal(resp.Message, op.Error)
+}
+
+func (s *FreezeTestSuite) Test_ScaleNodes_AllowsScaleUp() {
+	s.nsm.On("Scale", mock.Anything, uint64(0), service.ScaleUpDirection, mock.Anything, "").
+		Return(uint64(2), uint64(2), nil)
+	s.rsm.On("IsWaitingToReschedule").Return(false)
+	rec, _ := s.request("POST", "/v1/scale-nodes?scale=up&type=worker", "")
+	s.Equal(http.StatusOK, rec.Code)
+	s.nsm.AssertExpectations(s.T())
+}
+
+func (s *FreezeTestSuite) Test_Reschedule_Frozen() {
+	rec, resp := s.request("POST", "/v1/reschedule-services", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeFrozen, resp.ErrorCode)
+
+	rec, resp = s.request("POST", "/v1/reschedule-service?service=web", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeFrozen, resp.ErrorCode)
+	s.Equal("web", resp.Service)
+	s.rsm.AssertNotCalled(s.T(), "RescheduleAll", mock.Anything)
+}
+
+func (s *FreezeTestSuite) Test_RescheduleService_FrozenService() {
+	s.f.SetConfigured(nil)
+	frozen := &service.FrozenError{Freeze: service.Freeze{Name: "db", End: time.Now()}}
+	s.rsm.On("RescheduleService", "web", mock.AnythingOfType("string")).Return(frozen)
+	rec, resp := s.request("POST", "/v1/reschedule-service?service=web", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeFrozen, resp.ErrorCode)
+	s.Equal(frozen.Error(), resp.Message)
+}
+
+func (s *FreezeTestSuite) Test_ScaleService_Frozen() {
+	frozen := &service.FrozenError{Freeze: service.Freeze{Name: "db", End: time.Now()}}
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleDownDirection).
+		Return(service.ScaleResult{}, frozen)
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=down", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeFrozen, resp.ErrorCode)
+	s.Equal(frozen.Error(), resp.Message)
+}
+
+func (s *FreezeTestSuite) Test_AddListDeleteFreeze() {
+	start := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	body := `{"name": "migration", "start": "` + start.Format(time.RFC3339) + `", "end": "` +
+		start.Add(time.Hour).Format(time.RFC3339) + `", "scope": "services", "selector": "com.df.tier=db"}`
+	rec, _ := s.request("POST", "/v1/freezes", body)
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	var added FreezeResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &added))
+	s.Equal("api", added.Freeze.Source)
+	s.Equal(service.FreezeReject, added.Freeze.Policy)
+
+	rec, _ = s.request("GET", "/v1/freezes", "")
+	var list FreezesResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &list))
+	s.Require().Len(list.Freezes, 2)
+	s.Equal("vendor", list.Freezes[0].Name)
+	s.Equal("migration", list.Freezes[1].Name)
+
+	rec, _ = s.request("GET", "/v1/freezes?active=true", "")
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &list))
+	s.Require().Len(list.Freezes, 1)
+	s.Equal("vendor", list.Freezes[0].Name)
+
+	rec, resp := s.request("DELETE", "/v1/freezes/migration", "")
+	s.Equal(http.StatusOK, rec.Code)
+	s.Equal("Removed freeze migration", resp.Message)
+	rec, resp = s.request("DELETE", "/v1/freezes/migration", "")
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Equal(ErrorCodeFreezeNotFound, resp.ErrorCode)
+}
+
+func (s *FreezeTestSuite) Test_AddFreeze_Invalid() {
+	rec, resp := s.request("POST", "/v1/freezes", `{"name": "x", "scope": "all"}`)
+	s.Equal(http.StatusBadRequest, rec.Code)
+	s.Equal(ErrorCodeInvalidFreeze, resp.ErrorCode)
+	s.Equal("Freeze x needs a start and an end", resp.Message)
+
+	rec, resp = s.request("POST", "/v1/freezes", `{"name": "x", "owner": "me"}`)
+	s.Equal(http.StatusBadRequest, rec.Code)
+	s.Equal(ErrorCodeInvalidBody, resp.ErrorCode)
+
+	rec, resp = s.request("POST", "/v1/freezes",
+		`{"name": "x", "start": "2026-10-20T22:00:00Z", "end": "2026-10-21T22:00:00Z", "cluster": "eu-west"}`)
+	s.Equal(http.StatusNotFound, rec.Code)
+	s.Equal(ErrorCodeUnknownCluster, resp.ErrorCode)
+}
+
+func (s *FreezeTestSuite) Test_ConfiguredFreeze_CanNotChange() {
+	rec, resp := s.request("POST", "/v1/freezes",
+		`{"name": "vendor", "start": "2026-10-20T22:00:00Z", "end": "2026-10-21T22:00:00Z"}`)
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeFreezeConfigured, resp.ErrorCode)
+
+	rec, resp = s.request("DELETE", "/v1/freezes/vendor", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal("Freeze vendor is declared in the config file and can only be removed there", resp.Message)
+}
+
+func (s *FreezeTestSuite) Test_ReloadFreezes() {
+	leader, err := service.OpenFreezes(s.path)
+	s.Require().NoError(err)
+	_, err = leader.Add(service.Freeze{Name: "migration",
+		Start: time.Now().Add(time.Hour), End: time.Now().Add(2 * time.Hour)})
+	s.Require().NoError(err)
+
+	s.s.reloadFreezes()
+	rec, _ := s.request("GET", "/v1/freezes", "")
+	var resp FreezesResponse
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	s.Require().Len(resp.Freezes, 2)
+	s.Equal("migration", resp.Freezes[1].Name)
+}
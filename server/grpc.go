@@ -0,0 +1,209 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/thomasjpfan/docker-scaler/server/handler"
+	"github.com/thomasjpfan/docker-scaler/server/scalerpb"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/tracing"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// grpcServer serves the gRPC api with the same logic as the REST api
+type grpcServer struct {
+	scalerpb.UnimplementedScalerServer
+	s *Server
+}
+
+// SetGRPCPort serves the gRPC api on `port` when Run is called. The gRPC
+// api is disabled when `port` is 0
+func (s *Server) SetGRPCPort(port uint16) {
+	s.grpcPort = port
+}
+
+// NewGRPCServer creates a gRPC server for the Scaler service
+func (s *Server) NewGRPCServer() *grpc.Server {
+	gs := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			handler.RequestIDUnaryInterceptor,
+			tracing.UnaryServerInterceptor,
+			handler.RecoveryUnaryInterceptor(s.logger),
+			s.leaderUnaryInterceptor),
+		grpc.ChainStreamInterceptor(
+			handler.RequestIDStreamInterceptor,
+			tracing.StreamServerInterceptor,
+			handler.RecoveryStreamInterceptor(s.logger),
+			s.leaderStreamInterceptor),
+	)
+	scalerpb.RegisterScalerServer(gs, &grpcServer{s: s})
+	return gs
+}
+
+func (g *grpcServer) ScaleService(ctx context.Context, req *scalerpb.ScaleServiceRequest) (*scalerpb.ScaleServiceResponse, error) {
+	resp, code := g.s.scaleService(ctx, req.GetCluster(), req.GetService(),
+		directionString(req.GetDirection()), req.GetBy())
+	// Changes awaiting approval, or queued until an update completes, are
+	// accepted
+	if code != http.StatusOK && code != http.StatusAccepted {
+		return nil, grpcError(ctx, code, resp)
+	}
+	return &scalerpb.ScaleServiceResponse{
+		Message:     resp.Message,
+		OperationId: resp.OperationID,
+		Previous:    uint64Value(resp.Previous),
+		Current:     uint64Value(resp.Current),
+		Min:         uint64Value(resp.Min),
+		Max:         uint64Value(resp.Max),
+		AtBound:     resp.AtBound != nil && *resp.AtBound,
+	}, nil
+}
+
+func (g *grpcServer) ScaleNodes(ctx context.Context, req *scalerpb.ScaleNodesRequest) (*scalerpb.ScaleNodesResponse, error) {
+	if !g.s.scalesNodes() {
+		return nil, status.Error(codes.Unimplemented, "Node scaling is not configured")
+	}
+	resp, code := g.s.scaleNodes(ctx, req.GetCluster(), req.GetService(), directionString(req.GetDirection()),
+		req.GetBy(), nodeTypeString(req.GetNodeType()))
+	if code != http.StatusOK && code != http.StatusAccepted {
+		return nil, grpcError(ctx, code, resp)
+	}
+	return &scalerpb.ScaleNodesResponse{
+		Message:     resp.Message,
+		OperationId: resp.OperationID,
+		NodesBefore: uint64Value(resp.NodesBefore),
+		NodesAfter:  uint64Value(resp.NodesAfter),
+		AtBound:     resp.AtBound != nil && *resp.AtBound,
+	}, nil
+}
+
+func (g *grpcServer) Reschedule(ctx context.Context, req *scalerpb.RescheduleRequest) (*scalerpb.RescheduleResponse, error) {
+	var resp Response
+	var code int
+	if len(req.GetService()) == 0 {
+		resp, code = g.s.rescheduleAll(ctx, req.GetCluster())
+	} else {
+		resp, code = g.s.rescheduleService(ctx, req.GetCluster(), req.GetService())
+	}
+	if code != http.StatusOK {
+		return nil, grpcError(ctx, code, resp)
+	}
+	return &scalerpb.RescheduleResponse{
+		Message:     resp.Message,
+		OperationId: resp.OperationID,
+	}, nil
+}
+
+func (g *grpcServer) WatchEvents(req *scalerpb.WatchEventsRequest, stream scalerpb.Scaler_WatchEventsServer) error {
+	filter, err := eventFilter(req.GetClusters(), req.GetServices(), req.GetKinds())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	events, unsubscribe := g.s.events.Subscribe(eventBufferSize, filter)
+	defer unsubscribe()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-g.s.done:
+			return status.Error(codes.Unavailable, "docker-scaler is shutting down")
+		case e := <-events:
+			err := stream.Send(eventToProto(e))
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// stopGRPC drains in-flight gRPC requests, and stops the server when
+// `ctx` is done first
+func stopGRPC(ctx context.Context, gs *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		gs.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		gs.Stop()
+	}
+}
+
+// grpcError converts an error response into a gRPC status. The error code
+// and operation id are sent as trailers
+func grpcError(ctx context.Context, code int, resp Response) error {
+	trailer := metadata.Pairs("error-code", resp.ErrorCode)
+	if len(resp.OperationID) > 0 {
+		trailer.Append("operation-id", resp.OperationID)
+	}
+	grpc.SetTrailer(ctx, trailer)
+
+	var c codes.Code
+	switch code {
+	case http.StatusBadRequest:
+		c = codes.InvalidArgument
+	case http.StatusNotFound:
+		c = codes.NotFound
+	case http.StatusConflict:
+		c = codes.FailedPrecondition
+	case http.StatusInternalServerError:
+		c = codes.Internal
+	case http.StatusServiceUnavailable:
+		c = codes.Unavailable
+	default:
+		c = codes.Unknown
+	}
+	return status.Error(c, resp.Message)
+}
+
+func directionString(d scalerpb.Direction) string {
+	switch d {
+	case scalerpb.Direction_DIRECTION_UNSPECIFIED:
+		return ""
+	case scalerpb.Direction_DIRECTION_UP:
+		return "up"
+	case scalerpb.Direction_DIRECTION_DOWN:
+		return "down"
+	}
+	return d.String()
+}
+
+func nodeTypeString(t scalerpb.NodeType) string {
+	switch t {
+	case scalerpb.NodeType_NODE_TYPE_UNSPECIFIED:
+		return ""
+	case scalerpb.NodeType_NODE_TYPE_MANAGER:
+		return "manager"
+	case scalerpb.NodeType_NODE_TYPE_WORKER:
+		return "worker"
+	}
+	return t.String()
+}
+
+func eventToProto(e service.Event) *scalerpb.Event {
+	return &scalerpb.Event{
+		Time:        timestamppb.New(e.Time),
+		Kind:        e.Kind,
+		Cluster:     e.Cluster,
+		Service:     e.Service,
+		OperationId: e.OperationID,
+		RequestId:   e.RequestID,
+		Status:      e.Status,
+		Message:     e.Message,
+	}
+}
+
+func uint64Value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
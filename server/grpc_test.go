@@ -0,0 +1,267 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/server/scalerpb"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type GRPCTestSuite struct {
+	suite.Suite
+	m    *ScalerServicerMock
+	am   *AlertServicerMock
+	nsm  *NodeScalerMock
+	rsm  *ReschedulerServiceMock
+	s    *Server
+	gs   *grpc.Server
+	conn *grpc.ClientConn
+	c    scalerpb.ScalerClient
+	b    *bytes.Buffer
+	ctx  context.Context
+}
+
+func TestGRPCUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(GRPCTestSuite))
+}
+
+func (s *GRPCTestSuite) SetupTest() {
+	s.m = new(ScalerServicerMock)
+	s.am = new(AlertServicerMock)
+	s.nsm = new(NodeScalerMock)
+	s.rsm = new(ReschedulerServiceMock)
+	s.b = new(bytes.Buffer)
+	s.s = NewServer(s.m, s.am, s.nsm, s.rsm, newMessageLogger(s.b),
+		false, true, false, true)
+	s.connect(s.s)
+	s.ctx = context.Background()
+}
+
+func (s *GRPCTestSuite) connect(srv *Server) {
+	lis := bufconn.Listen(1024 * 1024)
+	s.gs = srv.NewGRPCServer()
+	go s.gs.Serve(lis)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	s.Require().NoError(err)
+	s.conn = conn
+	s.c = scalerpb.NewScalerClient(conn)
+}
+
+func (s *GRPCTestSuite) TearDownTest() {
+	s.conn.Close()
+	s.gs.Stop()
+}
+
+func (s *GRPCTestSuite) Test_ScaleService() {
+	expMsg := "Scaling web from 2 to 3 replicas (min: 1, max: 4)"
+	s.am.On("Send", "scale_service", "web", "Scale service up: web", "success", expMsg).Return(nil)
+	s.m.On("Scale", mock.Anything, "web", uint64(1), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: expMsg, Previous: 2, Current: 3, Min: 1, Max: 4}, nil)
+
+	resp, err := s.c.ScaleService(s.ctx, &scalerpb.ScaleServiceRequest{
+		Service: "web", Direction: scalerpb.Direction_DIRECTION_UP, By: 1})
+	s.Require().NoError(err)
+	s.Equal(expMsg, resp.Message)
+	s.NotEmpty(resp.OperationId)
+	s.Equal(uint64(2), resp.Previous)
+	s.Equal(uint64(3), resp.Current)
+	s.Equal(uint64(4), resp.Max)
+	s.False(resp.AtBound)
+	s.RequireLogs(s.b.String(), "Scale service up: web", "scale-service success: "+expMsg)
+	s.am.AssertExpectations(s.T())
+
+	op, ok := s.s.operations.Get(resp.OperationId)
+	s.Require().True(ok)
+	s.Equal(service.OperationDone, op.State)
+}
+
+func (s *GRPCTestSuite) Test_ScaleService_NoDirection() {
+	s.am.On("Send", "scale_service", "bad_request", "Incorrect request", "error", "No scale direction in request").Return(nil)
+
+	var trailer metadata.MD
+	_, err := s.c.ScaleService(s.ctx, &scalerpb.ScaleServiceRequest{Service: "web"}, grpc.Trailer(&trailer))
+	s.Equal(codes.InvalidArgument, status.Code(err))
+	s.Equal("No scale direction in request", status.Convert(err).Message())
+	s.Equal([]string{ErrorCodeMissingDirection}, trailer.Get("error-code"))
+	s.am.AssertExpectations(s.T())
+}
+
+func (s *GRPCTestSuite) Test_ScaleService_Error() {
+	expErr := errors.New("Unable to scale service: web")
+	s.am.On("Send", "scale_service", "web", "Scale service down: web", "error", expErr.Error()).Return(nil)
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleDownDirection).
+		Return(service.ScaleResult{}, expErr)
+
+	var trailer metadata.MD
+	_, err := s.c.ScaleService(s.ctx, &scalerpb.ScaleServiceRequest{
+		Service: "web", Direction: scalerpb.Direction_DIRECTION_DOWN}, grpc.Trailer(&trailer))
+	s.Equal(codes.Internal, status.Code(err))
+	s.Equal([]string{ErrorCodeScaleFailed}, trailer.Get("error-code"))
+	s.Len(trailer.Get("operation-id"), 1)
+	s.am.AssertExpectations(s.T())
+}
+
+func (s *GRPCTestSuite) Test_ScaleNodes() {
+	message := "Changing the number of manager nodes on mock from 3 to 2"
+	s.am.On("Send", "scale_nodes", "mock", "Scale nodes down on: mock, by: 1, type: manager", "success", message).Return(nil)
+	s.nsm.On("Scale", mock.Anything, uint64(1), service.ScaleDownDirection, cloud.NodeManagerType, "").
+		Return(uint64(3), uint64(2), nil)
+	s.rsm.On("IsWaitingToReschedule").Return(false)
+
+	resp, err := s.c.ScaleNodes(s.ctx, &scalerpb.ScaleNodesRequest{
+		NodeType: scalerpb.NodeType_NODE_TYPE_MANAGER, Direction: scalerpb.Direction_DIRECTION_DOWN, By: 1})
+	s.Require().NoError(err)
+	s.Equal(message, resp.Message)
+	s.Equal(uint64(3), resp.NodesBefore)
+	s.Equal(uint64(2), resp.NodesAfter)
+	s.nsm.AssertExpectations(s.T())
+	s.am.AssertExpectations(s.T())
+}
+
+func (s *GRPCTestSuite) Test_ScaleNodes_NoNodeType() {
+	s.am.On("Send", "scale_nodes", "mock", "Incorrect request", "error", mock.Anything).Return(nil)
+
+	_, err := s.c.ScaleNodes(s.ctx, &scalerpb.ScaleNodesRequest{
+		Direction: scalerpb.Direction_DIRECTION_UP})
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
+
+func (s *GRPCTestSuite) Test_ScaleNodes_NotConfigured() {
+	s.TearDownTest()
+	s.connect(NewServer(s.m, s.am, nil, s.rsm, newMessageLogger(s.b),
+		false, true, false, true))
+
+	_, err := s.c.ScaleNodes(s.ctx, &scalerpb.ScaleNodesRequest{
+		NodeType: scalerpb.NodeType_NODE_TYPE_WORKER, Direction: scalerpb.Direction_DIRECTION_UP})
+	s.Equal(codes.Unimplemented, status.Code(err))
+}
+
+func (s *GRPCTestSuite) Test_Reschedule() {
+	s.am.On("Send", "reschedule_service", "reschedule", mock.Anything, "success", mock.Anything).Return(nil)
+	s.rsm.On("RescheduleAll", mock.AnythingOfType("string")).Return("Rescheduled all services", nil)
+	s.rsm.On("RescheduleService", "web", mock.AnythingOfType("string")).Return(nil)
+
+	resp, err := s.c.Reschedule(s.ctx, &scalerpb.RescheduleRequest{})
+	s.Require().NoError(err)
+	s.Equal("Rescheduled all services", resp.Message)
+
+	resp, err = s.c.Reschedule(s.ctx, &scalerpb.RescheduleRequest{Service: "web"})
+	s.Require().NoError(err)
+	s.Equal("Rescheduled service: web", resp.Message)
+	s.rsm.AssertExpectations(s.T())
+}
+
+func (s *GRPCTestSuite) Test_Reschedule_Cluster() {
+	ersm := new(ReschedulerServiceMock)
+	srv := NewServer(s.m, s.am, s.nsm, s.rsm, newMessageLogger(s.b),
+		false, true, false, true)
+	srv.AddCluster("eu-west", Cluster{ServiceScaler: new(ScalerServicerMock), Rescheduler: ersm})
+	s.TearDownTest()
+	s.s = srv
+	s.connect(srv)
+
+	s.am.On("Send", "reschedule_service", "reschedule", mock.Anything, "success", mock.Anything).Return(nil)
+	ersm.On("RescheduleService", "web", mock.AnythingOfType("string")).Return(nil)
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	stream, err := s.c.WatchEvents(ctx, &scalerpb.WatchEventsRequest{Clusters: []string{"eu-west"}})
+	s.Require().NoError(err)
+	s.waitForSubscriber()
+
+	resp, err := s.c.Reschedule(s.ctx, &scalerpb.RescheduleRequest{Service: "web", Cluster: "eu-west"})
+	s.Require().NoError(err)
+	ersm.AssertExpectations(s.T())
+
+	e, err := stream.Recv()
+	s.Require().NoError(err)
+	s.Equal("eu-west", e.Cluster)
+	s.Equal(resp.OperationId, e.OperationId)
+
+	var trailer metadata.MD
+	_, err = s.c.Reschedule(s.ctx, &scalerpb.RescheduleRequest{Cluster: "ap-south"}, grpc.Trailer(&trailer))
+	s.Equal(codes.NotFound, status.Code(err))
+	s.Equal([]string{ErrorCodeUnknownCluster}, trailer.Get("error-code"))
+}
+
+func (s *GRPCTestSuite) Test_WatchEvents() {
+	s.am.On("Send", "reschedule_service", "reschedule", mock.Anything, "success", mock.Anything).Return(nil)
+	s.rsm.On("RescheduleService", "web", mock.AnythingOfType("string")).Return(nil)
+
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	stream, err := s.c.WatchEvents(ctx, &scalerpb.WatchEventsRequest{})
+	s.Require().NoError(err)
+	s.waitForSubscriber()
+
+	resp, err := s.c.Reschedule(s.ctx, &scalerpb.RescheduleRequest{Service: "web"})
+	s.Require().NoError(err)
+
+	e, err := stream.Recv()
+	s.Require().NoError(err)
+	s.Equal(service.EventReschedule, e.Kind)
+	s.Equal("web", e.Service)
+	s.Equal(resp.OperationId, e.OperationId)
+	s.Equal("success", e.Status)
+	s.Equal("Rescheduled service: web", e.Message)
+	s.NotNil(e.Time)
+}
+
+func (s *GRPCTestSuite) Test_WatchEvents_EndsOnShutdown() {
+	s.rsm.On("Stop").Return()
+	ctx, cancel := context.WithTimeout(s.ctx, 5*time.Second)
+	defer cancel()
+	stream, err := s.c.WatchEvents(ctx, &scalerpb.WatchEventsRequest{})
+	s.Require().NoError(err)
+	s.waitForSubscriber()
+
+	s.s.shutdown(ctx, &http.Server{}, s.gs)
+
+	_, err = stream.Recv()
+	s.Equal(codes.Unavailable, status.Code(err))
+}
+
+// waitForSubscriber waits until a WatchEvents stream subscribed to events
+func (s *GRPCTestSuite) waitForSubscriber() {
+	for i := 0; i < 100; i++ {
+		if s.s.events.Subscribers() > 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.FailNow("WatchEvents did not subscribe")
+}
+
+func (s *GRPCTestSuite) RequireLogs(logs string, expectedLogs ...string) {
+	for _, l := range expectedLogs {
+		s.Contains(logs, l)
+	}
+}
+
+func (s *GRPCTestSuite) Test_WatchEvents_InvalidKind() {
+	stream, err := s.c.WatchEvents(s.ctx, &scalerpb.WatchEventsRequest{Kinds: []string{"scale"}})
+	s.Require().NoError(err)
+
+	_, err = stream.Recv()
+	s.Equal(codes.InvalidArgument, status.Code(err))
+}
@@ -1,20 +1,25 @@
 package handler
 
 import (
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type recoveryHandler struct {
 	handler http.Handler
-	logger  *log.Logger
+	logger  *slog.Logger
 }
 
 func (h recoveryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	defer func() {
 		if err := recover(); err != nil {
 			w.WriteHeader(http.StatusInternalServerError)
-			h.logger.Printf("Internal error: %v", err)
+			h.logger.ErrorContext(req.Context(), "Internal error", "error", err)
 		}
 	}()
 
@@ -23,9 +28,37 @@ func (h recoveryHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 // RecoveryHandler is a HTTP middleware that recovers from a panic,
 // logs the panic, and writes http.StatusInternalServerError
-func RecoveryHandler(logger *log.Logger) func(h http.Handler) http.Handler {
+func RecoveryHandler(logger *slog.Logger) func(h http.Handler) http.Handler {
 	return func(h http.Handler) http.Handler {
 		r := &recoveryHandler{handler: h, logger: logger}
 		return r
 	}
 }
+
+// RecoveryUnaryInterceptor is a gRPC interceptor that recovers from a
+// panic, logs the panic, and returns codes.Internal
+func RecoveryUnaryInterceptor(logger *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorContext(ctx, "Internal error", "error", r)
+				err = status.Error(codes.Internal, "Internal error")
+			}
+		}()
+		return handler(ctx, req)
+	}
+}
+
+// RecoveryStreamInterceptor is a gRPC stream interceptor that recovers
+// from a panic, logs the panic, and returns codes.Internal
+func RecoveryStreamInterceptor(logger *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.ErrorContext(ss.Context(), "Internal error", "error", r)
+				err = status.Error(codes.Internal, "Internal error")
+			}
+		}()
+		return handler(srv, ss)
+	}
+}
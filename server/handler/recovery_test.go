@@ -2,16 +2,21 @@ package handler
 
 import (
 	"bytes"
-	"log"
+	"context"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 func TestRecoveryLoggerUnitTest(t *testing.T) {
 	var buf bytes.Buffer
-	logger := log.New(&buf, "", log.LstdFlags)
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
 	errorMsg := "Unexpected error!"
 
 	handler := RecoveryHandler(logger)
@@ -28,3 +33,22 @@ func TestRecoveryLoggerUnitTest(t *testing.T) {
 	}
 
 }
+
+func TestRecoveryUnaryInterceptorUnitTest(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	errorMsg := "Unexpected error!"
+
+	interceptor := RecoveryUnaryInterceptor(logger)
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic(errorMsg)
+	}
+
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{}, handler)
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("Got error %v, wanted code %s", err, codes.Internal)
+	}
+	if !strings.Contains(buf.String(), errorMsg) {
+		t.Fatalf("Got log %#v, wanted substring %#v", buf.String(), errorMsg)
+	}
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// RequestIDHeader is the header carrying the id of a request
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDMetadata is the gRPC metadata key carrying the id of a request
+const requestIDMetadata = "x-request-id"
+
+// maxRequestIDLength is the longest request id accepted from clients
+const maxRequestIDLength = 128
+
+// RequestIDHandler is a HTTP middleware that adds the id of the request
+// to its context and to the X-Request-ID response header. The id is taken
+// from the X-Request-ID request header, or generated when it is missing
+// or invalid
+func RequestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id := requestID(req.Header.Get(RequestIDHeader))
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, req.WithContext(logging.WithRequestID(req.Context(), id)))
+	})
+}
+
+// RequestIDUnaryInterceptor is a gRPC interceptor that adds the id of the
+// request to its context and to the x-request-id response header
+func RequestIDUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	return handler(grpcRequestID(ctx), req)
+}
+
+// RequestIDStreamInterceptor is a gRPC stream interceptor that adds the id
+// of the request to its context and to the x-request-id response header
+func RequestIDStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return handler(srv, &contextStream{ServerStream: ss, ctx: grpcRequestID(ss.Context())})
+}
+
+// contextStream replaces the context of a gRPC stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+func grpcRequestID(ctx context.Context) context.Context {
+	var clientID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(requestIDMetadata); len(values) > 0 {
+			clientID = values[0]
+		}
+	}
+	id := requestID(clientID)
+	grpc.SetHeader(ctx, metadata.Pairs(requestIDMetadata, id))
+	return logging.WithRequestID(ctx, id)
+}
+
+// requestID returns `id` when it is a valid request id, otherwise a new id
+// is generated
+func requestID(id string) string {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return logging.NewRequestID()
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return logging.NewRequestID()
+		}
+	}
+	return id
+}
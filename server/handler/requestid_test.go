@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func serveRequestID(header string) (string, string) {
+	var ctxID string
+	h := RequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ctxID = logging.RequestID(req.Context())
+	}))
+	req, _ := http.NewRequest("GET", "/hello", nil)
+	if len(header) > 0 {
+		req.Header.Set(RequestIDHeader, header)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return ctxID, rec.Header().Get(RequestIDHeader)
+}
+
+func TestRequestIDHandler_FromHeaderUnitTest(t *testing.T) {
+	ctxID, respID := serveRequestID("req-1")
+	if ctxID != "req-1" || respID != "req-1" {
+		t.Fatalf("Got ids %#v and %#v, wanted %#v", ctxID, respID, "req-1")
+	}
+}
+
+func TestRequestIDHandler_GeneratedUnitTest(t *testing.T) {
+	for _, header := range []string{"", "has space", strings.Repeat("a", 129)} {
+		ctxID, respID := serveRequestID(header)
+		if len(ctxID) == 0 || ctxID == header || ctxID != respID {
+			t.Fatalf("Got ids %#v and %#v for header %#v, wanted a generated id", ctxID, respID, header)
+		}
+	}
+}
+
+func TestRequestIDUnaryInterceptorUnitTest(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(),
+		metadata.Pairs("x-request-id", "req-1"))
+	var ctxID string
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		ctxID = logging.RequestID(ctx)
+		return nil, nil
+	}
+
+	RequestIDUnaryInterceptor(ctx, nil, &grpc.UnaryServerInfo{}, handler)
+	if ctxID != "req-1" {
+		t.Fatalf("Got id %#v, wanted %#v", ctxID, "req-1")
+	}
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+const healthCheckTimeout = 10 * time.Second
+
+// HealthCheckResult is the result of checking one dependency
+type HealthCheckResult struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// HealthResponse is returned by the readiness check
+type HealthResponse struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// SetHealthCheckers sets the dependencies checked by the readiness check
+func (s *Server) SetHealthCheckers(checkers ...service.HealthChecker) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.healthCheckers = checkers
+}
+
+// LiveHandler sends StatusOK while the server is able to handle requests
+func (s *Server) LiveHandler(w http.ResponseWriter, r *http.Request) {
+	respondWithJSON(w, http.StatusOK, Response{Status: "OK", Message: "live"})
+}
+
+// ReadyHandler checks every dependency and sends StatusOK only when
+// all of them are reachable
+func (s *Server) ReadyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), healthCheckTimeout)
+	defer cancel()
+
+	results := s.checkHealth(ctx)
+
+	code := http.StatusOK
+	resp := HealthResponse{Status: "OK", Checks: results}
+	for name, result := range results {
+		if result.Status != "OK" {
+			code = http.StatusServiceUnavailable
+			resp.Status = "NOK"
+			s.logger.WarnContext(ctx, fmt.Sprintf("health-ready error: %s: %s", name, result.Message), "check", name)
+		}
+	}
+	respondWithJSON(w, code, resp)
+}
+
+func (s *Server) checkHealth(ctx context.Context) map[string]HealthCheckResult {
+	results := map[string]HealthCheckResult{}
+	var mux sync.Mutex
+	var wg sync.WaitGroup
+
+	s.mu.RLock()
+	checkers := s.healthCheckers
+	s.mu.RUnlock()
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker service.HealthChecker) {
+			defer wg.Done()
+			result := HealthCheckResult{Status: "OK"}
+			if err := checker.Check(ctx); err != nil {
+				result = HealthCheckResult{Status: "NOK", Message: err.Error()}
+			}
+			mux.Lock()
+			results[checker.Name()] = result
+			mux.Unlock()
+		}(checker)
+	}
+	wg.Wait()
+	return results
+}
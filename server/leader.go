@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/metrics"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// forwardedHeader marks requests forwarded by a follower, so a replica
+// that lost the lease in the meantime does not forward them again
+const forwardedHeader = "X-Docker-Scaler-Forwarded"
+
+// SetElector elects a leader among the replicas of docker-scaler while the
+// server runs. Only the leader scales and reschedules. The other replicas
+// forward these requests to the leader. It must be called before the
+// router is made
+func (s *Server) SetElector(e *service.Elector) {
+	s.elector = e
+}
+
+// isLeader returns true when this replica may scale, which is always the
+// case without an elector
+func (s *Server) isLeader() bool {
+	return s.elector == nil || s.elector.IsLeader()
+}
+
+// runElector campaigns until the returned function is called, which
+// resigns the lease
+func (s *Server) runElector() func() {
+	if s.elector == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.elector.Run(ctx, s.leadershipChanged, func(err error) {
+			s.logger.Warn(fmt.Sprintf("Unable to reach the leader lock: %s", err))
+		})
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// leadershipChanged is called when this replica starts or stops leading.
+// A replica that starts leading reads the defaults, the freezes and the
+// decided replicas again, and resumes the operations its previous run left
+// unfinished. A replica that stops leading fails its queued scales and
+// cancels its reschedules waiting for nodes, since another replica scales
+// from now on
+func (s *Server) leadershipChanged(leading bool) {
+	metrics.SetLeader(leading)
+	if leading {
+		s.logger.Info(fmt.Sprintf("Leading as %s", s.elector.ID()))
+		s.reloadDefaults()
+		s.reloadFreezes()
+		s.reloadReplicas()
+		s.resumeOperations()
+		return
+	}
+	s.logger.Warn(fmt.Sprintf("Stopped leading as %s", s.elector.ID()))
+	s.failQueuedScales()
+
+	for _, op := range s.operations.List() {
+		if op.Finished() || len(op.RescheduleKey) == 0 {
+			continue
+		}
+		_, c, err := s.cluster(context.Background(), op.Cluster)
+		if err != nil || !c.Rescheduler.CancelWait(op.RescheduleKey) {
+			continue
+		}
+		message := "Stopped waiting for nodes, since this replica is no longer the leader"
+		s.operations.AddResult(op.ID, "error", message)
+		s.logger.Warn(message, "operation_id", op.ID)
+	}
+}
+
+// leaderOnly runs `h`, which changes the state of a cluster, on the leader.
+// Followers forward the request to the leader, or respond with 503 when the
+// leader is not known. Reads are served by every replica without it
+func (s *Server) leaderOnly(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.isLeader() {
+			h(w, r)
+			return
+		}
+		leader := s.elector.Leader()
+		if len(leader) == 0 || len(r.Header.Get(forwardedHeader)) > 0 {
+			s.logger.WarnContext(r.Context(), fmt.Sprintf("%s %s error: this replica is not the leader", r.Method, r.URL.Path))
+			respondWithError(w, http.StatusServiceUnavailable, ErrorCodeNotLeader, notLeaderMessage(leader))
+			return
+		}
+		s.forward(w, r, leader)
+	}
+}
+
+// forward proxies `r` to the `leader`, which is the url the leader
+// advertises
+func (s *Server) forward(w http.ResponseWriter, r *http.Request, leader string) {
+	target, err := url.Parse(leader)
+	if err != nil {
+		respondWithError(w, http.StatusServiceUnavailable, ErrorCodeNotLeader,
+			fmt.Sprintf("Unable to forward request to the leader at %s: %s", leader, err))
+		return
+	}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	director := proxy.Director
+	proxy.Director = func(req *http.Request) {
+		director(req)
+		req.Header.Set(forwardedHeader, s.elector.ID())
+		if id := logging.RequestID(r.Context()); len(id) > 0 {
+			req.Header.Set("X-Request-ID", id)
+		}
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, req *http.Request, err error) {
+		s.logger.WarnContext(r.Context(), fmt.Sprintf("Unable to forward request to the leader at %s: %s", leader, err))
+		respondWithError(w, http.StatusServiceUnavailable, ErrorCodeNotLeader,
+			fmt.Sprintf("Unable to forward request to the leader at %s", leader))
+	}
+	s.logger.DebugContext(r.Context(), fmt.Sprintf("Forwarding %s %s to the leader at %s", r.Method, r.URL.Path, leader))
+	proxy.ServeHTTP(w, r)
+}
+
+// leaderUnaryInterceptor rejects gRPC requests on followers. gRPC requests
+// are not forwarded, the leader is sent in the `leader` trailer instead
+func (s *Server) leaderUnaryInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (interface{}, error) {
+	if err := s.notLeaderError(ctx); err != nil {
+		return nil, err
+	}
+	return h(ctx, req)
+}
+
+// leaderStreamInterceptor rejects gRPC streams on followers, since only
+// the leader publishes events
+func (s *Server) leaderStreamInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) error {
+	if err := s.notLeaderError(ss.Context()); err != nil {
+		return err
+	}
+	return h(srv, ss)
+}
+
+func (s *Server) notLeaderError(ctx context.Context) error {
+	if s.isLeader() {
+		return nil
+	}
+	leader := s.elector.Leader()
+	grpc.SetTrailer(ctx, metadata.Pairs("error-code", ErrorCodeNotLeader, "leader", leader))
+	return status.Error(codes.Unavailable, notLeaderMessage(leader))
+}
+
+func notLeaderMessage(leader string) string {
+	if len(leader) == 0 {
+		return "This replica is not the leader and no leader is elected"
+	}
+	return fmt.Sprintf("This replica is not the leader, the leader is %s", leader)
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/server/scalerpb"
+	"github.com/thomasjpfan/docker-scaler/service"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+type LeaderTestSuite struct {
+	suite.Suite
+	serverFixture
+	path string
+	lm   *ScalerServicerMock
+	lts  *httptest.Server
+}
+
+func TestLeaderUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LeaderTestSuite))
+}
+
+func (s *LeaderTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "leader.json")
+	am := new(AlertServicerMock)
+	am.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+	s.lm = new(ScalerServicerMock)
+	ls := NewServer(s.lm, am, nil, new(ReschedulerServiceMock), newMessageLogger(new(bytes.Buffer)),
+		false, false, false, false)
+	s.lts = httptest.NewUnstartedServer(nil)
+	s.lts.Config.Handler = ls.MakeRouter("/")
+	s.lts.Start()
+	ls.SetElector(service.NewElector(service.NewFileLock(s.path), s.lts.URL, time.Minute))
+	s.campaign(ls)
+
+	s.setUpServer(func(srv *Server) {
+		srv.SetElector(service.NewElector(service.NewFileLock(s.path), "http://follower:8080", time.Minute))
+	})
+}
+
+func (s *LeaderTestSuite) TearDownTest() {
+	s.lts.Close()
+}
+
+func (s *LeaderTestSuite) campaign(srv *Server) {
+	_, err := srv.elector.Campaign(context.Background())
+	s.Require().NoError(err)
+}
+
+func (s *LeaderTestSuite) requestWithHeader(method, url string, header http.Header) (*httptest.ResponseRecorder, Response) {
+	req, _ := http.NewRequest(method, url, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return s.serve(req)
+}
+
+func (s *LeaderTestSuite) Test_Follower_ForwardsToLeader() {
+	s.campaign(s.s)
+	s.Require().False(s.s.isLeader())
+	s.lm.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 1 to 2 replicas"}, nil)
+
+	rec, resp := s.requestWithHeader("POST", "/v1/scale-service?service=web&scale=up",
+		http.Header{"X-Request-Id": {"abc"}})
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal("Scaling web from 1 to 2 replicas", resp.Message)
+	s.Equal("abc", rec.Header().Get("X-Request-ID"))
+	s.lm.AssertExpectations(s.T())
+	s.m.AssertNotCalled(s.T(), "Scale", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	s.Empty(s.s.operations.List())
+}
+
+func (s *LeaderTestSuite) Test_Follower_ServesHealthLocally() {
+	s.campaign(s.s)
+	rec, _ := s.request("GET", "/v1/health/live", "")
+	s.Equal(http.StatusOK, rec.Code)
+}
+
+func (s *LeaderTestSuite) Test_Follower_ServesReadsLocally() {
+	op := s.s.operations.Create("scale_service", "web", "")
+	for _, url := range []string{"/v1/operations", "/v1/operations/" + op.ID, "/v1/freezes", "/v1/approvals"} {
+		rec, _ := s.request("GET", url, "")
+		s.Equal(http.StatusOK, rec.Code, url)
+	}
+}
+
+func (s *LeaderTestSuite) Test_Follower_NoLeader() {
+	rec, resp := s.request("POST", "/v1/reschedule-services", "")
+	s.Equal(http.StatusServiceUnavailable, rec.Code)
+	s.Equal(ErrorCodeNotLeader, resp.ErrorCode)
+	s.Equal("This replica is not the leader and no leader is elected", resp.Message)
+}
+
+func (s *LeaderTestSuite) Test_Follower_DoesNotForwardTwice() {
+	s.campaign(s.s)
+	rec, resp := s.requestWithHeader("POST", "/v1/reschedule-services",
+		http.Header{forwardedHeader: {"http://other:8080"}})
+	s.Equal(http.StatusServiceUnavailable, rec.Code)
+	s.Equal("This replica is not the leader, the leader is "+s.lts.URL, resp.Message)
+}
+
+func (s *LeaderTestSuite) Test_Follower_LeaderUnreachable() {
+	s.campaign(s.s)
+	s.lts.Close()
+	rec, resp := s.request("POST", "/v1/reschedule-services", "")
+	s.Equal(http.StatusServiceUnavailable, rec.Code)
+	s.Equal(ErrorCodeNotLeader, resp.ErrorCode)
+	s.Equal("Unable to forward request to the leader at "+s.lts.URL, resp.Message)
+}
+
+func (s *LeaderTestSuite) Test_Follower_RejectsGRPC() {
+	s.campaign(s.s)
+	gs := &GRPCTestSuite{}
+	gs.SetT(s.T())
+	gs.connect(s.s)
+	defer gs.TearDownTest()
+
+	var trailer metadata.MD
+	_, err := gs.c.Reschedule(context.Background(), &scalerpb.RescheduleRequest{}, grpc.Trailer(&trailer))
+	s.Equal(codes.Unavailable, status.Code(err))
+	s.Equal([]string{ErrorCodeNotLeader}, trailer.Get("error-code"))
+	s.Equal([]string{s.lts.URL}, trailer.Get("leader"))
+}
+
+func (s *LeaderTestSuite) Test_StoppedLeading_CancelsWaits() {
+	waiting := s.s.operations.Create("scale_nodes", "worker", "")
+	s.s.operations.SetRescheduleKey(waiting.ID, "key")
+	done := s.s.operations.Create("scale_service", "web", "")
+	s.s.operations.Finish(done.ID, "done", nil)
+	s.rsm.On("CancelWait", "key").Return(true)
+
+	s.s.leadershipChanged(false)
+	s.rsm.AssertExpectations(s.T())
+	op, _ := s.s.operations.Get(waiting.ID)
+	s.Require().Len(op.Results, 1)
+	s.Equal("Stopped waiting for nodes, since this replica is no longer the leader", op.Results[0].Message)
+}
+
+func (s *LeaderTestSuite) Test_Shutdown_ResignsLease() {
+	path := filepath.Join(s.T().TempDir(), "leader.json")
+	s.s.SetElector(service.NewElector(service.NewFileLock(path), "http://follower:8080", time.Minute))
+	s.s.stopElector = s.s.runElector()
+	for i := 0; i < 100 && !s.s.isLeader(); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.Require().True(s.s.isLeader())
+
+	s.rsm.On("Stop")
+	s.s.shutdown(context.Background(), &http.Server{}, nil)
+	s.False(s.s.isLeader())
+	holder, err := service.NewFileLock(path).Acquire(context.Background(), "b", time.Minute)
+	s.Require().NoError(err)
+	s.Equal("b", holder)
+}
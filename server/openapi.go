@@ -0,0 +1,18 @@
+package server
+
+import (
+	_ "embed" // embeds openapi.json
+	"net/http"
+)
+
+// openAPISpec describes the /v1 endpoints
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// OpenAPIHandler serves the OpenAPI document of the api
+func (s *Server) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(openAPISpec)
+}
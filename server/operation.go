@@ -0,0 +1,79 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// OperationResponse returns one operation to HTTP clients
+type OperationResponse struct {
+	Status    string            `json:"status"`
+	Operation service.Operation `json:"operation"`
+}
+
+// OperationsResponse returns operations to HTTP clients
+type OperationsResponse struct {
+	Status     string              `json:"status"`
+	Operations []service.Operation `json:"operations"`
+}
+
+// ListOperations returns recent operations, newest first. The `cluster`
+// parameter selects the operations of one cluster
+func (s *Server) ListOperations(w http.ResponseWriter, r *http.Request) {
+	ops := s.operations.List()
+	if cluster := r.URL.Query().Get("cluster"); len(cluster) > 0 {
+		selected := []service.Operation{}
+		for _, op := range ops {
+			if op.Cluster == cluster {
+				selected = append(selected, op)
+			}
+		}
+		ops = selected
+	}
+	respondWithJSON(w, http.StatusOK, OperationsResponse{
+		Status:     "OK",
+		Operations: ops,
+	})
+}
+
+// GetOperation returns one operation
+func (s *Server) GetOperation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	op, ok := s.operations.Get(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, ErrorCodeOperationNotFound, fmt.Sprintf("Operation %s does not exist", id))
+		return
+	}
+	respondWithJSON(w, http.StatusOK, OperationResponse{Status: "OK", Operation: op})
+}
+
+// CancelOperation cancels an operation waiting for nodes before rescheduling
+func (s *Server) CancelOperation(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	op, ok := s.operations.Get(id)
+	if !ok {
+		respondWithError(w, http.StatusNotFound, ErrorCodeOperationNotFound, fmt.Sprintf("Operation %s does not exist", id))
+		return
+	}
+
+	_, c, err := s.cluster(r.Context(), op.Cluster)
+	if err != nil || op.State != service.OperationWaitingForNodes ||
+		!c.Rescheduler.CancelWait(op.RescheduleKey) {
+		message := fmt.Sprintf("Operation %s is not waiting for nodes (state: %s)", id, op.State)
+		s.logger.WarnContext(r.Context(), fmt.Sprintf("cancel-operation error: %s", message), "operation_id", id)
+		respondWithJSON(w, http.StatusConflict, Response{
+			Status:      "NOK",
+			Message:     message,
+			ErrorCode:   ErrorCodeOperationNotWaiting,
+			OperationID: id,
+		})
+		return
+	}
+
+	message := fmt.Sprintf("Canceling operation %s", id)
+	s.logger.InfoContext(r.Context(), fmt.Sprintf("cancel-operation success: %s", message), "operation_id", id)
+	respondWithJSON(w, http.StatusOK, Response{Status: "OK", Message: message, OperationID: id})
+}
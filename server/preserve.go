@@ -0,0 +1,168 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/thomasjpfan/docker-scaler/metrics"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// replicaPreserver is implemented by service scalers that restore the
+// replicas they decided, once the replicas are changed outside of them
+type replicaPreserver interface {
+	WatchServiceUpdates(ctx context.Context, updated func(serviceName string), failed func(error))
+	Restore(ctx context.Context, serviceName string) (service.ScaleResult, bool, error)
+}
+
+// replicasReloader is implemented by service scalers that save the
+// replicas they decided to a file
+type replicasReloader interface {
+	ReloadReplicas() error
+}
+
+// reloadReplicas reads the decided replicas of every cluster again, so a
+// replica that starts leading restores what the previous leader decided
+func (s *Server) reloadReplicas() {
+	for _, name := range s.clusterNames() {
+		r, ok := s.clusters[name].ServiceScaler.(replicasReloader)
+		if !ok {
+			continue
+		}
+		if err := r.ReloadReplicas(); err != nil {
+			s.logger.Error(err.Error(), "cluster", name)
+		}
+	}
+}
+
+// watchServiceUpdates restores the decided replicas of the services of
+// every cluster, until the server shuts down
+func (s *Server) watchServiceUpdates() {
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-s.done
+		cancel()
+	}()
+	for _, name := range s.clusterNames() {
+		p, ok := s.clusters[name].ServiceScaler.(replicaPreserver)
+		if !ok {
+			continue
+		}
+		ctx := service.WithCluster(ctx, name)
+		s.waits.Add(1)
+		go func() {
+			defer s.waits.Done()
+			p.WatchServiceUpdates(ctx, func(serviceName string) {
+				s.restoreReplicas(ctx, p, serviceName)
+			}, func(err error) {
+				s.logger.WarnContext(ctx, fmt.Sprintf("scale-preserve error: %s", err))
+			})
+		}()
+	}
+}
+
+// restoreReplicas restores the decided replicas of `serviceName` after it
+// was updated. A restore rejected by a freeze, an update in progress or the
+// replica quota is alerted as an error, and a restore queued by an update
+// in progress is retried once the update completes. Only the leader
+// restores replicas
+func (s *Server) restoreReplicas(ctx context.Context, p replicaPreserver, serviceName string) {
+	if !s.isLeader() {
+		return
+	}
+	requestMessage := fmt.Sprintf("Restore replicas: %s", serviceName)
+	result, restored, err := p.Restore(ctx, serviceName)
+	if err == nil && !restored {
+		return
+	}
+	op := s.createOperation(ctx, "scale_preserve", serviceName)
+	logger := s.logger.With("service", serviceName, "operation_id", op.ID)
+	if e, ok := err.(*service.UpdateInProgressError); ok && e.Policy == service.UpdateQueue {
+		s.queueRestore(ctx, logger, p, op.ID, serviceName, requestMessage, e)
+		return
+	}
+	s.restoreResult(ctx, logger, op.ID, serviceName, requestMessage, result, restored, err)
+}
+
+// queueRestore restores the replicas of `serviceName` in the background
+// once swarm completes updating it, like a queued scale. The restore is
+// dropped when a scale of the service is already queued, since the queued
+// scale decides its replicas
+func (s *Server) queueRestore(ctx context.Context, logger *slog.Logger, p replicaPreserver, opID, serviceName, requestMessage string,
+	e *service.UpdateInProgressError) {
+	key := service.ClusterFrom(ctx) + "/" + serviceName
+	s.mu.Lock()
+	queuedID, queued := s.queued[key]
+	if !queued {
+		s.queued[key] = opID
+	}
+	s.mu.Unlock()
+
+	if queued {
+		message := fmt.Sprintf("Restoring the replicas of %s is dropped, since operation %s is already queued", serviceName, queuedID)
+		logger.InfoContext(ctx, fmt.Sprintf("scale-preserve: %s", message))
+		s.publish(ctx, service.EventScaleService, serviceName, opID, "success", message)
+		s.operations.Finish(opID, message, nil)
+		return
+	}
+
+	message := e.Error()
+	s.operations.SetState(opID, service.OperationQueued, message)
+	logger.WarnContext(ctx, fmt.Sprintf("scale-preserve queued: %s", message))
+	s.sendAlert(ctx, "scale_preserve", serviceName, requestMessage, "pending", message)
+	s.publish(ctx, service.EventScaleService, serviceName, opID, "pending", message)
+	metrics.CountScaleRequest("scale_preserve", "", "queued", false)
+
+	// The queued restore outlives the watch, which stops on shutdown
+	queueCtx := context.WithoutCancel(ctx)
+	deadline := time.Now().Add(e.QueueTimeout)
+	s.waits.Add(1)
+	go func() {
+		defer s.waits.Done()
+		var result service.ScaleResult
+		var restored bool
+		finished, err := s.retryQueued(queueCtx, key, opID, serviceName, deadline, func() error {
+			var err error
+			result, restored, err = p.Restore(queueCtx, serviceName)
+			return err
+		})
+		if finished {
+			s.restoreResult(queueCtx, logger, opID, serviceName, requestMessage, result, restored, err)
+		}
+	}()
+}
+
+// restoreResult finishes operation `opID` restoring the replicas of
+// `serviceName` with the result of the restore
+func (s *Server) restoreResult(ctx context.Context, logger *slog.Logger, opID, serviceName, requestMessage string,
+	result service.ScaleResult, restored bool, err error) {
+	s.reportPreemptions(ctx, logger, opID, serviceName, requestMessage, result.Preempted)
+	if err != nil {
+		message := fmt.Sprintf("Unable to restore the replicas of %s: %s", serviceName, err)
+		logger.ErrorContext(ctx, fmt.Sprintf("scale-preserve error: %s", message))
+		s.sendAlert(ctx, "scale_preserve", serviceName, requestMessage, "error", message)
+		s.publish(ctx, service.EventScaleService, serviceName, opID, "error", message)
+		metrics.CountScaleRequest("scale_preserve", "", "error", false)
+		s.operations.Finish(opID, "", errors.New(message))
+		return
+	}
+	if !restored {
+		message := fmt.Sprintf("%s already runs its decided replicas", serviceName)
+		s.publish(ctx, service.EventScaleService, serviceName, opID, "success", message)
+		s.operations.Finish(opID, message, nil)
+		return
+	}
+
+	direction := "up"
+	if result.Current < result.Previous {
+		direction = "down"
+	}
+	s.operations.Finish(opID, result.Message, nil)
+	logger.WarnContext(ctx, fmt.Sprintf("scale-preserve: %s", result.Message))
+	s.sendAlert(ctx, "scale_preserve", serviceName, requestMessage, "success", result.Message)
+	s.publish(ctx, service.EventScaleService, serviceName, opID, "success", result.Message)
+	metrics.CountScaleRequest("scale_preserve", direction, "success", false)
+}
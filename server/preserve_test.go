@@ -0,0 +1,121 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type ReplicaPreserverMock struct {
+	mock.Mock
+}
+
+func (m *ReplicaPreserverMock) WatchServiceUpdates(ctx context.Context, updated func(serviceName string), failed func(error)) {
+	m.Called(ctx, updated, failed)
+}
+
+func (m *ReplicaPreserverMock) Restore(ctx context.Context, serviceName string) (service.ScaleResult, bool, error) {
+	args := m.Called(ctx, serviceName)
+	return args.Get(0).(service.ScaleResult), args.Bool(1), args.Error(2)
+}
+
+type reloadingScalerMock struct {
+	*ScalerServicerMock
+	reloads int
+}
+
+func (m *reloadingScalerMock) ReloadReplicas() error {
+	m.reloads++
+	return nil
+}
+
+type PreserveTestSuite struct {
+	suite.Suite
+	ctx context.Context
+	pm  *ReplicaPreserverMock
+	am  *AlertServicerMock
+	s   *Server
+}
+
+func TestPreserveUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PreserveTestSuite))
+}
+
+func (s *PreserveTestSuite) SetupTest() {
+	s.ctx = service.WithCluster(context.Background(), "default")
+	s.pm = new(ReplicaPreserverMock)
+	s.am = new(AlertServicerMock)
+	s.am.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s.s = NewServer(new(ScalerServicerMock), s.am, new(NodeScalerMock), new(ReschedulerServiceMock),
+		newMessageLogger(new(bytes.Buffer)), false, false, false, false)
+}
+
+func (s *PreserveTestSuite) Test_RestoreReplicas() {
+	message := "Restoring web from 2 to 6 replicas (min: 1, max: 10), since its replicas were changed outside of docker-scaler"
+	s.pm.On("Restore", s.ctx, "web").Return(service.ScaleResult{Previous: 2, Current: 6, Message: message}, true, nil)
+	events, unsubscribe := s.s.events.Subscribe(1, service.EventFilter{})
+	defer unsubscribe()
+
+	s.s.restoreReplicas(s.ctx, s.pm, "web")
+	s.am.AssertCalled(s.T(), "Send", "scale_preserve", "web", "Restore replicas: web", "success", message)
+
+	e := <-events
+	s.Equal(service.EventScaleService, e.Kind)
+	s.Equal(message, e.Message)
+	op, ok := s.s.operations.Get(e.OperationID)
+	s.Require().True(ok)
+	s.Equal("scale_preserve", op.Kind)
+	s.Equal(service.OperationDone, op.State)
+}
+
+func (s *PreserveTestSuite) Test_RestoreReplicas_NothingToRestore() {
+	s.pm.On("Restore", s.ctx, "web").Return(service.ScaleResult{}, false, nil)
+
+	s.s.restoreReplicas(s.ctx, s.pm, "web")
+	s.am.AssertNotCalled(s.T(), "Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	s.Empty(s.s.operations.List())
+}
+
+func (s *PreserveTestSuite) Test_RestoreReplicas_Error() {
+	s.pm.On("Restore", s.ctx, "web").Return(service.ScaleResult{}, false, errors.New("conflict"))
+
+	s.s.restoreReplicas(s.ctx, s.pm, "web")
+	s.am.AssertCalled(s.T(), "Send", "scale_preserve", "web", "Restore replicas: web", "error",
+		"Unable to restore the replicas of web: conflict")
+	ops := s.s.operations.List()
+	s.Require().Len(ops, 1)
+	s.Equal(service.OperationFailed, ops[0].State)
+}
+
+func (s *PreserveTestSuite) Test_RestoreReplicas_Preempts() {
+	message := "Restoring web from 2 to 6 replicas (min: 1, max: 10), since its replicas were changed outside of docker-scaler"
+	s.pm.On("Restore", s.ctx, "web").Return(service.ScaleResult{
+		Previous: 2, Current: 6, Message: message,
+		Preempted: []service.Preemption{{Service: "batch", Previous: 4, Current: 2}},
+	}, true, nil)
+
+	s.s.restoreReplicas(s.ctx, s.pm, "web")
+	s.am.AssertCalled(s.T(), "Send", "scale_preempted", "batch", "Restore replicas: web", "success",
+		"Scaling batch from 4 to 2 replicas to make room for web, which has a higher priority, within the replica quota")
+	s.am.AssertCalled(s.T(), "Send", "scale_preserve", "web", "Restore replicas: web", "success", message)
+}
+
+func (s *PreserveTestSuite) Test_StartedLeading_ReloadsReplicas() {
+	scaler := &reloadingScalerMock{ScalerServicerMock: new(ScalerServicerMock)}
+	srv := NewServer(scaler, s.am, new(NodeScalerMock), new(ReschedulerServiceMock),
+		newMessageLogger(new(bytes.Buffer)), false, false, false, false)
+	srv.SetElector(service.NewElector(service.NewFileLock(filepath.Join(s.T().TempDir(), "leader.json")),
+		"http://replica:8080", time.Minute))
+
+	srv.leadershipChanged(true)
+	s.Equal(1, scaler.reloads)
+	srv.leadershipChanged(false)
+	s.Equal(1, scaler.reloads)
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/metrics"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// queueRetryInterval is how often a queued scale checks whether the update
+// of its service completed
+var queueRetryInterval = 5 * time.Second
+
+// queueScale scales `serviceName` in the background once swarm completes
+// updating it, and announces the queued scale through the alerter. A
+// service has at most one queued scale, later requests are dropped while
+// it waits
+func (s *Server) queueScale(ctx context.Context, logger *slog.Logger, c Cluster, opID, serviceName, scaleDirection string,
+	by uint64, requestMessage string, e *service.UpdateInProgressError) Response {
+	key := service.ClusterFrom(ctx) + "/" + serviceName
+	s.mu.Lock()
+	queuedID, queued := s.queued[key]
+	if !queued {
+		s.queued[key] = opID
+	}
+	s.mu.Unlock()
+
+	resp := Response{
+		Status:      "OK",
+		OperationID: opID,
+		Service:     serviceName,
+		Direction:   scaleDirection,
+	}
+	if queued {
+		resp.Message = fmt.Sprintf("Scaling %s is already queued by operation %s", serviceName, queuedID)
+		logger.InfoContext(ctx, fmt.Sprintf("scale-service queued: %s", resp.Message))
+		s.publish(ctx, service.EventScaleService, serviceName, opID, "success", resp.Message)
+		metrics.CountScaleRequest("scale_service", scaleDirection, "queued", false)
+		s.operations.Finish(opID, resp.Message, nil)
+		return resp
+	}
+
+	resp.Message = e.Error()
+	s.operations.SetState(opID, service.OperationQueued, resp.Message)
+	logger.WarnContext(ctx, fmt.Sprintf("scale-service queued: %s", resp.Message))
+	s.sendAlert(ctx, "scale_service", serviceName, requestMessage, "pending", resp.Message)
+	s.publish(ctx, service.EventScaleService, serviceName, opID, "pending", resp.Message)
+	metrics.CountScaleRequest("scale_service", scaleDirection, "queued", false)
+
+	// The queued scale outlives the request, but keeps its request id
+	queueCtx := context.WithoutCancel(ctx)
+	deadline := time.Now().Add(e.QueueTimeout)
+	s.waits.Add(1)
+	go func() {
+		defer s.waits.Done()
+		s.runQueuedScale(queueCtx, logger, c, key, opID, serviceName, scaleDirection, by, requestMessage, deadline)
+	}()
+	return resp
+}
+
+// runQueuedScale scales `serviceName` once swarm completes updating it,
+// and then lets the service, queued as `key`, be queued again. Every retry
+// requests the scale again, so a freeze, an approval policy or a quota that
+// applies by then is checked before the update is. Queued scales are not
+// saved: when docker-scaler shuts down, the operation is left unfinished
+// and the next run fails it with an alert
+func (s *Server) runQueuedScale(ctx context.Context, logger *slog.Logger, c Cluster, key, opID, serviceName, scaleDirection string,
+	by uint64, requestMessage string, deadline time.Time) {
+	var result service.ScaleResult
+	finished, err := s.retryQueued(ctx, key, opID, serviceName, deadline, func() error {
+		var err error
+		result, err = c.ServiceScaler.Scale(ctx, serviceName, by, scaleDirectionOf(scaleDirection))
+		return err
+	})
+	if finished {
+		s.scaleServiceResult(ctx, logger, opID, serviceName, scaleDirection, requestMessage, result, err)
+	}
+}
+
+// retryQueued calls `retry` for operation `opID`, queued as `key`, until
+// it is no longer queued by the update of `serviceName`, and returns its
+// error. The operation fails when the update is still in progress at
+// `deadline`. Only the leader retries: a replica that stops leading fails
+// the operation, so `serviceName` is not scaled by two replicas. It
+// returns false when the operation stopped without finishing, since
+// docker-scaler shuts down or this replica stopped leading
+func (s *Server) retryQueued(ctx context.Context, key, opID, serviceName string, deadline time.Time,
+	retry func() error) (bool, error) {
+	ticker := time.NewTicker(queueRetryInterval)
+	defer ticker.Stop()
+	started := time.Now()
+	for {
+		select {
+		case <-s.done:
+			return false, nil
+		case <-ticker.C:
+		}
+		if !s.isLeader() {
+			s.failQueuedScale(ctx, key, opID, serviceName)
+		}
+		if !s.isQueued(key, opID) {
+			return false, nil
+		}
+
+		err := retry()
+		if e, ok := err.(*service.UpdateInProgressError); ok && e.Policy == service.UpdateQueue {
+			if time.Now().Before(deadline) {
+				continue
+			}
+			err = fmt.Errorf("Stopped waiting to scale %s, since its update did not complete within %d seconds",
+				serviceName, int(time.Since(started).Seconds()))
+		}
+		s.mu.Lock()
+		delete(s.queued, key)
+		s.mu.Unlock()
+		return true, err
+	}
+}
+
+// isQueued returns true when operation `opID` is still the queued scale of
+// `key`
+func (s *Server) isQueued(key, opID string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.queued[key] == opID
+}
+
+// failQueuedScale fails the scale of `serviceName` queued as `key` by
+// operation `opID`, since this replica stopped leading
+func (s *Server) failQueuedScale(ctx context.Context, key, opID, serviceName string) {
+	s.mu.Lock()
+	if s.queued[key] != opID {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.queued, key)
+	s.mu.Unlock()
+
+	// Queued restores of the decided replicas fail with their own kind
+	kind := "scale_service"
+	if op, ok := s.operations.Get(opID); ok && len(op.Kind) > 0 {
+		kind = op.Kind
+	}
+	err := fmt.Errorf("Stopped waiting to scale %s until its update completes, since this replica is no longer the leader", serviceName)
+	s.logger.WarnContext(ctx, fmt.Sprintf("%s error: %s", strings.ReplaceAll(kind, "_", "-"), err), "operation_id", opID)
+	s.sendAlert(ctx, kind, serviceName, "Stop leading", "error", err.Error())
+	s.publish(ctx, service.EventScaleService, serviceName, opID, "error", err.Error())
+	s.operations.Finish(opID, "", err)
+}
+
+// failQueuedScales fails every queued scale, since this replica stopped
+// leading
+func (s *Server) failQueuedScales() {
+	s.mu.RLock()
+	queued := make(map[string]string, len(s.queued))
+	for key, opID := range s.queued {
+		queued[key] = opID
+	}
+	s.mu.RUnlock()
+	for key, opID := range queued {
+		op, _ := s.operations.Get(opID)
+		ctx := service.WithCluster(logging.WithRequestID(context.Background(), op.RequestID), op.Cluster)
+		s.failQueuedScale(ctx, key, opID, op.Target)
+	}
+}
+
+// scaleDirectionOf converts `up` or `down` to a ScaleDirection
+func scaleDirectionOf(scaleDirection string) service.ScaleDirection {
+	if scaleDirection == "down" {
+		return service.ScaleDownDirection
+	}
+	return service.ScaleUpDirection
+}
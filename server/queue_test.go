@@ -0,0 +1,185 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type QueueTestSuite struct {
+	suite.Suite
+	serverFixture
+}
+
+func TestQueueUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueTestSuite))
+}
+
+func (s *QueueTestSuite) SetupTest() {
+	queueRetryInterval = 5 * time.Millisecond
+	s.setUpServer(nil)
+}
+
+func (s *QueueTestSuite) TearDownTest() {
+	close(s.s.done)
+	s.s.waits.Wait()
+	queueRetryInterval = 5 * time.Second
+}
+
+// waitFor returns operation `id` once it finished, or after a second
+func (s *QueueTestSuite) waitFor(id string) service.Operation {
+	var op service.Operation
+	for i := 0; i < 200; i++ {
+		op, _ = s.s.operations.Get(id)
+		if op.Finished() {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	return op
+}
+
+func (s *QueueTestSuite) updating(policy service.UpdatePolicy, timeout time.Duration) *service.UpdateInProgressError {
+	return &service.UpdateInProgressError{
+		Service: "web", State: swarm.UpdateStateUpdating, Policy: policy, QueueTimeout: timeout,
+	}
+}
+
+func (s *QueueTestSuite) Test_ScaleService_Rejected() {
+	updating := s.updating(service.UpdateReject, 0)
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, updating)
+
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeUpdateInProgress, resp.ErrorCode)
+	s.Equal("Scaling web is rejected while it is updated, the update is in progress", resp.Message)
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Scale service up: web", "error", resp.Message)
+}
+
+func (s *QueueTestSuite) Test_ScaleService_Queued() {
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, s.updating(service.UpdateQueue, time.Minute)).Twice()
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaling web from 2 to 3 replicas", Previous: 2, Current: 3}, nil).Once()
+
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	s.Require().Equal(http.StatusAccepted, rec.Code, rec.Body.String())
+	s.Equal("Scaling web is queued until its update completes, the update is in progress", resp.Message)
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationQueued, op.State)
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Scale service up: web", "pending", resp.Message)
+
+	op = s.waitFor(resp.OperationID)
+	s.Equal(service.OperationDone, op.State)
+	s.Equal("Scaling web from 2 to 3 replicas", op.Message)
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Scale service up: web", "success", op.Message)
+	s.m.AssertNumberOfCalls(s.T(), "Scale", 3)
+}
+
+func (s *QueueTestSuite) Test_ScaleService_AlreadyQueued() {
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, s.updating(service.UpdateQueue, time.Minute))
+
+	_, first := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	s.Equal(http.StatusAccepted, rec.Code)
+	s.Equal("Scaling web is already queued by operation "+first.OperationID, resp.Message)
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationDone, op.State)
+}
+
+func (s *QueueTestSuite) Test_ScaleService_QueueTimesOut() {
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, s.updating(service.UpdateQueue, 20*time.Millisecond))
+
+	_, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	op := s.waitFor(resp.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Contains(op.Error, "Stopped waiting to scale web, since its update did not complete within ")
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Scale service up: web", "error", op.Error)
+
+	s.s.mu.RLock()
+	s.Empty(s.s.queued)
+	s.s.mu.RUnlock()
+}
+
+func (s *QueueTestSuite) Test_ScaleService_FrozenWhileQueued() {
+	frozen := &service.FrozenError{Freeze: service.Freeze{Name: "db", End: time.Now()}}
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, s.updating(service.UpdateQueue, time.Minute)).Twice()
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, frozen).Once()
+
+	_, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	op := s.waitFor(resp.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal(frozen.Error(), op.Error)
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Scale service up: web", "error", op.Error)
+	s.m.AssertNumberOfCalls(s.T(), "Scale", 3)
+}
+
+func (s *QueueTestSuite) Test_StoppedLeading_FailsQueuedScale() {
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{}, s.updating(service.UpdateQueue, time.Minute))
+	s.s.SetElector(service.NewElector(service.NewFileLock(filepath.Join(s.T().TempDir(), "leader.json")),
+		"http://replica:8080", time.Minute))
+	_, err := s.s.elector.Campaign(context.Background())
+	s.Require().NoError(err)
+
+	_, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	s.s.leadershipChanged(false)
+	op := s.waitFor(resp.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal("Stopped waiting to scale web until its update completes, since this replica is no longer the leader", op.Error)
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Stop leading", "error", op.Error)
+
+	calls := len(s.m.Calls)
+	time.Sleep(4 * queueRetryInterval)
+	s.Len(s.m.Calls, calls)
+}
+
+func (s *QueueTestSuite) Test_RestoreReplicas_Queued() {
+	ctx := service.WithCluster(context.Background(), "default")
+	message := "Restoring web from 2 to 6 replicas (min: 1, max: 10), since its replicas were changed outside of docker-scaler"
+	pm := new(ReplicaPreserverMock)
+	pm.On("Restore", mock.Anything, "web").
+		Return(service.ScaleResult{}, false, s.updating(service.UpdateQueue, time.Minute)).Twice()
+	pm.On("Restore", mock.Anything, "web").
+		Return(service.ScaleResult{Previous: 2, Current: 6, Message: message}, true, nil).Once()
+
+	s.s.restoreReplicas(ctx, pm, "web")
+	ops := s.s.operations.List()
+	s.Require().Len(ops, 1)
+	s.Equal(service.OperationQueued, ops[0].State)
+	s.am.AssertCalled(s.T(), "Send", "scale_preserve", "web", "Restore replicas: web", "pending",
+		"Scaling web is queued until its update completes, the update is in progress")
+
+	op := s.waitFor(ops[0].ID)
+	s.Equal(service.OperationDone, op.State)
+	s.Equal(message, op.Message)
+	s.am.AssertCalled(s.T(), "Send", "scale_preserve", "web", "Restore replicas: web", "success", message)
+	pm.AssertNumberOfCalls(s.T(), "Restore", 3)
+}
+
+func (s *QueueTestSuite) Test_RestoreReplicas_QueueTimesOut() {
+	ctx := service.WithCluster(context.Background(), "default")
+	pm := new(ReplicaPreserverMock)
+	pm.On("Restore", mock.Anything, "web").
+		Return(service.ScaleResult{}, false, s.updating(service.UpdateQueue, 20*time.Millisecond))
+
+	s.s.restoreReplicas(ctx, pm, "web")
+	ops := s.s.operations.List()
+	s.Require().Len(ops, 1)
+	op := s.waitFor(ops[0].ID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Contains(op.Error, "Unable to restore the replicas of web: Stopped waiting to scale web, since its update did not complete within ")
+	s.am.AssertCalled(s.T(), "Send", "scale_preserve", "web", "Restore replicas: web", "error", op.Error)
+}
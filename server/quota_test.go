@@ -0,0 +1,61 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type QuotaTestSuite struct {
+	suite.Suite
+	serverFixture
+}
+
+func TestQuotaUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(QuotaTestSuite))
+}
+
+func (s *QuotaTestSuite) SetupTest() {
+	s.setUpServer(nil)
+}
+
+func (s *QuotaTestSuite) Test_ScaleService_ReportsPreemptions() {
+	preempted := []service.Preemption{
+		{Service: "batch", Priority: 1, Previous: 4, Current: 3},
+		{Service: "reports", Priority: 5, Previous: 2, Current: 1},
+	}
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{
+			Message: "Scaling web from 4 to 6 replicas (min: 1, max: 10)", Previous: 4, Current: 6, Preempted: preempted,
+		}, nil)
+
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	s.Require().Equal(http.StatusOK, rec.Code, rec.Body.String())
+	s.Equal(preempted, resp.Preempted)
+	s.am.AssertCalled(s.T(), "Send", "scale_preempted", "batch", "Scale service up: web", "success",
+		"Scaling batch from 4 to 3 replicas to make room for web, which has a higher priority, within the replica quota")
+	s.am.AssertCalled(s.T(), "Send", "scale_preempted", "reports", "Scale service up: web", "success",
+		"Scaling reports from 2 to 1 replicas to make room for web, which has a higher priority, within the replica quota")
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Scale service up: web", "success", resp.Message)
+
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Len(op.Results, 2)
+	s.Equal(resp.Message, op.Message)
+}
+
+func (s *QuotaTestSuite) Test_ScaleService_QuotaExceeded() {
+	quotaErr := &service.QuotaError{
+		Service: "web", Current: 4, Requested: 6, Scope: "cluster", Used: 10, Max: 8,
+	}
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Previous: 4}, quotaErr)
+
+	rec, resp := s.request("POST", "/v1/scale-service?service=web&scale=up", "")
+	s.Equal(http.StatusConflict, rec.Code)
+	s.Equal(ErrorCodeQuotaExceeded, resp.ErrorCode)
+	s.Equal(quotaErr.Error(), resp.Message)
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Scale service up: web", "error", resp.Message)
+}
@@ -5,6 +5,7 @@ type groupLabels struct {
 	Scale   string `json:"scale,omitempty"`
 	By      uint64 `json:"by,omitempty"`
 	Type    string `json:"type,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
 }
 
 // ScaleRequest is the POST body used to scale services/nodes
@@ -3,23 +3,81 @@ package server
 import (
 	"encoding/json"
 	"net/http"
+
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// Error codes returned to HTTP clients in `Response.ErrorCode`
+const (
+	ErrorCodeInvalidBody              = "invalid_body"
+	ErrorCodeMissingService           = "missing_service"
+	ErrorCodeMissingDirection         = "missing_direction"
+	ErrorCodeInvalidDirection         = "invalid_direction"
+	ErrorCodeInvalidNodeType          = "invalid_node_type"
+	ErrorCodeScaleFailed              = "scale_failed"
+	ErrorCodeRescheduleFailed         = "reschedule_failed"
+	ErrorCodeOperationNotFound        = "operation_not_found"
+	ErrorCodeOperationNotWaiting      = "operation_not_waiting"
+	ErrorCodeInvalidEventKind         = "invalid_event_kind"
+	ErrorCodeInvalidDefaults          = "invalid_defaults"
+	ErrorCodeUpdateDefaultsFailed     = "update_defaults_failed"
+	ErrorCodeUnknownCluster           = "unknown_cluster"
+	ErrorCodeNodeScalingNotConfigured = "node_scaling_not_configured"
+	ErrorCodeNotLeader                = "not_leader"
+	ErrorCodeFrozen                   = "frozen"
+	ErrorCodeInvalidFreeze            = "invalid_freeze"
+	ErrorCodeFreezeNotFound           = "freeze_not_found"
+	ErrorCodeFreezeConfigured         = "freeze_configured"
+	ErrorCodeSaveFreezeFailed         = "save_freeze_failed"
+	ErrorCodeBudgetExceeded           = "budget_exceeded"
+	ErrorCodeApprovalNotFound         = "approval_not_found"
+	ErrorCodeApprovalNotPending       = "approval_not_pending"
+	ErrorCodeUpdateInProgress         = "update_in_progress"
+	ErrorCodeQuotaExceeded            = "quota_exceeded"
+	ErrorCodeDisruptionBudget         = "disruption_budget"
 )
 
 // Response message returns to HTTP clients for scaling
 type Response struct {
-	Status  string `json:"status"`
-	Message string `json:"message"`
+	Status      string  `json:"status"`
+	Message     string  `json:"message"`
+	ErrorCode   string  `json:"errorCode,omitempty"`
+	OperationID string  `json:"operationId,omitempty"`
+	ApprovalID  string  `json:"approvalId,omitempty"`
+	Service     string  `json:"service,omitempty"`
+	Direction   string  `json:"direction,omitempty"`
+	Previous    *uint64 `json:"previous,omitempty"`
+	Current     *uint64 `json:"current,omitempty"`
+	Min         *uint64 `json:"min,omitempty"`
+	Max         *uint64 `json:"max,omitempty"`
+	AtBound     *bool   `json:"atBound,omitempty"`
+	NodeType    string  `json:"nodeType,omitempty"`
+	NodesBefore *uint64 `json:"nodesBefore,omitempty"`
+	NodesAfter  *uint64 `json:"nodesAfter,omitempty"`
+
+	Preempted []service.Preemption `json:"preempted,omitempty"`
+}
+
+func errorResponse(errorCode string, message string) Response {
+	return Response{Status: "NOK", Message: message, ErrorCode: errorCode}
 }
 
-func respondWithError(w http.ResponseWriter, code int, message string) {
-	r := Response{Status: "NOK", Message: message}
-	respondWithJSON(w, code, r)
+func respondWithError(w http.ResponseWriter, code int, errorCode string, message string) {
+	respondWithJSON(w, code, errorResponse(errorCode, message))
 }
 
-func respondWithJSON(w http.ResponseWriter, code int, payload Response) {
+func respondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
 	response, _ := json.Marshal(payload)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
 	w.Write(response)
 }
+
+func uint64Ptr(v uint64) *uint64 {
+	return &v
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
@@ -23,7 +23,7 @@ func (s *ResponseTestSuite) Test_RespondWithError() {
 	message := "ERROR ERROR"
 
 	rec := httptest.NewRecorder()
-	respondWithError(rec, code, message)
+	respondWithError(rec, code, ErrorCodeMissingService, message)
 
 	var m Response
 	err := json.Unmarshal(rec.Body.Bytes(), &m)
@@ -31,6 +31,7 @@ func (s *ResponseTestSuite) Test_RespondWithError() {
 
 	s.Equal("NOK", m.Status)
 	s.Equal(message, m.Message)
+	s.Equal(ErrorCodeMissingService, m.ErrorCode)
 	s.Equal(code, rec.Code)
 	s.Equal("application/json", rec.HeaderMap["Content-Type"][0])
 
@@ -54,3 +55,13 @@ func (s *ResponseTestSuite) Test_ResponseWithJSON() {
 	s.Equal(code, rec.Code)
 	s.Equal("application/json", rec.HeaderMap["Content-Type"][0])
 }
+
+func (s *ResponseTestSuite) Test_ResponseWithJSON_OmitsEmptyFields() {
+
+	r := Response{Status: "OK", Message: "world"}
+
+	rec := httptest.NewRecorder()
+	respondWithJSON(rec, http.StatusOK, r)
+
+	s.JSONEq(`{"status":"OK","message":"world"}`, rec.Body.String())
+}
@@ -0,0 +1,4 @@
+// Package scalerpb contains the gRPC api generated from scaler.proto
+package scalerpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative scaler.proto
@@ -0,0 +1,258 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: scaler.proto
+
+package scalerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Scaler_ScaleService_FullMethodName = "/dockerscaler.v1.Scaler/ScaleService"
+	Scaler_ScaleNodes_FullMethodName   = "/dockerscaler.v1.Scaler/ScaleNodes"
+	Scaler_Reschedule_FullMethodName   = "/dockerscaler.v1.Scaler/Reschedule"
+	Scaler_WatchEvents_FullMethodName  = "/dockerscaler.v1.Scaler/WatchEvents"
+)
+
+// ScalerClient is the client API for Scaler service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ScalerClient interface {
+	// ScaleService scales a service up or down
+	ScaleService(ctx context.Context, in *ScaleServiceRequest, opts ...grpc.CallOption) (*ScaleServiceResponse, error)
+	// ScaleNodes scales manager or worker nodes up or down
+	ScaleNodes(ctx context.Context, in *ScaleNodesRequest, opts ...grpc.CallOption) (*ScaleNodesResponse, error)
+	// Reschedule reschedules one service, or all services with the
+	// reschedule label when no service is given
+	Reschedule(ctx context.Context, in *RescheduleRequest, opts ...grpc.CallOption) (*RescheduleResponse, error)
+	// WatchEvents streams events until the client cancels
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (Scaler_WatchEventsClient, error)
+}
+
+type scalerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewScalerClient(cc grpc.ClientConnInterface) ScalerClient {
+	return &scalerClient{cc}
+}
+
+func (c *scalerClient) ScaleService(ctx context.Context, in *ScaleServiceRequest, opts ...grpc.CallOption) (*ScaleServiceResponse, error) {
+	out := new(ScaleServiceResponse)
+	err := c.cc.Invoke(ctx, Scaler_ScaleService_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scalerClient) ScaleNodes(ctx context.Context, in *ScaleNodesRequest, opts ...grpc.CallOption) (*ScaleNodesResponse, error) {
+	out := new(ScaleNodesResponse)
+	err := c.cc.Invoke(ctx, Scaler_ScaleNodes_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scalerClient) Reschedule(ctx context.Context, in *RescheduleRequest, opts ...grpc.CallOption) (*RescheduleResponse, error) {
+	out := new(RescheduleResponse)
+	err := c.cc.Invoke(ctx, Scaler_Reschedule_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *scalerClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (Scaler_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Scaler_ServiceDesc.Streams[0], Scaler_WatchEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &scalerWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Scaler_WatchEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type scalerWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *scalerWatchEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ScalerServer is the server API for Scaler service.
+// All implementations must embed UnimplementedScalerServer
+// for forward compatibility
+type ScalerServer interface {
+	// ScaleService scales a service up or down
+	ScaleService(context.Context, *ScaleServiceRequest) (*ScaleServiceResponse, error)
+	// ScaleNodes scales manager or worker nodes up or down
+	ScaleNodes(context.Context, *ScaleNodesRequest) (*ScaleNodesResponse, error)
+	// Reschedule reschedules one service, or all services with the
+	// reschedule label when no service is given
+	Reschedule(context.Context, *RescheduleRequest) (*RescheduleResponse, error)
+	// WatchEvents streams events until the client cancels
+	WatchEvents(*WatchEventsRequest, Scaler_WatchEventsServer) error
+	mustEmbedUnimplementedScalerServer()
+}
+
+// UnimplementedScalerServer must be embedded to have forward compatible implementations.
+type UnimplementedScalerServer struct {
+}
+
+func (UnimplementedScalerServer) ScaleService(context.Context, *ScaleServiceRequest) (*ScaleServiceResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScaleService not implemented")
+}
+func (UnimplementedScalerServer) ScaleNodes(context.Context, *ScaleNodesRequest) (*ScaleNodesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ScaleNodes not implemented")
+}
+func (UnimplementedScalerServer) Reschedule(context.Context, *RescheduleRequest) (*RescheduleResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reschedule not implemented")
+}
+func (UnimplementedScalerServer) WatchEvents(*WatchEventsRequest, Scaler_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedScalerServer) mustEmbedUnimplementedScalerServer() {}
+
+// UnsafeScalerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ScalerServer will
+// result in compilation errors.
+type UnsafeScalerServer interface {
+	mustEmbedUnimplementedScalerServer()
+}
+
+func RegisterScalerServer(s grpc.ServiceRegistrar, srv ScalerServer) {
+	s.RegisterService(&Scaler_ServiceDesc, srv)
+}
+
+func _Scaler_ScaleService_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScaleServiceRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScalerServer).ScaleService(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scaler_ScaleService_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScalerServer).ScaleService(ctx, req.(*ScaleServiceRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scaler_ScaleNodes_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ScaleNodesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScalerServer).ScaleNodes(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scaler_ScaleNodes_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScalerServer).ScaleNodes(ctx, req.(*ScaleNodesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scaler_Reschedule_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RescheduleRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ScalerServer).Reschedule(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Scaler_Reschedule_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ScalerServer).Reschedule(ctx, req.(*RescheduleRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Scaler_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ScalerServer).WatchEvents(m, &scalerWatchEventsServer{stream})
+}
+
+type Scaler_WatchEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type scalerWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *scalerWatchEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// Scaler_ServiceDesc is the grpc.ServiceDesc for Scaler service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Scaler_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "dockerscaler.v1.Scaler",
+	HandlerType: (*ScalerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ScaleService",
+			Handler:    _Scaler_ScaleService_Handler,
+		},
+		{
+			MethodName: "ScaleNodes",
+			Handler:    _Scaler_ScaleNodes_Handler,
+		},
+		{
+			MethodName: "Reschedule",
+			Handler:    _Scaler_Reschedule_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _Scaler_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "scaler.proto",
+}
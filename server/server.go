@@ -1,66 +1,117 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
 	"path"
 	"strconv"
+	"sync"
+	"syscall"
 	"time"
 
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/metrics"
 	"github.com/thomasjpfan/docker-scaler/server/handler"
 	"github.com/thomasjpfan/docker-scaler/service"
 	"github.com/thomasjpfan/docker-scaler/service/cloud"
+	"github.com/thomasjpfan/docker-scaler/tracing"
 
 	"github.com/gorilla/mux"
+	"google.golang.org/grpc"
 )
 
+// OperationHistorySize is the number of finished operations kept
+const OperationHistorySize = 100
+
 // Server runs service that scales docker services
 type Server struct {
-	serviceScaler service.ScalerServicer
-	alerter       service.AlertServicer
-	nodeScaler    service.NodeScaling
-	rescheduler   service.ReschedulerServicer
-	logger        *log.Logger
-	alertScaleMin bool
-	alertScaleMax bool
-	alertNodeMin  bool
-	alertNodeMax  bool
+	clusters map[string]Cluster
+	alerter  service.AlertServicer
+	logger   *slog.Logger
+
+	// mu guards the alerter, alert options and health checkers, which
+	// change when the config is reloaded
+	mu             sync.RWMutex
+	alertBounds    map[string]alertBounds
+	healthCheckers []service.HealthChecker
+	waits          sync.WaitGroup
+	operations     *service.OperationStore
+	events         *service.EventBus
+	grpcPort       uint16
+	defaults       DefaultsUpdater
+	freezes        *service.Freezes
+	approvals      *service.Approvals
+	elector        *service.Elector
+	stopElector    func()
+	done           chan struct{}
+
+	// queued are the operations of the scales queued until an update
+	// completes, by cluster and service
+	queued map[string]string
+
+	// interrupted are the operations a previous run left unfinished, which
+	// are resumed within the RESCHEDULE_TIMEOUT of their cluster
+	interrupted        []service.Operation
+	rescheduleTimeouts map[string]time.Duration
 }
 
-// NewServer creates Server
+// NewServer creates Server with the scalers of the default cluster
 func NewServer(
 	serviceScaler service.ScalerServicer,
 	alerter service.AlertServicer,
 	nodeScaler service.NodeScaling,
 	rescheduler service.ReschedulerServicer,
-	logger *log.Logger,
+	logger *slog.Logger,
 	alertScaleMin bool,
 	alertScaleMax bool,
 	alertNodeMin bool,
 	alertNodeMax bool) *Server {
 	return &Server{
-		serviceScaler: serviceScaler,
-		alerter:       alerter,
-		nodeScaler:    nodeScaler,
-		rescheduler:   rescheduler,
-		logger:        logger,
-		alertScaleMin: alertScaleMin,
-		alertScaleMax: alertScaleMax,
-		alertNodeMin:  alertNodeMin,
-		alertNodeMax:  alertNodeMax,
+		clusters: map[string]Cluster{
+			config.DefaultCluster: {
+				ServiceScaler: serviceScaler,
+				NodeScaler:    nodeScaler,
+				Rescheduler:   rescheduler,
+			},
+		},
+		alerter: alerter,
+		logger:  logger,
+		alertBounds: map[string]alertBounds{
+			config.DefaultCluster: {alertScaleMin, alertScaleMax, alertNodeMin, alertNodeMax},
+		},
+		operations: service.NewOperationStore(OperationHistorySize),
+		events:     service.NewEventBus(),
+		freezes:    service.NewFreezes(),
+		approvals:  service.NewApprovals(),
+		done:       make(chan struct{}),
+		queued:     map[string]string{},
 	}
 }
 
 // MakeRouter routes url paths to handlers
 func (s *Server) MakeRouter(prefix string) *mux.Router {
 	router := mux.NewRouter()
+	router.Use(handler.RequestIDHandler)
+	router.Use(tracing.Middleware)
+	router.Path("/metrics").
+		Methods("GET").
+		Handler(metrics.Handler()).
+		Name("Metrics")
 	v1router := router.PathPrefix("/v1").Subrouter()
 	s.addRoutes(v1router)
 	if prefix != "/" {
+		router.Path(path.Join(prefix, "/metrics")).
+			Methods("GET").
+			Handler(metrics.Handler())
 		rootPrefix := path.Join(prefix, "/v1")
 		v1prefixRouter := router.PathPrefix(rootPrefix).Subrouter()
 		s.addRoutes(v1prefixRouter)
@@ -70,38 +121,184 @@ func (s *Server) MakeRouter(prefix string) *mux.Router {
 
 func (s *Server) addRoutes(router *mux.Router) {
 
-	if s.nodeScaler != nil {
+	if s.scalesNodes() {
 		router.Path("/scale-nodes").
 			Methods("POST").
-			HandlerFunc(s.ScaleNodes).
+			HandlerFunc(s.leaderOnly(s.ScaleNodes)).
 			Name("ScaleNode")
 	}
 
+	if s.defaults != nil {
+		router.Path("/config/defaults").
+			Methods("GET").
+			HandlerFunc(s.GetDefaults).
+			Name("GetDefaults")
+		router.Path("/config/defaults").
+			Methods("PUT").
+			HandlerFunc(s.leaderOnly(s.UpdateDefaults)).
+			Name("UpdateDefaults")
+	}
+
 	router.Path("/scale-service").
 		Methods("POST").
-		HandlerFunc(s.ScaleService).
+		HandlerFunc(s.leaderOnly(s.ScaleService)).
 		Name("ScaleService")
 	router.Path("/reschedule-services").
 		Methods("POST").
-		HandlerFunc(s.RescheduleAllServices).
+		HandlerFunc(s.leaderOnly(s.RescheduleAllServices)).
 		Name("RescheduleAllServices")
 	router.Path("/reschedule-service").
 		Methods("POST").
 		Queries("service", "{service}").
-		HandlerFunc(s.RescheduleOneService).
+		HandlerFunc(s.leaderOnly(s.RescheduleOneService)).
 		Name("RescheduleOneService")
+	router.Path("/freezes").
+		Methods("GET").
+		HandlerFunc(s.ListFreezes).
+		Name("ListFreezes")
+	router.Path("/freezes").
+		Methods("POST").
+		HandlerFunc(s.leaderOnly(s.AddFreeze)).
+		Name("AddFreeze")
+	router.Path("/freezes/{name}").
+		Methods("DELETE").
+		HandlerFunc(s.leaderOnly(s.DeleteFreeze)).
+		Name("DeleteFreeze")
+	router.Path("/approvals").
+		Methods("GET").
+		HandlerFunc(s.ListApprovals).
+		Name("ListApprovals")
+	router.Path("/approvals/{id}").
+		Methods("GET").
+		HandlerFunc(s.GetApproval).
+		Name("GetApproval")
+	router.Path("/approvals/{id}/approve").
+		Methods("POST").
+		HandlerFunc(s.leaderOnly(s.ApproveChange)).
+		Name("ApproveChange")
+	router.Path("/approvals/{id}/reject").
+		Methods("POST").
+		HandlerFunc(s.leaderOnly(s.RejectChange)).
+		Name("RejectChange")
+	router.Path("/operations").
+		Methods("GET").
+		HandlerFunc(s.ListOperations).
+		Name("ListOperations")
+	router.Path("/operations/{id}").
+		Methods("GET").
+		HandlerFunc(s.GetOperation).
+		Name("GetOperation")
+	router.Path("/operations/{id}").
+		Methods("DELETE").
+		HandlerFunc(s.leaderOnly(s.CancelOperation)).
+		Name("CancelOperation")
 	router.Path("/ping").
 		Methods("GET").
 		HandlerFunc(s.PingHandler).
 		Name("Ping")
+	router.Path("/health/live").
+		Methods("GET").
+		HandlerFunc(s.LiveHandler).
+		Name("HealthLive")
+	router.Path("/health/ready").
+		Methods("GET").
+		HandlerFunc(s.ReadyHandler).
+		Name("HealthReady")
+	router.Path("/events").
+		Methods("GET").
+		HandlerFunc(s.EventsHandler).
+		Name("Events")
+	router.Path("/openapi.json").
+		Methods("GET").
+		HandlerFunc(s.OpenAPIHandler).
+		Name("OpenAPI")
 }
 
-// Run starts server
-func (s *Server) Run(port uint16, prefix string) {
+// Run starts server and blocks until SIGINT or SIGTERM is received.
+// Afterwards, in-flight requests are drained and reschedules waiting for
+// nodes are interrupted, waiting at most gracePeriod
+func (s *Server) Run(port uint16, prefix string, gracePeriod time.Duration) {
 	address := fmt.Sprintf(":%d", port)
 	m := s.MakeRouter(prefix)
 	h := handler.RecoveryHandler(s.logger)
-	log.Fatal(http.ListenAndServe(address, h(m)))
+	srv := &http.Server{Addr: address, Handler: h(m)}
+
+	errC := make(chan error, 2)
+	go func() {
+		errC <- srv.ListenAndServe()
+	}()
+	s.stopElector = s.runElector()
+	if s.elector == nil {
+		s.resumeOperations()
+	}
+	s.watchServiceUpdates()
+
+	var gs *grpc.Server
+	if s.grpcPort != 0 {
+		lis, err := net.Listen("tcp", fmt.Sprintf(":%d", s.grpcPort))
+		if err != nil {
+			s.logger.Error(fmt.Sprintf("Unable to serve gRPC: %s", err))
+			os.Exit(1)
+		}
+		gs = s.NewGRPCServer()
+		s.logger.Info(fmt.Sprintf("Serving gRPC on port %d", s.grpcPort))
+		go func() {
+			errC <- gs.Serve(lis)
+		}()
+	}
+
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigC)
+
+	select {
+	case err := <-errC:
+		s.logger.Error(err.Error())
+		os.Exit(1)
+	case sig := <-sigC:
+		s.logger.Info(fmt.Sprintf("Received %s, shutting down within %s", sig, gracePeriod))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+	defer cancel()
+	s.shutdown(ctx, srv, gs)
+}
+
+// shutdown drains in-flight requests, then interrupts reschedules that are
+// waiting for nodes and waits for their final alerts to be sent. `gs` is
+// nil when the gRPC api is disabled
+func (s *Server) shutdown(ctx context.Context, srv *http.Server, gs *grpc.Server) {
+	close(s.done)
+
+	err := srv.Shutdown(ctx)
+	if err != nil {
+		s.logger.Error(fmt.Sprintf("shutdown error: unable to drain in-flight requests: %s", err))
+	}
+	if gs != nil {
+		stopGRPC(ctx, gs)
+	}
+	// Reschedules interrupted from here on are resumed by the next run
+	s.operations.Close()
+	if s.stopElector != nil {
+		s.stopElector()
+	}
+
+	for _, c := range s.clusters {
+		c.Rescheduler.Stop()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		s.waits.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		s.logger.Info("Docker Scaler stopped")
+	case <-ctx.Done():
+		s.logger.Error("shutdown error: grace period ended before pending reschedules were interrupted")
+	}
 }
 
 // PingHandler is sends StatusOK (used by healthcheck)
@@ -112,7 +309,6 @@ func (s *Server) PingHandler(w http.ResponseWriter, req *http.Request) {
 // ScaleService scales service
 func (s *Server) ScaleService(w http.ResponseWriter, r *http.Request) {
 
-	ctx := r.Context()
 	var ssReq ScaleRequest
 
 	if r.Body != nil {
@@ -121,9 +317,10 @@ func (s *Server) ScaleService(w http.ResponseWriter, r *http.Request) {
 
 		if err != nil {
 			message := "Unable to recognize POST body"
-			s.logger.Printf("scale-service error: %s", message)
-			s.sendAlert("scale_service", "bad_request", "Incorrect request", "error", message)
-			respondWithError(w, http.StatusBadRequest, message)
+			s.logger.ErrorContext(r.Context(), fmt.Sprintf("scale-service error: %s", message))
+			s.sendAlert(r.Context(), "scale_service", "bad_request", "Incorrect request", "error", message)
+			metrics.CountScaleRequest("scale_service", "", "bad_request", false)
+			respondWithError(w, http.StatusBadRequest, ErrorCodeInvalidBody, message)
 			return
 		}
 
@@ -131,64 +328,138 @@ func (s *Server) ScaleService(w http.ResponseWriter, r *http.Request) {
 	}
 
 	serviceName, scaleDirection, by, _ := s.getServiceScaleByType(r.URL.Query(), ssReq)
+	cluster := getCluster(r.URL.Query(), ssReq)
+	resp, code := s.scaleService(r.Context(), cluster, serviceName, scaleDirection, by)
+	respondWithJSON(w, code, resp)
+}
+
+// scaleService scales `serviceName` of `cluster` and records the outcome
+// in the logs, alerts, metrics, events and operations. It returns the
+// response and its HTTP status code
+func (s *Server) scaleService(ctx context.Context, cluster, serviceName, scaleDirection string, by uint64) (Response, int) {
+
+	ctx, c, err := s.cluster(ctx, cluster)
+	if err != nil {
+		message := err.Error()
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-service error: %s", message))
+		s.sendAlert(ctx, "scale_service", "bad_request", "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_service", "", "bad_request", false)
+		return errorResponse(ErrorCodeUnknownCluster, message), http.StatusNotFound
+	}
 
 	if len(serviceName) == 0 {
 		message := "No service name in request"
-		s.logger.Printf("scale-service error: %s", message)
-		s.sendAlert("scale_service", "bad_request", "Incorrect request", "error", message)
-		respondWithError(w, http.StatusBadRequest, message)
-		return
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-service error: %s", message))
+		s.sendAlert(ctx, "scale_service", "bad_request", "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_service", "", "bad_request", false)
+		return errorResponse(ErrorCodeMissingService, message), http.StatusBadRequest
 	}
 
 	if len(scaleDirection) == 0 {
 		message := "No scale direction in request"
-		s.logger.Printf("scale-service error: %s", message)
-		s.sendAlert("scale_service", "bad_request", "Incorrect request", "error", message)
-		respondWithError(w, http.StatusBadRequest, message)
-		return
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-service error: %s", message))
+		s.sendAlert(ctx, "scale_service", "bad_request", "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_service", "", "bad_request", false)
+		return errorResponse(ErrorCodeMissingDirection, message), http.StatusBadRequest
 	}
 
 	if scaleDirection != "up" && scaleDirection != "down" {
 		message := "Incorrect scale direction in request"
-		s.logger.Printf("scale-service error: %s", message)
-		s.sendAlert("scale_service", "bad_request", "Incorrect request", "error", message)
-		respondWithError(w, http.StatusBadRequest, message)
-		return
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-service error: %s", message))
+		s.sendAlert(ctx, "scale_service", "bad_request", "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_service", "", "bad_request", false)
+		return errorResponse(ErrorCodeInvalidDirection, message), http.StatusBadRequest
 	}
 
 	requestMessage := fmt.Sprintf("Scale service %s: %s", scaleDirection, serviceName)
-	s.logger.Print(requestMessage)
+	op := s.createOperation(ctx, "scale_service", serviceName)
+	logger := s.logger.With("service", serviceName, "operation_id", op.ID)
+	logger.InfoContext(ctx, requestMessage)
 
-	var message string
-	var atBound bool
-	var err error
-	if scaleDirection == "down" {
-		message, atBound, err = s.serviceScaler.Scale(ctx, serviceName, by, service.ScaleDownDirection)
-	} else {
-		message, atBound, err = s.serviceScaler.Scale(ctx, serviceName, by, service.ScaleUpDirection)
+	result, err := c.ServiceScaler.Scale(ctx, serviceName, by, scaleDirectionOf(scaleDirection))
+	if e, ok := err.(*service.UpdateInProgressError); ok && e.Policy == service.UpdateQueue {
+		return s.queueScale(ctx, logger, c, op.ID, serviceName, scaleDirection, by, requestMessage, e), http.StatusAccepted
 	}
+	return s.scaleServiceResult(ctx, logger, op.ID, serviceName, scaleDirection, requestMessage, result, err)
+}
 
+// scaleServiceResult records the outcome of scaling `serviceName` for
+// operation `opID`, and finishes the operation unless the change awaits
+// approval
+func (s *Server) scaleServiceResult(ctx context.Context, logger *slog.Logger, opID, serviceName, scaleDirection, requestMessage string,
+	result service.ScaleResult, err error) (Response, int) {
+	if e, ok := err.(*service.ApprovalRequiredError); ok {
+		return s.awaitApproval(ctx, logger, opID, e, requestMessage), http.StatusAccepted
+	}
+	s.reportPreemptions(ctx, logger, opID, serviceName, requestMessage, result.Preempted)
 	if err != nil {
-		message = err.Error()
-		respondWithError(w, http.StatusInternalServerError, message)
-		s.logger.Printf("scale-service error: %s", message)
-		s.sendAlert("scale_service", serviceName, requestMessage, "error", message)
-		return
+		message := err.Error()
+		code, errorCode, outcome := http.StatusInternalServerError, ErrorCodeScaleFailed, "error"
+		switch err.(type) {
+		case *service.FrozenError:
+			code, errorCode, outcome = http.StatusConflict, ErrorCodeFrozen, "frozen"
+		case *service.UpdateInProgressError:
+			code, errorCode, outcome = http.StatusConflict, ErrorCodeUpdateInProgress, "update_in_progress"
+		case *service.QuotaError:
+			code, errorCode, outcome = http.StatusConflict, ErrorCodeQuotaExceeded, "quota"
+		}
+		s.operations.Finish(opID, "", err)
+		logger.ErrorContext(ctx, fmt.Sprintf("scale-service error: %s", message))
+		s.sendAlert(ctx, "scale_service", serviceName, requestMessage, "error", message)
+		s.publish(ctx, service.EventScaleService, serviceName, opID, "error", message)
+		metrics.CountScaleRequest("scale_service", scaleDirection, outcome, false)
+		return Response{
+			Status:      "NOK",
+			Message:     message,
+			ErrorCode:   errorCode,
+			OperationID: opID,
+			Service:     serviceName,
+			Direction:   scaleDirection,
+			Preempted:   result.Preempted,
+		}, code
 	}
 
-	s.logger.Printf("scale-service success: %s", message)
-	if !atBound ||
-		(scaleDirection == "up" && s.alertScaleMax) ||
-		(scaleDirection == "down" && s.alertScaleMin) {
-		s.sendAlert("scale_service", serviceName, requestMessage, "success", message)
+	message, atBound := result.Message, result.AtBound
+	logger.InfoContext(ctx, fmt.Sprintf("scale-service success: %s", message))
+	if !atBound || s.alertAtBound(ctx, false, scaleDirection) {
+		s.sendAlert(ctx, "scale_service", serviceName, requestMessage, "success", message)
+	}
+	s.publish(ctx, service.EventScaleService, serviceName, opID, "success", message)
+	metrics.CountScaleRequest("scale_service", scaleDirection, "success", atBound)
+	s.operations.Finish(opID, message, nil)
+	return Response{
+		Status:      "OK",
+		Message:     message,
+		OperationID: opID,
+		Service:     serviceName,
+		Direction:   scaleDirection,
+		Previous:    uint64Ptr(result.Previous),
+		Current:     uint64Ptr(result.Current),
+		Min:         uint64Ptr(result.Min),
+		Max:         uint64Ptr(result.Max),
+		AtBound:     boolPtr(atBound),
+		Preempted:   result.Preempted,
+	}, http.StatusOK
+}
+
+// reportPreemptions reports each service scaled down to make room for
+// `serviceName` within the replica quota
+func (s *Server) reportPreemptions(ctx context.Context, logger *slog.Logger, opID, serviceName, requestMessage string,
+	preemptions []service.Preemption) {
+	for _, p := range preemptions {
+		message := fmt.Sprintf("Scaling %s from %d to %d replicas to make room for %s, which has a higher priority, within the replica quota",
+			p.Service, p.Previous, p.Current, serviceName)
+		logger.WarnContext(ctx, fmt.Sprintf("scale-service preempted: %s", message), "preempted_service", p.Service)
+		s.sendAlert(ctx, "scale_preempted", p.Service, requestMessage, "success", message)
+		s.publish(ctx, service.EventScaleService, p.Service, opID, "success", message)
+		s.operations.AddResult(opID, "success", message)
+		metrics.CountScaleRequest("scale_preempted", "down", "success", false)
 	}
-	respondWithJSON(w, http.StatusOK, Response{Status: "OK", Message: message})
 }
 
 // ScaleNodes scales nodes
 func (s *Server) ScaleNodes(w http.ResponseWriter, r *http.Request) {
 
-	ctx := r.Context()
 	var ssReq ScaleRequest
 
 	if r.Body != nil {
@@ -197,9 +468,10 @@ func (s *Server) ScaleNodes(w http.ResponseWriter, r *http.Request) {
 
 		if err != nil {
 			message := "Unable to recognize POST body"
-			s.logger.Printf("scale-nodes error: %s", message)
-			s.sendAlert("scale_nodes", "bad_request", "Incorrect request", "error", message)
-			respondWithError(w, http.StatusBadRequest, message)
+			s.logger.ErrorContext(r.Context(), fmt.Sprintf("scale-nodes error: %s", message))
+			s.sendAlert(r.Context(), "scale_nodes", "bad_request", "Incorrect request", "error", message)
+			metrics.CountScaleRequest("scale_nodes", "", "bad_request", false)
+			respondWithError(w, http.StatusBadRequest, ErrorCodeInvalidBody, message)
 			return
 		}
 
@@ -207,33 +479,61 @@ func (s *Server) ScaleNodes(w http.ResponseWriter, r *http.Request) {
 	}
 
 	serviceName, scaleDirection, by, typeStr := s.getServiceScaleByType(r.URL.Query(), ssReq)
+	cluster := getCluster(r.URL.Query(), ssReq)
+	resp, code := s.scaleNodes(r.Context(), cluster, serviceName, scaleDirection, by, typeStr)
+	respondWithJSON(w, code, resp)
+}
+
+// scaleNodes scales nodes of `typeStr` in `cluster` and records the
+// outcome in the logs, alerts, metrics, events and operations. When nodes
+// are added, services are rescheduled in the background once the nodes
+// are online
+func (s *Server) scaleNodes(ctx context.Context, cluster, serviceName, scaleDirection string, by uint64, typeStr string) (Response, int) {
+
+	ctx, c, err := s.cluster(ctx, cluster)
+	if err != nil {
+		message := err.Error()
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes error: %s", message))
+		s.sendAlert(ctx, "scale_nodes", "bad_request", "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_nodes", "", "bad_request", false)
+		return errorResponse(ErrorCodeUnknownCluster, message), http.StatusNotFound
+	}
+
+	if c.NodeScaler == nil {
+		message := fmt.Sprintf("Node scaling is not configured for cluster %s", service.ClusterFrom(ctx))
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes error: %s", message))
+		metrics.CountScaleRequest("scale_nodes", "", "bad_request", false)
+		return errorResponse(ErrorCodeNodeScalingNotConfigured, message), http.StatusNotFound
+	}
 
 	if len(scaleDirection) == 0 {
 		message := "No scale direction"
-		s.logger.Printf("scale-nodes error: %s", message)
-		s.sendAlert("scale_nodes", "bad_request", "Incorrect request", "error", message)
-		respondWithError(w, http.StatusBadRequest, message)
-		return
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes error: %s", message))
+		s.sendAlert(ctx, "scale_nodes", "bad_request", "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_nodes", "", "bad_request", false)
+		return errorResponse(ErrorCodeMissingDirection, message), http.StatusBadRequest
 	}
 
 	if scaleDirection != "up" && scaleDirection != "down" {
 		message := "Incorrect scale direction"
-		s.logger.Printf("scale-nodes error: %s", message)
-		s.sendAlert("scale_nodes", "bad_request", "Incorrect request", "error", message)
-		respondWithError(w, http.StatusBadRequest, message)
-		return
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes error: %s", message))
+		s.sendAlert(ctx, "scale_nodes", "bad_request", "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_nodes", "", "bad_request", false)
+		return errorResponse(ErrorCodeInvalidDirection, message), http.StatusBadRequest
 	}
 
 	if typeStr != "worker" && typeStr != "manager" {
 		message := fmt.Sprintf("Incorrect node type: %s, type can only be worker or manager", typeStr)
-		respondWithError(w, http.StatusBadRequest, message)
-		s.logger.Printf("scale-nodes error: %s", message)
-		s.sendAlert("scale_nodes", s.nodeScaler.String(), "Incorrect request", "error", message)
-		return
+		s.logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes error: %s", message))
+		s.sendAlert(ctx, "scale_nodes", c.NodeScaler.String(), "Incorrect request", "error", message)
+		metrics.CountScaleRequest("scale_nodes", "", "bad_request", false)
+		return errorResponse(ErrorCodeInvalidNodeType, message), http.StatusBadRequest
 	}
 
-	requestMessage := fmt.Sprintf("Scale nodes %s on: %s, by: %d, type: %s", scaleDirection, s.nodeScaler.String(), by, typeStr)
-	s.logger.Printf(requestMessage)
+	requestMessage := fmt.Sprintf("Scale nodes %s on: %s, by: %d, type: %s", scaleDirection, c.NodeScaler.String(), by, typeStr)
+	op := s.createOperation(ctx, "scale_nodes", typeStr)
+	logger := s.logger.With("node_type", typeStr, "operation_id", op.ID)
+	logger.InfoContext(ctx, requestMessage)
 
 	isManager := (typeStr == "manager")
 
@@ -251,14 +551,100 @@ func (s *Server) ScaleNodes(w http.ResponseWriter, r *http.Request) {
 	} else {
 		nodeType = cloud.NodeWorkerType
 	}
-	nodesBefore, nodesNow, err := s.nodeScaler.Scale(
+
+	err = s.freezes.Check(service.FreezeAction{
+		Cluster:   service.ClusterFrom(ctx),
+		Kind:      "scale_nodes",
+		Direction: direction,
+	})
+	if err != nil {
+		s.operations.Finish(op.ID, "", err)
+		logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes error: %s", err))
+		s.sendAlert(ctx, "scale_nodes", c.NodeScaler.String(), requestMessage, "error", err.Error())
+		s.publish(ctx, service.EventScaleNodes, serviceName, op.ID, "error", err.Error())
+		metrics.CountScaleRequest("scale_nodes", scaleDirection, "frozen", false)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeFrozen,
+			OperationID: op.ID,
+			Service:     serviceName,
+			Direction:   scaleDirection,
+			NodeType:    typeStr,
+		}, http.StatusConflict
+	}
+
+	nodesBefore, nodesNow, err := c.NodeScaler.Scale(
 		ctx, by, direction, nodeType, serviceName)
 
+	if e, ok := err.(*service.ApprovalRequiredError); ok {
+		return s.awaitApproval(ctx, logger, op.ID, e, requestMessage), http.StatusAccepted
+	}
+
+	budgetErr, budgeted := err.(*service.BudgetError)
+	if budgeted {
+		logger.WarnContext(ctx, fmt.Sprintf("scale-nodes budget: %s", budgetErr))
+		s.sendAlert(ctx, "node_budget", c.NodeScaler.String(), requestMessage, "error", budgetErr.Error())
+		if budgetErr.Capped() {
+			err = nil
+		}
+	}
+	if budgeted && err != nil {
+		s.operations.Finish(op.ID, "", err)
+		s.publish(ctx, service.EventScaleNodes, serviceName, op.ID, "error", err.Error())
+		metrics.CountScaleRequest("scale_nodes", scaleDirection, "budget", false)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeBudgetExceeded,
+			OperationID: op.ID,
+			Service:     serviceName,
+			Direction:   scaleDirection,
+			NodeType:    typeStr,
+			NodesBefore: uint64Ptr(nodesBefore),
+			NodesAfter:  uint64Ptr(nodesNow),
+		}, http.StatusConflict
+	}
+
+	disruptionErr, disrupted := err.(*service.DisruptionBudgetError)
+	if disrupted {
+		logger.WarnContext(ctx, fmt.Sprintf("scale-nodes disruption budget: %s", disruptionErr))
+		s.sendAlert(ctx, "disruption_budget", c.NodeScaler.String(), requestMessage, "error", disruptionErr.Error())
+		if disruptionErr.Capped() {
+			err = nil
+		}
+	}
+	if disrupted && err != nil {
+		s.operations.Finish(op.ID, "", err)
+		s.publish(ctx, service.EventScaleNodes, serviceName, op.ID, "error", err.Error())
+		metrics.CountScaleRequest("scale_nodes", scaleDirection, "disruption_budget", false)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeDisruptionBudget,
+			OperationID: op.ID,
+			Service:     serviceName,
+			Direction:   scaleDirection,
+			NodeType:    typeStr,
+			NodesBefore: uint64Ptr(nodesBefore),
+			NodesAfter:  uint64Ptr(nodesNow),
+		}, http.StatusConflict
+	}
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		s.logger.Printf("scale-nodes error: %s", err)
-		s.sendAlert("scale_nodes", s.nodeScaler.String(), requestMessage, "error", err.Error())
-		return
+		s.operations.Finish(op.ID, "", err)
+		logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes error: %s", err))
+		s.sendAlert(ctx, "scale_nodes", c.NodeScaler.String(), requestMessage, "error", err.Error())
+		s.publish(ctx, service.EventScaleNodes, serviceName, op.ID, "error", err.Error())
+		metrics.CountScaleRequest("scale_nodes", scaleDirection, "error", false)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeScaleFailed,
+			OperationID: op.ID,
+			Service:     serviceName,
+			Direction:   scaleDirection,
+			NodeType:    typeStr,
+		}, http.StatusInternalServerError
 	}
 
 	var message string
@@ -267,38 +653,133 @@ func (s *Server) ScaleNodes(w http.ResponseWriter, r *http.Request) {
 	} else if scaleDirection == "down" && nodesBefore == nodesNow {
 		message = fmt.Sprintf("%s nodes are already descaled to the minimum number of %d nodes", typeStr, nodesNow)
 	} else {
-		message = fmt.Sprintf("Changing the number of %s nodes on %s from %d to %d", typeStr, s.nodeScaler.String(), nodesBefore, nodesNow)
+		message = fmt.Sprintf("Changing the number of %s nodes on %s from %d to %d", typeStr, c.NodeScaler.String(), nodesBefore, nodesNow)
+	}
+	if budgeted {
+		message = fmt.Sprintf("%s, capped by the node budget", message)
+	}
+	if disrupted {
+		message = fmt.Sprintf("%s, capped by the disruption budget of %s", message, disruptionErr.Service)
 	}
 
-	s.logger.Printf("scale-nodes success: %s", message)
+	logger.InfoContext(ctx, fmt.Sprintf("scale-nodes success: %s", message))
 
-	if nodesBefore != nodesNow ||
-		(scaleDirection == "up" && s.alertNodeMax) ||
-		(scaleDirection == "down" && s.alertNodeMin) {
-		s.sendAlert("scale_nodes", s.nodeScaler.String(), requestMessage, "success", message)
+	if nodesBefore != nodesNow || s.alertAtBound(ctx, true, scaleDirection) {
+		s.sendAlert(ctx, "scale_nodes", c.NodeScaler.String(), requestMessage, "success", message)
 	}
-	respondWithJSON(w, http.StatusOK, Response{Status: "OK", Message: message})
+	s.publish(ctx, service.EventScaleNodes, serviceName, op.ID, "success", message)
+	metrics.CountScaleRequest("scale_nodes", scaleDirection, "success", nodesBefore == nodesNow)
 
 	// Call rescheduler if nodesNow is greater than nodesBefore
+	waitToReschedule := nodesNow > nodesBefore || c.Rescheduler.IsWaitingToReschedule()
+
+	s.operations.AddResult(op.ID, "success", message)
+	if waitToReschedule {
+		s.operations.SetState(op.ID, service.OperationWaitingForNodes, message)
 
-	if nodesNow > nodesBefore || s.rescheduler.IsWaitingToReschedule() {
-		rightNow := time.Now().UTC().Format("20060102T150405")
+		key := rescheduleKey()
 		reqMsg := fmt.Sprintf("Waiting for %s nodes to scale from %d to %d for rescheduling", typeStr, nodesBefore, nodesNow)
-		s.logger.Printf("scale-nodes: %s", reqMsg)
-		s.sendAlert("scale_nodes", "reschedule", "Wait to reschedule", "pending", reqMsg)
+		logger.InfoContext(ctx, fmt.Sprintf("scale-nodes: %s", reqMsg))
+		s.sendAlert(ctx, "scale_nodes", "reschedule", "Wait to reschedule", "pending", reqMsg)
+		s.publish(ctx, service.EventScaleNodes, serviceName, op.ID, "pending", reqMsg)
+		s.operations.SetWait(op.ID, service.OperationWait{
+			Key:       key,
+			IsManager: isManager,
+			NodeType:  typeStr,
+			Previous:  int(nodesBefore),
+			Target:    int(nodesNow),
+			Direction: direction,
+			StartedAt: time.Now().UTC(),
+		})
+
+		// The wait outlives the request, but keeps its request id
+		waitCtx := context.WithoutCancel(ctx)
+		s.waits.Add(1)
+		go func() {
+			defer s.waits.Done()
+			s.rescheduleServiceWait(waitCtx, c.Rescheduler, op.ID, isManager, typeStr, int(nodesBefore), int(nodesNow), key, direction, 0)
+		}()
+	} else {
+		s.operations.Finish(op.ID, message, nil)
+	}
+
+	return Response{
+		Status:      "OK",
+		Message:     message,
+		OperationID: op.ID,
+		Service:     serviceName,
+		Direction:   scaleDirection,
+		AtBound:     boolPtr(nodesBefore == nodesNow),
+		NodeType:    typeStr,
+		NodesBefore: uint64Ptr(nodesBefore),
+		NodesAfter:  uint64Ptr(nodesNow),
+	}, http.StatusOK
+}
+
+// alertBounds select whether to alert when services or nodes of a cluster
+// are already at their minimum or maximum
+type alertBounds struct {
+	scaleMin, scaleMax, nodeMin, nodeMax bool
+}
 
-		go s.rescheduleServiceWait(isManager, typeStr, int(nodesBefore), int(nodesNow), rightNow, direction)
+// alertAtBound returns whether to alert when scaling services, or nodes
+// when `nodes` is true, of the cluster of `ctx` in `direction` leaves them
+// at their bound
+func (s *Server) alertAtBound(ctx context.Context, nodes bool, direction string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b := s.alertBounds[service.ClusterFrom(ctx)]
+	if nodes {
+		return (direction == "up" && b.nodeMax) ||
+			(direction == "down" && b.nodeMin)
 	}
+	return (direction == "up" && b.scaleMax) ||
+		(direction == "down" && b.scaleMin)
 }
 
-func (s *Server) sendAlert(alertName string, serviceName string, request string,
+// SetAlerter sends alerts with `alerter`
+func (s *Server) SetAlerter(alerter service.AlertServicer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alerter = alerter
+}
+
+// SetAlertBounds sets whether to alert when services or nodes of
+// `cluster` are already at their minimum or maximum
+func (s *Server) SetAlertBounds(cluster string, alertScaleMin, alertScaleMax, alertNodeMin, alertNodeMax bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.alertBounds[cluster] = alertBounds{alertScaleMin, alertScaleMax, alertNodeMin, alertNodeMax}
+}
+
+func (s *Server) sendAlert(ctx context.Context, alertName string, serviceName string, request string,
 	status string, message string) {
-	err := s.alerter.Send(alertName, serviceName, request, status, message)
+	s.mu.RLock()
+	alerter := s.alerter
+	s.mu.RUnlock()
+	err := alerter.Send(ctx, alertName, serviceName, request, status, message)
 	if err != nil {
-		s.logger.Printf("Alertmanager did not receive message: %s, error: %v", message, err)
+		metrics.AlertSendFailures.Inc()
+		s.logger.WarnContext(ctx, fmt.Sprintf("Alertmanager did not receive message: %s, error: %v", message, err),
+			"alertname", alertName)
+		s.publish(ctx, service.EventAlertFailure, serviceName, "", "error",
+			fmt.Sprintf("Alertmanager did not receive %s alert: %v", alertName, err))
 	}
 }
 
+// publish publishes an event with the request id and cluster of `ctx`
+func (s *Server) publish(ctx context.Context, kind, serviceName, operationID, status, message string) {
+	s.events.Publish(service.Event{
+		Kind:        kind,
+		Cluster:     service.ClusterFrom(ctx),
+		Service:     serviceName,
+		OperationID: operationID,
+		RequestID:   logging.RequestID(ctx),
+		Status:      status,
+		Message:     message,
+	})
+}
+
 func (s *Server) getServiceScaleByType(q url.Values, ssReq ScaleRequest) (string, string, uint64, string) {
 
 	service := ssReq.GroupLabels.Service
@@ -332,59 +813,155 @@ func (s *Server) getServiceScaleByType(q url.Values, ssReq ScaleRequest) (string
 
 // RescheduleAllServices reschedules all services
 func (s *Server) RescheduleAllServices(w http.ResponseWriter, r *http.Request) {
+	resp, code := s.rescheduleAll(r.Context(), r.URL.Query().Get("cluster"))
+	respondWithJSON(w, code, resp)
+}
+
+// rescheduleAll reschedules all labeled services of `cluster` and records
+// the outcome in the logs, alerts, events and operations
+func (s *Server) rescheduleAll(ctx context.Context, cluster string) (Response, int) {
+	ctx, c, err := s.cluster(ctx, cluster)
+	if err != nil {
+		s.logger.ErrorContext(ctx, fmt.Sprintf("reschedule-services error: %s", err))
+		return errorResponse(ErrorCodeUnknownCluster, err.Error()), http.StatusNotFound
+	}
+
 	requestMessage := "Rescheduling all labeled services"
-	s.logger.Print(requestMessage)
+	op := s.createOperation(ctx, "reschedule_services", "")
+	logger := s.logger.With("operation_id", op.ID)
+	logger.InfoContext(ctx, requestMessage)
 
-	nowStr := time.Now().UTC().Format("20060102T150405")
-	message, err := s.rescheduler.RescheduleAll(nowStr)
+	err = s.freezes.Check(service.FreezeAction{Cluster: service.ClusterFrom(ctx), Kind: "reschedule"})
+	if err != nil {
+		logger.ErrorContext(ctx, fmt.Sprintf("reschedule-services error: %s", err))
+		s.sendAlert(ctx, "reschedule_service", "reschedule", requestMessage, "error", err.Error())
+		s.publish(ctx, service.EventReschedule, "", op.ID, "error", err.Error())
+		s.operations.Finish(op.ID, "", err)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeFrozen,
+			OperationID: op.ID,
+		}, http.StatusConflict
+	}
+	s.operations.SetState(op.ID, service.OperationRescheduling, requestMessage)
+
+	message, err := c.Rescheduler.RescheduleAll(ctx, rescheduleKey())
 
 	if err != nil {
-		s.logger.Printf("reschedule-services error: %s", err)
-		s.alerter.Send("reschedule_service", "reschedule", requestMessage, "error", err.Error())
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+		logger.ErrorContext(ctx, fmt.Sprintf("reschedule-services error: %s", err))
+		s.sendAlert(ctx, "reschedule_service", "reschedule", requestMessage, "error", err.Error())
+		s.publish(ctx, service.EventReschedule, "", op.ID, "error", err.Error())
+		s.operations.Finish(op.ID, "", err)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeRescheduleFailed,
+			OperationID: op.ID,
+		}, http.StatusInternalServerError
 	}
 
-	s.logger.Printf("reschedule-services success: %s", message)
-	s.sendAlert("reschedule_service", "reschedule", requestMessage, "success", message)
-	respondWithJSON(w, http.StatusOK, Response{Status: "OK", Message: message})
+	logger.InfoContext(ctx, fmt.Sprintf("reschedule-services success: %s", message))
+	s.sendAlert(ctx, "reschedule_service", "reschedule", requestMessage, "success", message)
+	s.publish(ctx, service.EventReschedule, "", op.ID, "success", message)
+	s.operations.Finish(op.ID, message, nil)
+	return Response{
+		Status:      "OK",
+		Message:     message,
+		OperationID: op.ID,
+	}, http.StatusOK
 }
 
 // RescheduleOneService reschedule one service
 func (s *Server) RescheduleOneService(w http.ResponseWriter, r *http.Request) {
-
 	q := r.URL.Query()
-	service := q.Get("service")
+	resp, code := s.rescheduleService(r.Context(), q.Get("cluster"), q.Get("service"))
+	respondWithJSON(w, code, resp)
+}
+
+// rescheduleService reschedules `serviceName` of `cluster` and records the
+// outcome in the logs, alerts, events and operations
+func (s *Server) rescheduleService(ctx context.Context, cluster, serviceName string) (Response, int) {
+
+	ctx, c, err := s.cluster(ctx, cluster)
+	if err != nil {
+		s.logger.ErrorContext(ctx, fmt.Sprintf("reschedule-service error: %s", err))
+		return errorResponse(ErrorCodeUnknownCluster, err.Error()), http.StatusNotFound
+	}
+
+	requestMessage := fmt.Sprintf("Rescheduling service: %s", serviceName)
+	op := s.createOperation(ctx, "reschedule_service", serviceName)
+	logger := s.logger.With("service", serviceName, "operation_id", op.ID)
+	logger.InfoContext(ctx, requestMessage)
 
-	requestMessage := fmt.Sprintf("Rescheduling service: %s", service)
-	s.logger.Print(requestMessage)
+	err = s.freezes.Check(service.FreezeAction{Cluster: service.ClusterFrom(ctx), Kind: "reschedule", Service: serviceName})
+	if err != nil {
+		logger.ErrorContext(ctx, fmt.Sprintf("reschedule-service error: %s", err))
+		s.sendAlert(ctx, "reschedule_service", "reschedule", requestMessage, "error", err.Error())
+		s.publish(ctx, service.EventReschedule, serviceName, op.ID, "error", err.Error())
+		s.operations.Finish(op.ID, "", err)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   ErrorCodeFrozen,
+			OperationID: op.ID,
+			Service:     serviceName,
+		}, http.StatusConflict
+	}
+	s.operations.SetState(op.ID, service.OperationRescheduling, requestMessage)
 
-	nowStr := time.Now().UTC().Format("20060102T150405")
-	err := s.rescheduler.RescheduleService(service, nowStr)
+	err = c.Rescheduler.RescheduleService(ctx, serviceName, rescheduleKey())
 
 	if err != nil {
-		s.logger.Printf("reschedule-service error: %s", err.Error())
-		s.alerter.Send("reschedule_service", "reschedule", requestMessage, "error", err.Error())
-		respondWithError(w, http.StatusInternalServerError, err.Error())
-		return
+		code, errorCode := http.StatusInternalServerError, ErrorCodeRescheduleFailed
+		if _, ok := err.(*service.FrozenError); ok {
+			code, errorCode = http.StatusConflict, ErrorCodeFrozen
+		}
+		logger.ErrorContext(ctx, fmt.Sprintf("reschedule-service error: %s", err))
+		s.sendAlert(ctx, "reschedule_service", "reschedule", requestMessage, "error", err.Error())
+		s.publish(ctx, service.EventReschedule, serviceName, op.ID, "error", err.Error())
+		s.operations.Finish(op.ID, "", err)
+		return Response{
+			Status:      "NOK",
+			Message:     err.Error(),
+			ErrorCode:   errorCode,
+			OperationID: op.ID,
+			Service:     serviceName,
+		}, code
 	}
 
-	message := fmt.Sprintf("Rescheduled service: %s", service)
-	s.logger.Printf("reschedule_service success: %s", message)
-	s.sendAlert("reschedule_service", "reschedule", requestMessage, "success", message)
-	respondWithJSON(w, http.StatusOK, Response{Status: "OK", Message: message})
+	message := fmt.Sprintf("Rescheduled service: %s", serviceName)
+	logger.InfoContext(ctx, fmt.Sprintf("reschedule_service success: %s", message))
+	s.sendAlert(ctx, "reschedule_service", "reschedule", requestMessage, "success", message)
+	s.publish(ctx, service.EventReschedule, serviceName, op.ID, "success", message)
+	s.operations.Finish(op.ID, message, nil)
+	return Response{
+		Status:      "OK",
+		Message:     message,
+		OperationID: op.ID,
+		Service:     serviceName,
+	}, http.StatusOK
+}
 
+// rescheduleKey returns the value set in the environment of rescheduled
+// services, which also keys a reschedule waiting for nodes. It has
+// nanoseconds, so reschedules started in the same second never share a key
+func rescheduleKey() string {
+	return time.Now().UTC().Format("20060102T150405.000000000")
 }
 
-func (s *Server) rescheduleServiceWait(isManager bool, typeStr string, previousNodeCnt int, targetNodeCnt int, nowStr string, direction service.ScaleDirection) {
+// rescheduleServiceWait waits at most `timeOut` for the nodes, or
+// RESCHEDULE_TIMEOUT when it is 0
+func (s *Server) rescheduleServiceWait(ctx context.Context, rescheduler service.ReschedulerServicer, operationID string, isManager bool, typeStr string, previousNodeCnt int, targetNodeCnt int, nowStr string, direction service.ScaleDirection, timeOut time.Duration) {
 
 	tickerC := make(chan time.Time)
 	errC := make(chan error)
 	statusC := make(chan string)
 
-	s.rescheduler.RescheduleServicesWaitForNodes(isManager, targetNodeCnt, nowStr, tickerC, errC, statusC)
+	rescheduler.RescheduleServicesWaitForNodes(ctx, isManager, targetNodeCnt, nowStr, timeOut, tickerC, errC, statusC)
 
 	requestMsg := "Waiting for nodes to scale"
+	logger := s.logger.With("node_type", typeStr, "operation_id", operationID)
 
 	timeStart := time.Now().UTC()
 
@@ -392,16 +969,23 @@ func (s *Server) rescheduleServiceWait(isManager bool, typeStr string, previousN
 		select {
 		case t := <-tickerC:
 			msg := fmt.Sprintf("Waited %d seconds for a total of %d %s nodes to come online", int(t.Sub(timeStart).Seconds()), targetNodeCnt, typeStr)
-			s.logger.Printf("scale-nodes-reschedule: %s", msg)
-			s.sendAlert("reschedule_service", "reschedule", requestMsg, "pending", msg)
+			logger.InfoContext(ctx, fmt.Sprintf("scale-nodes-reschedule: %s", msg))
+			s.sendAlert(ctx, "reschedule_service", "reschedule", requestMsg, "pending", msg)
+			s.operations.AddResult(operationID, "pending", msg)
+			s.publish(ctx, service.EventRescheduleTick, "", operationID, "pending", msg)
 		case err := <-errC:
 			if err != nil {
-				s.logger.Printf("scale-nodes-reschedule error: %s", err)
-				s.sendAlert("reschedule_service", "reschedule", requestMsg, "error", err.Error())
+				logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes-reschedule error: %s", err))
+				s.sendAlert(ctx, "reschedule_service", "reschedule", requestMsg, "error", err.Error())
+				s.publish(ctx, service.EventReschedule, "", operationID, "error", err.Error())
+				s.operations.Finish(operationID, "", err)
+				return
 			}
 		case status := <-statusC:
-			s.logger.Printf("scale-nodes-reschedule: %s", status)
-			s.sendAlert("reschedule_service", "reschedule", status, "success", status)
+			logger.InfoContext(ctx, fmt.Sprintf("scale-nodes-reschedule: %s", status))
+			s.sendAlert(ctx, "reschedule_service", "reschedule", status, "success", status)
+			s.publish(ctx, service.EventReschedule, "", operationID, "success", status)
+			s.operations.Finish(operationID, status, nil)
 			return
 		}
 	}
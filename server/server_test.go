@@ -6,16 +6,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/logging"
 	"github.com/thomasjpfan/docker-scaler/service"
 	"github.com/thomasjpfan/docker-scaler/service/cloud"
 )
@@ -24,16 +27,16 @@ type ScalerServicerMock struct {
 	mock.Mock
 }
 
-func (m *ScalerServicerMock) Scale(ctx context.Context, serviceName string, by uint64, direction service.ScaleDirection) (string, bool, error) {
+func (m *ScalerServicerMock) Scale(ctx context.Context, serviceName string, by uint64, direction service.ScaleDirection) (service.ScaleResult, error) {
 	args := m.Called(ctx, serviceName, by, direction)
-	return args.String(0), args.Bool(1), args.Error(2)
+	return args.Get(0).(service.ScaleResult), args.Error(1)
 }
 
 type AlertServicerMock struct {
 	mock.Mock
 }
 
-func (am *AlertServicerMock) Send(alertName string, serviceName string, status string, message string, request string) error {
+func (am *AlertServicerMock) Send(ctx context.Context, alertName string, serviceName string, status string, message string, request string) error {
 	args := am.Called(alertName, serviceName, status, message, request)
 	return args.Error(0)
 }
@@ -55,16 +58,16 @@ type ReschedulerServiceMock struct {
 	mock.Mock
 }
 
-func (rsm *ReschedulerServiceMock) RescheduleService(serviceID, value string) error {
+func (rsm *ReschedulerServiceMock) RescheduleService(ctx context.Context, serviceID, value string) error {
 	args := rsm.Called(serviceID, value)
 	return args.Error(0)
 }
 
-func (rsm *ReschedulerServiceMock) RescheduleServicesWaitForNodes(manager bool, targetNodeCnt int, value string, tickerC chan<- time.Time, errorC chan<- error, statusC chan<- string) {
-	rsm.Called(manager, targetNodeCnt, value, tickerC, errorC, statusC)
+func (rsm *ReschedulerServiceMock) RescheduleServicesWaitForNodes(ctx context.Context, manager bool, targetNodeCnt int, value string, timeOut time.Duration, tickerC chan<- time.Time, errorC chan<- error, statusC chan<- string) {
+	rsm.Called(manager, targetNodeCnt, value, timeOut, tickerC, errorC, statusC)
 }
 
-func (rsm *ReschedulerServiceMock) RescheduleAll(value string) (string, error) {
+func (rsm *ReschedulerServiceMock) RescheduleAll(ctx context.Context, value string) (string, error) {
 	args := rsm.Called(value)
 	return args.String(0), args.Error(1)
 }
@@ -74,6 +77,101 @@ func (rsm *ReschedulerServiceMock) IsWaitingToReschedule() bool {
 	return args.Bool(0)
 }
 
+func (rsm *ReschedulerServiceMock) CancelWait(value string) bool {
+	args := rsm.Called(value)
+	return args.Bool(0)
+}
+
+func (rsm *ReschedulerServiceMock) Stop() {
+	rsm.Called()
+}
+
+// messageHandler writes the message of each record on its own line
+type messageHandler struct {
+	w   io.Writer
+	mux *sync.Mutex
+}
+
+func newMessageLogger(w io.Writer) *slog.Logger {
+	return slog.New(messageHandler{w: w, mux: &sync.Mutex{}})
+}
+
+func (h messageHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h messageHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+	_, err := fmt.Fprintln(h.w, r.Message)
+	return err
+}
+
+func (h messageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+func (h messageHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+type HealthCheckerMock struct {
+	mock.Mock
+	name string
+}
+
+func (hm *HealthCheckerMock) Name() string {
+	return hm.name
+}
+
+func (hm *HealthCheckerMock) Check(ctx context.Context) error {
+	args := hm.Called(ctx)
+	return args.Error(0)
+}
+
+// serverFixture is a Server with mocked dependencies and its router,
+// shared by the test suites of the server. Its alerts succeed
+type serverFixture struct {
+	m   *ScalerServicerMock
+	am  *AlertServicerMock
+	nsm *NodeScalerMock
+	rsm *ReschedulerServiceMock
+	b   *bytes.Buffer
+	s   *Server
+	r   http.Handler
+}
+
+// setUpServer creates the server. `configure` is called before the router
+// is made, for the settings that must be applied before it
+func (f *serverFixture) setUpServer(configure func(s *Server)) {
+	f.m = new(ScalerServicerMock)
+	f.am = new(AlertServicerMock)
+	f.am.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	f.nsm = new(NodeScalerMock)
+	f.rsm = new(ReschedulerServiceMock)
+	f.b = new(bytes.Buffer)
+	f.s = NewServer(f.m, f.am, f.nsm, f.rsm, newMessageLogger(f.b), false, true, false, true)
+	if configure != nil {
+		configure(f.s)
+	}
+	f.r = f.s.MakeRouter("/")
+}
+
+// request serves a request with `body` to the router
+func (f *serverFixture) request(method, url, body string) (*httptest.ResponseRecorder, Response) {
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	return f.serve(req)
+}
+
+// serve serves `req` to the router and decodes its response
+func (f *serverFixture) serve(req *http.Request) (*httptest.ResponseRecorder, Response) {
+	rec := httptest.NewRecorder()
+	f.r.ServeHTTP(rec, req)
+	var resp Response
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec, resp
+}
+
 type ServerTestSuite struct {
 	suite.Suite
 	m   *ScalerServicerMock
@@ -82,7 +180,7 @@ type ServerTestSuite struct {
 	rsm *ReschedulerServiceMock
 	s   *Server
 	r   *mux.Router
-	l   *log.Logger
+	l   *slog.Logger
 	b   *bytes.Buffer
 }
 
@@ -97,7 +195,7 @@ func (s *ServerTestSuite) SetupTest() {
 	s.rsm = new(ReschedulerServiceMock)
 
 	s.b = new(bytes.Buffer)
-	s.l = log.New(s.b, "", 0)
+	s.l = newMessageLogger(s.b)
 	s.s = NewServer(s.m, s.am,
 		s.nsm, s.rsm, s.l, false, true, false, true)
 	s.r = s.s.MakeRouter("/")
@@ -116,6 +214,125 @@ func (s *ServerTestSuite) Test_Ping_Returns_StatusCode() {
 	s.Require().Equal(http.StatusOK, rec.Code)
 }
 
+func (s *ServerTestSuite) Test_Metrics_CountsScaleRequests() {
+	expMsg := "Scaled up service: web"
+	s.am.On("Send", "scale_service", "web", "Scale service up: web", "success", expMsg).Return(nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(1), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: false}, nil)
+
+	req, _ := http.NewRequest("POST", "/v1/scale-service?service=web&scale=up&by=1", nil)
+	s.r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req, _ = http.NewRequest("GET", "/metrics", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.Contains(rec.Body.String(),
+		`docker_scaler_scale_requests_total{at_bound="false",direction="up",endpoint="scale_service",outcome="success"}`)
+}
+
+func (s *ServerTestSuite) Test_Metrics_WithPrefix() {
+	m := s.s.MakeRouter("/scaler")
+	req, _ := http.NewRequest("GET", "/scaler/metrics", nil)
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, req)
+	s.Equal(http.StatusOK, rec.Code)
+}
+
+func (s *ServerTestSuite) Test_OpenAPI_Returns_Spec() {
+	req, _ := http.NewRequest("GET", "/v1/openapi.json", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.Equal("application/json", rec.Header().Get("Content-Type"))
+
+	var spec map[string]interface{}
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &spec))
+	s.Equal("3.0.3", spec["openapi"])
+}
+
+func (s *ServerTestSuite) Test_OpenAPI_DocumentsEveryRoute() {
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	s.Require().NoError(json.Unmarshal(openAPISpec, &spec))
+
+	s.s.SetDefaultsUpdater(&DefaultsUpdaterStub{})
+	r := s.s.MakeRouter("/")
+	documented := 0
+	err := r.Walk(func(route *mux.Route, router *mux.Router, ancestors []*mux.Route) error {
+		tmpl, err := route.GetPathTemplate()
+		if err != nil || !strings.HasPrefix(tmpl, "/v1/") {
+			return nil
+		}
+		methods, err := route.GetMethods()
+		if err != nil {
+			return nil
+		}
+		p := strings.TrimPrefix(tmpl, "/v1")
+		s.Contains(spec.Paths, p)
+		for _, m := range methods {
+			s.Contains(spec.Paths[p], strings.ToLower(m), "%s %s", m, tmpl)
+			documented++
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Equal(21, documented)
+}
+
+func (s *ServerTestSuite) Test_HealthLive_Returns_StatusCode() {
+	req, _ := http.NewRequest("GET", "/v1/health/live", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.RequireResponse(rec.Body.Bytes(), "OK", "live")
+}
+
+func (s *ServerTestSuite) Test_HealthReady_AllReachable() {
+	docker := &HealthCheckerMock{name: "docker"}
+	docker.On("Check", mock.Anything).Return(nil)
+	alertmanager := &HealthCheckerMock{name: "alertmanager"}
+	alertmanager.On("Check", mock.Anything).Return(nil)
+	s.s.SetHealthCheckers(docker, alertmanager)
+
+	req, _ := http.NewRequest("GET", "/v1/health/ready", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	var resp HealthResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal("OK", resp.Status)
+	s.Equal(map[string]HealthCheckResult{
+		"docker":       {Status: "OK"},
+		"alertmanager": {Status: "OK"},
+	}, resp.Checks)
+	docker.AssertExpectations(s.T())
+	alertmanager.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_HealthReady_DependencyUnreachable() {
+	docker := &HealthCheckerMock{name: "docker"}
+	docker.On("Check", mock.Anything).Return(nil)
+	cloud := &HealthCheckerMock{name: "cloud"}
+	cloud.On("Check", mock.Anything).Return(errors.New("invalid credentials"))
+	s.s.SetHealthCheckers(docker, cloud)
+
+	req, _ := http.NewRequest("GET", "/v1/health/ready", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusServiceUnavailable, rec.Code)
+
+	var resp HealthResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal("NOK", resp.Status)
+	s.Equal(map[string]HealthCheckResult{
+		"docker": {Status: "OK"},
+		"cloud":  {Status: "NOK", Message: "invalid credentials"},
+	}, resp.Checks)
+	s.RequireLogs(s.b.String(), "health-ready error: cloud: invalid credentials")
+}
+
 func (s *ServerTestSuite) Test_ScaleService_NoServiceNameInBody() {
 	errorMessage := "No service name in request"
 	url := "/v1/scale-service"
@@ -171,7 +388,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleUp() {
 	requestMessage := "Scale service up: web"
 	expMsg := "Scaled up service: web"
 	s.am.On("Send", "scale_service", "web", requestMessage, "success", expMsg).Return(nil)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(expMsg, false, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: false}, nil)
 
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 	url := "/v1/scale-service"
@@ -190,7 +407,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleUp_Query() {
 	requestMessage := "Scale service up: web"
 	expMsg := "Scaled up service: web"
 	s.am.On("Send", "scale_service", "web", requestMessage, "success", expMsg).Return(nil)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(1), service.ScaleUpDirection).Return(expMsg, false, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(1), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: false}, nil)
 
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 	url := "/v1/scale-service?service=web&scale=up&by=1"
@@ -205,11 +422,48 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleUp_Query() {
 	s.m.AssertExpectations(s.T())
 }
 
+func (s *ServerTestSuite) Test_ScaleService_RequestID() {
+	expMsg := "Scaled up service: web"
+	s.am.On("Send", "scale_service", "web", "Scale service up: web", "success", expMsg).Return(nil)
+	s.m.On("Scale", mock.MatchedBy(func(ctx context.Context) bool {
+		return logging.RequestID(ctx) == "req-1"
+	}), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg}, nil)
+	events, unsubscribe := s.s.events.Subscribe(1, service.EventFilter{})
+	defer unsubscribe()
+
+	req, _ := http.NewRequest("POST", "/v1/scale-service?service=web&scale=up", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.Equal("req-1", rec.Header().Get("X-Request-ID"))
+	s.m.AssertExpectations(s.T())
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	op, ok := s.s.operations.Get(resp.OperationID)
+	s.Require().True(ok)
+	s.Equal("req-1", op.RequestID)
+	s.Equal("req-1", (<-events).RequestID)
+}
+
+func (s *ServerTestSuite) Test_ScaleService_GeneratesRequestID() {
+	s.am.On("Send", "scale_service", "web", mock.Anything, "success", mock.Anything).Return(nil)
+	s.m.On("Scale", mock.Anything, "web", uint64(0), service.ScaleUpDirection).
+		Return(service.ScaleResult{Message: "Scaled up service: web"}, nil)
+
+	req, _ := http.NewRequest("POST", "/v1/scale-service?service=web&scale=up", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.Len(rec.Header().Get("X-Request-ID"), 16)
+}
+
 func (s *ServerTestSuite) Test_ScaleService_ScaleDown_NegativeBy_Query() {
 	requestMessage := "Scale service up: web"
 	expMsg := "Scaled up service: web"
 	s.am.On("Send", "scale_service", "web", requestMessage, "success", expMsg).Return(nil)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(2), service.ScaleUpDirection).Return(expMsg, false, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(2), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: false}, nil)
 
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 	url := "/v1/scale-service?service=web&scale=up&by=-2"
@@ -231,7 +485,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleUp_AlertFails() {
 	alertErr := errors.New("Alert failed")
 	alertMsg := fmt.Sprintf("Alertmanager did not receive message: %s, error: %v", expMsg, alertErr)
 	s.am.On("Send", "scale_service", "web", requestMessage, "success", expMsg).Return(alertErr)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(expMsg, false, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: false}, nil)
 
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 	url := "/v1/scale-service"
@@ -251,7 +505,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleUp_Bounded_AlertMaxTrue() {
 	requestMessage := "Scale service up: web"
 	expMsg := "web is already scaled to the maximum number of 5 replicas"
 	s.am.On("Send", "scale_service", "web", requestMessage, "success", expMsg).Return(nil)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(expMsg, true, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: true}, nil)
 
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 	url := "/v1/scale-service"
@@ -271,7 +525,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleUp_Bounded_AlertMaxFalse() {
 	jsonStr := `{"groupLabels":{"service": "web", "scale": "up"}}`
 	requestMessage := "Scale service up: web"
 	expMsg := "web is already scaled to the maximum number of 5 replicas"
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(expMsg, true, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: true}, nil)
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 
 	url := "/v1/scale-service"
@@ -295,7 +549,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleDown_Bounded_AlertMinTrue() {
 	requestMessage := "Scale service down: web"
 	expMsg := "web is already descaled to the minimum number of 1 replicas"
 
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(expMsg, true, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(service.ScaleResult{Message: expMsg, AtBound: true}, nil)
 	s.am.On("Send", "scale_service", "web", requestMessage, "success", expMsg).Return(nil)
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 
@@ -319,7 +573,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleDown_Bounded_AlertMinFalse() {
 	jsonStr := `{"groupLabels":{"service": "web", "scale": "down"}}`
 	requestMessage := "Scale service down: web"
 	expMsg := "web is already descaled to the minimum number of 1 replicas"
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(expMsg, true, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(service.ScaleResult{Message: expMsg, AtBound: true}, nil)
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 
 	url := "/v1/scale-service"
@@ -340,7 +594,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleUp_Error() {
 	jsonStr := `{"groupLabels":{"service": "web", "scale": "up"}}`
 	requestMessage := "Scale service up: web"
 	s.am.On("Send", "scale_service", "web", requestMessage, "error", expErr.Error()).Return(nil)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return("", false, expErr)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{}, expErr)
 
 	logMessage := fmt.Sprintf("scale-service error: %s", expErr.Error())
 	url := "/v1/scale-service"
@@ -360,7 +614,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleDown() {
 	requestMessage := "Scale service down: web"
 	expMsg := "Scaled down service: web"
 	s.am.On("Send", "scale_service", "web", requestMessage, "success", expMsg).Return(nil)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(expMsg, false, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(service.ScaleResult{Message: expMsg, AtBound: false}, nil)
 
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 	url := "/v1/scale-service"
@@ -379,7 +633,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleDown_Bounded() {
 	jsonStr := `{"groupLabels":{"service": "web", "scale": "down"}}`
 	requestMessage := "Scale service down: web"
 	expMsg := "Scaled down service: web"
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(expMsg, true, nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(service.ScaleResult{Message: expMsg, AtBound: true}, nil)
 
 	logMessage := fmt.Sprintf("scale-service success: %s", expMsg)
 	url := "/v1/scale-service"
@@ -400,7 +654,7 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleDown_Error() {
 	jsonStr := `{"groupLabels":{"service": "web", "scale": "down"}}`
 	requestMessage := "Scale service down: web"
 	s.am.On("Send", "scale_service", "web", requestMessage, "error", expErr.Error()).Return(nil)
-	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return("", false, expErr)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleDownDirection).Return(service.ScaleResult{}, expErr)
 
 	logMessage := fmt.Sprintf("scale-service error: %s", expErr.Error())
 	url := "/v1/scale-service"
@@ -415,6 +669,60 @@ func (s *ServerTestSuite) Test_ScaleService_ScaleDown_Error() {
 	s.m.AssertExpectations(s.T())
 }
 
+func (s *ServerTestSuite) Test_ScaleService_StructuredResponse() {
+	expMsg := "Scaling web from 2 to 3 replicas (min: 1, max: 4)"
+	result := service.ScaleResult{
+		Message: expMsg, Previous: 2, Current: 3, Min: 1, Max: 4}
+	s.am.On("Send", "scale_service", "web", "Scale service up: web", "success", expMsg).Return(nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(1), service.ScaleUpDirection).Return(result, nil)
+
+	req, _ := http.NewRequest("POST", "/v1/scale-service?service=web&scale=up&by=1", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal("web", resp.Service)
+	s.Equal("up", resp.Direction)
+	s.Equal(uint64(2), *resp.Previous)
+	s.Equal(uint64(3), *resp.Current)
+	s.Equal(uint64(1), *resp.Min)
+	s.Equal(uint64(4), *resp.Max)
+	s.False(*resp.AtBound)
+	s.Empty(resp.ErrorCode)
+}
+
+func (s *ServerTestSuite) Test_ScaleService_ErrorCodes() {
+	s.am.On("Send", "scale_service", mock.Anything, mock.Anything, "error", mock.Anything).Return(nil)
+	expErr := fmt.Errorf("Unable to scale service: web")
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{}, expErr)
+
+	tt := []struct {
+		url       string
+		body      string
+		code      int
+		errorCode string
+	}{
+		{"/v1/scale-service?scale=up", "", http.StatusBadRequest, ErrorCodeMissingService},
+		{"/v1/scale-service?service=web", "", http.StatusBadRequest, ErrorCodeMissingDirection},
+		{"/v1/scale-service?service=web&scale=sideways", "", http.StatusBadRequest, ErrorCodeInvalidDirection},
+		{"/v1/scale-service?service=web&scale=up", "", http.StatusInternalServerError, ErrorCodeScaleFailed},
+	}
+
+	for _, tc := range tt {
+		req, _ := http.NewRequest("POST", tc.url, bytes.NewBufferString(tc.body))
+		rec := httptest.NewRecorder()
+		s.r.ServeHTTP(rec, req)
+		s.Equal(tc.code, rec.Code, tc.url)
+
+		var resp Response
+		s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+		s.Equal("NOK", resp.Status)
+		s.Equal(tc.errorCode, resp.ErrorCode, tc.url)
+	}
+}
+
 func (s *ServerTestSuite) Test_ScaleNode_Nil_NodeScaler() {
 	server := NewServer(s.m, s.am,
 		nil, s.rsm, s.l, false, true, false, true)
@@ -488,6 +796,114 @@ func (s *ServerTestSuite) Test_ScaleNode_ScaleByDeltaError() {
 
 }
 
+func (s *ServerTestSuite) Test_ScaleNode_BudgetRejected() {
+
+	url := "/v1/scale-nodes?type=worker&by=1"
+	requestMessage := "Scale nodes up on: mock, by: 1, type: worker"
+	budgetErr := &service.BudgetError{NodeType: cloud.NodeWorkerType, Current: 3, Target: 4, Allowed: 3,
+		Reason: "21 nodes in every cluster would exceed NODE_BUDGET_MAX_NODES of 20"}
+	jsonStr := `{"groupLabels":{"scale":"up"}}`
+
+	s.am.On("Send", "node_budget", "mock", requestMessage, "error", budgetErr.Error()).Return(nil)
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleUpDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(3), budgetErr)
+
+	req, _ := http.NewRequest("POST", url, bytes.NewBufferString(jsonStr))
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Equal(http.StatusConflict, rec.Code)
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal(ErrorCodeBudgetExceeded, resp.ErrorCode)
+	s.Equal("Node budget rejected scaling worker nodes from 3 to 4: 21 nodes in every cluster would exceed NODE_BUDGET_MAX_NODES of 20", resp.Message)
+	s.RequireLogs(s.b.String(), requestMessage, fmt.Sprintf("scale-nodes budget: %s", budgetErr))
+	s.am.AssertExpectations(s.T())
+	s.nsm.AssertExpectations(s.T())
+
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+}
+
+func (s *ServerTestSuite) Test_ScaleNode_BudgetCapped() {
+
+	url := "/v1/scale-nodes?type=worker&by=3"
+	requestMessage := "Scale nodes up on: mock, by: 3, type: worker"
+	budgetErr := &service.BudgetError{NodeType: cloud.NodeWorkerType, Current: 3, Target: 6, Allowed: 4,
+		Reason: "an estimated 10.50 per hour would exceed NODE_BUDGET_MAX_HOURLY_COST of 10.00"}
+	message := "Changing the number of worker nodes on mock from 3 to 4, capped by the node budget"
+	jsonStr := `{"groupLabels":{"scale":"up"}}`
+
+	s.am.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(3), service.ScaleUpDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(4), budgetErr)
+	statusCs := make(chan chan<- string, 1)
+	s.rsm.On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"), time.Duration(0),
+		mock.Anything, mock.Anything, mock.Anything).Return().Run(func(args mock.Arguments) {
+		statusCs <- args.Get(6).(chan<- string)
+	})
+
+	req, _ := http.NewRequest("POST", url, bytes.NewBufferString(jsonStr))
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Equal(http.StatusOK, rec.Code)
+	s.RequireResponse(rec.Body.Bytes(), "OK", message)
+	s.am.AssertCalled(s.T(), "Send", "node_budget", "mock", requestMessage, "error",
+		"Node budget capped worker nodes at 4 instead of 6: an estimated 10.50 per hour would exceed NODE_BUDGET_MAX_HOURLY_COST of 10.00")
+	s.am.AssertCalled(s.T(), "Send", "scale_nodes", "mock", requestMessage, "success", message)
+
+	statusC := <-statusCs
+	statusC <- "4 worker nodes are online"
+	s.s.waits.Wait()
+}
+
+func (s *ServerTestSuite) Test_ScaleNode_DisruptionBudgetRejected() {
+
+	url := "/v1/scale-nodes?type=worker&by=1"
+	requestMessage := "Scale nodes down on: mock, by: 1, type: worker"
+	disruptionErr := &service.DisruptionBudgetError{NodeType: cloud.NodeWorkerType, Current: 3, Target: 2, Allowed: 3,
+		Service: "web", MinAvailable: 3, Left: 2}
+	jsonStr := `{"groupLabels":{"scale":"down"}}`
+
+	s.am.On("Send", "disruption_budget", "mock", requestMessage, "error", disruptionErr.Error()).Return(nil)
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleDownDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(3), disruptionErr)
+
+	req, _ := http.NewRequest("POST", url, bytes.NewBufferString(jsonStr))
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Equal(http.StatusConflict, rec.Code)
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal(ErrorCodeDisruptionBudget, resp.ErrorCode)
+	s.Equal("Disruption budget rejected scaling worker nodes from 3 to 2: removing a node could leave web with 2 running replicas, less than its minimum available of 3", resp.Message)
+	s.am.AssertExpectations(s.T())
+
+	op, _ := s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+}
+
+func (s *ServerTestSuite) Test_ScaleNode_DisruptionBudgetCapped() {
+
+	url := "/v1/scale-nodes?type=worker&by=2"
+	requestMessage := "Scale nodes down on: mock, by: 2, type: worker"
+	disruptionErr := &service.DisruptionBudgetError{NodeType: cloud.NodeWorkerType, Current: 4, Target: 2, Allowed: 3,
+		Service: "web", MinAvailable: 3, Left: 2}
+	message := "Changing the number of worker nodes on mock from 4 to 3, capped by the disruption budget of web"
+	jsonStr := `{"groupLabels":{"scale":"down"}}`
+
+	s.am.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(2), service.ScaleDownDirection, cloud.NodeWorkerType, "").Return(uint64(4), uint64(3), disruptionErr)
+	s.rsm.On("IsWaitingToReschedule").Return(false)
+
+	req, _ := http.NewRequest("POST", url, bytes.NewBufferString(jsonStr))
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Equal(http.StatusOK, rec.Code)
+	s.RequireResponse(rec.Body.Bytes(), "OK", message)
+	s.am.AssertCalled(s.T(), "Send", "disruption_budget", "mock", requestMessage, "error",
+		"Disruption budget capped worker nodes at 3 instead of 2: removing 2 nodes could leave web with 2 running replicas, less than its minimum available of 3")
+	s.am.AssertCalled(s.T(), "Send", "scale_nodes", "mock", requestMessage, "success", message)
+}
+
 func (s *ServerTestSuite) Test_ScaleNode_IncorrectNodeType() {
 
 	url := "/v1/scale-nodes?type=invalid&by=1"
@@ -520,23 +936,20 @@ func (s *ServerTestSuite) Test_ScaleNode_ScaleWorkerUp() {
 	s.am.
 		On("Send", "scale_nodes", "mock", requestMessage, "success", message).Return(nil).
 		On("Send", "scale_nodes", "reschedule", "Wait to reschedule", "pending", rescheduleMsg).Return(nil).
-		On("Send", "reschedule_service", "reschedule", "Waiting for nodes to scale", "error", mock.AnythingOfType("string")).Return(nil).
 		On("Send", "reschedule_service", "reschedule", "Waiting for nodes to scale", "pending", mock.AnythingOfType("string")).Return(nil).
 		On("Send", "reschedule_service", "reschedule", "4 worker nodes are online, status: web_test rescheduled", "success", "4 worker nodes are online, status: web_test rescheduled").Return(nil).Run(func(args mock.Arguments) {
 		done <- struct{}{}
 	})
 
 	var tickerC chan<- time.Time
-	var errC chan<- error
 	var statusC chan<- string
 	waitCalled := make(chan struct{})
 	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleUpDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(4), nil)
 	s.rsm.
-		On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"),
+		On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"), time.Duration(0),
 			mock.AnythingOfType("chan<- time.Time"), mock.AnythingOfType("chan<- error"), mock.AnythingOfType("chan<- string")).Return().Run(func(args mock.Arguments) {
-		tickerC = args.Get(3).(chan<- time.Time)
-		errC = args.Get(4).(chan<- error)
-		statusC = args.Get(5).(chan<- string)
+		tickerC = args.Get(4).(chan<- time.Time)
+		statusC = args.Get(6).(chan<- string)
 		waitCalled <- struct{}{}
 	})
 
@@ -558,7 +971,6 @@ H:
 	}
 	go func() {
 		tickerC <- time.Now()
-		errC <- errors.New("Here is an error")
 		statusC <- "4 worker nodes are online, status: web_test rescheduled"
 	}()
 
@@ -575,7 +987,78 @@ L:
 
 	s.RequireLogs(s.b.String(), requestMessage, logMessage, logMessage2)
 	logMessages := strings.Split(s.b.String(), "\n")
-	s.Len(logMessages, 7)
+	s.Len(logMessages, 6)
+
+	s.rsm.AssertExpectations(s.T())
+	s.nsm.AssertExpectations(s.T())
+	s.am.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_ScaleNode_ScaleWorkerUp_RescheduleError() {
+	url := "/v1/scale-nodes?type=worker&by=1"
+	requestMessage := "Scale nodes up on: mock, by: 1, type: worker"
+	message := "Changing the number of worker nodes on mock from 3 to 4"
+	logMessage := fmt.Sprintf("scale-nodes success: %s", message)
+	rescheduleMsg := "Waiting for worker nodes to scale from 3 to 4 for rescheduling"
+	logMessage2 := fmt.Sprintf("scale-nodes: %s", rescheduleMsg)
+	jsonStr := `{"groupLabels":{"scale":"up"}}`
+
+	done := make(chan struct{})
+	s.am.
+		On("Send", "scale_nodes", "mock", requestMessage, "success", message).Return(nil).
+		On("Send", "scale_nodes", "reschedule", "Wait to reschedule", "pending", rescheduleMsg).Return(nil).
+		On("Send", "reschedule_service", "reschedule", "Waiting for nodes to scale", "pending", mock.AnythingOfType("string")).Return(nil).
+		On("Send", "reschedule_service", "reschedule", "Waiting for nodes to scale", "error", "Here is an error").Return(nil).Run(func(args mock.Arguments) {
+		done <- struct{}{}
+	})
+
+	var tickerC chan<- time.Time
+	var errC chan<- error
+	waitCalled := make(chan struct{})
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleUpDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(4), nil)
+	s.rsm.
+		On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"), time.Duration(0),
+			mock.AnythingOfType("chan<- time.Time"), mock.AnythingOfType("chan<- error"), mock.AnythingOfType("chan<- string")).Return().Run(func(args mock.Arguments) {
+		tickerC = args.Get(4).(chan<- time.Time)
+		errC = args.Get(5).(chan<- error)
+		waitCalled <- struct{}{}
+	})
+
+	req, _ := http.NewRequest("POST", url, bytes.NewBufferString(jsonStr))
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Equal(http.StatusOK, rec.Code)
+	s.RequireResponse(rec.Body.Bytes(), "OK", message)
+
+H:
+	for {
+		select {
+		case <-time.After(time.Second * 5):
+			s.Fail("Timeout")
+			return
+		case <-waitCalled:
+			break H
+		}
+	}
+	go func() {
+		tickerC <- time.Now()
+		errC <- errors.New("Here is an error")
+	}()
+
+L:
+	for {
+		select {
+		case <-time.After(time.Second * 5):
+			s.Fail("Timeout")
+			return
+		case <-done:
+			break L
+		}
+	}
+
+	s.RequireLogs(s.b.String(), requestMessage, logMessage, logMessage2)
+	logMessages := strings.Split(s.b.String(), "\n")
+	s.Len(logMessages, 6)
 
 	s.rsm.AssertExpectations(s.T())
 	s.nsm.AssertExpectations(s.T())
@@ -606,6 +1089,26 @@ func (s *ServerTestSuite) Test_ScaleNode_ScaleManagerDown() {
 	s.rsm.AssertExpectations(s.T())
 }
 
+func (s *ServerTestSuite) Test_ScaleNode_StructuredResponse() {
+	message := "Changing the number of manager nodes on mock from 3 to 2"
+	s.am.On("Send", "scale_nodes", "mock", mock.Anything, "success", message).Return(nil)
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleDownDirection, cloud.NodeManagerType, "").Return(uint64(3), uint64(2), nil)
+	s.rsm.On("IsWaitingToReschedule").Return(false)
+
+	req, _ := http.NewRequest("POST", "/v1/scale-nodes?type=manager&by=1&scale=down", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Equal("down", resp.Direction)
+	s.Equal("manager", resp.NodeType)
+	s.Equal(uint64(3), *resp.NodesBefore)
+	s.Equal(uint64(2), *resp.NodesAfter)
+	s.False(*resp.AtBound)
+}
+
 func (s *ServerTestSuite) Test_ScaleNode_ScaleManagerDown_QueryInBody() {
 	url := "/v1/scale-nodes"
 	requestMessage := "Scale nodes down on: mock, by: 1, type: manager"
@@ -667,7 +1170,7 @@ func (s *ServerTestSuite) Test_ScaleNode_ScaleWorkerUp_QueryInURL() {
 	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleUpDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(4), nil)
 
 	waitCalled := make(chan struct{})
-	s.rsm.On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"),
+	s.rsm.On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"), time.Duration(0),
 		mock.AnythingOfType("chan<- time.Time"), mock.AnythingOfType("chan<- error"), mock.AnythingOfType("chan<- string")).Return().Run(func(args mock.Arguments) {
 		waitCalled <- struct{}{}
 	})
@@ -813,7 +1316,7 @@ func (s *ServerTestSuite) Test_ScaleNode_ScaleWorkerDown_IsWaitingToReschedule()
 
 	waitCalled := make(chan struct{})
 	s.rsm.On("IsWaitingToReschedule").Return(true).
-		On("RescheduleServicesWaitForNodes", false, 2, mock.AnythingOfType("string"),
+		On("RescheduleServicesWaitForNodes", false, 2, mock.AnythingOfType("string"), time.Duration(0),
 			mock.AnythingOfType("chan<- time.Time"), mock.AnythingOfType("chan<- error"), mock.AnythingOfType("chan<- string")).Return().Run(func(args mock.Arguments) {
 		waitCalled <- struct{}{}
 	})
@@ -947,6 +1450,220 @@ func (s *ServerTestSuite) Test_RescheduleOneService() {
 
 }
 
+func (s *ServerTestSuite) Test_RescheduleKey_SameSecond() {
+	first, second := rescheduleKey(), rescheduleKey()
+	s.NotEqual(first, second)
+}
+
+func (s *ServerTestSuite) Test_Shutdown_InterruptsPendingReschedule() {
+	url := "/v1/scale-nodes?type=worker&by=1&scale=up"
+	interruptMsg := "Interrupted: docker-scaler stopped while waiting for 4 worker nodes to activate"
+
+	s.am.
+		On("Send", "scale_nodes", "mock", mock.AnythingOfType("string"), "success", mock.AnythingOfType("string")).Return(nil).
+		On("Send", "scale_nodes", "reschedule", "Wait to reschedule", "pending", mock.AnythingOfType("string")).Return(nil).
+		On("Send", "reschedule_service", "reschedule", "Waiting for nodes to scale", "error", interruptMsg).Return(nil)
+
+	var errC chan<- error
+	waitCalled := make(chan struct{})
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleUpDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(4), nil)
+	s.rsm.
+		On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"), time.Duration(0),
+			mock.AnythingOfType("chan<- time.Time"), mock.AnythingOfType("chan<- error"), mock.AnythingOfType("chan<- string")).Return().Run(func(args mock.Arguments) {
+		errC = args.Get(5).(chan<- error)
+		close(waitCalled)
+	}).
+		On("Stop").Return().Run(func(args mock.Arguments) {
+		go func() {
+			errC <- errors.New(interruptMsg)
+		}()
+	})
+
+	req, _ := http.NewRequest("POST", url, nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	select {
+	case <-waitCalled:
+	case <-time.After(time.Second * 5):
+		s.Fail("Timeout")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	s.s.shutdown(ctx, &http.Server{}, nil)
+
+	s.Contains(s.b.String(), fmt.Sprintf("scale-nodes-reschedule error: %s", interruptMsg))
+	s.Contains(s.b.String(), "Docker Scaler stopped")
+	s.rsm.AssertExpectations(s.T())
+	s.am.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_Shutdown_GracePeriodEnds() {
+	s.rsm.On("Stop").Return()
+	s.s.waits.Add(1)
+	defer s.s.waits.Done()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*10)
+	defer cancel()
+	s.s.shutdown(ctx, &http.Server{}, nil)
+
+	s.RequireLogs(s.b.String(), "shutdown error: grace period ended before pending reschedules were interrupted")
+	s.rsm.AssertExpectations(s.T())
+}
+
+func (s *ServerTestSuite) Test_Operations_ScaleService() {
+	expMsg := "Scaling web from 2 to 3 replicas (min: 1, max: 5)"
+	s.am.On("Send", "scale_service", "web", "Scale service up: web", "success", expMsg).Return(nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{Message: expMsg, AtBound: false}, nil)
+
+	req, _ := http.NewRequest("POST", "/v1/scale-service?service=web&scale=up", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	s.Require().NotEmpty(resp.OperationID)
+
+	req, _ = http.NewRequest("GET", "/v1/operations/"+resp.OperationID, nil)
+	rec = httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	var opResp OperationResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &opResp))
+	s.Equal("OK", opResp.Status)
+	s.Equal(resp.OperationID, opResp.Operation.ID)
+	s.Equal("scale_service", opResp.Operation.Kind)
+	s.Equal("web", opResp.Operation.Target)
+	s.Equal(service.OperationDone, opResp.Operation.State)
+	s.Equal(expMsg, opResp.Operation.Message)
+	s.NotNil(opResp.Operation.FinishedAt)
+
+	req, _ = http.NewRequest("GET", "/v1/operations", nil)
+	rec = httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	var opsResp OperationsResponse
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &opsResp))
+	s.Require().Len(opsResp.Operations, 1)
+	s.Equal(resp.OperationID, opsResp.Operations[0].ID)
+}
+
+func (s *ServerTestSuite) Test_Operations_ScaleServiceError() {
+	expErr := fmt.Errorf("Unable to scale service: web")
+	s.am.On("Send", "scale_service", "web", "Scale service up: web", "error", expErr.Error()).Return(nil)
+	s.m.On("Scale", mock.AnythingOfType("*context.valueCtx"), "web", uint64(0), service.ScaleUpDirection).Return(service.ScaleResult{}, expErr)
+
+	req, _ := http.NewRequest("POST", "/v1/scale-service?service=web&scale=up", nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusInternalServerError, rec.Code)
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+	op, ok := s.s.operations.Get(resp.OperationID)
+	s.Require().True(ok)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal(expErr.Error(), op.Error)
+}
+
+func (s *ServerTestSuite) Test_Operations_DoesNotExist() {
+	for _, method := range []string{"GET", "DELETE"} {
+		req, _ := http.NewRequest(method, "/v1/operations/DOESNOTEXIST", nil)
+		rec := httptest.NewRecorder()
+		s.r.ServeHTTP(rec, req)
+		s.Equal(http.StatusNotFound, rec.Code)
+		s.RequireResponse(rec.Body.Bytes(), "NOK", "Operation DOESNOTEXIST does not exist")
+	}
+}
+
+func (s *ServerTestSuite) Test_Operations_CancelFinished() {
+	op := s.s.operations.Create("scale_service", "web", "")
+	s.s.operations.Finish(op.ID, "done", nil)
+
+	req, _ := http.NewRequest("DELETE", "/v1/operations/"+op.ID, nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Equal(http.StatusConflict, rec.Code)
+	s.RequireResponse(rec.Body.Bytes(), "NOK",
+		fmt.Sprintf("Operation %s is not waiting for nodes (state: done)", op.ID))
+}
+
+func (s *ServerTestSuite) Test_Operations_CancelWaitingForNodes() {
+	url := "/v1/scale-nodes?type=worker&by=1&scale=up"
+	cancelMsg := "Canceled: stopped waiting for 4 worker nodes to activate"
+	failed := make(chan struct{})
+
+	s.am.
+		On("Send", "scale_nodes", "mock", mock.AnythingOfType("string"), "success", mock.AnythingOfType("string")).Return(nil).
+		On("Send", "scale_nodes", "reschedule", "Wait to reschedule", "pending", mock.AnythingOfType("string")).Return(nil).
+		On("Send", "reschedule_service", "reschedule", "Waiting for nodes to scale", "error", cancelMsg).Return(nil).Run(func(args mock.Arguments) {
+		close(failed)
+	})
+
+	var errC chan<- error
+	var rescheduleKey string
+	waitCalled := make(chan struct{})
+	s.nsm.On("Scale", mock.AnythingOfType("*context.valueCtx"), uint64(1), service.ScaleUpDirection, cloud.NodeWorkerType, "").Return(uint64(3), uint64(4), nil)
+	s.rsm.
+		On("RescheduleServicesWaitForNodes", false, 4, mock.AnythingOfType("string"), time.Duration(0),
+			mock.AnythingOfType("chan<- time.Time"), mock.AnythingOfType("chan<- error"), mock.AnythingOfType("chan<- string")).Return().Run(func(args mock.Arguments) {
+		rescheduleKey = args.String(2)
+		errC = args.Get(5).(chan<- error)
+		close(waitCalled)
+	})
+
+	req, _ := http.NewRequest("POST", url, nil)
+	rec := httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+
+	var resp Response
+	s.Require().NoError(json.Unmarshal(rec.Body.Bytes(), &resp))
+
+	select {
+	case <-waitCalled:
+	case <-time.After(time.Second * 5):
+		s.Fail("Timeout")
+		return
+	}
+
+	op, ok := s.s.operations.Get(resp.OperationID)
+	s.Require().True(ok)
+	s.Equal(service.OperationWaitingForNodes, op.State)
+
+	s.rsm.On("CancelWait", rescheduleKey).Return(true).Run(func(args mock.Arguments) {
+		go func() {
+			errC <- errors.New(cancelMsg)
+		}()
+	})
+
+	req, _ = http.NewRequest("DELETE", "/v1/operations/"+resp.OperationID, nil)
+	rec = httptest.NewRecorder()
+	s.r.ServeHTTP(rec, req)
+	s.Require().Equal(http.StatusOK, rec.Code)
+	s.RequireResponse(rec.Body.Bytes(), "OK", fmt.Sprintf("Canceling operation %s", resp.OperationID))
+
+	select {
+	case <-failed:
+	case <-time.After(time.Second * 5):
+		s.Fail("Timeout")
+		return
+	}
+	s.s.waits.Wait()
+
+	op, _ = s.s.operations.Get(resp.OperationID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal(cancelMsg, op.Error)
+	s.rsm.AssertExpectations(s.T())
+	s.am.AssertExpectations(s.T())
+}
+
 func (s *ServerTestSuite) RequireLogs(logMessage string, expectedLogs ...string) {
 	logMessages := strings.Split(logMessage, "\n")
 	s.Require().True(len(logMessages) >= len(expectedLogs))
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+// SetOperationStore keeps the operations in `store`, usually a store saved
+// to STATE_DIR. The operations a previous run left unfinished are resumed
+// or expired once the server runs, or once it starts leading with an
+// elector. `timeouts` are the RESCHEDULE_TIMEOUT of each cluster. It must
+// be called before the server runs
+func (s *Server) SetOperationStore(store *service.OperationStore, timeouts map[string]time.Duration) {
+	store.SetErrorHandler(func(err error) {
+		s.logger.Error(err.Error())
+	})
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.operations = store
+	s.rescheduleTimeouts = timeouts
+	s.interrupted = nil
+	for _, op := range store.List() {
+		if !op.Finished() {
+			s.interrupted = append(s.interrupted, op)
+		}
+	}
+}
+
+// resumeOperations resumes or expires the operations left unfinished by a
+// previous run. Each operation is only handled once
+func (s *Server) resumeOperations() {
+	s.mu.Lock()
+	ops := s.interrupted
+	s.interrupted = nil
+	s.mu.Unlock()
+	for _, op := range ops {
+		s.resumeOperation(op)
+	}
+}
+
+// resumeOperation waits for nodes again when `op` was waiting for nodes
+// within RESCHEDULE_TIMEOUT, for the rest of RESCHEDULE_TIMEOUT. An
+// operation waiting for an approval that was saved keeps waiting until the
+// approval expires. Other operations are failed, since it is not known how
+// far they got
+func (s *Server) resumeOperation(op service.Operation) {
+	ctx := logging.WithRequestID(context.Background(), op.RequestID)
+	logger := s.logger.With("operation_id", op.ID)
+	ctx, c, err := s.cluster(ctx, op.Cluster)
+	if err == nil && op.State == service.OperationAwaitingApproval {
+		if a, ok := s.pendingApproval(op.ID); ok {
+			message := fmt.Sprintf("Still waiting for approval %s after a restart: %s", a.ID, a.Change())
+			logger.InfoContext(ctx, fmt.Sprintf("resume-operation: %s", message), "approval_id", a.ID)
+			s.sendAlert(ctx, "approval", a.Target, "Resume after restart", "pending", message)
+			s.operations.AddResult(op.ID, "pending", message)
+			s.expireApprovalLater(ctx, a)
+			return
+		}
+	}
+	if err == nil && op.State == service.OperationQueued {
+		err = fmt.Errorf("Stopped waiting to scale %s until its update completes, since docker-scaler restarted", op.Target)
+	} else if err == nil && op.Wait == nil {
+		err = errors.New("Interrupted by a restart of docker-scaler")
+	}
+	if err != nil {
+		logger.ErrorContext(ctx, fmt.Sprintf("resume-operation error: %s", err))
+		s.sendAlert(ctx, op.Kind, op.Target, "Resume after restart", "error", err.Error())
+		kind, serviceName := operationEvent(op)
+		s.publish(ctx, kind, serviceName, op.ID, "error", err.Error())
+		s.operations.Finish(op.ID, "", err)
+		return
+	}
+
+	wait := *op.Wait
+	s.mu.RLock()
+	timeout := s.rescheduleTimeouts[service.ClusterFrom(ctx)]
+	s.mu.RUnlock()
+	waited := time.Since(wait.StartedAt)
+	if waited >= timeout {
+		err := fmt.Errorf("Stopped waiting for %s nodes to scale from %d to %d, since docker-scaler restarted after waiting %d seconds", wait.NodeType, wait.Previous, wait.Target, int(waited.Seconds()))
+		logger.ErrorContext(ctx, fmt.Sprintf("scale-nodes-reschedule error: %s", err))
+		s.sendAlert(ctx, "reschedule_service", "reschedule", "Resume after restart", "error", err.Error())
+		s.publish(ctx, service.EventReschedule, "", op.ID, "error", err.Error())
+		s.operations.Finish(op.ID, "", err)
+		return
+	}
+
+	message := fmt.Sprintf("Resuming the wait for %s nodes to scale from %d to %d after a restart", wait.NodeType, wait.Previous, wait.Target)
+	logger.InfoContext(ctx, fmt.Sprintf("scale-nodes-reschedule: %s", message))
+	s.sendAlert(ctx, "reschedule_service", "reschedule", "Resume after restart", "pending", message)
+	s.publish(ctx, service.EventScaleNodes, "", op.ID, "pending", message)
+	s.operations.AddResult(op.ID, "pending", message)
+
+	s.waits.Add(1)
+	go func() {
+		defer s.waits.Done()
+		s.rescheduleServiceWait(ctx, c.Rescheduler, op.ID, wait.IsManager, wait.NodeType,
+			wait.Previous, wait.Target, wait.Key, wait.Direction, timeout-waited)
+	}()
+}
+
+// operationEvent returns the kind and the service of the events of
+// operation `op`. Operations scaling nodes do not record the service whose
+// labels configured them
+func operationEvent(op service.Operation) (string, string) {
+	switch op.Kind {
+	case "scale_service", "scale_preserve":
+		return service.EventScaleService, op.Target
+	case "scale_nodes":
+		return service.EventScaleNodes, ""
+	case "reschedule_service":
+		return service.EventReschedule, op.Target
+	case "update_defaults":
+		return service.EventUpdateDefaults, ""
+	}
+	return service.EventReschedule, ""
+}
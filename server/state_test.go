@@ -0,0 +1,242 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/config"
+	"github.com/thomasjpfan/docker-scaler/service"
+)
+
+type StateTestSuite struct {
+	suite.Suite
+	path  string
+	am    *AlertServicerMock
+	rsm   *ReschedulerServiceMock
+	s     *Server
+	store *service.OperationStore
+}
+
+func TestStateUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(StateTestSuite))
+}
+
+func (s *StateTestSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "state.json")
+	s.am = new(AlertServicerMock)
+	s.am.On("Send", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	s.rsm = new(ReschedulerServiceMock)
+	s.s = NewServer(new(ScalerServicerMock), s.am, nil, s.rsm, newMessageLogger(new(bytes.Buffer)),
+		false, false, false, false)
+}
+
+// previousRun saves an operation waiting for nodes since `startedAt` and
+// a pending operation, as a run that stopped would
+func (s *StateTestSuite) previousRun(startedAt time.Time) (service.Operation, service.Operation) {
+	store, err := service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	waiting := store.Create("scale_nodes", "worker", "req-1")
+	store.SetState(waiting.ID, service.OperationWaitingForNodes, "Waiting")
+	store.SetWait(waiting.ID, service.OperationWait{
+		Key: "20180101T000000", NodeType: "worker", Previous: 2, Target: 4,
+		Direction: service.ScaleUpDirection, StartedAt: startedAt,
+	})
+	pending := store.Create("scale_service", "web", "req-2")
+	done := store.Create("scale_service", "api", "req-3")
+	store.Finish(done.ID, "Scaled api", nil)
+
+	s.store, err = service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	s.s.SetOperationStore(s.store, map[string]time.Duration{config.DefaultCluster: time.Hour})
+	return waiting, pending
+}
+
+func (s *StateTestSuite) Test_Resume_WaitsForNodes() {
+	waiting, pending := s.previousRun(time.Now().UTC().Add(-time.Minute))
+	statusCs := make(chan chan<- string, 1)
+	s.rsm.On("RescheduleServicesWaitForNodes", false, 4, "20180101T000000",
+		mock.MatchedBy(func(timeOut time.Duration) bool {
+			return timeOut > 58*time.Minute && timeOut < 59*time.Minute
+		}),
+		mock.Anything, mock.Anything, mock.Anything).Return().Run(func(args mock.Arguments) {
+		statusCs <- args.Get(6).(chan<- string)
+	})
+
+	s.s.resumeOperations()
+	statusC := <-statusCs
+	s.rsm.AssertExpectations(s.T())
+	s.am.AssertCalled(s.T(), "Send", "reschedule_service", "reschedule", "Resume after restart", "pending",
+		"Resuming the wait for worker nodes to scale from 2 to 4 after a restart")
+
+	op, _ := s.s.operations.Get(pending.ID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal("Interrupted by a restart of docker-scaler", op.Error)
+
+	statusC <- "Rescheduled services"
+	s.s.waits.Wait()
+	op, _ = s.s.operations.Get(waiting.ID)
+	s.Equal(service.OperationDone, op.State)
+	s.Equal("Rescheduled services", op.Message)
+
+	reopened, err := service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	op, _ = reopened.Get(waiting.ID)
+	s.Equal(service.OperationDone, op.State)
+}
+
+func (s *StateTestSuite) Test_Resume_Expired() {
+	waiting, _ := s.previousRun(time.Now().UTC().Add(-2 * time.Hour))
+
+	s.s.resumeOperations()
+	s.rsm.AssertNotCalled(s.T(), "RescheduleServicesWaitForNodes",
+		mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	op, _ := s.s.operations.Get(waiting.ID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Contains(op.Error, "Stopped waiting for worker nodes to scale from 2 to 4, since docker-scaler restarted after waiting 7200 seconds")
+	s.am.AssertCalled(s.T(), "Send", "reschedule_service", "reschedule", "Resume after restart", "error", op.Error)
+}
+
+func (s *StateTestSuite) Test_Resume_UnknownCluster() {
+	store, err := service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	op := store.Create("scale_nodes", "worker", "")
+	store.SetCluster(op.ID, "eu-west")
+	store.SetWait(op.ID, service.OperationWait{Key: "key", StartedAt: time.Now().UTC()})
+	s.s.SetOperationStore(store, nil)
+
+	s.s.resumeOperations()
+	op, _ = s.s.operations.Get(op.ID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal("Unknown cluster: eu-west, cluster can only be default", op.Error)
+}
+
+func (s *StateTestSuite) Test_Resume_AwaitsSavedApproval() {
+	dir := s.T().TempDir()
+	approvals, err := service.OpenApprovals(filepath.Join(dir, "approvals.json"))
+	s.Require().NoError(err)
+	approvals.SetPolicy(config.DefaultCluster, service.ApprovalPolicy{MaxReplicaFactor: 2, Timeout: 20 * time.Millisecond})
+	store, err := service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	parked := store.Create("scale_service", "web", "")
+	lost := store.Create("scale_service", "api", "")
+	for _, op := range []service.Operation{parked, lost} {
+		store.SetState(op.ID, service.OperationAwaitingApproval, "Awaiting approval")
+	}
+	err = approvals.Check(service.WithCluster(context.Background(), config.DefaultCluster),
+		service.ApprovalChange{Kind: "scale_service", Target: "web", Previous: 1, Requested: 10})
+	a := err.(*service.ApprovalRequiredError).Approval
+	approvals.SetOperation(a.ID, parked.ID)
+
+	approvals, err = service.OpenApprovals(filepath.Join(dir, "approvals.json"))
+	s.Require().NoError(err)
+	s.s.SetApprovals(approvals)
+	store, err = service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	s.s.SetOperationStore(store, nil)
+	s.s.resumeOperations()
+
+	op, _ := s.s.operations.Get(parked.ID)
+	s.Equal(service.OperationAwaitingApproval, op.State)
+	s.Equal("Still waiting for approval "+a.ID+" after a restart: Scaling web from 1 to 10 replicas",
+		op.Results[len(op.Results)-1].Message)
+	op, _ = s.s.operations.Get(lost.ID)
+	s.Equal(service.OperationFailed, op.State)
+
+	for i := 0; i < 100; i++ {
+		op, _ = s.s.operations.Get(parked.ID)
+		if op.State != service.OperationAwaitingApproval {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	s.Equal(service.OperationFailed, op.State)
+	s.Contains(op.Error, "Approval "+a.ID+" expired")
+}
+
+func (s *StateTestSuite) Test_Resume_FailsQueuedScale() {
+	store, err := service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	op := store.Create("scale_service", "web", "")
+	store.SetState(op.ID, service.OperationQueued, "Queued")
+	s.s.SetOperationStore(store, nil)
+	events, unsubscribe := s.s.events.Subscribe(1, service.EventFilter{})
+	defer unsubscribe()
+
+	s.s.resumeOperations()
+	op, _ = s.s.operations.Get(op.ID)
+	s.Equal(service.OperationFailed, op.State)
+	s.Equal("Stopped waiting to scale web until its update completes, since docker-scaler restarted", op.Error)
+	s.am.AssertCalled(s.T(), "Send", "scale_service", "web", "Resume after restart", "error", op.Error)
+
+	e := <-events
+	s.Equal(service.EventScaleService, e.Kind)
+	s.Equal("web", e.Service)
+	s.Equal(op.ID, e.OperationID)
+}
+
+func (s *StateTestSuite) Test_Resume_InterruptedEvents() {
+	store, err := service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	interrupted := []service.Operation{
+		store.Create("scale_service", "web", ""),
+		store.Create("reschedule_service", "api", ""),
+		store.Create("scale_nodes", "worker", ""),
+		store.Create("update_defaults", "", ""),
+	}
+	s.s.SetOperationStore(store, nil)
+	events, unsubscribe := s.s.events.Subscribe(len(interrupted), service.EventFilter{})
+	defer unsubscribe()
+
+	s.s.resumeOperations()
+	published := map[string]service.Event{}
+	for range interrupted {
+		e := <-events
+		published[e.OperationID] = e
+	}
+	expected := []struct{ kind, service string }{
+		{service.EventScaleService, "web"},
+		{service.EventReschedule, "api"},
+		{service.EventScaleNodes, ""},
+		{service.EventUpdateDefaults, ""},
+	}
+	for i, op := range interrupted {
+		e := published[op.ID]
+		s.Equal(expected[i].kind, e.Kind, op.Kind)
+		s.Equal(expected[i].service, e.Service, op.Kind)
+		s.Equal("error", e.Status)
+	}
+}
+
+func (s *StateTestSuite) Test_Resume_Once() {
+	s.previousRun(time.Now().UTC().Add(-2 * time.Hour))
+	s.s.resumeOperations()
+	s.s.resumeOperations()
+	s.am.AssertNumberOfCalls(s.T(), "Send", 2)
+}
+
+func (s *StateTestSuite) Test_Shutdown_KeepsWaitsForNextRun() {
+	waiting, _ := s.previousRun(time.Now().UTC())
+	errCs := make(chan chan<- error, 1)
+	s.rsm.On("RescheduleServicesWaitForNodes", false, 4, "20180101T000000",
+		mock.MatchedBy(func(timeOut time.Duration) bool { return timeOut > 0 && timeOut <= time.Hour }),
+		mock.Anything, mock.Anything, mock.Anything).Return().Run(func(args mock.Arguments) {
+		errCs <- args.Get(5).(chan<- error)
+	})
+	s.s.resumeOperations()
+	errC := <-errCs
+
+	s.store.Close()
+	errC <- errors.New("Interrupted while waiting for nodes")
+	s.s.waits.Wait()
+
+	reopened, err := service.OpenOperationStore(s.path, OperationHistorySize)
+	s.Require().NoError(err)
+	op, _ := reopened.Get(waiting.ID)
+	s.Equal(service.OperationWaitingForNodes, op.State)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -10,18 +11,22 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"github.com/thomasjpfan/docker-scaler/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// AlertServicer interface to send alerts
+// AlertServicer interface to send alerts. The request id and cluster in
+// `ctx` are added to the alert
 type AlertServicer interface {
-	Send(alertName string, serviceName string,
+	Send(ctx context.Context, alertName string, serviceName string,
 		request string, status string,
 		message string) error
 }
 
 type silentAlertService struct{}
 
-func (s silentAlertService) Send(alertName string,
+func (s silentAlertService) Send(ctx context.Context, alertName string,
 	serviceName string, request string,
 	status string, message string) error {
 	return nil
@@ -47,16 +52,38 @@ func NewAlertService(url string, alertTimeout time.Duration) AlertServicer {
 }
 
 // Send sends alert to alert service
-func (a alertService) Send(alertName string, serviceName string, request string, status string, message string) error {
+func (a alertService) Send(ctx context.Context, alertName string, serviceName string, request string, status string, message string) (err error) {
+	ctx, span := tracing.Start(ctx, "alertmanager.send",
+		attribute.String("alertname", alertName),
+		attribute.String("service", serviceName),
+		attribute.String("status", status))
+	defer tracing.End(span, &err)
+
 	startsAt := time.Now().UTC()
 	alert := generateAlert(alertName, serviceName, request, status, message, startsAt, a.alertTimeout)
+	requestID := logging.RequestID(ctx)
+	if len(requestID) > 0 {
+		alert.Annotations["requestID"] = model.LabelValue(requestID)
+	}
+	if cluster := ClusterFrom(ctx); len(cluster) > 0 {
+		alert.Labels["cluster"] = model.LabelValue(cluster)
+	}
 
 	alerts := []*model.Alert{alert}
 	alertsJSON, _ := json.Marshal(alerts)
 	r := bytes.NewReader(alertsJSON)
 
 	endpoint := fmt.Sprintf("%s/api/v1/alerts", a.url)
-	resp, err := http.Post(endpoint, "application/json", r)
+	req, err := http.NewRequest("POST", endpoint, r)
+	if err != nil {
+		return errors.Wrap(err, "Failed to send alert to alertmanager")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(requestID) > 0 {
+		req.Header.Set("X-Request-ID", requestID)
+	}
+	tracing.Inject(ctx, req.Header)
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return errors.Wrap(err, "Failed to send alert to alertmanager")
 	}
@@ -2,13 +2,23 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os/exec"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/client"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 )
 
 type AlertTestSuite struct {
@@ -24,13 +34,13 @@ func TestAlertUnitTestSuite(t *testing.T) {
 
 func (s *AlertTestSuite) SetupSuite() {
 	client, _ := NewDockerClientFromEnv()
-	_, err := client.dc.Info(context.Background())
+	_, err := client.client().Info(context.Background())
 	if err != nil {
 		s.T().Skipf("Unable to connect to Docker Client")
 	}
 	s.url = "http://localhost:9093"
 	s.alertService = NewAlertService(s.url, time.Second*15)
-	s.client = client.dc
+	s.client = client.client()
 }
 
 func (s *AlertTestSuite) TearDownSuite() {
@@ -70,7 +80,7 @@ L:
 	summary := "Scaled web from 3 to 5 replicas"
 	request := "Scale web with delta=1"
 
-	err = s.alertService.Send(alertname, serviceName, request, status, summary)
+	err = s.alertService.Send(context.Background(), alertname, serviceName, request, status, summary)
 	require.NoError(err)
 	time.Sleep(1 * time.Second)
 
@@ -112,6 +122,65 @@ func (s *AlertTestSuite) Test_generateAlert() {
 
 func (s *AlertTestSuite) Test_SilentAlert() {
 	sa := NewSilentAlertService()
-	err := sa.Send("", "", "", "", "")
+	err := sa.Send(context.Background(), "", "", "", "", "")
 	s.NoError(err)
 }
+
+func TestAlertService_RequestIDUnitTest(t *testing.T) {
+	var header string
+	var alerts []*model.Alert
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get("X-Request-ID")
+		json.NewDecoder(r.Body).Decode(&alerts)
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer ts.Close()
+
+	ctx := logging.WithRequestID(context.Background(), "req-1")
+	err := NewAlertService(ts.URL, time.Second).
+		Send(ctx, "scale_service", "web", "Scale service up: web", "success", "Scaled web")
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "req-1", string(alerts[0].Annotations["requestID"]))
+	require.Equal(t, "req-1", header)
+	require.NotContains(t, alerts[0].Labels, model.LabelName("cluster"))
+}
+
+func TestAlertService_ClusterUnitTest(t *testing.T) {
+	var alerts []*model.Alert
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&alerts)
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer ts.Close()
+
+	ctx := WithCluster(context.Background(), "eu-west")
+	err := NewAlertService(ts.URL, time.Second).
+		Send(ctx, "scale_service", "web", "Scale service up: web", "success", "Scaled web")
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	require.Equal(t, "eu-west", string(alerts[0].Labels["cluster"]))
+}
+
+func TestAlertService_TraceContextUnitTest(t *testing.T) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	var traceparent string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.Write([]byte(`{"status":"success"}`))
+	}))
+	defer ts.Close()
+
+	err := NewAlertService(ts.URL, time.Second).
+		Send(context.Background(), "scale_service", "web", "Scale service up: web", "success", "Scaled web")
+	require.NoError(t, err)
+
+	spans := sr.Ended()
+	require.Len(t, spans, 1)
+	require.Equal(t, "alertmanager.send", spans[0].Name())
+	sc := spans[0].SpanContext()
+	require.Equal(t, "00-"+sc.TraceID().String()+"-"+sc.SpanID().String()+"-01", traceparent)
+}
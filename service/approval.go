@@ -0,0 +1,366 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/logging"
+)
+
+// ApprovalState is the state of a change that needs approval
+type ApprovalState string
+
+const (
+	// ApprovalPending denotes a change waiting to be approved or rejected
+	ApprovalPending ApprovalState = "awaiting-approval"
+	// ApprovalApproved denotes a change that was approved and carried out
+	ApprovalApproved ApprovalState = "approved"
+	// ApprovalRejected denotes a change that was rejected
+	ApprovalRejected ApprovalState = "rejected"
+	// ApprovalExpired denotes a change that was not approved in time
+	ApprovalExpired ApprovalState = "expired"
+)
+
+// approvalHistorySize is the number of decided approvals kept
+const approvalHistorySize = 100
+
+// ApprovalPolicy is the size of the changes that need approval
+type ApprovalPolicy struct {
+	// MaxNodeChange is the most nodes scaled at once without approval.
+	// No node change needs approval when it is 0
+	MaxNodeChange uint64
+	// MaxReplicaFactor is the most a service is scaled up by, as a factor
+	// of its replicas, without approval. Scaling down to less than the
+	// replicas divided by the factor also needs approval. No service
+	// change needs approval when it is 0, or when the service scales from
+	// 0 replicas
+	MaxReplicaFactor float64
+	// Timeout is how long a change waits to be approved
+	Timeout time.Duration
+}
+
+// ApprovalChange is a change checked against the approval policy
+type ApprovalChange struct {
+	// Kind is `scale_service` or `scale_nodes`
+	Kind string
+	// Target is the service or the node type to scale
+	Target string
+	// Service is the service whose labels configure node scaling
+	Service   string
+	Direction ScaleDirection
+	By        uint64
+	Previous  uint64
+	Requested uint64
+}
+
+// Approval is a change that waits to be approved before it is carried out
+type Approval struct {
+	ID          string         `json:"id"`
+	Cluster     string         `json:"cluster,omitempty"`
+	Kind        string         `json:"kind"`
+	Target      string         `json:"target"`
+	Service     string         `json:"service,omitempty"`
+	Direction   ScaleDirection `json:"direction"`
+	By          uint64         `json:"by,omitempty"`
+	Previous    uint64         `json:"previous"`
+	Requested   uint64         `json:"requested"`
+	Reason      string         `json:"reason"`
+	State       ApprovalState  `json:"state"`
+	OperationID string         `json:"operationId,omitempty"`
+	RequestID   string         `json:"requestId,omitempty"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	ExpiresAt   time.Time      `json:"expiresAt"`
+	DecidedAt   *time.Time     `json:"decidedAt,omitempty"`
+}
+
+// Change describes the change of the approval, like `Scaling web from 2
+// to 10 replicas`
+func (a Approval) Change() string {
+	if a.Kind == "scale_nodes" {
+		return fmt.Sprintf("Scaling %s nodes from %d to %d", a.Target, a.Previous, a.Requested)
+	}
+	return fmt.Sprintf("Scaling %s from %d to %d replicas", a.Target, a.Previous, a.Requested)
+}
+
+// ApprovalRequiredError is returned when a change is parked until it is
+// approved
+type ApprovalRequiredError struct {
+	Approval Approval
+	// Existing is true when the change was already parked by an earlier
+	// request, whose operation waits for the approval
+	Existing bool
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("%s awaits approval %s until %s, since %s", e.Approval.Change(),
+		e.Approval.ID, e.Approval.ExpiresAt.UTC().Format(time.RFC3339), e.Approval.Reason)
+}
+
+type approvalKey struct{}
+
+// WithApproval returns a copy of `ctx` that carries out the change of
+// approval `id` without asking for approval again
+func WithApproval(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, approvalKey{}, id)
+}
+
+// ApprovalID returns the approval carried by `ctx`, or an empty string
+func ApprovalID(ctx context.Context) string {
+	id, _ := ctx.Value(approvalKey{}).(string)
+	return id
+}
+
+// Approvals parks changes that exceed the approval policy of their cluster
+// until they are approved, rejected or expire
+type Approvals struct {
+	mu        sync.Mutex
+	policies  map[string]ApprovalPolicy
+	approvals map[string]*Approval
+	path      string
+	onError   func(err error)
+}
+
+// NewApprovals creates Approvals where no change needs approval
+func NewApprovals() *Approvals {
+	return &Approvals{
+		policies:  map[string]ApprovalPolicy{},
+		approvals: map[string]*Approval{},
+	}
+}
+
+// OpenApprovals creates Approvals that saves every approval to the file at
+// `path`, and reads the approvals saved there, so parked changes outlive a
+// restart
+func OpenApprovals(path string) (*Approvals, error) {
+	a := NewApprovals()
+	a.path = path
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read approvals file %s", path)
+	}
+	var approvals []Approval
+	if err := json.Unmarshal(b, &approvals); err != nil {
+		return nil, errors.Wrapf(err, "Unable to read approvals file %s", path)
+	}
+	for i := range approvals {
+		a.approvals[approvals[i].ID] = &approvals[i]
+	}
+	return a, nil
+}
+
+// SetErrorHandler calls `f` when the approvals can not be saved
+func (a *Approvals) SetErrorHandler(f func(err error)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onError = f
+}
+
+// SetPolicy changes the approval policy of `cluster`. Parked changes keep
+// the expiry they were parked with
+func (a *Approvals) SetPolicy(cluster string, p ApprovalPolicy) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.policies[cluster] = p
+}
+
+// Check parks `c` and returns an *ApprovalRequiredError when it exceeds
+// the approval policy of the cluster of `ctx`. A change of the same kind,
+// target and direction that is still waiting is not parked again, its
+// approval is returned instead. Changes carried out for an approval are
+// not checked again
+func (a *Approvals) Check(ctx context.Context, c ApprovalChange) error {
+	if a == nil || len(ApprovalID(ctx)) > 0 {
+		return nil
+	}
+	cluster := ClusterFrom(ctx)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	p := a.policies[cluster]
+	reason := p.reason(c)
+	if len(reason) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	for _, pending := range a.approvals {
+		if pending.State == ApprovalPending && now.Before(pending.ExpiresAt) &&
+			pending.Cluster == cluster && pending.Kind == c.Kind &&
+			pending.Target == c.Target && pending.Direction == c.Direction {
+			return &ApprovalRequiredError{Approval: *pending, Existing: true}
+		}
+	}
+	approval := &Approval{
+		ID:        newOperationID(),
+		Cluster:   cluster,
+		Kind:      c.Kind,
+		Target:    c.Target,
+		Service:   c.Service,
+		Direction: c.Direction,
+		By:        c.By,
+		Previous:  c.Previous,
+		Requested: c.Requested,
+		Reason:    reason,
+		State:     ApprovalPending,
+		RequestID: logging.RequestID(ctx),
+		CreatedAt: now,
+		ExpiresAt: now.Add(p.Timeout),
+	}
+	a.approvals[approval.ID] = approval
+	a.evict()
+	a.save()
+	return &ApprovalRequiredError{Approval: *approval}
+}
+
+// reason returns why `c` needs approval, or an empty string when it does
+// not
+func (p ApprovalPolicy) reason(c ApprovalChange) string {
+	switch c.Kind {
+	case "scale_nodes":
+		change := c.Requested - c.Previous
+		if c.Requested < c.Previous {
+			change = c.Previous - c.Requested
+		}
+		if p.MaxNodeChange > 0 && change > p.MaxNodeChange {
+			return fmt.Sprintf("a change of %d nodes is more than APPROVAL_MAX_NODE_CHANGE of %d", change, p.MaxNodeChange)
+		}
+	case "scale_service":
+		if p.MaxReplicaFactor <= 0 || c.Previous == 0 {
+			return ""
+		}
+		previous, requested := float64(c.Previous), float64(c.Requested)
+		if requested > previous*p.MaxReplicaFactor {
+			return fmt.Sprintf("%d replicas are more than APPROVAL_MAX_REPLICA_FACTOR of %g times %d replicas", c.Requested, p.MaxReplicaFactor, c.Previous)
+		}
+		if requested < previous/p.MaxReplicaFactor {
+			return fmt.Sprintf("%d replicas are less than %d replicas divided by APPROVAL_MAX_REPLICA_FACTOR of %g", c.Requested, c.Previous, p.MaxReplicaFactor)
+		}
+	}
+	return ""
+}
+
+// SetOperation records the operation that waits for approval `id`
+func (a *Approvals) SetOperation(id, operationID string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if approval, ok := a.approvals[id]; ok {
+		approval.OperationID = operationID
+		a.save()
+	}
+}
+
+// Get returns approval `id`
+func (a *Approvals) Get(id string) (Approval, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval, ok := a.approvals[id]
+	if !ok {
+		return Approval{}, false
+	}
+	return *approval, true
+}
+
+// List returns every approval, newest first
+func (a *Approvals) List() []Approval {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approvals := make([]Approval, 0, len(a.approvals))
+	for _, approval := range a.approvals {
+		approvals = append(approvals, *approval)
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreatedAt.After(approvals[j].CreatedAt)
+	})
+	return approvals
+}
+
+// Decide approves or rejects approval `id`. It returns false when the
+// approval does not exist, and an error when it was already decided or
+// expired
+func (a *Approvals) Decide(id string, approve bool) (Approval, bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval, ok := a.approvals[id]
+	if !ok {
+		return Approval{}, false, nil
+	}
+	now := time.Now().UTC()
+	if approval.State == ApprovalPending && !now.Before(approval.ExpiresAt) {
+		approval.State = ApprovalExpired
+		approval.DecidedAt = &now
+		a.save()
+	}
+	if approval.State != ApprovalPending {
+		return *approval, true, fmt.Errorf("Approval %s is already %s", id, approval.State)
+	}
+	approval.State = ApprovalRejected
+	if approve {
+		approval.State = ApprovalApproved
+	}
+	approval.DecidedAt = &now
+	a.save()
+	return *approval, true, nil
+}
+
+// Expire expires approval `id` when it is still waiting. It returns false
+// when the approval was already decided
+func (a *Approvals) Expire(id string) (Approval, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	approval, ok := a.approvals[id]
+	if !ok || approval.State != ApprovalPending {
+		return Approval{}, false
+	}
+	now := time.Now().UTC()
+	approval.State = ApprovalExpired
+	approval.DecidedAt = &now
+	a.save()
+	return *approval, true
+}
+
+// evict removes the oldest decided approvals above approvalHistorySize.
+// It must be called with the lock held
+func (a *Approvals) evict() {
+	decided := []*Approval{}
+	for _, approval := range a.approvals {
+		if approval.State != ApprovalPending {
+			decided = append(decided, approval)
+		}
+	}
+	if len(decided) <= approvalHistorySize {
+		return
+	}
+	sort.Slice(decided, func(i, j int) bool {
+		return decided[i].CreatedAt.Before(decided[j].CreatedAt)
+	})
+	for _, approval := range decided[:len(decided)-approvalHistorySize] {
+		delete(a.approvals, approval.ID)
+	}
+}
+
+// save writes the approvals to the file, and reports an error to the error
+// handler. It must be called with the lock held
+func (a *Approvals) save() {
+	if len(a.path) == 0 {
+		return
+	}
+	approvals := make([]Approval, 0, len(a.approvals))
+	for _, approval := range a.approvals {
+		approvals = append(approvals, *approval)
+	}
+	sort.Slice(approvals, func(i, j int) bool {
+		return approvals[i].CreatedAt.Before(approvals[j].CreatedAt)
+	})
+	if err := writeStateFile(a.path, approvals); err != nil && a.onError != nil {
+		a.onError(err)
+	}
+}
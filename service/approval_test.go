@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type ApprovalsTestSuite struct {
+	suite.Suite
+	ctx       context.Context
+	approvals *Approvals
+}
+
+func TestApprovalsUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ApprovalsTestSuite))
+}
+
+func (s *ApprovalsTestSuite) SetupTest() {
+	s.ctx = WithCluster(context.Background(), "default")
+	s.approvals = NewApprovals()
+	s.approvals.SetPolicy("default", ApprovalPolicy{
+		MaxNodeChange: 2, MaxReplicaFactor: 2, Timeout: time.Hour,
+	})
+}
+
+func (s *ApprovalsTestSuite) check(kind string, previous, requested uint64) error {
+	direction := ScaleUpDirection
+	if requested < previous {
+		direction = ScaleDownDirection
+	}
+	return s.approvals.Check(s.ctx, ApprovalChange{
+		Kind: kind, Target: "web", Direction: direction,
+		Previous: previous, Requested: requested,
+	})
+}
+
+func (s *ApprovalsTestSuite) Test_Check_WithinPolicy() {
+	s.NoError(s.check("scale_service", 2, 4))
+	s.NoError(s.check("scale_service", 4, 2))
+	s.NoError(s.check("scale_nodes", 5, 3))
+	s.Empty(s.approvals.List())
+}
+
+func (s *ApprovalsTestSuite) Test_Check_FromZeroReplicas() {
+	s.NoError(s.check("scale_service", 0, 1))
+	s.NoError(s.check("scale_service", 0, 20))
+	s.Empty(s.approvals.List())
+}
+
+func (s *ApprovalsTestSuite) Test_Check_ParksLargeChanges() {
+	err := s.check("scale_service", 2, 5)
+	s.Require().Error(err)
+	ae, ok := err.(*ApprovalRequiredError)
+	s.Require().True(ok)
+	s.Equal("5 replicas are more than APPROVAL_MAX_REPLICA_FACTOR of 2 times 2 replicas", ae.Approval.Reason)
+	s.Equal("default", ae.Approval.Cluster)
+	s.Equal(ApprovalPending, ae.Approval.State)
+	s.Equal(time.Hour, ae.Approval.ExpiresAt.Sub(ae.Approval.CreatedAt))
+
+	err = s.check("scale_service", 9, 4)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "4 replicas are less than 9 replicas divided by APPROVAL_MAX_REPLICA_FACTOR of 2")
+
+	err = s.check("scale_nodes", 1, 4)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Scaling web nodes from 1 to 4 awaits approval ")
+	s.Contains(err.Error(), "since a change of 3 nodes is more than APPROVAL_MAX_NODE_CHANGE of 2")
+	s.Len(s.approvals.List(), 3)
+}
+
+func (s *ApprovalsTestSuite) Test_Check_ReturnsPendingApproval() {
+	err := s.check("scale_service", 2, 5)
+	s.Require().Error(err)
+	first := err.(*ApprovalRequiredError)
+	s.False(first.Existing)
+
+	err = s.check("scale_service", 2, 6)
+	s.Require().Error(err)
+	again := err.(*ApprovalRequiredError)
+	s.True(again.Existing)
+	s.Equal(first.Approval.ID, again.Approval.ID)
+	s.Equal(uint64(5), again.Approval.Requested)
+	s.Len(s.approvals.List(), 1)
+
+	_, _, err = s.approvals.Decide(first.Approval.ID, false)
+	s.Require().NoError(err)
+	err = s.check("scale_service", 2, 6)
+	s.Require().Error(err)
+	s.False(err.(*ApprovalRequiredError).Existing)
+	s.Len(s.approvals.List(), 2)
+}
+
+func (s *ApprovalsTestSuite) Test_Check_OtherClusterAndApproved() {
+	s.NoError(s.approvals.Check(WithCluster(context.Background(), "eu-west"),
+		ApprovalChange{Kind: "scale_service", Previous: 1, Requested: 10}))
+	s.NoError(s.approvals.Check(WithApproval(s.ctx, "abc"),
+		ApprovalChange{Kind: "scale_service", Previous: 1, Requested: 10}))
+
+	var approvals *Approvals
+	s.NoError(approvals.Check(s.ctx, ApprovalChange{Kind: "scale_service", Previous: 1, Requested: 10}))
+}
+
+func (s *ApprovalsTestSuite) Test_Decide() {
+	err := s.check("scale_service", 1, 10)
+	id := err.(*ApprovalRequiredError).Approval.ID
+
+	a, found, err := s.approvals.Decide(id, true)
+	s.True(found)
+	s.Require().NoError(err)
+	s.Equal(ApprovalApproved, a.State)
+	s.NotNil(a.DecidedAt)
+
+	_, found, err = s.approvals.Decide(id, false)
+	s.True(found)
+	s.EqualError(err, "Approval "+id+" is already approved")
+	_, ok := s.approvals.Expire(id)
+	s.False(ok)
+
+	_, found, _ = s.approvals.Decide("nope", true)
+	s.False(found)
+}
+
+func (s *ApprovalsTestSuite) Test_Decide_Expired() {
+	s.approvals.SetPolicy("default", ApprovalPolicy{MaxReplicaFactor: 2})
+	err := s.check("scale_service", 1, 10)
+	id := err.(*ApprovalRequiredError).Approval.ID
+
+	a, _, err := s.approvals.Decide(id, true)
+	s.EqualError(err, "Approval "+id+" is already expired")
+	s.Equal(ApprovalExpired, a.State)
+}
+
+func (s *ApprovalsTestSuite) Test_Evict() {
+	for i := 0; i < approvalHistorySize+5; i++ {
+		err := s.check("scale_service", 1, 10)
+		s.approvals.Expire(err.(*ApprovalRequiredError).Approval.ID)
+	}
+	s.check("scale_service", 1, 10)
+	s.Len(s.approvals.List(), approvalHistorySize+1)
+}
+
+func (s *ApprovalsTestSuite) Test_OpenApprovals_Saves() {
+	path := filepath.Join(s.T().TempDir(), "approvals.json")
+	approvals, err := OpenApprovals(path)
+	s.Require().NoError(err)
+	approvals.SetPolicy("default", ApprovalPolicy{MaxReplicaFactor: 2, Timeout: time.Hour})
+	err = approvals.Check(s.ctx, ApprovalChange{Kind: "scale_service", Target: "web", Previous: 1, Requested: 10})
+	id := err.(*ApprovalRequiredError).Approval.ID
+	approvals.SetOperation(id, "op")
+
+	reopened, err := OpenApprovals(path)
+	s.Require().NoError(err)
+	a, ok := reopened.Get(id)
+	s.Require().True(ok)
+	s.Equal(ApprovalPending, a.State)
+	s.Equal("op", a.OperationID)
+	s.Equal("web", a.Target)
+
+	_, _, err = approvals.Decide(id, false)
+	s.Require().NoError(err)
+	reopened, err = OpenApprovals(path)
+	s.Require().NoError(err)
+	a, _ = reopened.Get(id)
+	s.Equal(ApprovalRejected, a.State)
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+// BudgetError is returned when the node budget caps or rejects scaling up
+// nodes
+type BudgetError struct {
+	Cluster  string
+	NodeType cloud.NodeType
+	Current  uint64
+	Target   uint64
+	// Allowed is the number of nodes set within the budget. Nothing is
+	// scaled when it equals Current
+	Allowed uint64
+	// Reason is the limit scaling to Target exceeds
+	Reason string
+}
+
+func (e *BudgetError) Error() string {
+	if e.Allowed > e.Current {
+		return fmt.Sprintf("Node budget capped %s nodes at %d instead of %d: %s",
+			e.NodeType, e.Allowed, e.Target, e.Reason)
+	}
+	return fmt.Sprintf("Node budget rejected scaling %s nodes from %d to %d: %s",
+		e.NodeType, e.Current, e.Target, e.Reason)
+}
+
+// Capped returns true when the nodes were scaled, but not as far as
+// requested
+func (e *BudgetError) Capped() bool {
+	return e.Allowed > e.Current
+}
+
+// budgetPool is the cloud of a cluster and the hourly price of its nodes
+type budgetPool struct {
+	cloud  cloud.Cloud
+	prices map[cloud.NodeType]float64
+}
+
+// NodeBudget caps the total number of nodes of every cluster, and their
+// estimated hourly cost. Only scaling up is capped, so scaling down always
+// brings the nodes back within the budget
+type NodeBudget struct {
+	mu            sync.RWMutex
+	maxNodes      uint64
+	maxHourlyCost float64
+	pools         map[string]budgetPool
+
+	// scaling lets one pool be scaled at a time, so two pools can not
+	// both use what is left of the budget
+	scaling sync.Mutex
+}
+
+// NewNodeBudget creates a NodeBudget without limits
+func NewNodeBudget() *NodeBudget {
+	return &NodeBudget{pools: map[string]budgetPool{}}
+}
+
+// SetLimits changes the maximum number of nodes and the maximum estimated
+// hourly cost. A limit of 0 is no limit
+func (b *NodeBudget) SetLimits(maxNodes uint64, maxHourlyCost float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.maxNodes = maxNodes
+	b.maxHourlyCost = maxHourlyCost
+}
+
+// SetPool counts the nodes of `c` as the nodes of `cluster`, with the
+// hourly price of a manager and of a worker node
+func (b *NodeBudget) SetPool(cluster string, c cloud.Cloud, managerPrice, workerPrice float64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.pools[cluster] = budgetPool{
+		cloud: c,
+		prices: map[cloud.NodeType]float64{
+			cloud.NodeManagerType: managerPrice,
+			cloud.NodeWorkerType:  workerPrice,
+		},
+	}
+}
+
+// Reserve caps scaling the `nodeType` nodes of `cluster` from `current` to
+// `target` nodes to the budget. It returns the number of nodes to set, and
+// a *BudgetError when that is less than `target`. No other pool is scaled
+// until `release` is called, which must be called once the nodes are set,
+// even when Reserve returns an error
+func (b *NodeBudget) Reserve(ctx context.Context, cluster string, nodeType cloud.NodeType, current, target uint64) (uint64, func(), error) {
+	if b == nil || target <= current {
+		return target, func() {}, nil
+	}
+	b.mu.RLock()
+	maxNodes, maxHourlyCost := b.maxNodes, b.maxHourlyCost
+	b.mu.RUnlock()
+	if maxNodes == 0 && maxHourlyCost == 0 {
+		return target, func() {}, nil
+	}
+
+	b.scaling.Lock()
+	release := b.scaling.Unlock
+	nodes, cost, price, err := b.others(ctx, cluster, nodeType)
+	if err != nil {
+		return current, release, err
+	}
+
+	exceeds := func(n uint64) string {
+		total, hourly := nodes+n, cost+float64(n)*price
+		switch {
+		case maxNodes > 0 && total > maxNodes:
+			return fmt.Sprintf("%d nodes in every cluster would exceed NODE_BUDGET_MAX_NODES of %d", total, maxNodes)
+		case maxHourlyCost > 0 && hourly > maxHourlyCost:
+			return fmt.Sprintf("an estimated %.2f per hour would exceed NODE_BUDGET_MAX_HOURLY_COST of %.2f", hourly, maxHourlyCost)
+		}
+		return ""
+	}
+	reason := exceeds(target)
+	if len(reason) == 0 {
+		return target, release, nil
+	}
+	allowed := target - 1
+	for allowed > current && len(exceeds(allowed)) > 0 {
+		allowed--
+	}
+	return allowed, release, &BudgetError{
+		Cluster:  cluster,
+		NodeType: nodeType,
+		Current:  current,
+		Target:   target,
+		Allowed:  allowed,
+		Reason:   reason,
+	}
+}
+
+// others returns the number of nodes and the estimated hourly cost of
+// every pool except the `nodeType` nodes of `cluster`, and the price of
+// those nodes
+func (b *NodeBudget) others(ctx context.Context, cluster string, nodeType cloud.NodeType) (uint64, float64, float64, error) {
+	b.mu.RLock()
+	pools := make(map[string]budgetPool, len(b.pools))
+	for name, pool := range b.pools {
+		pools[name] = pool
+	}
+	b.mu.RUnlock()
+
+	names := make([]string, 0, len(pools))
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var nodes uint64
+	var cost float64
+	for _, name := range names {
+		pool := pools[name]
+		for _, t := range []cloud.NodeType{cloud.NodeManagerType, cloud.NodeWorkerType} {
+			if name == cluster && t == nodeType {
+				continue
+			}
+			n, err := pool.cloud.GetNodes(ctx, t)
+			if err != nil {
+				return 0, 0, 0, errors.Wrapf(err, "Unable to count the %s nodes of cluster %s for the node budget", t, name)
+			}
+			nodes += n
+			cost += float64(n) * pool.prices[t]
+		}
+	}
+	return nodes, cost, pools[cluster].prices[nodeType], nil
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+type NodeBudgetTestSuite struct {
+	suite.Suite
+	ctx    context.Context
+	aws    *CloudProviderMock
+	euWest *CloudProviderMock
+	budget *NodeBudget
+}
+
+func TestNodeBudgetUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(NodeBudgetTestSuite))
+}
+
+func (s *NodeBudgetTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.aws = new(CloudProviderMock)
+	s.euWest = new(CloudProviderMock)
+	s.budget = NewNodeBudget()
+	s.budget.SetPool("default", s.aws, 0.5, 0.25)
+	s.budget.SetPool("eu-west", s.euWest, 1, 0.5)
+
+	s.aws.On("GetNodes", s.ctx, cloud.NodeManagerType).Return(uint64(3), nil)
+	s.euWest.On("GetNodes", s.ctx, cloud.NodeManagerType).Return(uint64(3), nil)
+	s.euWest.On("GetNodes", s.ctx, cloud.NodeWorkerType).Return(uint64(4), nil)
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_NoLimits() {
+	nodes, release, err := s.budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 2, 100)
+	release()
+	s.Require().NoError(err)
+	s.Equal(uint64(100), nodes)
+	s.aws.AssertNotCalled(s.T(), "GetNodes", s.ctx, cloud.NodeManagerType)
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_NilBudget() {
+	var budget *NodeBudget
+	nodes, release, err := budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 2, 100)
+	release()
+	s.Require().NoError(err)
+	s.Equal(uint64(100), nodes)
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_WithinBudget() {
+	s.budget.SetLimits(20, 0)
+	nodes, release, err := s.budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 2, 4)
+	release()
+	s.Require().NoError(err)
+	s.Equal(uint64(4), nodes)
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_CapsNodes() {
+	// 3 + 3 + 4 nodes in the other pools leave room for 2 workers
+	s.budget.SetLimits(12, 0)
+	nodes, release, err := s.budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 1, 4)
+	release()
+	s.Require().Error(err)
+	s.Equal(uint64(2), nodes)
+	be, ok := err.(*BudgetError)
+	s.Require().True(ok)
+	s.True(be.Capped())
+	s.Equal("Node budget capped worker nodes at 2 instead of 4: 14 nodes in every cluster would exceed NODE_BUDGET_MAX_NODES of 12", err.Error())
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_CapsHourlyCost() {
+	// The other pools cost 1.5 + 3 + 2 per hour
+	s.budget.SetLimits(0, 7.25)
+	nodes, release, err := s.budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 1, 5)
+	release()
+	s.Require().Error(err)
+	s.Equal(uint64(3), nodes)
+	s.Equal("Node budget capped worker nodes at 3 instead of 5: an estimated 7.75 per hour would exceed NODE_BUDGET_MAX_HOURLY_COST of 7.25", err.Error())
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_Rejects() {
+	s.budget.SetLimits(10, 0)
+	nodes, release, err := s.budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 0, 1)
+	release()
+	s.Require().Error(err)
+	s.Equal(uint64(0), nodes)
+	be, ok := err.(*BudgetError)
+	s.Require().True(ok)
+	s.False(be.Capped())
+	s.Equal("Node budget rejected scaling worker nodes from 0 to 1: 11 nodes in every cluster would exceed NODE_BUDGET_MAX_NODES of 10", err.Error())
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_ScaleDownIsNotCapped() {
+	s.budget.SetLimits(1, 1)
+	nodes, release, err := s.budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 5, 4)
+	release()
+	s.Require().NoError(err)
+	s.Equal(uint64(4), nodes)
+}
+
+func (s *NodeBudgetTestSuite) Test_Reserve_CountError() {
+	s.budget.SetLimits(20, 0)
+	other := new(CloudProviderMock)
+	other.On("GetNodes", s.ctx, cloud.NodeManagerType).Return(uint64(0), errors.New("throttled"))
+	s.budget.SetPool("us-east", other, 0, 0)
+
+	nodes, release, err := s.budget.Reserve(s.ctx, "default", cloud.NodeWorkerType, 1, 2)
+	release()
+	s.Require().Error(err)
+	s.Equal(uint64(1), nodes)
+	s.Equal("Unable to count the manager nodes of cluster us-east for the node budget: throttled", err.Error())
+}
@@ -3,6 +3,7 @@ package cloud
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/request"
@@ -10,6 +11,9 @@ import (
 	"github.com/aws/aws-sdk-go/service/autoscaling"
 	"github.com/kelseyhightower/envconfig"
 	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/metrics"
+	"github.com/thomasjpfan/docker-scaler/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 type awsAutoScaler interface {
@@ -60,17 +64,23 @@ func NewAWSScalerFromEnv() (*AWSScaler, error) {
 }
 
 // GetNodes return the number of nodes
-func (s AWSScaler) GetNodes(ctx context.Context, nodeType NodeType) (uint64, error) {
-	groupsOutput, err := s.svc.DescribeAutoScalingGroupsWithContext(ctx, &autoscaling.DescribeAutoScalingGroupsInput{})
-	if err != nil {
-		return 0, errors.Wrap(err, "Unable to describe-auto-scaling-groups")
-	}
+func (s AWSScaler) GetNodes(ctx context.Context, nodeType NodeType) (nodes uint64, err error) {
+	defer metrics.ObserveCloudCall(s.String(), "get_nodes", time.Now())
 	var groupName string
 	if nodeType == NodeManagerType {
 		groupName = s.spec.ManagerASG
 	} else {
 		groupName = s.spec.WorkerASG
 	}
+	ctx, span := tracing.Start(ctx, "aws.describe_auto_scaling_groups",
+		attribute.String("node_type", string(nodeType)),
+		attribute.String("aws.asg", groupName))
+	defer tracing.End(span, &err)
+
+	groupsOutput, err := s.svc.DescribeAutoScalingGroupsWithContext(ctx, &autoscaling.DescribeAutoScalingGroupsInput{})
+	if err != nil {
+		return 0, errors.Wrap(err, "Unable to describe-auto-scaling-groups")
+	}
 
 	var targetGroup *autoscaling.Group
 	for _, group := range groupsOutput.AutoScalingGroups {
@@ -98,7 +108,8 @@ func (s AWSScaler) GetNodes(ctx context.Context, nodeType NodeType) (uint64, err
 }
 
 // SetNodes sets the number of nodes on AWS
-func (s AWSScaler) SetNodes(ctx context.Context, nodeType NodeType, cnt, minSize, maxSize uint64) error {
+func (s AWSScaler) SetNodes(ctx context.Context, nodeType NodeType, cnt, minSize, maxSize uint64) (err error) {
+	defer metrics.ObserveCloudCall(s.String(), "set_nodes", time.Now())
 
 	var groupName string
 	if nodeType == NodeManagerType {
@@ -106,11 +117,16 @@ func (s AWSScaler) SetNodes(ctx context.Context, nodeType NodeType, cnt, minSize
 	} else {
 		groupName = s.spec.WorkerASG
 	}
+	ctx, span := tracing.Start(ctx, "aws.update_auto_scaling_group",
+		attribute.String("node_type", string(nodeType)),
+		attribute.String("aws.asg", groupName),
+		attribute.Int64("aws.desired_capacity", int64(cnt)))
+	defer tracing.End(span, &err)
 	newCapacity := int64(cnt)
 	minSizeInt := int64(minSize)
 	maxSizeInt := int64(maxSize)
 
-	_, err := s.svc.UpdateAutoScalingGroupWithContext(ctx, &autoscaling.UpdateAutoScalingGroupInput{
+	_, err = s.svc.UpdateAutoScalingGroupWithContext(ctx, &autoscaling.UpdateAutoScalingGroupInput{
 		AutoScalingGroupName: &groupName,
 		DesiredCapacity:      &newCapacity,
 		MinSize:              &minSizeInt,
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"context"
+
+	"github.com/thomasjpfan/docker-scaler/logging"
+)
+
+type clusterKey struct{}
+
+func init() {
+	logging.AddContextAttr(logging.ClusterKey, ClusterFrom)
+}
+
+// WithCluster returns a copy of `ctx` carrying the name of the cluster it
+// acts on. Freezes, approvals, budgets, queues and events are routed by it
+func WithCluster(ctx context.Context, cluster string) context.Context {
+	return context.WithValue(ctx, clusterKey{}, cluster)
+}
+
+// ClusterFrom returns the cluster carried by `ctx`, or an empty string
+func ClusterFrom(ctx context.Context) string {
+	cluster, _ := ctx.Value(clusterKey{}).(string)
+	return cluster
+}
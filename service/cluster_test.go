@@ -0,0 +1,34 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/logging"
+)
+
+type ClusterTestSuite struct {
+	suite.Suite
+}
+
+func TestClusterUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(ClusterTestSuite))
+}
+
+func (s *ClusterTestSuite) Test_WithCluster() {
+	ctx := WithCluster(context.Background(), "eu-west")
+	s.Equal("eu-west", ClusterFrom(ctx))
+	s.Empty(ClusterFrom(context.Background()))
+}
+
+func (s *ClusterTestSuite) Test_WithCluster_Logged() {
+	b := new(bytes.Buffer)
+	l, err := logging.New(b, "logfmt", "info")
+	s.Require().NoError(err)
+
+	ctx := WithCluster(logging.WithRequestID(context.Background(), "abc"), "eu-west")
+	l.InfoContext(ctx, "scale-service success")
+	s.Contains(b.String(), `request_id=abc cluster=eu-west`)
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+// PlacementLister lists the services of a swarm and where their tasks run
+type PlacementLister interface {
+	ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error)
+	NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error)
+	TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error)
+}
+
+// DisruptionBudgetError is returned when removing nodes could leave a
+// service with fewer running replicas than its minimum available
+type DisruptionBudgetError struct {
+	NodeType cloud.NodeType
+	Current  uint64
+	Target   uint64
+	// Allowed is the number of nodes set within the disruption budgets.
+	// Nothing is scaled when it equals Current
+	Allowed uint64
+	// Service is the service whose budget limits the scale down
+	Service      string
+	MinAvailable uint64
+	// Left is the running replicas of Service when one node more than
+	// allowed is removed
+	Left uint64
+}
+
+func (e *DisruptionBudgetError) Error() string {
+	removing := fmt.Sprintf("%d nodes", e.Current-e.Allowed+1)
+	if e.Current-e.Allowed+1 == 1 {
+		removing = "a node"
+	}
+	reason := fmt.Sprintf("removing %s could leave %s with %d running replicas, less than its minimum available of %d",
+		removing, e.Service, e.Left, e.MinAvailable)
+	if e.Capped() {
+		return fmt.Sprintf("Disruption budget capped %s nodes at %d instead of %d: %s",
+			e.NodeType, e.Allowed, e.Target, reason)
+	}
+	return fmt.Sprintf("Disruption budget rejected scaling %s nodes from %d to %d: %s",
+		e.NodeType, e.Current, e.Target, reason)
+}
+
+// Capped returns true when the nodes were scaled down, but not as far as
+// requested
+func (e *DisruptionBudgetError) Capped() bool {
+	return e.Allowed < e.Current
+}
+
+// checkDisruption fits scaling the `nodeType` nodes down from `current` to
+// `target` nodes in the disruption budgets of the services, set by the
+// `label` of each service. The cloud picks the nodes it removes, so every
+// node is assumed to be one of them: removing n nodes must leave the
+// minimum available replicas even when the n nodes with the most replicas
+// of a service are removed. It returns the number of nodes to set, and a
+// *DisruptionBudgetError when that is more than `target`
+func (s *NodeScaler) checkDisruption(ctx context.Context, label string, nodeType cloud.NodeType, current, target uint64) (uint64, error) {
+	if len(label) == 0 || target >= current {
+		return target, nil
+	}
+	lister, ok := s.inspector.(PlacementLister)
+	if !ok {
+		return current, errors.New("Unable to list tasks for the disruption budgets")
+	}
+	services, err := lister.ServiceList(ctx, types.ServiceListOptions{})
+	if err != nil {
+		return current, errors.Wrap(err, "Unable to list services for the disruption budgets")
+	}
+	budgets := map[string]uint64{}
+	names := map[string]string{}
+	for _, svc := range services {
+		minAvailable, err := strconv.ParseUint(svc.Spec.Labels[label], 10, 64)
+		if err != nil || minAvailable == 0 {
+			continue
+		}
+		budgets[svc.ID] = minAvailable
+		names[svc.ID] = svc.Spec.Name
+	}
+	if len(budgets) == 0 {
+		return target, nil
+	}
+
+	nodeFilter := filters.NewArgs()
+	nodeFilter.Add("role", string(nodeType))
+	nodes, err := lister.NodeList(ctx, types.NodeListOptions{Filters: nodeFilter})
+	if err != nil {
+		return current, errors.Wrap(err, "Unable to list nodes for the disruption budgets")
+	}
+	removable := map[string]bool{}
+	for _, n := range nodes {
+		removable[n.ID] = true
+	}
+
+	taskFilter := filters.NewArgs()
+	taskFilter.Add("desired-state", "running")
+	tasks, err := lister.TaskList(ctx, types.TaskListOptions{Filters: taskFilter})
+	if err != nil {
+		return current, errors.Wrap(err, "Unable to list tasks for the disruption budgets")
+	}
+	running := map[string]uint64{}
+	placed := map[string]map[string]uint64{}
+	for _, t := range tasks {
+		if _, ok := budgets[t.ServiceID]; !ok || t.Status.State != swarm.TaskStateRunning {
+			continue
+		}
+		running[t.ServiceID]++
+		if !removable[t.NodeID] {
+			continue
+		}
+		if placed[t.ServiceID] == nil {
+			placed[t.ServiceID] = map[string]uint64{}
+		}
+		placed[t.ServiceID][t.NodeID]++
+	}
+
+	ids := make([]string, 0, len(budgets))
+	for id := range budgets {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return names[ids[i]] < names[ids[j]] })
+
+	allowed := current - target
+	var limit *DisruptionBudgetError
+	for _, id := range ids {
+		perNode := make([]uint64, 0, len(placed[id]))
+		for _, cnt := range placed[id] {
+			perNode = append(perNode, cnt)
+		}
+		sort.Slice(perNode, func(i, j int) bool { return perNode[i] > perNode[j] })
+
+		// Remove the nodes with the most replicas first, until the next
+		// node would break the budget
+		var removed, lost uint64
+		for removed < allowed && removed < uint64(len(perNode)) {
+			next := lost + perNode[removed]
+			if running[id]-next < budgets[id] {
+				break
+			}
+			lost = next
+			removed++
+		}
+		if removed == uint64(len(perNode)) {
+			// The service runs on no other node that can be removed
+			continue
+		}
+		if removed < allowed {
+			allowed = removed
+			limit = &DisruptionBudgetError{
+				Service:      names[id],
+				MinAvailable: budgets[id],
+				Left:         running[id] - lost - perNode[removed],
+			}
+		}
+	}
+	if limit == nil {
+		return target, nil
+	}
+	limit.NodeType = nodeType
+	limit.Current = current
+	limit.Target = target
+	limit.Allowed = current - allowed
+	return limit.Allowed, limit
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+type DisruptionTestSuite struct {
+	suite.Suite
+	ctx        context.Context
+	cloudMock  *CloudProviderMock
+	clientMock *DockerClientMock
+	nodeScaler *NodeScaler
+}
+
+func TestDisruptionUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DisruptionTestSuite))
+}
+
+func (s *DisruptionTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.cloudMock = new(CloudProviderMock)
+	s.clientMock = new(DockerClientMock)
+	opts := ResolveDeltaOptions{DefaultMin: 0, DefaultMax: 5, DefaultScaleDownBy: 2, DefaultScaleUpBy: 1}
+	s.nodeScaler = NewNodeScaler(s.cloudMock, s.clientMock, opts, opts, nil).(*NodeScaler)
+	s.nodeScaler.SetMinAvailableLabel("com.df.minAvailable")
+	s.cloudMock.On("GetNodes", s.ctx, cloud.NodeWorkerType).Return(uint64(4), nil)
+}
+
+// setPlacement runs 5 replicas of web, 2 on the worker node w1 and 1 on w2,
+// w3 and the manager node m1. Only batch, without a budget, runs on w4
+func (s *DisruptionTestSuite) setPlacement(minAvailable string) {
+	s.clientMock.On("ServiceList", s.ctx, types.ServiceListOptions{}).Return([]swarm.Service{
+		{ID: "webID", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{
+			Name: "web", Labels: map[string]string{"com.df.minAvailable": minAvailable}}}},
+		{ID: "batchID", Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{Name: "batch"}}},
+	}, nil)
+	s.clientMock.On("NodeList", s.ctx, mock.Anything).Return([]swarm.Node{
+		{ID: "w1"}, {ID: "w2"}, {ID: "w3"}, {ID: "w4"},
+	}, nil)
+	task := func(serviceID, nodeID string, state swarm.TaskState) swarm.Task {
+		return swarm.Task{ServiceID: serviceID, NodeID: nodeID, Status: swarm.TaskStatus{State: state}}
+	}
+	s.clientMock.On("TaskList", s.ctx, mock.Anything).Return([]swarm.Task{
+		task("webID", "w1", swarm.TaskStateRunning),
+		task("webID", "w1", swarm.TaskStateRunning),
+		task("webID", "w2", swarm.TaskStateRunning),
+		task("webID", "w3", swarm.TaskStateRunning),
+		task("webID", "m1", swarm.TaskStateRunning),
+		task("webID", "w4", swarm.TaskStatePending),
+		task("batchID", "w4", swarm.TaskStateRunning),
+		task("batchID", "w4", swarm.TaskStateRunning),
+	}, nil)
+}
+
+func (s *DisruptionTestSuite) Test_ScaleDown_WithinBudget() {
+	s.setPlacement("2")
+	s.cloudMock.On("SetNodes", s.ctx, cloud.NodeWorkerType, uint64(2), uint64(0), uint64(5)).Return(nil)
+
+	before, after, err := s.nodeScaler.Scale(s.ctx, 0, ScaleDownDirection, cloud.NodeWorkerType, "")
+	s.Require().NoError(err)
+	s.Equal(uint64(4), before)
+	s.Equal(uint64(2), after)
+}
+
+func (s *DisruptionTestSuite) Test_ScaleDown_Capped() {
+	s.setPlacement("3")
+	s.cloudMock.On("SetNodes", s.ctx, cloud.NodeWorkerType, uint64(3), uint64(0), uint64(5)).Return(nil)
+
+	before, after, err := s.nodeScaler.Scale(s.ctx, 0, ScaleDownDirection, cloud.NodeWorkerType, "")
+	s.Require().Error(err)
+	de, ok := err.(*DisruptionBudgetError)
+	s.Require().True(ok)
+	s.True(de.Capped())
+	s.Equal("web", de.Service)
+	s.Equal("Disruption budget capped worker nodes at 3 instead of 2: removing 2 nodes could leave web with 2 running replicas, less than its minimum available of 3", err.Error())
+	s.Equal(uint64(4), before)
+	s.Equal(uint64(3), after)
+}
+
+func (s *DisruptionTestSuite) Test_ScaleDown_Rejected() {
+	s.setPlacement("4")
+
+	before, after, err := s.nodeScaler.Scale(s.ctx, 0, ScaleDownDirection, cloud.NodeWorkerType, "")
+	s.Require().Error(err)
+	de, ok := err.(*DisruptionBudgetError)
+	s.Require().True(ok)
+	s.False(de.Capped())
+	s.Equal("Disruption budget rejected scaling worker nodes from 4 to 2: removing a node could leave web with 3 running replicas, less than its minimum available of 4", err.Error())
+	s.Equal(uint64(4), before)
+	s.Equal(uint64(4), after)
+	s.cloudMock.AssertNotCalled(s.T(), "SetNodes", s.ctx, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *DisruptionTestSuite) Test_ScaleDown_WithoutLabel() {
+	s.nodeScaler.SetMinAvailableLabel("")
+	s.cloudMock.On("SetNodes", s.ctx, cloud.NodeWorkerType, uint64(2), uint64(0), uint64(5)).Return(nil)
+
+	_, after, err := s.nodeScaler.Scale(s.ctx, 0, ScaleDownDirection, cloud.NodeWorkerType, "")
+	s.Require().NoError(err)
+	s.Equal(uint64(2), after)
+	s.clientMock.AssertNotCalled(s.T(), "TaskList", s.ctx, mock.Anything)
+}
+
+func (s *DisruptionTestSuite) Test_ScaleUp_NotChecked() {
+	s.cloudMock.On("SetNodes", s.ctx, cloud.NodeWorkerType, uint64(5), uint64(0), uint64(5)).Return(nil)
+
+	_, after, err := s.nodeScaler.Scale(s.ctx, 0, ScaleUpDirection, cloud.NodeWorkerType, "")
+	s.Require().NoError(err)
+	s.Equal(uint64(5), after)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceList", s.ctx, mock.Anything)
+}
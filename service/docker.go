@@ -2,64 +2,191 @@ package service
 
 import (
 	"context"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/docker/docker/client"
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/metrics"
+	"github.com/thomasjpfan/docker-scaler/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 var dockerAPIVersion = "v1.37"
 
-// DockerClient wraps `*client.Client` in docker
+// dockerPingTimeout is how long to wait for a docker host when negotiating
+// the api version
+const dockerPingTimeout = 5 * time.Second
+
+// errNotManager is returned when a docker host answers, but is not a swarm
+// manager
+var errNotManager = errors.New("This node is not a swarm manager")
+
+// DockerClient wraps `*client.Client` in docker. With several hosts, calls
+// fail over to the next host when the active one can not be reached or is
+// no longer a swarm manager
 type DockerClient struct {
-	dc *client.Client
+	clients []*client.Client
+	active  *atomic.Int32
 }
 
-// NewDockerClientFromEnv returns a `*client.Client` struct
+// NewDockerClientFromEnv connects to docker like the docker cli does, with
+// DOCKER_HOST, DOCKER_TLS_VERIFY, DOCKER_CERT_PATH and DOCKER_API_VERSION
 func NewDockerClientFromEnv() (DockerClient, error) {
-	host := "unix:///var/run/docker.sock"
+	return NewDockerClient(nil)
+}
+
+// NewDockerClient connects to the docker `hosts` of swarm managers, in
+// order of preference. TLS and the api version are set by the same env
+// variables as NewDockerClientFromEnv. DOCKER_HOST is used when `hosts` is
+// empty
+func NewDockerClient(hosts []string) (DockerClient, error) {
+	if len(hosts) == 0 {
+		hosts = []string{""}
+	}
 	defaultHeaders := map[string]string{"User-Agent": "engine-api-cli-1.0"}
-	c, err := client.NewClient(host, dockerAPIVersion, nil, defaultHeaders)
-	if err != nil {
-		return DockerClient{}, err
+	clients := make([]*client.Client, 0, len(hosts))
+	for _, host := range hosts {
+		opts := []func(*client.Client) error{
+			client.WithVersion(dockerAPIVersion),
+			client.WithHTTPHeaders(defaultHeaders),
+			client.FromEnv,
+		}
+		if len(host) > 0 {
+			opts = append(opts, client.WithHost(host))
+		}
+		c, err := client.NewClientWithOpts(opts...)
+		if err != nil {
+			DockerClient{clients: clients}.Close()
+			return DockerClient{}, errors.Wrapf(err, "Unable to create docker client for %s", host)
+		}
+		negotiateAPIVersion(c)
+		clients = append(clients, c)
+	}
+	return DockerClient{clients: clients, active: new(atomic.Int32)}, nil
+}
+
+// negotiateAPIVersion lowers the api version to the one of the docker
+// host. The version is kept when the host can not be reached, since the
+// host may only be down for now
+func negotiateAPIVersion(c *client.Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), dockerPingTimeout)
+	defer cancel()
+	ping, err := c.Ping(ctx)
+	if err == nil {
+		c.NegotiateAPIVersionPing(ping)
 	}
-	c.NegotiateAPIVersion(context.Background())
-	return DockerClient{c}, nil
+}
+
+// Hosts returns the docker hosts, in order of preference
+func (c DockerClient) Hosts() []string {
+	hosts := make([]string, 0, len(c.clients))
+	for _, dc := range c.clients {
+		hosts = append(hosts, dc.DaemonHost())
+	}
+	return hosts
+}
+
+// Host returns the docker host calls are sent to
+func (c DockerClient) Host() string {
+	return c.client().DaemonHost()
+}
+
+func (c DockerClient) client() *client.Client {
+	return c.clients[c.active.Load()]
+}
+
+// do calls `f` with the active docker host. While `f` can not reach a
+// swarm manager, it is called again with the next hosts. The first host
+// that is a reachable manager becomes the active host
+func (c DockerClient) do(ctx context.Context, f func(dc *client.Client) error) error {
+	active := int(c.active.Load())
+	var err error
+	for i := range c.clients {
+		idx := (active + i) % len(c.clients)
+		err = f(c.clients[idx])
+		if ctx.Err() != nil {
+			return err
+		}
+		if isManagerUnavailable(err) {
+			continue
+		}
+		if idx != active && c.active.CompareAndSwap(int32(active), int32(idx)) {
+			metrics.CountDockerFailover(c.clients[idx].DaemonHost())
+		}
+		return err
+	}
+	return err
+}
+
+// isManagerUnavailable checks if `err` is caused by a docker host that can
+// not be reached or is not a swarm manager, so another host can be tried
+func isManagerUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	return err == errNotManager ||
+		client.IsErrConnectionFailed(err) ||
+		strings.Contains(err.Error(), "error during connect") ||
+		strings.Contains(err.Error(), errNotManager.Error())
 }
 
 // ServiceInspect wraps `dc.ServiceInspect`
-func (c DockerClient) ServiceInspect(ctx context.Context, serviceID string) (swarm.Service, error) {
-	service, _, err := c.dc.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
+func (c DockerClient) ServiceInspect(ctx context.Context, serviceID string) (service swarm.Service, err error) {
+	defer metrics.ObserveDockerCall("service_inspect", time.Now())
+	ctx, span := tracing.Start(ctx, "docker.service_inspect", attribute.String("service", serviceID))
+	defer tracing.End(span, &err)
+	err = c.do(ctx, func(dc *client.Client) error {
+		var err error
+		service, _, err = dc.ServiceInspectWithRaw(ctx, serviceID, types.ServiceInspectOptions{})
+		return err
+	})
 	return service, err
 }
 
 // ServiceUpdate wraps `dc.ServiceUpdate`
-func (c DockerClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, service swarm.ServiceSpec) error {
-	_, err := c.dc.ServiceUpdate(
-		ctx, serviceID, version, service,
-		types.ServiceUpdateOptions{
-			RegistryAuthFrom: types.RegistryAuthFromSpec,
-		})
-	return err
+func (c DockerClient) ServiceUpdate(ctx context.Context, serviceID string, version swarm.Version, service swarm.ServiceSpec) (err error) {
+	defer metrics.ObserveDockerCall("service_update", time.Now())
+	ctx, span := tracing.Start(ctx, "docker.service_update", attribute.String("service", serviceID))
+	defer tracing.End(span, &err)
+	return c.do(ctx, func(dc *client.Client) error {
+		_, err := dc.ServiceUpdate(
+			ctx, serviceID, version, service,
+			types.ServiceUpdateOptions{
+				RegistryAuthFrom: types.RegistryAuthFromSpec,
+			})
+		return err
+	})
 }
 
 // NodeReadyCnt wraps `dc.NodeList`
-func (c DockerClient) NodeReadyCnt(ctx context.Context, manager bool) (int, error) {
+func (c DockerClient) NodeReadyCnt(ctx context.Context, manager bool) (cnt int, err error) {
+	defer metrics.ObserveDockerCall("node_list", time.Now())
 	var typeStr string
 	if manager {
 		typeStr = "manager"
 	} else {
 		typeStr = "worker"
 	}
+	ctx, span := tracing.Start(ctx, "docker.node_list", attribute.String("node_type", typeStr))
+	defer tracing.End(span, &err)
 	f := filters.NewArgs()
 	f.Add("role", typeStr)
-	nodes, err := c.dc.NodeList(ctx, types.NodeListOptions{Filters: f})
+	var nodes []swarm.Node
+	err = c.do(ctx, func(dc *client.Client) error {
+		var err error
+		nodes, err = dc.NodeList(ctx, types.NodeListOptions{Filters: f})
+		return err
+	})
 	if err != nil {
 		return 0, err
 	}
 
-	cnt := 0
 	for _, n := range nodes {
 		if n.Status.State == swarm.NodeStateReady {
 			cnt++
@@ -69,12 +196,93 @@ func (c DockerClient) NodeReadyCnt(ctx context.Context, manager bool) (int, erro
 	return cnt, nil
 }
 
+// Info wraps `dc.Info`
+func (c DockerClient) Info(ctx context.Context) (info types.Info, err error) {
+	defer metrics.ObserveDockerCall("info", time.Now())
+	ctx, span := tracing.Start(ctx, "docker.info")
+	defer tracing.End(span, &err)
+	err = c.do(ctx, func(dc *client.Client) error {
+		var err error
+		info, err = dc.Info(ctx)
+		if err == nil && !info.Swarm.ControlAvailable {
+			return errNotManager
+		}
+		return err
+	})
+	// Every host answered, but none is a manager. The health check
+	// reports it from `info`
+	if err == errNotManager {
+		err = nil
+	}
+	return info, err
+}
+
 // ServiceList wraps `dc.ServiceList`
-func (c DockerClient) ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error) {
-	return c.dc.ServiceList(ctx, options)
+func (c DockerClient) ServiceList(ctx context.Context, options types.ServiceListOptions) (services []swarm.Service, err error) {
+	defer metrics.ObserveDockerCall("service_list", time.Now())
+	ctx, span := tracing.Start(ctx, "docker.service_list")
+	defer tracing.End(span, &err)
+	err = c.do(ctx, func(dc *client.Client) error {
+		var err error
+		services, err = dc.ServiceList(ctx, options)
+		return err
+	})
+	return services, err
 }
 
-// Close wraps `dc.Close`
+// NodeList wraps `dc.NodeList`
+func (c DockerClient) NodeList(ctx context.Context, options types.NodeListOptions) (nodes []swarm.Node, err error) {
+	defer metrics.ObserveDockerCall("node_list", time.Now())
+	ctx, span := tracing.Start(ctx, "docker.node_list")
+	defer tracing.End(span, &err)
+	err = c.do(ctx, func(dc *client.Client) error {
+		var err error
+		nodes, err = dc.NodeList(ctx, options)
+		return err
+	})
+	return nodes, err
+}
+
+// TaskList wraps `dc.TaskList`
+func (c DockerClient) TaskList(ctx context.Context, options types.TaskListOptions) (tasks []swarm.Task, err error) {
+	defer metrics.ObserveDockerCall("task_list", time.Now())
+	ctx, span := tracing.Start(ctx, "docker.task_list")
+	defer tracing.End(span, &err)
+	err = c.do(ctx, func(dc *client.Client) error {
+		var err error
+		tasks, err = dc.TaskList(ctx, options)
+		return err
+	})
+	return tasks, err
+}
+
+// Events wraps `dc.Events` of the first host that is a reachable manager,
+// probed with `dc.Info` like the other calls. A stream that fails is not
+// moved to another host: the caller subscribes again, which fails over to
+// the next host when the active one is gone
+func (c DockerClient) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	var manager *client.Client
+	err := c.do(ctx, func(dc *client.Client) error {
+		info, err := dc.Info(ctx)
+		if err == nil && !info.Swarm.ControlAvailable {
+			return errNotManager
+		}
+		if err == nil {
+			manager = dc
+		}
+		return err
+	})
+	if err != nil {
+		errs := make(chan error, 1)
+		errs <- err
+		return make(chan events.Message), errs
+	}
+	return manager.Events(ctx, options)
+}
+
+// Close wraps `dc.Close` for every host
 func (c DockerClient) Close() {
-	c.dc.Close()
+	for _, dc := range c.clients {
+		dc.Close()
+	}
 }
@@ -2,11 +2,17 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os/exec"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/suite"
@@ -56,7 +62,7 @@ L:
 	for {
 		select {
 		case <-tickerC:
-			service, _, err := s.client.dc.ServiceInspectWithRaw(
+			service, _, err := s.client.client().ServiceInspectWithRaw(
 				s.ctx, "web_test", types.ServiceInspectOptions{})
 			if err == nil {
 				s.service = service
@@ -171,3 +177,182 @@ func (s *DockerClientTestSuite) Test_ServiceList_FalseLabel() {
 
 	s.Len(sList, 0)
 }
+
+type DockerFailoverTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func TestDockerFailoverUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(DockerFailoverTestSuite))
+}
+
+func (s *DockerFailoverTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.T().Setenv("DOCKER_HOST", "")
+	s.T().Setenv("DOCKER_CERT_PATH", "")
+	s.T().Setenv("DOCKER_API_VERSION", "")
+}
+
+// daemon starts a fake docker daemon that answers `path` with `code` and
+// `body`, and returns its docker host
+func (s *DockerFailoverTestSuite) daemon(path string, code int, body interface{}) string {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/_ping") {
+			w.Header().Set("API-Version", "1.37")
+			return
+		}
+		if !strings.HasSuffix(r.URL.Path, path) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(body)
+	}))
+	s.T().Cleanup(ts.Close)
+	return "tcp://" + ts.Listener.Addr().String()
+}
+
+// downHost returns a docker host that refuses connections
+func (s *DockerFailoverTestSuite) downHost() string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	host := "tcp://" + l.Addr().String()
+	l.Close()
+	return host
+}
+
+func (s *DockerFailoverTestSuite) newClient(hosts ...string) DockerClient {
+	c, err := NewDockerClient(hosts)
+	s.Require().NoError(err)
+	s.T().Cleanup(c.Close)
+	s.Equal(hosts, c.Hosts())
+	return c
+}
+
+func (s *DockerFailoverTestSuite) Test_FailsOverWhenHostIsDown() {
+	down := s.downHost()
+	up := s.daemon("/services/web", http.StatusOK, swarm.Service{ID: "web-id"})
+	c := s.newClient(down, up)
+
+	service, err := c.ServiceInspect(s.ctx, "web")
+	s.Require().NoError(err)
+	s.Equal("web-id", service.ID)
+	s.Equal(up, c.Host())
+}
+
+func (s *DockerFailoverTestSuite) Test_FailsOverWhenHostIsNotManager() {
+	demoted := s.daemon("/services", http.StatusServiceUnavailable, types.ErrorResponse{
+		Message: "This node is not a swarm manager. Worker nodes can't be used to view or modify cluster state."})
+	manager := s.daemon("/services", http.StatusOK, []swarm.Service{{ID: "web-id"}})
+	c := s.newClient(demoted, manager)
+
+	services, err := c.ServiceList(s.ctx, types.ServiceListOptions{})
+	s.Require().NoError(err)
+	s.Len(services, 1)
+	s.Equal(manager, c.Host())
+}
+
+func (s *DockerFailoverTestSuite) Test_KeepsHostOnOtherErrors() {
+	first := s.daemon("/services/web", http.StatusNotFound, types.ErrorResponse{Message: "service web not found"})
+	second := s.daemon("/services/web", http.StatusOK, swarm.Service{ID: "web-id"})
+	c := s.newClient(first, second)
+
+	_, err := c.ServiceInspect(s.ctx, "web")
+	s.Require().Error(err)
+	s.Contains(err.Error(), "No such service: web")
+	s.Equal(first, c.Host())
+}
+
+func (s *DockerFailoverTestSuite) Test_AllHostsDown() {
+	c := s.newClient(s.downHost(), s.downHost())
+	_, err := c.ServiceInspect(s.ctx, "web")
+	s.Require().Error(err)
+	s.True(isManagerUnavailable(err))
+}
+
+func (s *DockerFailoverTestSuite) Test_Info_FailsOverWhenDemoted() {
+	worker := types.Info{}
+	worker.Swarm.LocalNodeState = swarm.LocalNodeStateActive
+	manager := worker
+	manager.Swarm.ControlAvailable = true
+
+	demoted := s.daemon("/info", http.StatusOK, worker)
+	up := s.daemon("/info", http.StatusOK, manager)
+	c := s.newClient(demoted, up)
+
+	info, err := c.Info(s.ctx)
+	s.Require().NoError(err)
+	s.True(info.Swarm.ControlAvailable)
+	s.Equal(up, c.Host())
+}
+
+func (s *DockerFailoverTestSuite) Test_Info_OneHostNotManager() {
+	worker := types.Info{}
+	worker.Swarm.LocalNodeState = swarm.LocalNodeStateActive
+	c := s.newClient(s.daemon("/info", http.StatusOK, worker))
+
+	info, err := c.Info(s.ctx)
+	s.Require().NoError(err)
+	s.False(info.Swarm.ControlAvailable)
+}
+
+// eventsDaemon starts a fake docker manager that streams `msg` on
+// /events, and returns its docker host
+func (s *DockerFailoverTestSuite) eventsDaemon(msg events.Message) string {
+	manager := types.Info{}
+	manager.Swarm.ControlAvailable = true
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/_ping"):
+			w.Header().Set("API-Version", "1.37")
+		case strings.HasSuffix(r.URL.Path, "/info"):
+			json.NewEncoder(w).Encode(manager)
+		case strings.HasSuffix(r.URL.Path, "/events"):
+			json.NewEncoder(w).Encode(msg)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	s.T().Cleanup(ts.Close)
+	return "tcp://" + ts.Listener.Addr().String()
+}
+
+func (s *DockerFailoverTestSuite) Test_Events_FailsOverWhenHostIsDown() {
+	up := s.eventsDaemon(events.Message{Type: events.ServiceEventType, Action: "update"})
+	c := s.newClient(s.downHost(), up)
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+
+	msgs, errs := c.Events(ctx, types.EventsOptions{})
+	select {
+	case msg := <-msgs:
+		s.Equal("update", msg.Action)
+	case err := <-errs:
+		s.Fail("Unexpected error", err.Error())
+	case <-time.After(5 * time.Second):
+		s.Fail("No event received")
+	}
+	s.Equal(up, c.Host())
+}
+
+func (s *DockerFailoverTestSuite) Test_Events_AllHostsDown() {
+	c := s.newClient(s.downHost(), s.downHost())
+
+	_, errs := c.Events(s.ctx, types.EventsOptions{})
+	err := <-errs
+	s.Require().Error(err)
+	s.True(isManagerUnavailable(err))
+}
+
+func (s *DockerFailoverTestSuite) Test_FromEnv() {
+	host := s.daemon("/info", http.StatusOK, types.Info{})
+	s.T().Setenv("DOCKER_HOST", host)
+	c, err := NewDockerClientFromEnv()
+	s.Require().NoError(err)
+	defer c.Close()
+	s.Equal([]string{host}, c.Hosts())
+}
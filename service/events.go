@@ -0,0 +1,141 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// Kinds of events published by docker-scaler
+const (
+	EventScaleService   = "scale_service"
+	EventScaleNodes     = "scale_nodes"
+	EventReschedule     = "reschedule"
+	EventRescheduleTick = "reschedule_tick"
+	EventAlertFailure   = "alert_failure"
+	EventUpdateDefaults = "update_defaults"
+	EventApproval       = "approval"
+)
+
+// EventKinds are the kinds of events published by docker-scaler
+var EventKinds = []string{
+	EventScaleService, EventScaleNodes, EventReschedule,
+	EventRescheduleTick, EventAlertFailure, EventUpdateDefaults,
+	EventApproval,
+}
+
+// IsEventKind checks if `kind` is one of EventKinds
+func IsEventKind(kind string) bool {
+	for _, k := range EventKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Event describes a decision or action taken by docker-scaler
+type Event struct {
+	Time        time.Time `json:"time"`
+	Kind        string    `json:"kind"`
+	Cluster     string    `json:"cluster,omitempty"`
+	Service     string    `json:"service,omitempty"`
+	OperationID string    `json:"operationId,omitempty"`
+	RequestID   string    `json:"requestId,omitempty"`
+	Status      string    `json:"status"`
+	Message     string    `json:"message"`
+}
+
+// EventFilter selects events by cluster, service and kind. An empty list
+// matches every cluster, service or kind
+type EventFilter struct {
+	Clusters []string
+	Services []string
+	Kinds    []string
+}
+
+// Match checks if `e` is selected by the filter
+func (f EventFilter) Match(e Event) bool {
+	return matchAny(f.Clusters, e.Cluster) && matchAny(f.Services, e.Service) &&
+		matchAny(f.Kinds, e.Kind)
+}
+
+func matchAny(values []string, v string) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
+
+type subscriber struct {
+	c      chan Event
+	filter EventFilter
+}
+
+// EventBus delivers published events to every subscriber. A nil EventBus
+// drops published events
+type EventBus struct {
+	subs map[int]subscriber
+	next int
+	mux  sync.RWMutex
+}
+
+// NewEventBus creates an EventBus
+func NewEventBus() *EventBus {
+	return &EventBus{subs: map[int]subscriber{}}
+}
+
+// Publish sends `e` to subscribers. Events are dropped for subscribers
+// that are not keeping up, so publishing never blocks
+func (b *EventBus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	for _, sub := range b.subs {
+		if !sub.filter.Match(e) {
+			continue
+		}
+		select {
+		case sub.c <- e:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving published events matching
+// `filter`, buffering at most `buffer` events, and a function to
+// unsubscribe
+func (b *EventBus) Subscribe(buffer int, filter EventFilter) (<-chan Event, func()) {
+	c := make(chan Event, buffer)
+	b.mux.Lock()
+	id := b.next
+	b.next++
+	b.subs[id] = subscriber{c: c, filter: filter}
+	b.mux.Unlock()
+
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			b.mux.Lock()
+			delete(b.subs, id)
+			b.mux.Unlock()
+			close(c)
+		})
+	}
+}
+
+// Subscribers returns the number of subscribers
+func (b *EventBus) Subscribers() int {
+	b.mux.RLock()
+	defer b.mux.RUnlock()
+	return len(b.subs)
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type EventBusTestSuite struct {
+	suite.Suite
+}
+
+func TestEventBusUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(EventBusTestSuite))
+}
+
+func (s *EventBusTestSuite) Test_Publish_DeliversToSubscribers() {
+	b := NewEventBus()
+	c1, unsubscribe1 := b.Subscribe(1, EventFilter{})
+	defer unsubscribe1()
+	c2, unsubscribe2 := b.Subscribe(1, EventFilter{})
+	defer unsubscribe2()
+
+	b.Publish(Event{Kind: EventScaleService, Service: "web", Status: "success"})
+
+	for _, c := range []<-chan Event{c1, c2} {
+		e := <-c
+		s.Equal(EventScaleService, e.Kind)
+		s.Equal("web", e.Service)
+		s.False(e.Time.IsZero())
+	}
+}
+
+func (s *EventBusTestSuite) Test_Publish_KeepsTime() {
+	b := NewEventBus()
+	c, unsubscribe := b.Subscribe(1, EventFilter{})
+	defer unsubscribe()
+
+	now := time.Date(2018, 7, 10, 12, 0, 0, 0, time.UTC)
+	b.Publish(Event{Time: now})
+	s.Equal(now, (<-c).Time)
+}
+
+func (s *EventBusTestSuite) Test_Publish_DropsWhenSubscriberIsFull() {
+	b := NewEventBus()
+	c, unsubscribe := b.Subscribe(1, EventFilter{})
+	defer unsubscribe()
+
+	b.Publish(Event{Message: "first"})
+	b.Publish(Event{Message: "second"})
+
+	s.Equal("first", (<-c).Message)
+	s.Len(c, 0)
+}
+
+func (s *EventBusTestSuite) Test_Unsubscribe() {
+	b := NewEventBus()
+	c, unsubscribe := b.Subscribe(1, EventFilter{})
+	s.Equal(1, b.Subscribers())
+
+	unsubscribe()
+	unsubscribe()
+	s.Equal(0, b.Subscribers())
+
+	_, ok := <-c
+	s.False(ok)
+	b.Publish(Event{})
+}
+
+func (s *EventBusTestSuite) Test_Subscribe_Filter() {
+	b := NewEventBus()
+	c, unsubscribe := b.Subscribe(2, EventFilter{
+		Services: []string{"web"},
+		Kinds:    []string{EventScaleService, EventAlertFailure},
+	})
+	defer unsubscribe()
+
+	b.Publish(Event{Kind: EventScaleService, Service: "db"})
+	b.Publish(Event{Kind: EventReschedule, Service: "web"})
+	b.Publish(Event{Kind: EventAlertFailure, Service: "web", Message: "first"})
+	b.Publish(Event{Kind: EventScaleService, Service: "web", Message: "second"})
+
+	s.Equal("first", (<-c).Message)
+	s.Equal("second", (<-c).Message)
+	s.Len(c, 0)
+}
+
+func (s *EventBusTestSuite) Test_Subscribe_FilterCluster() {
+	b := NewEventBus()
+	c, unsubscribe := b.Subscribe(2, EventFilter{Clusters: []string{"eu-west"}})
+	defer unsubscribe()
+
+	b.Publish(Event{Kind: EventScaleService, Service: "web"})
+	b.Publish(Event{Kind: EventScaleService, Cluster: "us-east", Service: "web"})
+	b.Publish(Event{Kind: EventScaleService, Cluster: "eu-west", Service: "web", Message: "eu"})
+
+	s.Equal("eu", (<-c).Message)
+	s.Len(c, 0)
+}
+
+func (s *EventBusTestSuite) Test_Publish_NilBus() {
+	var b *EventBus
+	b.Publish(Event{})
+}
+
+func (s *EventBusTestSuite) Test_IsEventKind() {
+	s.True(IsEventKind(EventRescheduleTick))
+	s.False(IsEventKind("scale"))
+}
@@ -0,0 +1,326 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// FreezeScope is what a freeze stops
+type FreezeScope string
+
+const (
+	// FreezeAll stops scaling services and nodes, and rescheduling
+	FreezeAll FreezeScope = "all"
+	// FreezeNodes stops scaling nodes and rescheduling
+	FreezeNodes FreezeScope = "nodes"
+	// FreezeServices stops scaling and rescheduling the services matching
+	// the selector
+	FreezeServices FreezeScope = "services"
+)
+
+// FreezePolicy is what a freeze still allows
+type FreezePolicy string
+
+const (
+	// FreezeReject rejects every change in scope
+	FreezeReject FreezePolicy = "reject"
+	// FreezeAllowScaleUp rejects every change in scope except scaling up
+	FreezeAllowScaleUp FreezePolicy = "allow-scale-up"
+)
+
+// Freeze is a window in which scaling is rejected, like a maintenance
+// window or a change freeze
+type Freeze struct {
+	Name  string      `json:"name"`
+	Start time.Time   `json:"start"`
+	End   time.Time   `json:"end"`
+	Scope FreezeScope `json:"scope"`
+	// Selector selects the services of a `services` freeze as
+	// comma-separated `label=value` or `label` terms. It selects every
+	// service when empty
+	Selector string       `json:"selector,omitempty"`
+	Policy   FreezePolicy `json:"policy"`
+	// Cluster is the cluster the freeze applies to. It applies to every
+	// cluster when empty
+	Cluster string `json:"cluster,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+	// Source is `config` for freezes declared in the config file and `api`
+	// for freezes added through the api
+	Source string `json:"source"`
+}
+
+// FreezeAction is a change checked against freezes
+type FreezeAction struct {
+	Cluster string
+	// Kind is `scale_service`, `scale_nodes`, `reschedule` for every
+	// labeled service or `reschedule_service` for one service
+	Kind      string
+	Direction ScaleDirection
+	// Service and Labels are the name and labels of the service to scale
+	// or reschedule
+	Service string
+	Labels  map[string]string
+}
+
+// FrozenError is returned when a freeze rejects an action
+type FrozenError struct {
+	Freeze Freeze
+}
+
+func (e *FrozenError) Error() string {
+	message := fmt.Sprintf("Rejected by freeze %s until %s", e.Freeze.Name, e.Freeze.End.UTC().Format(time.RFC3339))
+	if len(e.Freeze.Reason) > 0 {
+		message = fmt.Sprintf("%s: %s", message, e.Freeze.Reason)
+	}
+	return message
+}
+
+// Validate checks the fields of `f`, and fills in the default scope and
+// policy
+func (f *Freeze) Validate() error {
+	if len(f.Scope) == 0 {
+		f.Scope = FreezeAll
+	}
+	if len(f.Policy) == 0 {
+		f.Policy = FreezeReject
+	}
+	switch {
+	case len(f.Name) == 0:
+		return errors.New("A freeze needs a name")
+	case f.Start.IsZero() || f.End.IsZero():
+		return fmt.Errorf("Freeze %s needs a start and an end", f.Name)
+	case !f.End.After(f.Start):
+		return fmt.Errorf("Freeze %s ends before it starts", f.Name)
+	case f.Scope != FreezeAll && f.Scope != FreezeNodes && f.Scope != FreezeServices:
+		return fmt.Errorf("Unknown scope: %s, scope can only be all, nodes or services", f.Scope)
+	case f.Policy != FreezeReject && f.Policy != FreezeAllowScaleUp:
+		return fmt.Errorf("Unknown policy: %s, policy can only be reject or allow-scale-up", f.Policy)
+	case len(f.Selector) > 0 && f.Scope != FreezeServices:
+		return fmt.Errorf("Freeze %s has a selector, which needs scope services", f.Name)
+	}
+	if len(f.Selector) == 0 {
+		return nil
+	}
+	for _, term := range strings.Split(f.Selector, ",") {
+		if len(strings.TrimSpace(strings.SplitN(term, "=", 2)[0])) == 0 {
+			return fmt.Errorf("Invalid selector of freeze %s: %s", f.Name, f.Selector)
+		}
+	}
+	return nil
+}
+
+// Active returns true when the freeze applies at `now`
+func (f Freeze) Active(now time.Time) bool {
+	return !now.Before(f.Start) && now.Before(f.End)
+}
+
+// Rejects returns true when the freeze rejects `a` at `now`
+func (f Freeze) Rejects(a FreezeAction, now time.Time) bool {
+	if !f.Active(now) || (len(f.Cluster) > 0 && f.Cluster != a.Cluster) {
+		return false
+	}
+	if f.Policy == FreezeAllowScaleUp && a.Direction == ScaleUpDirection {
+		return false
+	}
+	switch f.Scope {
+	case FreezeAll:
+		return true
+	case FreezeNodes:
+		return a.Kind != "scale_service"
+	case FreezeServices:
+		return (a.Kind == "scale_service" || a.Kind == "reschedule_service") && f.selects(a.Labels)
+	}
+	return false
+}
+
+// selects returns true when `labels` match the selector
+func (f Freeze) selects(labels map[string]string) bool {
+	if len(f.Selector) == 0 {
+		return true
+	}
+	for _, term := range strings.Split(f.Selector, ",") {
+		kv := strings.SplitN(term, "=", 2)
+		value, ok := labels[strings.TrimSpace(kv[0])]
+		if !ok || (len(kv) == 2 && value != strings.TrimSpace(kv[1])) {
+			return false
+		}
+	}
+	return true
+}
+
+// Freezes keeps the freezes declared in the config file and the freezes
+// added through the api
+type Freezes struct {
+	mu         sync.RWMutex
+	configured []Freeze
+	added      map[string]Freeze
+	path       string
+}
+
+// NewFreezes creates Freezes without freezes
+func NewFreezes() *Freezes {
+	return &Freezes{added: map[string]Freeze{}}
+}
+
+// OpenFreezes creates Freezes that saves the freezes added through the
+// api to the file at `path`, and reads the freezes saved there
+func OpenFreezes(path string) (*Freezes, error) {
+	added, err := readFreezes(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Freezes{added: added, path: path}, nil
+}
+
+// Reload reads the freezes saved to the file again, replacing the freezes
+// added through the api, so a replica that starts leading uses the freezes
+// the previous leader added
+func (f *Freezes) Reload() error {
+	if len(f.path) == 0 {
+		return nil
+	}
+	added, err := readFreezes(f.path)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.added = added
+	return nil
+}
+
+// readFreezes reads the freezes saved to the file at `path`, by name
+func readFreezes(path string) (map[string]Freeze, error) {
+	added := map[string]Freeze{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return added, nil
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read freezes file %s", path)
+	}
+	var freezes []Freeze
+	if err := json.Unmarshal(b, &freezes); err != nil {
+		return nil, errors.Wrapf(err, "Unable to read freezes file %s", path)
+	}
+	for _, freeze := range freezes {
+		added[freeze.Name] = freeze
+	}
+	return added, nil
+}
+
+// SetConfigured replaces the freezes declared in the config file
+func (f *Freezes) SetConfigured(freezes []Freeze) {
+	configured := make([]Freeze, len(freezes))
+	for i, freeze := range freezes {
+		freeze.Source = "config"
+		configured[i] = freeze
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.configured = configured
+}
+
+// Add adds or replaces a freeze through the api. Freezes declared in the
+// config file can not be replaced. The freeze is not added when it can not
+// be saved
+func (f *Freezes) Add(freeze Freeze) (Freeze, error) {
+	if err := freeze.Validate(); err != nil {
+		return freeze, err
+	}
+	freeze.Source = "api"
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.configured {
+		if c.Name == freeze.Name {
+			return freeze, fmt.Errorf("Freeze %s is declared in the config file and can only be changed there", freeze.Name)
+		}
+	}
+	previous, existed := f.added[freeze.Name]
+	f.added[freeze.Name] = freeze
+	if err := f.save(); err != nil {
+		if existed {
+			f.added[freeze.Name] = previous
+		} else {
+			delete(f.added, freeze.Name)
+		}
+		return freeze, err
+	}
+	return freeze, nil
+}
+
+// Delete removes a freeze added through the api. It returns false when the
+// freeze does not exist. The freeze is kept when the change can not be saved
+func (f *Freezes) Delete(name string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	previous, ok := f.added[name]
+	if !ok {
+		for _, c := range f.configured {
+			if c.Name == name {
+				return true, fmt.Errorf("Freeze %s is declared in the config file and can only be removed there", name)
+			}
+		}
+		return false, nil
+	}
+	delete(f.added, name)
+	if err := f.save(); err != nil {
+		f.added[name] = previous
+		return true, err
+	}
+	return true, nil
+}
+
+// List returns every freeze, ordered by start
+func (f *Freezes) List() []Freeze {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	freezes := append([]Freeze{}, f.configured...)
+	for _, freeze := range f.added {
+		freezes = append(freezes, freeze)
+	}
+	sort.SliceStable(freezes, func(i, j int) bool {
+		if freezes[i].Start.Equal(freezes[j].Start) {
+			return freezes[i].Name < freezes[j].Name
+		}
+		return freezes[i].Start.Before(freezes[j].Start)
+	})
+	return freezes
+}
+
+// Check returns a *FrozenError when an active freeze rejects `a`
+func (f *Freezes) Check(a FreezeAction) error {
+	if f == nil {
+		return nil
+	}
+	now := time.Now()
+	for _, freeze := range f.List() {
+		if freeze.Rejects(a, now) {
+			return &FrozenError{Freeze: freeze}
+		}
+	}
+	return nil
+}
+
+// save writes the freezes added through the api to the file. It must be
+// called with the lock held
+func (f *Freezes) save() error {
+	if len(f.path) == 0 {
+		return nil
+	}
+	added := make([]Freeze, 0, len(f.added))
+	for _, freeze := range f.added {
+		added = append(added, freeze)
+	}
+	sort.Slice(added, func(i, j int) bool { return added[i].Name < added[j].Name })
+	return writeStateFile(f.path, added)
+}
@@ -0,0 +1,198 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FreezeTestSuite struct {
+	suite.Suite
+	now time.Time
+}
+
+func TestFreezeUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(FreezeTestSuite))
+}
+
+func (s *FreezeTestSuite) SetupTest() {
+	s.now = time.Now()
+}
+
+func (s *FreezeTestSuite) freeze(scope FreezeScope, policy FreezePolicy) Freeze {
+	return Freeze{Name: "f", Start: s.now.Add(-time.Hour), End: s.now.Add(time.Hour), Scope: scope, Policy: policy}
+}
+
+func (s *FreezeTestSuite) Test_Rejects_Scope() {
+	scaleService := FreezeAction{Kind: "scale_service", Direction: ScaleUpDirection}
+	scaleNodes := FreezeAction{Kind: "scale_nodes", Direction: ScaleUpDirection}
+	reschedule := FreezeAction{Kind: "reschedule"}
+	rescheduleService := FreezeAction{Kind: "reschedule_service", Service: "web"}
+
+	all := s.freeze(FreezeAll, FreezeReject)
+	s.True(all.Rejects(scaleService, s.now))
+	s.True(all.Rejects(scaleNodes, s.now))
+	s.True(all.Rejects(reschedule, s.now))
+	s.True(all.Rejects(rescheduleService, s.now))
+
+	nodes := s.freeze(FreezeNodes, FreezeReject)
+	s.False(nodes.Rejects(scaleService, s.now))
+	s.True(nodes.Rejects(scaleNodes, s.now))
+	s.True(nodes.Rejects(reschedule, s.now))
+	s.True(nodes.Rejects(rescheduleService, s.now))
+
+	services := s.freeze(FreezeServices, FreezeReject)
+	s.True(services.Rejects(scaleService, s.now))
+	s.False(services.Rejects(scaleNodes, s.now))
+	s.False(services.Rejects(reschedule, s.now))
+	s.True(services.Rejects(rescheduleService, s.now))
+}
+
+func (s *FreezeTestSuite) Test_Rejects_AllowScaleUp() {
+	f := s.freeze(FreezeAll, FreezeAllowScaleUp)
+	s.False(f.Rejects(FreezeAction{Kind: "scale_nodes", Direction: ScaleUpDirection}, s.now))
+	s.True(f.Rejects(FreezeAction{Kind: "scale_nodes", Direction: ScaleDownDirection}, s.now))
+	s.True(f.Rejects(FreezeAction{Kind: "reschedule"}, s.now))
+}
+
+func (s *FreezeTestSuite) Test_Rejects_Window() {
+	f := s.freeze(FreezeAll, FreezeReject)
+	a := FreezeAction{Kind: "scale_nodes"}
+	s.False(f.Rejects(a, f.Start.Add(-time.Second)))
+	s.True(f.Rejects(a, f.Start))
+	s.False(f.Rejects(a, f.End))
+}
+
+func (s *FreezeTestSuite) Test_Rejects_ClusterAndSelector() {
+	f := s.freeze(FreezeServices, FreezeReject)
+	f.Cluster = "eu-west"
+	f.Selector = "com.df.tier=db, com.df.critical"
+	labels := map[string]string{"com.df.tier": "db", "com.df.critical": "yes"}
+
+	s.True(f.Rejects(FreezeAction{Cluster: "eu-west", Kind: "scale_service", Labels: labels}, s.now))
+	s.False(f.Rejects(FreezeAction{Cluster: "default", Kind: "scale_service", Labels: labels}, s.now))
+	s.False(f.Rejects(FreezeAction{Cluster: "eu-west", Kind: "scale_service",
+		Labels: map[string]string{"com.df.tier": "web", "com.df.critical": "yes"}}, s.now))
+	s.False(f.Rejects(FreezeAction{Cluster: "eu-west", Kind: "scale_service",
+		Labels: map[string]string{"com.df.tier": "db"}}, s.now))
+	s.True(f.Rejects(FreezeAction{Cluster: "eu-west", Kind: "reschedule_service", Labels: labels}, s.now))
+	s.False(f.Rejects(FreezeAction{Cluster: "eu-west", Kind: "reschedule_service",
+		Labels: map[string]string{"com.df.tier": "web"}}, s.now))
+}
+
+func (s *FreezeTestSuite) Test_Validate() {
+	f := Freeze{Name: "f", Start: s.now, End: s.now.Add(time.Hour)}
+	s.Require().NoError(f.Validate())
+	s.Equal(FreezeAll, f.Scope)
+	s.Equal(FreezeReject, f.Policy)
+
+	f.Selector = "com.df.tier=db"
+	s.EqualError(f.Validate(), "Freeze f has a selector, which needs scope services")
+	f.Scope = "pools"
+	s.EqualError(f.Validate(), "Unknown scope: pools, scope can only be all, nodes or services")
+	f.Scope, f.Policy = FreezeServices, "allow"
+	s.EqualError(f.Validate(), "Unknown policy: allow, policy can only be reject or allow-scale-up")
+	f.Policy, f.Selector = FreezeReject, "=db"
+	s.EqualError(f.Validate(), "Invalid selector of freeze f: =db")
+	f.Selector, f.End = "", s.now
+	s.EqualError(f.Validate(), "Freeze f ends before it starts")
+	f.Name = ""
+	s.EqualError(f.Validate(), "A freeze needs a name")
+}
+
+func (s *FreezeTestSuite) Test_Freezes_AddDeleteAndSave() {
+	path := filepath.Join(s.T().TempDir(), "freezes.json")
+	freezes, err := OpenFreezes(path)
+	s.Require().NoError(err)
+	freezes.SetConfigured([]Freeze{s.freeze(FreezeNodes, FreezeReject)})
+
+	_, err = freezes.Add(s.freeze(FreezeAll, FreezeReject))
+	s.EqualError(err, "Freeze f is declared in the config file and can only be changed there")
+
+	added := s.freeze(FreezeAll, FreezeReject)
+	added.Name = "vendor"
+	added.Start = s.now.Add(time.Hour)
+	added.End = s.now.Add(2 * time.Hour)
+	_, err = freezes.Add(added)
+	s.Require().NoError(err)
+
+	list := freezes.List()
+	s.Require().Len(list, 2)
+	s.Equal("f", list[0].Name)
+	s.Equal("config", list[0].Source)
+	s.Equal("vendor", list[1].Name)
+	s.Equal("api", list[1].Source)
+	s.NoError(freezes.Check(FreezeAction{Kind: "scale_service"}))
+	s.IsType(&FrozenError{}, freezes.Check(FreezeAction{Kind: "scale_nodes"}))
+
+	reopened, err := OpenFreezes(path)
+	s.Require().NoError(err)
+	s.Len(reopened.List(), 1)
+
+	found, err := freezes.Delete("vendor")
+	s.True(found)
+	s.NoError(err)
+	found, err = freezes.Delete("vendor")
+	s.False(found)
+	s.NoError(err)
+	_, err = freezes.Delete("f")
+	s.EqualError(err, "Freeze f is declared in the config file and can only be removed there")
+
+	reopened, err = OpenFreezes(path)
+	s.Require().NoError(err)
+	s.Empty(reopened.List())
+}
+
+func (s *FreezeTestSuite) Test_Freezes_Reload() {
+	path := filepath.Join(s.T().TempDir(), "freezes.json")
+	freezes, err := OpenFreezes(path)
+	s.Require().NoError(err)
+	s.Require().NoError(freezes.Reload())
+	s.Empty(freezes.List())
+
+	leader, err := OpenFreezes(path)
+	s.Require().NoError(err)
+	_, err = leader.Add(s.freeze(FreezeAll, FreezeReject))
+	s.Require().NoError(err)
+
+	s.Require().NoError(freezes.Reload())
+	s.Require().Len(freezes.List(), 1)
+	s.Equal("f", freezes.List()[0].Name)
+
+	s.Require().NoError(os.WriteFile(path, []byte("{"), 0644))
+	s.Error(freezes.Reload())
+	s.Len(freezes.List(), 1)
+}
+
+func (s *FreezeTestSuite) Test_Freezes_SaveFails() {
+	path := filepath.Join(s.T().TempDir(), "freezes.json")
+	freezes, err := OpenFreezes(path)
+	s.Require().NoError(err)
+	_, err = freezes.Add(s.freeze(FreezeAll, FreezeReject))
+	s.Require().NoError(err)
+	freezes.path = filepath.Join(s.T().TempDir(), "missing", "freezes.json")
+
+	_, err = freezes.Add(s.freeze(FreezeNodes, FreezeReject))
+	s.Error(err)
+	s.Require().Len(freezes.List(), 1)
+	s.Equal(FreezeAll, freezes.List()[0].Scope)
+
+	added := s.freeze(FreezeAll, FreezeReject)
+	added.Name = "vendor"
+	_, err = freezes.Add(added)
+	s.Error(err)
+	s.Len(freezes.List(), 1)
+
+	found, err := freezes.Delete("f")
+	s.True(found)
+	s.Error(err)
+	s.Len(freezes.List(), 1)
+}
+
+func (s *FreezeTestSuite) Test_Check_NilFreezes() {
+	var freezes *Freezes
+	s.NoError(freezes.Check(FreezeAction{Kind: "scale_nodes"}))
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+// HealthChecker checks if a dependency of the scaler is reachable
+type HealthChecker interface {
+	Name() string
+	Check(ctx context.Context) error
+}
+
+// Infoer is an interface for getting information about the docker daemon
+type Infoer interface {
+	Info(ctx context.Context) (types.Info, error)
+}
+
+type dockerHealthChecker struct {
+	c Infoer
+}
+
+// NewDockerHealthChecker checks that docker is reachable and the node is a
+// swarm manager
+func NewDockerHealthChecker(c Infoer) HealthChecker {
+	return &dockerHealthChecker{c: c}
+}
+
+func (h dockerHealthChecker) Name() string {
+	return "docker"
+}
+
+func (h dockerHealthChecker) Check(ctx context.Context) error {
+	info, err := h.c.Info(ctx)
+	if err != nil {
+		return errors.Wrap(err, "Unable to reach docker")
+	}
+	if info.Swarm.LocalNodeState != swarm.LocalNodeStateActive {
+		return fmt.Errorf("Node is not part of an active swarm (state: %s)", info.Swarm.LocalNodeState)
+	}
+	if !info.Swarm.ControlAvailable {
+		return fmt.Errorf("Node is not a swarm manager")
+	}
+	return nil
+}
+
+type cloudHealthChecker struct {
+	c cloud.Cloud
+}
+
+// NewCloudHealthChecker checks that the node scaling backend can get
+// the number of manager and worker nodes
+func NewCloudHealthChecker(c cloud.Cloud) HealthChecker {
+	return &cloudHealthChecker{c: c}
+}
+
+func (h cloudHealthChecker) Name() string {
+	return "cloud"
+}
+
+func (h cloudHealthChecker) Check(ctx context.Context) error {
+	for _, nodeType := range []cloud.NodeType{cloud.NodeManagerType, cloud.NodeWorkerType} {
+		_, err := h.c.GetNodes(ctx, nodeType)
+		if err != nil {
+			return errors.Wrapf(err, "Unable to get %s nodes from %s", nodeType, h.c.String())
+		}
+	}
+	return nil
+}
+
+type alertmanagerHealthChecker struct {
+	url    string
+	client *http.Client
+}
+
+// NewAlertmanagerHealthChecker checks that alertmanager at `url` responds
+// on `/-/healthy`, since newer versions removed the v1 api
+func NewAlertmanagerHealthChecker(url string, timeout time.Duration) HealthChecker {
+	return &alertmanagerHealthChecker{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (h alertmanagerHealthChecker) Name() string {
+	return "alertmanager"
+}
+
+func (h alertmanagerHealthChecker) Check(ctx context.Context) error {
+	endpoint := fmt.Sprintf("%s/-/healthy", h.url)
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return errors.Wrap(err, "Unable to create alertmanager request")
+	}
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return errors.Wrap(err, "Unable to reach alertmanager")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Alertmanager responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/service/cloud"
+)
+
+type HealthTestSuite struct {
+	suite.Suite
+	ctx context.Context
+}
+
+func TestHealthUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(HealthTestSuite))
+}
+
+func (s *HealthTestSuite) SetupTest() {
+	s.ctx = context.Background()
+}
+
+func (s *HealthTestSuite) Test_DockerHealthChecker_Manager() {
+	m := new(DockerClientMock)
+	info := types.Info{Swarm: swarm.Info{
+		LocalNodeState:   swarm.LocalNodeStateActive,
+		ControlAvailable: true,
+	}}
+	m.On("Info", s.ctx).Return(info, nil)
+
+	checker := NewDockerHealthChecker(m)
+	s.Equal("docker", checker.Name())
+	s.NoError(checker.Check(s.ctx))
+	m.AssertExpectations(s.T())
+}
+
+func (s *HealthTestSuite) Test_DockerHealthChecker_Unreachable() {
+	m := new(DockerClientMock)
+	m.On("Info", s.ctx).Return(types.Info{}, errors.New("socket not found"))
+
+	err := NewDockerHealthChecker(m).Check(s.ctx)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "socket not found")
+}
+
+func (s *HealthTestSuite) Test_DockerHealthChecker_NotInSwarm() {
+	m := new(DockerClientMock)
+	info := types.Info{Swarm: swarm.Info{
+		LocalNodeState: swarm.LocalNodeStateInactive,
+	}}
+	m.On("Info", s.ctx).Return(info, nil)
+
+	err := NewDockerHealthChecker(m).Check(s.ctx)
+	s.Require().Error(err)
+	s.Equal("Node is not part of an active swarm (state: inactive)", err.Error())
+}
+
+func (s *HealthTestSuite) Test_DockerHealthChecker_Worker() {
+	m := new(DockerClientMock)
+	info := types.Info{Swarm: swarm.Info{
+		LocalNodeState:   swarm.LocalNodeStateActive,
+		ControlAvailable: false,
+	}}
+	m.On("Info", s.ctx).Return(info, nil)
+
+	err := NewDockerHealthChecker(m).Check(s.ctx)
+	s.Require().Error(err)
+	s.Equal("Node is not a swarm manager", err.Error())
+}
+
+func (s *HealthTestSuite) Test_CloudHealthChecker() {
+	m := new(CloudProviderMock)
+	m.On("GetNodes", s.ctx, cloud.NodeManagerType).Return(uint64(3), nil)
+	m.On("GetNodes", s.ctx, cloud.NodeWorkerType).Return(uint64(2), nil)
+
+	checker := NewCloudHealthChecker(m)
+	s.Equal("cloud", checker.Name())
+	s.NoError(checker.Check(s.ctx))
+	m.AssertExpectations(s.T())
+}
+
+func (s *HealthTestSuite) Test_CloudHealthChecker_Error() {
+	m := new(CloudProviderMock)
+	m.On("GetNodes", s.ctx, cloud.NodeManagerType).Return(uint64(0), errors.New("invalid credentials"))
+
+	err := NewCloudHealthChecker(m).Check(s.ctx)
+	s.Require().Error(err)
+	s.Equal("Unable to get manager nodes from cloudmock: invalid credentials", err.Error())
+}
+
+func (s *HealthTestSuite) Test_AlertmanagerHealthChecker() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.Equal("/-/healthy", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	checker := NewAlertmanagerHealthChecker(ts.URL, time.Second)
+	s.Equal("alertmanager", checker.Name())
+	s.NoError(checker.Check(s.ctx))
+}
+
+func (s *HealthTestSuite) Test_AlertmanagerHealthChecker_BadStatus() {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	err := NewAlertmanagerHealthChecker(ts.URL, time.Second).Check(s.ctx)
+	s.Require().Error(err)
+	s.Equal("Alertmanager responded with status 502", err.Error())
+}
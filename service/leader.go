@@ -0,0 +1,143 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Lock is a lease that one replica of docker-scaler holds at a time
+type Lock interface {
+	// Acquire takes the lease for `holder`, or renews it when `holder`
+	// already holds it, until `ttl` has passed. It returns the holder of
+	// the lease afterwards, which is another replica when the lease is
+	// taken
+	Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error)
+	// Release gives up the lease when `holder` holds it
+	Release(ctx context.Context, holder string) error
+}
+
+// lease is the state of a Lock
+type lease struct {
+	Holder  string    `json:"holder"`
+	Expires time.Time `json:"expires"`
+}
+
+// acquire returns the lease after `holder` tries to take or renew it at
+// `now`. The returned bool is true when the lease changed
+func (l lease) acquire(holder string, ttl time.Duration, now time.Time) (lease, bool) {
+	if l.Holder != holder && len(l.Holder) > 0 && now.Before(l.Expires) {
+		return l, false
+	}
+	return lease{Holder: holder, Expires: now.Add(ttl)}, true
+}
+
+// Elector campaigns for a Lock, so only one replica of docker-scaler
+// scales at a time
+type Elector struct {
+	lock Lock
+	id   string
+	ttl  time.Duration
+
+	mu        sync.RWMutex
+	leader    string
+	leading   bool
+	renewedAt time.Time
+}
+
+// NewElector creates an Elector for the replica `id`. The lease lasts
+// `ttl` and is renewed three times per `ttl`
+func NewElector(lock Lock, id string, ttl time.Duration) *Elector {
+	return &Elector{
+		lock: lock,
+		id:   id,
+		ttl:  ttl,
+	}
+}
+
+// ID returns the id of this replica
+func (e *Elector) ID() string {
+	return e.id
+}
+
+// IsLeader returns true when this replica holds the lease
+func (e *Elector) IsLeader() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leading
+}
+
+// Leader returns the id of the replica holding the lease, or an empty
+// string when it is not known
+func (e *Elector) Leader() string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.leader
+}
+
+// Campaign tries to take or renew the lease once. It returns true when this
+// replica started or stopped leading. When the lease can not be reached, a
+// leader keeps leading until its lease would have expired
+func (e *Elector) Campaign(ctx context.Context) (bool, error) {
+	holder, err := e.lock.Acquire(ctx, e.id, e.ttl)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	wasLeading := e.leading
+	if err != nil {
+		if e.leading && time.Since(e.renewedAt) >= e.ttl {
+			e.leading = false
+			e.leader = ""
+		}
+		return wasLeading != e.leading, err
+	}
+	e.leader = holder
+	e.leading = holder == e.id
+	if e.leading {
+		e.renewedAt = time.Now()
+	}
+	return wasLeading != e.leading, nil
+}
+
+// Run campaigns until `ctx` is done, then releases the lease. `onChange` is
+// called when this replica starts or stops leading, and `onError` when the
+// lease can not be reached
+func (e *Elector) Run(ctx context.Context, onChange func(leading bool), onError func(err error)) {
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	for {
+		changed, err := e.Campaign(ctx)
+		if err != nil && ctx.Err() == nil {
+			onError(err)
+		}
+		if changed {
+			onChange(e.IsLeader())
+		}
+		select {
+		case <-ctx.Done():
+			e.resign(onChange, onError)
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resign gives up the lease, so another replica can lead without waiting
+// for it to expire
+func (e *Elector) resign(onChange func(leading bool), onError func(err error)) {
+	e.mu.Lock()
+	wasLeading := e.leading
+	e.leading = false
+	e.leader = ""
+	e.mu.Unlock()
+	if !wasLeading {
+		return
+	}
+	onChange(false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.ttl)
+	defer cancel()
+	if err := e.lock.Release(ctx, e.id); err != nil {
+		onError(err)
+	}
+}
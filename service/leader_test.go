@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type failingLock struct{}
+
+func (failingLock) Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error) {
+	return "", errors.New("unreachable")
+}
+
+func (failingLock) Release(ctx context.Context, holder string) error {
+	return errors.New("unreachable")
+}
+
+type LeaderTestSuite struct {
+	suite.Suite
+	ctx  context.Context
+	path string
+}
+
+func TestLeaderUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(LeaderTestSuite))
+}
+
+func (s *LeaderTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.path = filepath.Join(s.T().TempDir(), "leader.json")
+}
+
+func (s *LeaderTestSuite) Test_FileLock_OneLeader() {
+	a := NewElector(NewFileLock(s.path), "a", time.Minute)
+	b := NewElector(NewFileLock(s.path), "b", time.Minute)
+
+	changed, err := a.Campaign(s.ctx)
+	s.Require().NoError(err)
+	s.True(changed)
+	s.True(a.IsLeader())
+
+	changed, err = b.Campaign(s.ctx)
+	s.Require().NoError(err)
+	s.False(changed)
+	s.False(b.IsLeader())
+	s.Equal("a", b.Leader())
+
+	changed, err = a.Campaign(s.ctx)
+	s.Require().NoError(err)
+	s.False(changed)
+	s.True(a.IsLeader())
+}
+
+func (s *LeaderTestSuite) Test_FileLock_Expired() {
+	a := NewElector(NewFileLock(s.path), "a", time.Millisecond)
+	b := NewElector(NewFileLock(s.path), "b", time.Minute)
+
+	_, err := a.Campaign(s.ctx)
+	s.Require().NoError(err)
+	time.Sleep(5 * time.Millisecond)
+
+	_, err = b.Campaign(s.ctx)
+	s.Require().NoError(err)
+	s.True(b.IsLeader())
+
+	changed, err := a.Campaign(s.ctx)
+	s.Require().NoError(err)
+	s.True(changed)
+	s.False(a.IsLeader())
+	s.Equal("b", a.Leader())
+}
+
+func (s *LeaderTestSuite) Test_FileLock_Release() {
+	lock := NewFileLock(s.path)
+	_, err := lock.Acquire(s.ctx, "a", time.Minute)
+	s.Require().NoError(err)
+
+	s.Require().NoError(lock.Release(s.ctx, "b"))
+	holder, err := lock.Acquire(s.ctx, "b", time.Minute)
+	s.Require().NoError(err)
+	s.Equal("a", holder)
+
+	s.Require().NoError(lock.Release(s.ctx, "a"))
+	holder, err = lock.Acquire(s.ctx, "b", time.Minute)
+	s.Require().NoError(err)
+	s.Equal("b", holder)
+}
+
+func (s *LeaderTestSuite) Test_FileLock_InvalidFile() {
+	_, err := NewFileLock(filepath.Join(s.path, "missing", "leader.json")).Acquire(s.ctx, "a", time.Minute)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to open lock file")
+}
+
+func (s *LeaderTestSuite) Test_Campaign_KeepsLeadingUntilLeaseExpires() {
+	e := NewElector(failingLock{}, "a", 20*time.Millisecond)
+	e.leading, e.leader, e.renewedAt = true, "a", time.Now()
+
+	changed, err := e.Campaign(s.ctx)
+	s.Error(err)
+	s.False(changed)
+	s.True(e.IsLeader())
+
+	time.Sleep(25 * time.Millisecond)
+	changed, err = e.Campaign(s.ctx)
+	s.Error(err)
+	s.True(changed)
+	s.False(e.IsLeader())
+	s.Empty(e.Leader())
+}
+
+func (s *LeaderTestSuite) Test_Run_ResignsWhenDone() {
+	e := NewElector(NewFileLock(s.path), "a", time.Minute)
+	ctx, cancel := context.WithCancel(s.ctx)
+	changes := make(chan bool, 2)
+	done := make(chan struct{})
+	go func() {
+		e.Run(ctx, func(leading bool) { changes <- leading }, func(err error) { s.Fail(err.Error()) })
+		close(done)
+	}()
+
+	s.True(<-changes)
+	cancel()
+	<-done
+	s.False(<-changes)
+
+	holder, err := NewFileLock(s.path).Acquire(s.ctx, "b", time.Minute)
+	s.Require().NoError(err)
+	s.Equal("b", holder)
+}
+
+func (s *LeaderTestSuite) Test_DockerLock() {
+	m := new(DockerClientMock)
+	lock := NewDockerLock(m, "scaler")
+	service := swarm.Service{
+		ID:   "scalerID",
+		Meta: swarm.Meta{Version: swarm.Version{Index: 3}},
+		Spec: swarm.ServiceSpec{Annotations: swarm.Annotations{
+			Labels: map[string]string{"com.df.notify": "true"}}},
+	}
+	m.On("ServiceInspect", mock.Anything, "scaler").Return(service, nil).Once()
+	m.On("ServiceUpdate", mock.Anything, "scalerID", swarm.Version{Index: 3},
+		mock.MatchedBy(func(spec swarm.ServiceSpec) bool {
+			expires, err := time.Parse(time.RFC3339Nano, spec.Labels[leaseExpiresLabel])
+			return err == nil && expires.After(time.Now()) &&
+				spec.Labels[leaderLabel] == "a" && spec.Labels["com.df.notify"] == "true"
+		})).Return(nil).Once()
+
+	holder, err := lock.Acquire(s.ctx, "a", time.Minute)
+	s.Require().NoError(err)
+	s.Equal("a", holder)
+	m.AssertExpectations(s.T())
+	s.Empty(service.Spec.Labels[leaderLabel])
+
+	service.Spec.Labels = map[string]string{
+		leaderLabel:       "a",
+		leaseExpiresLabel: time.Now().Add(time.Minute).Format(time.RFC3339Nano),
+	}
+	m.On("ServiceInspect", mock.Anything, "scaler").Return(service, nil).Twice()
+	holder, err = lock.Acquire(s.ctx, "b", time.Minute)
+	s.Require().NoError(err)
+	s.Equal("a", holder)
+
+	s.Require().NoError(lock.Release(s.ctx, "b"))
+	m.AssertNumberOfCalls(s.T(), "ServiceUpdate", 1)
+}
+
+func (s *LeaderTestSuite) Test_DockerLock_UpdateConflict() {
+	m := new(DockerClientMock)
+	m.On("ServiceInspect", mock.Anything, "scaler").Return(swarm.Service{ID: "scalerID"}, nil)
+	m.On("ServiceUpdate", mock.Anything, "scalerID", mock.Anything, mock.Anything).
+		Return(errors.New("update out of sequence"))
+
+	_, err := NewDockerLock(m, "scaler").Acquire(s.ctx, "a", time.Minute)
+	s.EqualError(err, "Unable to write the leader to scaler: update out of sequence")
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/pkg/errors"
+)
+
+const (
+	// leaderLabel is the service label with the id of the leader
+	leaderLabel = "com.df.scaler.leader"
+	// leaseExpiresLabel is the service label with the time the lease of
+	// the leader expires
+	leaseExpiresLabel = "com.df.scaler.leaseExpires"
+)
+
+// FileLock keeps the lease in a file. Replicas must share the file and its
+// file system must support flock, so it is meant for replicas on one host
+// and for tests
+type FileLock struct {
+	path string
+}
+
+// NewFileLock creates a FileLock at `path`
+func NewFileLock(path string) *FileLock {
+	return &FileLock{path: path}
+}
+
+// Acquire takes or renews the lease in the file
+func (l *FileLock) Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error) {
+	current, err := l.update(func(current lease) (lease, bool) {
+		return current.acquire(holder, ttl, time.Now())
+	})
+	return current.Holder, err
+}
+
+// Release empties the file when `holder` holds the lease
+func (l *FileLock) Release(ctx context.Context, holder string) error {
+	_, err := l.update(func(current lease) (lease, bool) {
+		return lease{}, current.Holder == holder
+	})
+	return err
+}
+
+// update replaces the lease in the file with the lease returned by `f`
+// when it changed. The file is locked while it is read and written
+func (l *FileLock) update(f func(current lease) (lease, bool)) (lease, error) {
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return lease{}, errors.Wrapf(err, "Unable to open lock file %s", l.path)
+	}
+	defer file.Close()
+
+	fd := int(file.Fd())
+	if err := syscall.Flock(fd, syscall.LOCK_EX); err != nil {
+		return lease{}, errors.Wrapf(err, "Unable to lock %s", l.path)
+	}
+	defer syscall.Flock(fd, syscall.LOCK_UN)
+
+	var current lease
+	b, err := ioutil.ReadAll(file)
+	if err != nil {
+		return lease{}, errors.Wrapf(err, "Unable to read lock file %s", l.path)
+	}
+	if len(b) > 0 {
+		if err := json.Unmarshal(b, &current); err != nil {
+			return lease{}, errors.Wrapf(err, "Unable to read lock file %s", l.path)
+		}
+	}
+
+	next, changed := f(current)
+	if !changed {
+		return current, nil
+	}
+	b, err = json.Marshal(next)
+	if err == nil {
+		err = file.Truncate(0)
+	}
+	if err == nil {
+		_, err = file.WriteAt(b, 0)
+	}
+	if err != nil {
+		return current, errors.Wrapf(err, "Unable to write lock file %s", l.path)
+	}
+	return next, nil
+}
+
+// DockerLock keeps the lease in labels of a docker service, usually the
+// service of docker-scaler itself. Swarm rejects updates made with an
+// outdated version of the service, so two replicas can not take the lease
+// at the same time
+type DockerLock struct {
+	c           UpdaterInspector
+	serviceName string
+}
+
+// NewDockerLock creates a DockerLock in the labels of `serviceName`
+func NewDockerLock(c UpdaterInspector, serviceName string) *DockerLock {
+	return &DockerLock{c: c, serviceName: serviceName}
+}
+
+// Acquire takes or renews the lease in the service labels
+func (l *DockerLock) Acquire(ctx context.Context, holder string, ttl time.Duration) (string, error) {
+	service, err := l.c.ServiceInspect(ctx, l.serviceName)
+	if err != nil {
+		return "", errors.Wrapf(err, "Unable to read the leader from %s", l.serviceName)
+	}
+	current := readLease(service)
+	next, changed := current.acquire(holder, ttl, time.Now())
+	if !changed {
+		return current.Holder, nil
+	}
+	if err := l.write(ctx, service, next); err != nil {
+		return current.Holder, err
+	}
+	return next.Holder, nil
+}
+
+// Release removes the lease from the service labels when `holder` holds it
+func (l *DockerLock) Release(ctx context.Context, holder string) error {
+	service, err := l.c.ServiceInspect(ctx, l.serviceName)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read the leader from %s", l.serviceName)
+	}
+	if readLease(service).Holder != holder {
+		return nil
+	}
+	return l.write(ctx, service, lease{})
+}
+
+func (l *DockerLock) write(ctx context.Context, service swarm.Service, next lease) error {
+	spec := service.Spec
+	labels := map[string]string{}
+	for k, v := range spec.Labels {
+		labels[k] = v
+	}
+	if len(next.Holder) == 0 {
+		delete(labels, leaderLabel)
+		delete(labels, leaseExpiresLabel)
+	} else {
+		labels[leaderLabel] = next.Holder
+		labels[leaseExpiresLabel] = next.Expires.UTC().Format(time.RFC3339Nano)
+	}
+	spec.Labels = labels
+	err := l.c.ServiceUpdate(ctx, service.ID, service.Version, spec)
+	return errors.Wrapf(err, "Unable to write the leader to %s", l.serviceName)
+}
+
+// readLease reads the lease from the labels of `service`. A lease without
+// a valid expiry is expired
+func readLease(service swarm.Service) lease {
+	expires, _ := time.Parse(time.RFC3339Nano, service.Spec.Labels[leaseExpiresLabel])
+	return lease{
+		Holder:  service.Spec.Labels[leaderLabel],
+		Expires: expires,
+	}
+}
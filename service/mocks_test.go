@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/stretchr/testify/mock"
 )
@@ -31,3 +32,23 @@ func (m *DockerClientMock) ServiceList(ctx context.Context, options types.Servic
 	called := m.Called(ctx, options)
 	return called.Get(0).([]swarm.Service), called.Error(1)
 }
+
+func (m *DockerClientMock) NodeList(ctx context.Context, options types.NodeListOptions) ([]swarm.Node, error) {
+	called := m.Called(ctx, options)
+	return called.Get(0).([]swarm.Node), called.Error(1)
+}
+
+func (m *DockerClientMock) TaskList(ctx context.Context, options types.TaskListOptions) ([]swarm.Task, error) {
+	called := m.Called(ctx, options)
+	return called.Get(0).([]swarm.Task), called.Error(1)
+}
+
+func (m *DockerClientMock) Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error) {
+	called := m.Called(ctx, options)
+	return called.Get(0).(chan events.Message), called.Get(1).(chan error)
+}
+
+func (m *DockerClientMock) Info(ctx context.Context) (types.Info, error) {
+	called := m.Called(ctx)
+	return called.Get(0).(types.Info), called.Error(1)
+}
@@ -2,13 +2,21 @@ package service
 
 import (
 	"context"
+	"fmt"
+	"sync"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/logging"
 	"github.com/thomasjpfan/docker-scaler/service/cloud"
 )
 
-// NodeScaling is an interface for node scaling
+// NodeScaling is an interface for node scaling. When the node budget caps
+// or rejects scaling up, Scale returns the nodes it did set together with
+// a *BudgetError, and likewise a *DisruptionBudgetError when the
+// disruption budgets of the services cap or reject scaling down. When the
+// change needs approval, nothing is scaled and an *ApprovalRequiredError
+// is returned
 type NodeScaling interface {
 	Scale(ctx context.Context, by uint64, direction ScaleDirection, nodeType cloud.NodeType, serviceName string) (uint64, uint64, error)
 	String() string
@@ -23,13 +31,23 @@ type Inspector interface {
 type NodeScaler struct {
 	cloudProvider cloud.Cloud
 	inspector     Inspector
+	mu            sync.RWMutex
 	managerOpts   ResolveDeltaOptions
 	workerOpts    ResolveDeltaOptions
+	events        *EventBus
+	budget        *NodeBudget
+	approvals     *Approvals
+
+	// minAvailableLabel is the service label with the minimum available
+	// replicas while scaling nodes down
+	minAvailableLabel string
 }
 
-// NewNodeScaler returns new node scaler
+// NewNodeScaler returns new node scaler. Node scaling steps are published
+// to `events`, which can be nil
 func NewNodeScaler(cloudProvider cloud.Cloud,
-	inspector Inspector, managerOpts, workerOpts ResolveDeltaOptions) NodeScaling {
+	inspector Inspector, managerOpts, workerOpts ResolveDeltaOptions,
+	events *EventBus) NodeScaling {
 	if cloudProvider == nil {
 		return nil
 	}
@@ -38,9 +56,41 @@ func NewNodeScaler(cloudProvider cloud.Cloud,
 		inspector:     inspector,
 		managerOpts:   managerOpts,
 		workerOpts:    workerOpts,
+		events:        events,
 	}
 }
 
+// SetResolveOptions changes the labels and defaults used to scale manager
+// and worker nodes from now on
+func (s *NodeScaler) SetResolveOptions(managerOpts, workerOpts ResolveDeltaOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.managerOpts = managerOpts
+	s.workerOpts = workerOpts
+}
+
+// SetBudget caps scaling up nodes to `b`
+func (s *NodeScaler) SetBudget(b *NodeBudget) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.budget = b
+}
+
+// SetApprovals parks the changes that need approval in `a`
+func (s *NodeScaler) SetApprovals(a *Approvals) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvals = a
+}
+
+// SetMinAvailableLabel reads the disruption budget of each service from
+// `label`. Scaling down nodes does not check budgets when it is empty
+func (s *NodeScaler) SetMinAvailableLabel(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.minAvailableLabel = label
+}
+
 // Scale scales nodes returns
 // 1. number of nodes before scaling
 // 2. number of nodes after scaling
@@ -60,23 +110,77 @@ func (s *NodeScaler) Scale(ctx context.Context, by uint64, direction ScaleDirect
 	}
 
 	var resolveOpts ResolveDeltaOptions
+	s.mu.RLock()
 	if nodeType == cloud.NodeManagerType {
 		resolveOpts = s.managerOpts
 	} else {
 		resolveOpts = s.workerOpts
 	}
+	budget := s.budget
+	approvals := s.approvals
+	minAvailableLabel := s.minAvailableLabel
+	s.mu.RUnlock()
 
 	minBound, maxBound, newNodes := resolveDelta(currentNodes, by, direction, labels, resolveOpts)
 
+	err = approvals.Check(ctx, ApprovalChange{
+		Kind:      "scale_nodes",
+		Target:    string(nodeType),
+		Service:   serviceName,
+		Direction: direction,
+		By:        by,
+		Previous:  currentNodes,
+		Requested: newNodes,
+	})
+	if err != nil {
+		return currentNodes, currentNodes, err
+	}
+
+	newNodes, disruptionErr := s.checkDisruption(ctx, minAvailableLabel, nodeType, currentNodes, newNodes)
+	if disruptionErr != nil {
+		de, ok := disruptionErr.(*DisruptionBudgetError)
+		if !ok {
+			return 0, 0, errors.Wrap(disruptionErr, "node scaling failed")
+		}
+		if !de.Capped() {
+			return currentNodes, currentNodes, de
+		}
+	}
+
+	newNodes, release, budgetErr := budget.Reserve(ctx, ClusterFrom(ctx), nodeType, currentNodes, newNodes)
+	defer release()
+	if budgetErr != nil {
+		be, ok := budgetErr.(*BudgetError)
+		if !ok {
+			return 0, 0, errors.Wrap(budgetErr, "node scaling failed")
+		}
+		if !be.Capped() {
+			return currentNodes, currentNodes, be
+		}
+	}
+
+	s.events.Publish(Event{
+		Kind:      EventScaleNodes,
+		Cluster:   ClusterFrom(ctx),
+		Service:   serviceName,
+		RequestID: logging.RequestID(ctx),
+		Status:    "pending",
+		Message: fmt.Sprintf("Setting %s nodes on %s from %d to %d (min: %d, max: %d)",
+			nodeType, s.cloudProvider.String(), currentNodes, newNodes, minBound, maxBound),
+	})
+
 	err = s.cloudProvider.SetNodes(ctx, nodeType, newNodes, minBound, maxBound)
 	if err != nil {
 		return 0, 0, errors.Wrap(err, "node scaling failed")
 	}
 
-	return currentNodes, newNodes, nil
+	if disruptionErr != nil {
+		return currentNodes, newNodes, disruptionErr
+	}
+	return currentNodes, newNodes, budgetErr
 }
 
 // String adapts to the String interface
-func (s NodeScaler) String() string {
+func (s *NodeScaler) String() string {
 	return s.cloudProvider.String()
 }
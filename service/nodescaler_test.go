@@ -3,6 +3,7 @@ package service
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/pkg/errors"
@@ -43,6 +44,7 @@ type NodeScalerTestSuite struct {
 	nodeScaler        *NodeScaler
 	managerOpts       ResolveDeltaOptions
 	workerOpts        ResolveDeltaOptions
+	events            *EventBus
 	ctx               context.Context
 }
 
@@ -74,11 +76,13 @@ func (s *NodeScalerTestSuite) SetupTest() {
 		DefaultScaleUpBy:   1,
 	}
 
+	s.events = NewEventBus()
 	s.nodeScaler = NewNodeScaler(
 		s.cloudProviderMock,
 		s.inspectorMock,
 		s.managerOpts,
 		s.workerOpts,
+		s.events,
 	).(*NodeScaler)
 	s.ctx = context.Background()
 }
@@ -118,7 +122,7 @@ func (s *NodeScalerTestSuite) getNodeService() swarm.Service {
 }
 
 func (s *NodeScalerTestSuite) Test_NewNodeScaler_NilCloudProvider() {
-	nodeScaler := NewNodeScaler(nil, s.inspectorMock, s.managerOpts, s.workerOpts)
+	nodeScaler := NewNodeScaler(nil, s.inspectorMock, s.managerOpts, s.workerOpts, nil)
 	s.Nil(nodeScaler)
 }
 
@@ -432,3 +436,86 @@ func (s *NodeScalerTestSuite) Test_ScaleDown_ByFromDefault() {
 	s.Equal(currentNodes, nodesBefore)
 	s.Equal(newNodes, nodesNow)
 }
+
+func (s *NodeScalerTestSuite) Test_Scale_PublishesStep() {
+	events, unsubscribe := s.events.Subscribe(1, EventFilter{})
+	defer unsubscribe()
+	nodeType := cloud.NodeWorkerType
+
+	s.cloudProviderMock.On("GetNodes", s.ctx, nodeType).
+		Return(uint64(2), nil).
+		On("SetNodes", s.ctx, nodeType,
+			uint64(3), uint64(0), uint64(5)).
+		Return(nil)
+
+	_, _, err := s.nodeScaler.Scale(s.ctx, 0, ScaleUpDirection, nodeType, "")
+	s.Require().NoError(err)
+
+	e := <-events
+	s.Equal(EventScaleNodes, e.Kind)
+	s.Equal("pending", e.Status)
+	s.Equal("Setting worker nodes on cloudmock from 2 to 3 (min: 0, max: 5)", e.Message)
+}
+
+func (s *NodeScalerTestSuite) Test_ScaleUp_CappedByBudget() {
+	ctx := WithCluster(s.ctx, "default")
+	budget := NewNodeBudget()
+	budget.SetPool("default", s.cloudProviderMock, 1, 1)
+	budget.SetLimits(7, 0)
+	s.nodeScaler.SetBudget(budget)
+
+	s.cloudProviderMock.On("GetNodes", ctx, cloud.NodeWorkerType).
+		Return(uint64(2), nil).
+		On("GetNodes", ctx, cloud.NodeManagerType).
+		Return(uint64(3), nil).
+		On("SetNodes", ctx, cloud.NodeWorkerType,
+			uint64(4), uint64(0), uint64(5)).
+		Return(nil)
+
+	nodesBefore, nodesNow, err := s.nodeScaler.Scale(ctx, 3, ScaleUpDirection, cloud.NodeWorkerType, "")
+	s.Require().Error(err)
+	s.Equal("Node budget capped worker nodes at 4 instead of 5: 8 nodes in every cluster would exceed NODE_BUDGET_MAX_NODES of 7", err.Error())
+	s.Equal(uint64(2), nodesBefore)
+	s.Equal(uint64(4), nodesNow)
+}
+
+func (s *NodeScalerTestSuite) Test_ScaleUp_RejectedByBudget() {
+	ctx := WithCluster(s.ctx, "default")
+	budget := NewNodeBudget()
+	budget.SetPool("default", s.cloudProviderMock, 0.5, 0.25)
+	budget.SetLimits(0, 2)
+	s.nodeScaler.SetBudget(budget)
+
+	s.cloudProviderMock.On("GetNodes", ctx, cloud.NodeWorkerType).
+		Return(uint64(2), nil).
+		On("GetNodes", ctx, cloud.NodeManagerType).
+		Return(uint64(3), nil)
+
+	nodesBefore, nodesNow, err := s.nodeScaler.Scale(ctx, 1, ScaleUpDirection, cloud.NodeWorkerType, "")
+	s.Require().Error(err)
+	_, ok := err.(*BudgetError)
+	s.True(ok)
+	s.Equal(uint64(2), nodesBefore)
+	s.Equal(uint64(2), nodesNow)
+	s.cloudProviderMock.AssertNotCalled(s.T(), "SetNodes", ctx, cloud.NodeWorkerType,
+		uint64(3), uint64(0), uint64(5))
+}
+
+func (s *NodeScalerTestSuite) Test_ScaleUp_AwaitsApproval() {
+	approvals := NewApprovals()
+	approvals.SetPolicy("", ApprovalPolicy{MaxNodeChange: 2, Timeout: time.Hour})
+	s.nodeScaler.SetApprovals(approvals)
+
+	s.cloudProviderMock.On("GetNodes", s.ctx, cloud.NodeWorkerType).
+		Return(uint64(2), nil)
+
+	nodesBefore, nodesNow, err := s.nodeScaler.Scale(s.ctx, 3, ScaleUpDirection, cloud.NodeWorkerType, "")
+	s.Require().Error(err)
+	ae, ok := err.(*ApprovalRequiredError)
+	s.Require().True(ok)
+	s.Equal("Scaling worker nodes from 2 to 5", ae.Approval.Change())
+	s.Equal(uint64(2), nodesBefore)
+	s.Equal(uint64(2), nodesNow)
+	s.cloudProviderMock.AssertNotCalled(s.T(), "SetNodes", s.ctx, cloud.NodeWorkerType,
+		uint64(5), uint64(0), uint64(5))
+}
@@ -0,0 +1,262 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sort"
+	"sync"
+	"time"
+)
+
+// OperationState is the state of a scaling or rescheduling operation
+type OperationState string
+
+const (
+	// OperationPending denotes an operation that has not finished
+	OperationPending OperationState = "pending"
+	// OperationWaitingForNodes denotes an operation waiting for nodes to
+	// come online before rescheduling
+	OperationWaitingForNodes OperationState = "waiting-for-nodes"
+	// OperationAwaitingApproval denotes an operation whose change waits
+	// to be approved
+	OperationAwaitingApproval OperationState = "awaiting-approval"
+	// OperationQueued denotes an operation whose scale waits for the
+	// update of its service to complete
+	OperationQueued OperationState = "queued"
+	// OperationRescheduling denotes an operation rescheduling services
+	OperationRescheduling OperationState = "rescheduling"
+	// OperationDone denotes an operation that finished successfully
+	OperationDone OperationState = "done"
+	// OperationFailed denotes an operation that finished with an error
+	OperationFailed OperationState = "failed"
+)
+
+// OperationResult is the outcome of one step of an operation
+type OperationResult struct {
+	Time    time.Time `json:"time"`
+	Status  string    `json:"status"`
+	Message string    `json:"message"`
+}
+
+// Operation is a scaling or rescheduling action
+type Operation struct {
+	ID         string            `json:"id"`
+	Kind       string            `json:"kind"`
+	Cluster    string            `json:"cluster,omitempty"`
+	Target     string            `json:"target,omitempty"`
+	RequestID  string            `json:"requestId,omitempty"`
+	State      OperationState    `json:"state"`
+	Message    string            `json:"message,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	UpdatedAt  time.Time         `json:"updatedAt"`
+	FinishedAt *time.Time        `json:"finishedAt,omitempty"`
+	Results    []OperationResult `json:"results,omitempty"`
+
+	// RescheduleKey is the value used to cancel a reschedule waiting for
+	// nodes
+	RescheduleKey string `json:"-"`
+	// Wait describes the reschedule waiting for nodes, so it can be
+	// resumed after a restart
+	Wait *OperationWait `json:"-"`
+}
+
+// OperationWait is a reschedule waiting for nodes
+type OperationWait struct {
+	Key       string         `json:"key"`
+	IsManager bool           `json:"isManager"`
+	NodeType  string         `json:"nodeType"`
+	Previous  int            `json:"previous"`
+	Target    int            `json:"target"`
+	Direction ScaleDirection `json:"direction"`
+	StartedAt time.Time      `json:"startedAt"`
+}
+
+// Finished returns true when the operation is done or failed
+func (o Operation) Finished() bool {
+	return o.State == OperationDone || o.State == OperationFailed
+}
+
+// OperationStore keeps track of recent operations
+type OperationStore struct {
+	ops      map[string]*Operation
+	capacity int
+	mux      sync.RWMutex
+
+	// path is the file the operations are saved to, when not empty
+	path    string
+	closed  bool
+	onError func(err error)
+	// version counts the changes, written is the last change saved
+	version  uint64
+	writeMux sync.Mutex
+	written  uint64
+}
+
+// NewOperationStore creates an OperationStore that keeps at most
+// `capacity` finished operations
+func NewOperationStore(capacity int) *OperationStore {
+	return &OperationStore{
+		ops:      map[string]*Operation{},
+		capacity: capacity,
+	}
+}
+
+// Create starts a new pending operation for the request with
+// `requestID`
+func (s *OperationStore) Create(kind, target, requestID string) Operation {
+	now := time.Now().UTC()
+	op := &Operation{
+		ID:        newOperationID(),
+		Kind:      kind,
+		Target:    target,
+		RequestID: requestID,
+		State:     OperationPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.mux.Lock()
+	s.ops[op.ID] = op
+	s.evict()
+	snap := s.snapshot()
+	created := *op
+	s.mux.Unlock()
+	s.save(snap)
+	return created
+}
+
+// Get returns operation with `id`
+func (s *OperationStore) Get(id string) (Operation, bool) {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	op, ok := s.ops[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return copyOperation(op), true
+}
+
+// List returns all operations, newest first
+func (s *OperationStore) List() []Operation {
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+	ops := make([]Operation, 0, len(s.ops))
+	for _, op := range s.ops {
+		ops = append(ops, copyOperation(op))
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].CreatedAt.After(ops[j].CreatedAt)
+	})
+	return ops
+}
+
+// SetState moves operation `id` to `state` and records `message`
+func (s *OperationStore) SetState(id string, state OperationState, message string) {
+	s.update(id, func(op *Operation) {
+		op.State = state
+		op.Message = message
+	})
+}
+
+// SetRescheduleKey records the key used to cancel a reschedule of
+// operation `id`
+func (s *OperationStore) SetRescheduleKey(id, key string) {
+	s.update(id, func(op *Operation) {
+		op.RescheduleKey = key
+	})
+}
+
+// SetWait records the reschedule operation `id` waits for
+func (s *OperationStore) SetWait(id string, wait OperationWait) {
+	s.update(id, func(op *Operation) {
+		op.RescheduleKey = wait.Key
+		op.Wait = &wait
+	})
+}
+
+// SetCluster records the cluster operation `id` acts on
+func (s *OperationStore) SetCluster(id, cluster string) {
+	s.update(id, func(op *Operation) {
+		op.Cluster = cluster
+	})
+}
+
+// AddResult appends a sub-result to operation `id`
+func (s *OperationStore) AddResult(id, status, message string) {
+	s.update(id, func(op *Operation) {
+		op.Results = append(op.Results, OperationResult{
+			Time:    op.UpdatedAt,
+			Status:  status,
+			Message: message,
+		})
+	})
+}
+
+// Finish marks operation `id` as done, or failed when err is not nil
+func (s *OperationStore) Finish(id string, message string, err error) {
+	s.update(id, func(op *Operation) {
+		finishedAt := op.UpdatedAt
+		op.FinishedAt = &finishedAt
+		if err != nil {
+			op.State = OperationFailed
+			op.Error = err.Error()
+			return
+		}
+		op.State = OperationDone
+		op.Message = message
+	})
+}
+
+func (s *OperationStore) update(id string, f func(op *Operation)) {
+	s.mux.Lock()
+	op, ok := s.ops[id]
+	if !ok {
+		s.mux.Unlock()
+		return
+	}
+	op.UpdatedAt = time.Now().UTC()
+	f(op)
+	snap := s.snapshot()
+	s.mux.Unlock()
+	s.save(snap)
+}
+
+// evict removes the oldest finished operations above capacity
+func (s *OperationStore) evict() {
+	finished := []*Operation{}
+	for _, op := range s.ops {
+		if op.Finished() {
+			finished = append(finished, op)
+		}
+	}
+	if len(finished) <= s.capacity {
+		return
+	}
+	sort.Slice(finished, func(i, j int) bool {
+		return finished[i].CreatedAt.Before(finished[j].CreatedAt)
+	})
+	for _, op := range finished[:len(finished)-s.capacity] {
+		delete(s.ops, op.ID)
+	}
+}
+
+func copyOperation(op *Operation) Operation {
+	c := *op
+	c.Results = append([]OperationResult(nil), op.Results...)
+	if op.FinishedAt != nil {
+		finishedAt := *op.FinishedAt
+		c.FinishedAt = &finishedAt
+	}
+	if op.Wait != nil {
+		wait := *op.Wait
+		c.Wait = &wait
+	}
+	return c
+}
+
+func newOperationID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
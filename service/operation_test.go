@@ -0,0 +1,188 @@
+package service
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type OperationStoreTestSuite struct {
+	suite.Suite
+	store *OperationStore
+}
+
+func TestOperationStoreUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(OperationStoreTestSuite))
+}
+
+func (s *OperationStoreTestSuite) SetupTest() {
+	s.store = NewOperationStore(2)
+}
+
+func (s *OperationStoreTestSuite) Test_Create() {
+	op := s.store.Create("scale_service", "web", "req-1")
+	s.Len(op.ID, 16)
+	s.Equal("scale_service", op.Kind)
+	s.Equal("web", op.Target)
+	s.Equal("req-1", op.RequestID)
+	s.Equal(OperationPending, op.State)
+	s.Nil(op.FinishedAt)
+
+	got, ok := s.store.Get(op.ID)
+	s.Require().True(ok)
+	s.Equal(op, got)
+}
+
+func (s *OperationStoreTestSuite) Test_Get_DoesNotExist() {
+	_, ok := s.store.Get("DOESNOTEXIST")
+	s.False(ok)
+}
+
+func (s *OperationStoreTestSuite) Test_WaitingForNodes_ThenDone() {
+	op := s.store.Create("scale_nodes", "worker", "")
+	s.store.AddResult(op.ID, "success", "Changing the number of worker nodes on aws from 3 to 4")
+	s.store.SetState(op.ID, OperationWaitingForNodes, "Changing the number of worker nodes on aws from 3 to 4")
+	s.store.SetRescheduleKey(op.ID, "20180101T000000")
+	s.store.SetCluster(op.ID, "eu-west")
+	s.store.AddResult(op.ID, "pending", "Waited 60 seconds for a total of 4 worker nodes to come online")
+
+	got, _ := s.store.Get(op.ID)
+	s.Equal(OperationWaitingForNodes, got.State)
+	s.Equal("20180101T000000", got.RescheduleKey)
+	s.Equal("eu-west", got.Cluster)
+	s.False(got.Finished())
+
+	s.store.Finish(op.ID, "4 worker nodes are up, web rescheduled", nil)
+	got, _ = s.store.Get(op.ID)
+	s.Equal(OperationDone, got.State)
+	s.Equal("4 worker nodes are up, web rescheduled", got.Message)
+	s.Require().NotNil(got.FinishedAt)
+	s.True(got.Finished())
+	s.Require().Len(got.Results, 2)
+	s.Equal("success", got.Results[0].Status)
+	s.Equal("pending", got.Results[1].Status)
+}
+
+func (s *OperationStoreTestSuite) Test_Finish_Error() {
+	op := s.store.Create("scale_service", "web", "")
+	s.store.Finish(op.ID, "", errors.New("docker inspect failed"))
+
+	got, _ := s.store.Get(op.ID)
+	s.Equal(OperationFailed, got.State)
+	s.Equal("docker inspect failed", got.Error)
+	s.NotNil(got.FinishedAt)
+}
+
+func (s *OperationStoreTestSuite) Test_List_NewestFirst_EvictsFinished() {
+	op1 := s.store.Create("scale_service", "web1", "")
+	s.store.Finish(op1.ID, "", nil)
+	op2 := s.store.Create("scale_service", "web2", "")
+	s.store.Finish(op2.ID, "", nil)
+	op3 := s.store.Create("scale_nodes", "worker", "")
+	s.store.SetState(op3.ID, OperationWaitingForNodes, "")
+	op4 := s.store.Create("scale_service", "web4", "")
+	s.store.Finish(op4.ID, "", nil)
+	op5 := s.store.Create("scale_service", "web5", "")
+
+	ops := s.store.List()
+	ids := []string{}
+	for _, op := range ops {
+		ids = append(ids, op.ID)
+	}
+	s.Equal([]string{op5.ID, op4.ID, op3.ID, op2.ID}, ids)
+}
+
+func (s *OperationStoreTestSuite) Test_OpenOperationStore_KeepsOperations() {
+	path := filepath.Join(s.T().TempDir(), "state.json")
+	store, err := OpenOperationStore(path, 2)
+	s.Require().NoError(err)
+	s.FileExists(path)
+
+	waiting := store.Create("scale_nodes", "worker", "req-1")
+	store.SetCluster(waiting.ID, "eu-west")
+	wait := OperationWait{Key: "20180101T000000", NodeType: "worker", Previous: 2, Target: 3,
+		Direction: ScaleUpDirection, StartedAt: time.Now().UTC().Truncate(time.Second)}
+	store.SetWait(waiting.ID, wait)
+	done := store.Create("scale_service", "web", "req-2")
+	store.Finish(done.ID, "Scaled web", nil)
+
+	reopened, err := OpenOperationStore(path, 2)
+	s.Require().NoError(err)
+	s.Len(reopened.List(), 2)
+	op, ok := reopened.Get(waiting.ID)
+	s.Require().True(ok)
+	s.Equal("eu-west", op.Cluster)
+	s.Equal("req-1", op.RequestID)
+	s.Equal("20180101T000000", op.RescheduleKey)
+	s.Require().NotNil(op.Wait)
+	s.Equal(wait, *op.Wait)
+	op, _ = reopened.Get(done.ID)
+	s.Equal(OperationDone, op.State)
+}
+
+func (s *OperationStoreTestSuite) Test_OpenOperationStore_Close() {
+	path := filepath.Join(s.T().TempDir(), "state.json")
+	store, err := OpenOperationStore(path, 2)
+	s.Require().NoError(err)
+	op := store.Create("scale_nodes", "worker", "")
+	store.Close()
+	store.Finish(op.ID, "", errors.New("interrupted"))
+
+	reopened, err := OpenOperationStore(path, 2)
+	s.Require().NoError(err)
+	got, _ := reopened.Get(op.ID)
+	s.Equal(OperationPending, got.State)
+}
+
+func (s *OperationStoreTestSuite) Test_OpenOperationStore_InvalidFile() {
+	path := filepath.Join(s.T().TempDir(), "state.json")
+	s.Require().NoError(ioutil.WriteFile(path, []byte("{"), 0644))
+	_, err := OpenOperationStore(path, 2)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "Unable to read state file "+path)
+}
+
+func (s *OperationStoreTestSuite) Test_OpenOperationStore_SaveError() {
+	dir := s.T().TempDir()
+	store, err := OpenOperationStore(filepath.Join(dir, "state.json"), 2)
+	s.Require().NoError(err)
+	var saveErr error
+	store.SetErrorHandler(func(err error) { saveErr = err })
+	s.Require().NoError(os.RemoveAll(dir))
+
+	store.Create("scale_service", "web", "")
+	s.Require().Error(saveErr)
+	s.Contains(saveErr.Error(), "Unable to write state file")
+}
+
+func (s *OperationStoreTestSuite) Test_OpenOperationStore_ConcurrentChanges() {
+	path := filepath.Join(s.T().TempDir(), "state.json")
+	store, err := OpenOperationStore(path, 20)
+	s.Require().NoError(err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			op := store.Create("scale_service", "web", "")
+			store.AddResult(op.ID, "success", "Scaled web")
+			store.Finish(op.ID, "Scaled web", nil)
+		}()
+	}
+	wg.Wait()
+
+	reopened, err := OpenOperationStore(path, 20)
+	s.Require().NoError(err)
+	s.Len(reopened.List(), 10)
+	for _, op := range reopened.List() {
+		s.Equal(OperationDone, op.State)
+		s.Len(op.Results, 1)
+	}
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// storedOperation is an Operation as it is saved to the state file, with
+// what is needed to resume it
+type storedOperation struct {
+	Operation
+	RescheduleKey string         `json:"rescheduleKey,omitempty"`
+	Wait          *OperationWait `json:"wait,omitempty"`
+}
+
+// stateFile is the content of the file an OperationStore is saved to
+type stateFile struct {
+	Operations []storedOperation `json:"operations"`
+}
+
+// OpenOperationStore creates an OperationStore that keeps at most
+// `capacity` finished operations and saves every change to the file at
+// `path`. The operations saved by a previous run are read from the file,
+// which is created when it does not exist
+func OpenOperationStore(path string, capacity int) (*OperationStore, error) {
+	s := NewOperationStore(capacity)
+	s.path = path
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, writeStateFile(path, s.state())
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "Unable to read state file %s", path)
+	}
+	var state stateFile
+	if err := json.Unmarshal(b, &state); err != nil {
+		return nil, errors.Wrapf(err, "Unable to read state file %s", path)
+	}
+	for _, stored := range state.Operations {
+		op := stored.Operation
+		op.RescheduleKey = stored.RescheduleKey
+		op.Wait = stored.Wait
+		s.ops[op.ID] = &op
+	}
+	s.evict()
+	return s, nil
+}
+
+// SetErrorHandler calls `f` when the operations can not be saved
+func (s *OperationStore) SetErrorHandler(f func(err error)) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.onError = f
+}
+
+// Close stops saving the operations, so the operations interrupted while
+// docker-scaler stops are resumed by the next run
+func (s *OperationStore) Close() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.closed = true
+}
+
+// stateSnapshot is the content of the state file after a change of the
+// store
+type stateSnapshot struct {
+	version uint64
+	state   stateFile
+	onError func(err error)
+}
+
+// snapshot copies the operations to save after a change, or returns nil
+// when the store is not saved. It must be called with the lock held
+func (s *OperationStore) snapshot() *stateSnapshot {
+	if len(s.path) == 0 || s.closed {
+		return nil
+	}
+	s.version++
+	return &stateSnapshot{version: s.version, state: s.state(), onError: s.onError}
+}
+
+// save writes `snap` to the file of the store. It must be called without
+// the lock, so changes are not held up by the disk. A snapshot older than
+// the one already written is skipped, so concurrent changes are written
+// once and never overwritten by an older change
+func (s *OperationStore) save(snap *stateSnapshot) {
+	if snap == nil {
+		return
+	}
+	s.writeMux.Lock()
+	defer s.writeMux.Unlock()
+	if snap.version <= s.written {
+		return
+	}
+	if err := writeStateFile(s.path, snap.state); err != nil {
+		if snap.onError != nil {
+			snap.onError(err)
+		}
+		return
+	}
+	s.written = snap.version
+}
+
+// state copies the operations as they are saved. It must be called with
+// the lock held
+func (s *OperationStore) state() stateFile {
+	state := stateFile{Operations: make([]storedOperation, 0, len(s.ops))}
+	for _, op := range s.ops {
+		c := copyOperation(op)
+		state.Operations = append(state.Operations, storedOperation{
+			Operation:     c,
+			RescheduleKey: c.RescheduleKey,
+			Wait:          c.Wait,
+		})
+	}
+	sort.Slice(state.Operations, func(i, j int) bool {
+		return state.Operations[i].CreatedAt.Before(state.Operations[j].CreatedAt)
+	})
+	return state
+}
+
+// writeStateFile replaces the file at `path` with `v` as JSON. It is
+// written to a temporary file that is renamed, so a crash never leaves
+// half a file
+func writeStateFile(path string, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write state file %s", path)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return errors.Wrapf(err, "Unable to write state file %s", path)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(b)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	return errors.Wrapf(err, "Unable to write state file %s", path)
+}
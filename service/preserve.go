@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/metrics"
+)
+
+// preserveRetryInterval is how long to wait before subscribing to the
+// docker events again after the stream failed
+var preserveRetryInterval = 5 * time.Second
+
+// EventsSubscriber subscribes to the events of a swarm
+type EventsSubscriber interface {
+	Events(ctx context.Context, options types.EventsOptions) (<-chan events.Message, <-chan error)
+}
+
+// SetPreserveLabel restores the replicas decided by the scaler of services
+// where `label` is true, once they are changed outside of the scaler.
+// Nothing is restored when it is empty
+func (s *scalerService) SetPreserveLabel(label string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.preserveLabel = label
+}
+
+// decide records `replicas` as the replicas the scaler decided for
+// `serviceName`. It returns a function that records the previous replicas
+// again, for when setting the replicas failed. The replicas are recorded
+// before they are set, so the update event of the scaler itself never
+// finds replicas it did not decide, and saved to the replicas file
+func (s *scalerService) decide(serviceName string, replicas uint64) func() {
+	s.mu.Lock()
+	previous, ok := s.decided[serviceName]
+	s.decided[serviceName] = replicas
+	snap := s.decidedSnapshot()
+	s.mu.Unlock()
+	s.saveDecided(snap)
+	return func() {
+		s.mu.Lock()
+		if ok {
+			s.decided[serviceName] = previous
+		} else {
+			delete(s.decided, serviceName)
+		}
+		snap := s.decidedSnapshot()
+		s.mu.Unlock()
+		s.saveDecided(snap)
+	}
+}
+
+// Restore sets the replicas of `serviceName` back to the replicas the
+// scaler decided, within the bounds of its current labels. The restore is
+// checked like a scale: it is rejected by a freeze or an update in
+// progress without the `proceed` policy, with the UpdateInProgressError of
+// the update policy so the caller retries a queued restore, and restoring
+// more replicas fits in the replica quota. It returns false when the
+// service is not labeled to preserve its replicas, the scaler never scaled
+// it, or it already runs the decided replicas
+func (s *scalerService) Restore(ctx context.Context, serviceName string) (ScaleResult, bool, error) {
+	s.mu.RLock()
+	label := s.preserveLabel
+	decided, ok := s.decided[serviceName]
+	resolveOpts := s.resolveOpts
+	freezes := s.freezes
+	updateOpts := s.updateOpts
+	quotaOpts := s.quotaOpts
+	s.mu.RUnlock()
+	if len(label) == 0 || !ok {
+		return ScaleResult{}, false, nil
+	}
+
+	service, err := s.c.ServiceInspect(ctx, serviceName)
+	if err != nil {
+		return ScaleResult{}, false, errors.Wrap(err, "docker inspect failed in ScalerService")
+	}
+	if service.Spec.Labels[label] != "true" || service.Spec.Mode.Replicated == nil {
+		return ScaleResult{}, false, nil
+	}
+	currentReplicas, err := s.getReplicas(service)
+	if err != nil {
+		return ScaleResult{}, false, err
+	}
+
+	minReplicas, maxReplicas := getBounds(service.Spec.Labels, resolveOpts)
+	replicas := decided
+	if replicas < minReplicas {
+		replicas = minReplicas
+	} else if replicas > maxReplicas {
+		replicas = maxReplicas
+	}
+	if replicas == currentReplicas {
+		return ScaleResult{}, false, nil
+	}
+	result := ScaleResult{
+		Previous: currentReplicas,
+		Current:  replicas,
+		Min:      minReplicas,
+		Max:      maxReplicas,
+	}
+
+	direction := ScaleUpDirection
+	if replicas < currentReplicas {
+		direction = ScaleDownDirection
+	}
+	err = freezes.Check(FreezeAction{
+		Cluster:   ClusterFrom(ctx),
+		Kind:      "scale_service",
+		Direction: direction,
+		Service:   serviceName,
+		Labels:    service.Spec.Labels,
+	})
+	if err != nil {
+		return result, false, err
+	}
+	if updateInProgress(service.UpdateStatus) {
+		if policy := updateOpts.policy(service.Spec.Labels); policy != UpdateProceed {
+			return result, false, &UpdateInProgressError{
+				Service:      serviceName,
+				State:        service.UpdateStatus.State,
+				Policy:       policy,
+				QueueTimeout: updateOpts.QueueTimeout,
+			}
+		}
+	}
+
+	var capped bool
+	if quotaOpts.Max > 0 && replicas > currentReplicas {
+		s.quota.Lock()
+		defer s.quota.Unlock()
+		allowed, preemptions, err := s.reserveQuota(ctx, service, currentReplicas, replicas, quotaOpts, resolveOpts, freezes, updateOpts)
+		if err != nil {
+			return result, false, err
+		}
+		result.Preempted, err = s.preempt(ctx, serviceName, preemptions)
+		if err != nil {
+			return result, false, err
+		}
+		capped = allowed < replicas
+		replicas = allowed
+		result.Current = replicas
+	}
+
+	undo := s.decide(serviceName, replicas)
+	err = s.setReplicas(ctx, service, replicas)
+	if err != nil {
+		undo()
+		return result, false, err
+	}
+	metrics.SetServiceReplicas(serviceName, replicas)
+
+	result.Message = fmt.Sprintf("Restoring %s from %d to %d replicas (min: %d, max: %d), since its replicas were changed outside of docker-scaler",
+		serviceName, currentReplicas, replicas, minReplicas, maxReplicas)
+	if capped {
+		result.Message = fmt.Sprintf("%s, capped by the replica quota of %d", result.Message, quotaOpts.Max)
+	}
+	return result, true, nil
+}
+
+// WatchServiceUpdates calls `updated` with the name of every service whose
+// replicas are changed in the swarm until `ctx` is done. Updates that keep
+// the replicas, like the label writes of the docker leader lock, are
+// skipped by their `replicas.old` and `replicas.new` attributes without
+// inspecting the service. When the docker events can not be read, `failed`
+// is called and the events are subscribed to again
+func (s *scalerService) WatchServiceUpdates(ctx context.Context, updated func(serviceName string), failed func(error)) {
+	subscriber, ok := s.c.(EventsSubscriber)
+	if !ok {
+		failed(errors.New("Unable to subscribe to the docker events"))
+		return
+	}
+	f := filters.NewArgs()
+	f.Add("type", events.ServiceEventType)
+	f.Add("event", "update")
+	for {
+		msgs, errs := subscriber.Events(ctx, types.EventsOptions{Filters: f})
+	read:
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-msgs:
+				if name := msg.Actor.Attributes["name"]; len(name) > 0 && replicasChanged(msg) {
+					updated(name)
+				}
+			case err := <-errs:
+				if ctx.Err() != nil {
+					return
+				}
+				failed(errors.Wrap(err, "Unable to read the docker events"))
+				break read
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(preserveRetryInterval):
+		}
+	}
+}
+
+// replicasChanged returns true when the service update of `msg` changed
+// the replicas of the service. Docker only sets the replicas attributes
+// when they changed
+func replicasChanged(msg events.Message) bool {
+	previous, ok := msg.Actor.Attributes["replicas.old"]
+	if !ok {
+		return false
+	}
+	return previous != msg.Actor.Attributes["replicas.new"]
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type PreserveTestSuite struct {
+	suite.Suite
+	ctx        context.Context
+	clientMock *DockerClientMock
+	scaler     *scalerService
+}
+
+func TestPreserveUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(PreserveTestSuite))
+}
+
+func (s *PreserveTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.clientMock = new(DockerClientMock)
+	s.scaler = NewScalerService(s.clientMock, ResolveDeltaOptions{
+		MinLabel:           "com.df.scaleMin",
+		MaxLabel:           "com.df.scaleMax",
+		DefaultMin:         1,
+		DefaultMax:         10,
+		DefaultScaleDownBy: 1,
+		DefaultScaleUpBy:   2,
+	}).(*scalerService)
+	s.scaler.SetPreserveLabel("com.df.scalePreserve")
+}
+
+func (s *PreserveTestSuite) newService(replicas uint64, labels map[string]string) swarm.Service {
+	return swarm.Service{
+		ID: "webID",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: "web", Labels: labels},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			},
+		},
+	}
+}
+
+// deploy makes web run `replicas` after the scaler decided 6 replicas
+func (s *PreserveTestSuite) deploy(replicas uint64, labels map[string]string) {
+	s.scaler.decide("web", 6)
+	s.clientMock.On("ServiceInspect", s.ctx, "web").Return(s.newService(replicas, labels), nil)
+	s.clientMock.On("ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything).Return(nil)
+}
+
+func (s *PreserveTestSuite) Test_Scale_RecordsDecidedReplicas() {
+	s.clientMock.On("ServiceInspect", s.ctx, "web").Return(s.newService(4, nil), nil).Once()
+	s.clientMock.On("ServiceInspect", s.ctx, "web").Return(s.newService(4, nil), nil).Once()
+	s.clientMock.On("ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything).Return(errors.New("conflict")).Once()
+	s.clientMock.On("ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().Error(err)
+	s.NotContains(s.scaler.decided, "web")
+
+	_, err = s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(6), s.scaler.decided["web"])
+}
+
+func (s *PreserveTestSuite) Test_Restore() {
+	s.deploy(2, map[string]string{"com.df.scalePreserve": "true"})
+
+	result, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.Require().NoError(err)
+	s.True(restored)
+	s.Equal(uint64(2), result.Previous)
+	s.Equal(uint64(6), result.Current)
+	s.Equal("Restoring web from 2 to 6 replicas (min: 1, max: 10), since its replicas were changed outside of docker-scaler", result.Message)
+	s.clientMock.AssertCalled(s.T(), "ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything)
+}
+
+func (s *PreserveTestSuite) Test_Restore_ClampedByLabels() {
+	s.deploy(2, map[string]string{"com.df.scalePreserve": "true", "com.df.scaleMax": "4"})
+
+	result, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.Require().NoError(err)
+	s.True(restored)
+	s.Equal(uint64(4), result.Current)
+	s.Equal(uint64(4), s.scaler.decided["web"])
+}
+
+func (s *PreserveTestSuite) Test_Restore_Frozen() {
+	freezes := NewFreezes()
+	freezes.SetConfigured([]Freeze{{
+		Name: "migration", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour),
+		Scope: FreezeServices, Selector: "com.df.scalePreserve=true", Policy: FreezeReject,
+	}})
+	s.scaler.SetFreezes(freezes)
+	s.deploy(2, map[string]string{"com.df.scalePreserve": "true"})
+
+	_, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.IsType(&FrozenError{}, err)
+	s.False(restored)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything)
+}
+
+func (s *PreserveTestSuite) Test_Restore_DuringUpdate() {
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{Default: UpdateQueue})
+	s.scaler.decide("web", 6)
+	updating := s.newService(2, map[string]string{"com.df.scalePreserve": "true"})
+	updating.UpdateStatus = &swarm.UpdateStatus{State: swarm.UpdateStateUpdating}
+	s.clientMock.On("ServiceInspect", s.ctx, "web").Return(updating, nil)
+
+	_, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.EqualError(err, "Scaling web is queued until its update completes, the update is in progress")
+	s.Equal(UpdateQueue, err.(*UpdateInProgressError).Policy)
+	s.False(restored)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything)
+
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{Default: UpdateReject})
+	_, _, err = s.scaler.Restore(s.ctx, "web")
+	s.EqualError(err, "Scaling web is rejected while it is updated, the update is in progress")
+}
+
+func (s *PreserveTestSuite) Test_Restore_CappedByQuota() {
+	s.scaler.SetReplicaQuota(ReplicaQuotaOptions{Max: 7, Scope: QuotaCluster})
+	s.deploy(2, map[string]string{"com.df.scalePreserve": "true"})
+	batch := s.newService(4, nil)
+	batch.ID, batch.Spec.Name = "batchID", "batch"
+	s.clientMock.On("ServiceList", s.ctx, mock.Anything).Return([]swarm.Service{s.newService(2, nil), batch}, nil)
+
+	result, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.Require().NoError(err)
+	s.True(restored)
+	s.Equal(uint64(3), result.Current)
+	s.Equal("Restoring web from 2 to 3 replicas (min: 1, max: 10), since its replicas were changed outside of docker-scaler, capped by the replica quota of 7", result.Message)
+}
+
+func (s *PreserveTestSuite) Test_Restore_NotLabeled() {
+	s.deploy(2, map[string]string{"com.df.scalePreserve": "false"})
+
+	_, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.Require().NoError(err)
+	s.False(restored)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything)
+}
+
+func (s *PreserveTestSuite) Test_Restore_DecidedReplicasRunning() {
+	s.deploy(6, map[string]string{"com.df.scalePreserve": "true"})
+
+	_, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.Require().NoError(err)
+	s.False(restored)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "webID", mock.Anything, mock.Anything)
+}
+
+func (s *PreserveTestSuite) Test_Restore_NeverScaled() {
+	_, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.Require().NoError(err)
+	s.False(restored)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceInspect", s.ctx, "web")
+}
+
+func (s *PreserveTestSuite) Test_Restore_WithoutLabel() {
+	s.scaler.SetPreserveLabel("")
+	s.deploy(2, map[string]string{"com.df.scalePreserve": "true"})
+
+	_, restored, err := s.scaler.Restore(s.ctx, "web")
+	s.Require().NoError(err)
+	s.False(restored)
+}
+
+func (s *PreserveTestSuite) Test_WatchServiceUpdates() {
+	preserveRetryInterval = 5 * time.Millisecond
+	defer func() { preserveRetryInterval = 5 * time.Second }()
+
+	msgs, errs := make(chan events.Message), make(chan error, 1)
+	s.clientMock.On("Events", mock.Anything, mock.Anything).Return(msgs, errs)
+
+	ctx, cancel := context.WithCancel(s.ctx)
+	updated := make(chan string, 2)
+	failed := make(chan error, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.scaler.WatchServiceUpdates(ctx, func(name string) { updated <- name },
+			func(err error) { failed <- err })
+	}()
+
+	msgs <- events.Message{Actor: events.Actor{ID: "apiID", Attributes: map[string]string{"name": "api"}}}
+	msgs <- events.Message{Actor: events.Actor{ID: "webID", Attributes: map[string]string{
+		"name": "web", "replicas.old": "6", "replicas.new": "2"}}}
+	s.Equal("web", <-updated)
+
+	errs <- errors.New("connection reset")
+	s.EqualError(<-failed, "Unable to read the docker events: connection reset")
+
+	msgs <- events.Message{Actor: events.Actor{ID: "dbID", Attributes: map[string]string{
+		"name": "db", "replicas.old": "1", "replicas.new": "3"}}}
+	s.Equal("db", <-updated)
+	cancel()
+	<-done
+	s.clientMock.AssertNumberOfCalls(s.T(), "Events", 2)
+}
+
+func (s *PreserveTestSuite) Test_ReplicasFile() {
+	path := filepath.Join(s.T().TempDir(), "replicas.json")
+	s.Require().NoError(s.scaler.SetReplicasFile(path, nil))
+	undo := s.scaler.decide("web", 6)
+	s.scaler.decide("db", 3)
+	undo()
+
+	next := NewScalerService(s.clientMock, ResolveDeltaOptions{}).(*scalerService)
+	s.Require().NoError(next.SetReplicasFile(path, nil))
+	s.Equal(map[string]uint64{"db": 3}, next.decided)
+
+	s.scaler.decide("web", 4)
+	s.Require().NoError(next.ReloadReplicas())
+	s.Equal(map[string]uint64{"db": 3, "web": 4}, next.decided)
+}
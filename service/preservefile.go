@@ -0,0 +1,97 @@
+package service
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+// decidedSnapshot is the content of the replicas file after a change of
+// the decided replicas
+type decidedSnapshot struct {
+	version  uint64
+	path     string
+	replicas map[string]uint64
+	onError  func(err error)
+}
+
+// SetReplicasFile saves the decided replicas to the file at `path` after
+// every change, and reads the replicas saved there by a previous run.
+// `onError` is called when the replicas can not be saved
+func (s *scalerService) SetReplicasFile(path string, onError func(err error)) error {
+	s.mu.Lock()
+	s.decidedPath = path
+	s.onDecidedError = onError
+	s.mu.Unlock()
+	return s.ReloadReplicas()
+}
+
+// ReloadReplicas replaces the decided replicas with the replicas saved to
+// the file, so a replica that starts leading restores what the previous
+// leader decided. Nothing is read without a file
+func (s *scalerService) ReloadReplicas() error {
+	s.mu.RLock()
+	path := s.decidedPath
+	s.mu.RUnlock()
+	if len(path) == 0 {
+		return nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "Unable to read replicas file %s", path)
+	}
+	decided := map[string]uint64{}
+	if err := json.Unmarshal(b, &decided); err != nil {
+		return errors.Wrapf(err, "Unable to read replicas file %s", path)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.decided = decided
+	return nil
+}
+
+// decidedSnapshot copies the decided replicas to save after a change, or
+// returns nil when they are not saved. It must be called with the lock
+// held
+func (s *scalerService) decidedSnapshot() *decidedSnapshot {
+	if len(s.decidedPath) == 0 {
+		return nil
+	}
+	s.decidedVersion++
+	replicas := make(map[string]uint64, len(s.decided))
+	for name, r := range s.decided {
+		replicas[name] = r
+	}
+	return &decidedSnapshot{
+		version:  s.decidedVersion,
+		path:     s.decidedPath,
+		replicas: replicas,
+		onError:  s.onDecidedError,
+	}
+}
+
+// saveDecided writes `snap` to the replicas file. It must be called
+// without the lock, so scaling is not held up by the disk. A snapshot
+// older than the one already written is skipped
+func (s *scalerService) saveDecided(snap *decidedSnapshot) {
+	if snap == nil {
+		return
+	}
+	s.decidedWrite.Lock()
+	defer s.decidedWrite.Unlock()
+	if snap.version <= s.decidedWritten {
+		return
+	}
+	if err := writeStateFile(snap.path, snap.replicas); err != nil {
+		if snap.onError != nil {
+			snap.onError(err)
+		}
+		return
+	}
+	s.decidedWritten = snap.version
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/metrics"
+)
+
+// stackLabel is the label `docker stack deploy` sets to the name of the
+// stack of a service
+const stackLabel = "com.docker.stack.namespace"
+
+// QuotaScope is the services a replica quota applies to
+type QuotaScope string
+
+const (
+	// QuotaCluster caps the replicas of every service of the cluster
+	QuotaCluster QuotaScope = "cluster"
+	// QuotaStack caps the replicas of each stack on its own
+	QuotaStack QuotaScope = "stack"
+)
+
+// ReplicaQuotaOptions configure the replica quota of a cluster
+type ReplicaQuotaOptions struct {
+	// Max is the most replicas of the services in scope. There is no
+	// quota when it is 0
+	Max   uint64
+	Scope QuotaScope
+	// PriorityLabel is the label with the priority of a service. Services
+	// without it have priority 0
+	PriorityLabel string
+}
+
+// ServiceLister lists the services of a swarm
+type ServiceLister interface {
+	ServiceList(ctx context.Context, options types.ServiceListOptions) ([]swarm.Service, error)
+}
+
+// Preemption is a service scaled down to make room for a service with a
+// higher priority
+type Preemption struct {
+	Service  string `json:"service"`
+	Priority int64  `json:"priority"`
+	Previous uint64 `json:"previous"`
+	Current  uint64 `json:"current"`
+
+	service swarm.Service
+}
+
+// QuotaError is returned when scaling up a service does not fit in the
+// replica quota, even after scaling down the services with a lower
+// priority
+type QuotaError struct {
+	Service   string
+	Current   uint64
+	Requested uint64
+	// Scope describes the services of the quota, like `cluster` or
+	// `stack shop`
+	Scope string
+	// Used is the number of replicas in scope with Requested replicas
+	Used uint64
+	Max  uint64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("Replica quota rejected scaling %s from %d to %d replicas: %d replicas in the %s would exceed REPLICA_QUOTA of %d, and no service with a lower priority can be scaled down",
+		e.Service, e.Current, e.Requested, e.Used, e.Scope, e.Max)
+}
+
+// priority returns the priority of a service with `labels`
+func (o ReplicaQuotaOptions) priority(labels map[string]string) int64 {
+	p, err := strconv.ParseInt(labels[o.PriorityLabel], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return p
+}
+
+// scope describes the services the quota of `target` applies to
+func (o ReplicaQuotaOptions) scope(target swarm.Service) string {
+	if o.Scope != QuotaStack {
+		return "cluster"
+	}
+	if stack := target.Spec.Labels[stackLabel]; len(stack) > 0 {
+		return fmt.Sprintf("stack %s", stack)
+	}
+	return "services outside of a stack"
+}
+
+// inScope returns true when the quota of `target` applies to `s`
+func (o ReplicaQuotaOptions) inScope(target, s swarm.Service) bool {
+	return o.Scope != QuotaStack || target.Spec.Labels[stackLabel] == s.Spec.Labels[stackLabel]
+}
+
+// reserveQuota fits scaling `target` from `current` to `requested` replicas
+// in the replica quota. It returns the replicas to set, which are less than
+// `requested` when the quota caps the scale, and the services with a lower
+// priority to scale down first. Services are not scaled down below their
+// minimum, and services that are not preemptible are left alone
+func (s *scalerService) reserveQuota(ctx context.Context, target swarm.Service, current, requested uint64,
+	opts ReplicaQuotaOptions, resolveOpts ResolveDeltaOptions, freezes *Freezes, updateOpts UpdatePolicyOptions) (uint64, []Preemption, error) {
+	lister, ok := s.c.(ServiceLister)
+	if !ok {
+		return current, nil, errors.New("Unable to list services for the replica quota")
+	}
+	services, err := lister.ServiceList(ctx, types.ServiceListOptions{})
+	if err != nil {
+		return current, nil, errors.Wrap(err, "Unable to list services for the replica quota")
+	}
+
+	targetPriority := opts.priority(target.Spec.Labels)
+	used := requested
+	candidates := []Preemption{}
+	spare := map[string]uint64{}
+	for _, svc := range services {
+		if svc.ID == target.ID || svc.Spec.Mode.Replicated == nil ||
+			svc.Spec.Mode.Replicated.Replicas == nil || !opts.inScope(target, svc) {
+			continue
+		}
+		replicas := *svc.Spec.Mode.Replicated.Replicas
+		used += replicas
+		priority := opts.priority(svc.Spec.Labels)
+		minReplicas, _ := getBounds(svc.Spec.Labels, resolveOpts)
+		if priority < targetPriority && replicas > minReplicas && preemptible(ctx, svc, freezes, updateOpts) {
+			candidates = append(candidates, Preemption{
+				Service: svc.Spec.Name, Priority: priority, Previous: replicas, Current: replicas, service: svc,
+			})
+			spare[svc.Spec.Name] = replicas - minReplicas
+		}
+	}
+	if used <= opts.Max {
+		return requested, nil, nil
+	}
+
+	// The lowest priority is preempted first, and within a priority the
+	// service with the most spare replicas
+	sort.Slice(candidates, func(i, j int) bool {
+		ci, cj := candidates[i], candidates[j]
+		if ci.Priority != cj.Priority {
+			return ci.Priority < cj.Priority
+		}
+		if spare[ci.Service] != spare[cj.Service] {
+			return spare[ci.Service] > spare[cj.Service]
+		}
+		return ci.Service < cj.Service
+	})
+	need := used - opts.Max
+	var available uint64
+	for _, c := range candidates {
+		available += spare[c.Service]
+	}
+	if need >= available+requested-current {
+		return current, nil, &QuotaError{
+			Service:   target.Spec.Name,
+			Current:   current,
+			Requested: requested,
+			Scope:     opts.scope(target),
+			Used:      used,
+			Max:       opts.Max,
+		}
+	}
+
+	preemptions := []Preemption{}
+	for _, c := range candidates {
+		if need == 0 {
+			break
+		}
+		take := spare[c.Service]
+		if take > need {
+			take = need
+		}
+		c.Current = c.Previous - take
+		preemptions = append(preemptions, c)
+		need -= take
+	}
+	return requested - need, preemptions, nil
+}
+
+// preemptible returns true when `svc` may be scaled down to make room for
+// another service. Scaling it down is checked like a request would be: a
+// service a freeze rejects scaling down, or that swarm is updating without
+// the `proceed` policy, is not preemptible
+func preemptible(ctx context.Context, svc swarm.Service, freezes *Freezes, updateOpts UpdatePolicyOptions) bool {
+	if updateInProgress(svc.UpdateStatus) && updateOpts.policy(svc.Spec.Labels) != UpdateProceed {
+		return false
+	}
+	err := freezes.Check(FreezeAction{
+		Cluster:   ClusterFrom(ctx),
+		Kind:      "scale_service",
+		Direction: ScaleDownDirection,
+		Service:   svc.Spec.Name,
+		Labels:    svc.Spec.Labels,
+	})
+	return err == nil
+}
+
+// preempt scales down the services of `preemptions` to make room for
+// `serviceName`. It returns the preemptions that were carried out
+func (s *scalerService) preempt(ctx context.Context, serviceName string, preemptions []Preemption) ([]Preemption, error) {
+	done := []Preemption{}
+	for _, p := range preemptions {
+		undo := s.decide(p.Service, p.Current)
+		err := s.setReplicas(ctx, p.service, p.Current)
+		if err != nil {
+			undo()
+			return done, errors.Wrapf(err, "Unable to scale down %s to make room for %s", p.Service, serviceName)
+		}
+		metrics.SetServiceReplicas(p.Service, p.Current)
+		done = append(done, p)
+	}
+	return done, nil
+}
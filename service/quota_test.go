@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/swarm"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuotaTestSuite struct {
+	suite.Suite
+	ctx        context.Context
+	clientMock *DockerClientMock
+	scaler     *scalerService
+}
+
+func TestQuotaUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(QuotaTestSuite))
+}
+
+func (s *QuotaTestSuite) SetupTest() {
+	s.ctx = context.Background()
+	s.clientMock = new(DockerClientMock)
+	s.scaler = NewScalerService(s.clientMock, ResolveDeltaOptions{
+		MinLabel:           "com.df.scaleMin",
+		MaxLabel:           "com.df.scaleMax",
+		ScaleDownByLabel:   "com.df.scaleDownBy",
+		ScaleUpByLabel:     "com.df.scaleUpBy",
+		DefaultMin:         1,
+		DefaultMax:         10,
+		DefaultScaleDownBy: 1,
+		DefaultScaleUpBy:   2,
+	}).(*scalerService)
+}
+
+func (s *QuotaTestSuite) newService(name string, replicas uint64, labels map[string]string) swarm.Service {
+	return swarm.Service{
+		ID: name + "ID",
+		Spec: swarm.ServiceSpec{
+			Annotations: swarm.Annotations{Name: name, Labels: labels},
+			Mode: swarm.ServiceMode{
+				Replicated: &swarm.ReplicatedService{Replicas: &replicas},
+			},
+		},
+	}
+}
+
+// setServices makes web, with priority 10 and 4 replicas, the service to
+// scale up by 2 replicas
+func (s *QuotaTestSuite) setServices(others ...swarm.Service) {
+	web := s.newService("web", 4, map[string]string{"com.df.scalePriority": "10"})
+	s.clientMock.On("ServiceInspect", s.ctx, "web").Return(web, nil)
+	s.clientMock.On("ServiceList", s.ctx, types.ServiceListOptions{}).
+		Return(append([]swarm.Service{web}, others...), nil)
+	s.clientMock.On("ServiceUpdate", s.ctx, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+}
+
+func (s *QuotaTestSuite) setQuota(max uint64, scope QuotaScope) {
+	s.scaler.SetReplicaQuota(ReplicaQuotaOptions{
+		Max: max, Scope: scope, PriorityLabel: "com.df.scalePriority",
+	})
+}
+
+func (s *QuotaTestSuite) Test_Scale_WithinQuota() {
+	s.setQuota(10, QuotaCluster)
+	s.setServices(s.newService("batch", 4, nil))
+
+	result, err := s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(6), result.Current)
+	s.Empty(result.Preempted)
+	s.Equal("Scaling web from 4 to 6 replicas (min: 1, max: 10)", result.Message)
+}
+
+func (s *QuotaTestSuite) Test_Scale_PreemptsLowerPriority() {
+	s.setServices(
+		s.newService("batch", 4, map[string]string{"com.df.scalePriority": "1"}),
+		s.newService("reports", 4, map[string]string{"com.df.scalePriority": "5"}),
+		s.newService("api", 4, map[string]string{"com.df.scalePriority": "20"}),
+	)
+	s.setQuota(17, QuotaCluster)
+
+	result, err := s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(6), result.Current)
+	s.Require().Len(result.Preempted, 1)
+	p := result.Preempted[0]
+	s.Equal("batch", p.Service)
+	s.Equal(int64(1), p.Priority)
+	s.Equal(uint64(4), p.Previous)
+	s.Equal(uint64(3), p.Current)
+	s.clientMock.AssertCalled(s.T(), "ServiceUpdate", s.ctx, "batchID", mock.Anything, mock.Anything)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "reportsID", mock.Anything, mock.Anything)
+}
+
+func (s *QuotaTestSuite) Test_Scale_PreemptsDownToMinimum() {
+	s.setQuota(8, QuotaCluster)
+	s.setServices(
+		s.newService("batch", 3, map[string]string{"com.df.scaleMin": "2"}),
+		s.newService("reports", 1, nil),
+	)
+
+	result, err := s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(5), result.Current)
+	s.Require().Len(result.Preempted, 1)
+	s.Equal("batch", result.Preempted[0].Service)
+	s.Equal(uint64(2), result.Preempted[0].Current)
+	s.Equal("Scaling web from 4 to 5 replicas (min: 1, max: 10), capped by the replica quota of 8", result.Message)
+}
+
+func (s *QuotaTestSuite) Test_Scale_SkipsFrozenAndUpdatingServices() {
+	s.setQuota(14, QuotaCluster)
+	updating := s.newService("reports", 4, nil)
+	updating.UpdateStatus = &swarm.UpdateStatus{State: swarm.UpdateStateUpdating}
+	s.setServices(
+		s.newService("batch", 4, map[string]string{"com.df.tier": "db"}),
+		updating,
+		s.newService("cron", 2, map[string]string{"com.df.scalePriority": "1"}),
+	)
+	freezes := NewFreezes()
+	freezes.SetConfigured([]Freeze{{
+		Name: "migration", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour),
+		Scope: FreezeServices, Selector: "com.df.tier=db", Policy: FreezeAllowScaleUp,
+	}})
+	s.scaler.SetFreezes(freezes)
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{Default: UpdateQueue})
+
+	result, err := s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(5), result.Current)
+	s.Require().Len(result.Preempted, 1)
+	s.Equal("cron", result.Preempted[0].Service)
+	s.Equal(uint64(1), result.Preempted[0].Current)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "batchID", mock.Anything, mock.Anything)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "reportsID", mock.Anything, mock.Anything)
+}
+
+func (s *QuotaTestSuite) Test_Scale_QuotaExhausted() {
+	s.setQuota(8, QuotaCluster)
+	s.setServices(s.newService("batch", 4, map[string]string{"com.df.scalePriority": "10"}))
+
+	result, err := s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().Error(err)
+	_, ok := err.(*QuotaError)
+	s.Require().True(ok)
+	s.Equal("Replica quota rejected scaling web from 4 to 6 replicas: 10 replicas in the cluster would exceed REPLICA_QUOTA of 8, and no service with a lower priority can be scaled down", err.Error())
+	s.Equal(uint64(4), result.Previous)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *QuotaTestSuite) Test_Scale_ScaleDownIgnoresQuota() {
+	s.setQuota(1, QuotaCluster)
+	s.setServices(s.newService("batch", 4, nil))
+
+	result, err := s.scaler.Scale(s.ctx, "web", 0, ScaleDownDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(3), result.Current)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceList", s.ctx, mock.Anything)
+}
+
+func (s *QuotaTestSuite) Test_Scale_StackScope() {
+	s.setQuota(8, QuotaStack)
+	s.setServices(
+		s.newService("other_batch", 4, map[string]string{stackLabel: "other"}),
+		s.newService("tools", 2, map[string]string{"com.df.scalePriority": "10"}),
+	)
+
+	result, err := s.scaler.Scale(s.ctx, "web", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(6), result.Current)
+	s.Empty(result.Preempted)
+}
+
+func (s *QuotaTestSuite) Test_Scale_StackScope_Exhausted() {
+	s.setQuota(6, QuotaStack)
+	web := s.newService("shop_web", 4, map[string]string{stackLabel: "shop"})
+	s.clientMock.On("ServiceInspect", s.ctx, "shop_web").Return(web, nil)
+	s.clientMock.On("ServiceList", s.ctx, types.ServiceListOptions{}).Return([]swarm.Service{
+		web,
+		s.newService("shop_db", 2, map[string]string{stackLabel: "shop"}),
+		s.newService("batch", 4, nil),
+	}, nil)
+
+	_, err := s.scaler.Scale(s.ctx, "shop_web", 0, ScaleUpDirection)
+	s.Require().Error(err)
+	s.Contains(err.Error(), "8 replicas in the stack shop would exceed REPLICA_QUOTA of 6")
+}
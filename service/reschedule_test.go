@@ -11,6 +11,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/logging"
 )
 
 type ReschedulerTestSuite struct {
@@ -19,6 +20,7 @@ type ReschedulerTestSuite struct {
 	clientMock         *DockerClientMock
 	envKey             string
 	ctx                context.Context
+	waitCtx            interface{}
 }
 
 func TestReschedulerTestSuite(t *testing.T) {
@@ -27,7 +29,11 @@ func TestReschedulerTestSuite(t *testing.T) {
 
 func (s *ReschedulerTestSuite) SetupSuite() {
 	s.envKey = "RESCHEDULE_DATE"
-	s.ctx = context.Background()
+	s.ctx = logging.WithRequestID(context.Background(), "req-1")
+	// Waits keep the values of the context they are started with
+	s.waitCtx = mock.MatchedBy(func(ctx context.Context) bool {
+		return logging.RequestID(ctx) == "req-1"
+	})
 }
 
 func (s *ReschedulerTestSuite) SetupTest() {
@@ -49,7 +55,7 @@ func (s *ReschedulerTestSuite) Test_Reschedule_ServiceDoesNotExist() {
 	s.clientMock.On("ServiceInspect", s.ctx, "DOESNOTEXIST").
 		Return(swarm.Service{}, expErr)
 
-	err := s.reschedulerService.RescheduleService("DOESNOTEXIST", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "DOESNOTEXIST", "value")
 	s.Require().Error(err)
 	s.Contains(err.Error(), "Unable to inspect service DOESNOTEXIST")
 }
@@ -60,20 +66,34 @@ func (s *ReschedulerTestSuite) Test_Reschedule_ServiceWithoutFilterKey() {
 	s.clientMock.On("ServiceInspect", s.ctx, "web_test").
 		Return(ts, nil)
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().Error(err)
 	s.Equal("web_test is not labeled with com.df.reschedule=true (no label)", err.Error())
 
 	s.clientMock.AssertExpectations(s.T())
 }
 
+func (s *ReschedulerTestSuite) Test_SetOptions() {
+	err := s.reschedulerService.SetOptions("reschedule", s.envKey, time.Second, time.Second)
+	s.EqualError(err, "reschedule does not have form key=value")
+
+	err = s.reschedulerService.SetOptions("com.df.reschedule=yes", s.envKey, time.Second, time.Second)
+	s.Require().NoError(err)
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").
+		Return(s.getTestService(), nil)
+
+	err = s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
+	s.Require().Error(err)
+	s.Equal("web_test is not labeled with com.df.reschedule=yes (com.df.reschedule=true)", err.Error())
+}
+
 func (s *ReschedulerTestSuite) Test_Reschedule_ServiceWithoutFilterValue() {
 	ts := s.getTestService()
 	ts.Spec.Annotations.Labels["com.df.reschedule"] = "false"
 	s.clientMock.On("ServiceInspect", s.ctx, "web_test").
 		Return(ts, nil)
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().Error(err)
 	s.Equal("web_test is not labeled with com.df.reschedule=true (com.df.reschedule=false)", err.Error())
 	s.clientMock.AssertExpectations(s.T())
@@ -88,7 +108,7 @@ func (s *ReschedulerTestSuite) Test_Reschedule_Service_UpdateFails() {
 		On("ServiceUpdate", s.ctx, ts.ID, ts.Version, ts.Spec).
 		Return(expErr)
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().Error(err)
 
 	s.Contains(err.Error(), "Unable to reschedule service")
@@ -103,7 +123,7 @@ func (s *ReschedulerTestSuite) Test_Reschedule_Service_EnvExists() {
 		On("ServiceUpdate", s.ctx, ts.ID, ts.Version, ts.Spec).
 		Return(nil)
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().NoError(err)
 
 	s.clientMock.AssertExpectations(s.T())
@@ -124,7 +144,7 @@ func (s *ReschedulerTestSuite) Test_Reschedule_Service_NilContainerSpec() {
 		newSpec = args.Get(3).(swarm.ServiceSpec)
 	})
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().NoError(err)
 
 	s.clientMock.AssertExpectations(s.T())
@@ -146,7 +166,7 @@ func (s *ReschedulerTestSuite) Test_Reschedule_Service_UpdatesValue() {
 		newSpec = args.Get(3).(swarm.ServiceSpec)
 	})
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().NoError(err)
 
 	s.clientMock.AssertExpectations(s.T())
@@ -160,7 +180,7 @@ func (s *ReschedulerTestSuite) Test_Reschedule_Service_SameValueDoesNotUpdate()
 	s.clientMock.On("ServiceInspect", s.ctx, "web_test").
 		Return(ts, nil)
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().NoError(err)
 
 	s.clientMock.AssertExpectations(s.T())
@@ -182,7 +202,7 @@ func (s *ReschedulerTestSuite) Test_Reschedule_Service_AddsNewValue() {
 		newSpec = args.Get(3).(swarm.ServiceSpec)
 	})
 
-	err := s.reschedulerService.RescheduleService("web_test", "value")
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
 	s.Require().NoError(err)
 
 	s.clientMock.AssertExpectations(s.T())
@@ -195,7 +215,7 @@ func (s *ReschedulerTestSuite) Test_RescheduleAll_ListError() {
 
 	s.clientMock.On("ServiceList", s.ctx, s.getFilter()).Return(serviceList, expErr)
 
-	_, err := s.reschedulerService.RescheduleAll("value")
+	_, err := s.reschedulerService.RescheduleAll(s.ctx, "value")
 	s.Error(err)
 
 }
@@ -211,11 +231,52 @@ func (s *ReschedulerTestSuite) Test_RescheduleAll_List() {
 			mock.AnythingOfType("swarm.ServiceSpec")).
 		Return(nil)
 
-	status, err := s.reschedulerService.RescheduleAll("value")
+	status, err := s.reschedulerService.RescheduleAll(s.ctx, "value")
 	s.Require().NoError(err)
 	s.Regexp("(web_test|web_test2), (web_test|web_test2) rescheduled", status)
 }
 
+// freezeTier freezes the services labeled com.df.tier=db
+func (s *ReschedulerTestSuite) freezeTier() {
+	freezes := NewFreezes()
+	now := time.Now()
+	freezes.SetConfigured([]Freeze{{Name: "db-migration", Start: now.Add(-time.Hour), End: now.Add(time.Hour),
+		Scope: FreezeServices, Selector: "com.df.tier=db", Policy: FreezeReject}})
+	s.reschedulerService.SetFreezes(freezes)
+}
+
+func (s *ReschedulerTestSuite) Test_Reschedule_Service_Frozen() {
+	s.freezeTier()
+	ts := s.getTestService()
+	ts.Spec.Labels["com.df.tier"] = "db"
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(ts, nil)
+
+	err := s.reschedulerService.RescheduleService(s.ctx, "web_test", "value")
+	s.Require().Error(err)
+	fe, ok := err.(*FrozenError)
+	s.Require().True(ok)
+	s.Equal("db-migration", fe.Freeze.Name)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func (s *ReschedulerTestSuite) Test_RescheduleAll_SkipsFrozen() {
+	s.freezeTier()
+	ts1, ts2 := s.getTestService(), s.getTestService()
+	ts2.ID = "web_testID2"
+	ts2.Spec.Name = "web_test2"
+	ts2.Spec.Labels = map[string]string{"com.df.reschedule": "true", "com.df.tier": "db"}
+
+	s.clientMock.On("ServiceList", s.ctx, s.getFilter()).Return([]swarm.Service{ts1, ts2}, nil).
+		On("ServiceUpdate", s.ctx, "web_testID", mock.AnythingOfType("swarm.Version"),
+			mock.AnythingOfType("swarm.ServiceSpec")).
+		Return(nil)
+
+	status, err := s.reschedulerService.RescheduleAll(s.ctx, "value")
+	s.Require().NoError(err)
+	s.Regexp(`^web_test rescheduled, skipped web_test2 \(Rejected by freeze db-migration until .*\)$`, status)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "web_testID2", mock.Anything, mock.Anything)
+}
+
 func (s *ReschedulerTestSuite) Test_RescheduleAll_UpdateErrors() {
 	ts1, ts2 := s.getTestService(), s.getTestService()
 	ts2.ID = "web_testID2"
@@ -228,7 +289,7 @@ func (s *ReschedulerTestSuite) Test_RescheduleAll_UpdateErrors() {
 			mock.AnythingOfType("swarm.ServiceSpec")).
 		Return(errors.New("update error"))
 
-	_, err := s.reschedulerService.RescheduleAll("value")
+	_, err := s.reschedulerService.RescheduleAll(s.ctx, "value")
 	s.Require().Error(err)
 
 	s.Regexp("(web_test|web_test2), (web_test|web_test2) failed to reschedule", err.Error())
@@ -250,7 +311,7 @@ func (s *ReschedulerTestSuite) Test_RescheduleAll_PartialErrors() {
 			mock.AnythingOfType("swarm.ServiceSpec")).
 		Return(nil)
 
-	_, err := s.reschedulerService.RescheduleAll("value")
+	_, err := s.reschedulerService.RescheduleAll(s.ctx, "value")
 	s.Require().Error(err)
 
 	s.Equal("web_test failed to reschedule (web_test2 succeeded)", err.Error())
@@ -258,13 +319,13 @@ func (s *ReschedulerTestSuite) Test_RescheduleAll_PartialErrors() {
 
 func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_NodeReadyCntError() {
 	expErr := errors.New("Node list error")
-	s.clientMock.On("NodeReadyCnt", s.ctx, true).Return(0, expErr)
+	s.clientMock.On("NodeReadyCnt", s.waitCtx, true).Return(0, expErr)
 
 	tickerC := make(chan time.Time)
 	errorC := make(chan error)
 	statusC := make(chan string)
 
-	s.reschedulerService.RescheduleServicesWaitForNodes(true, 3, "value", tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 3, "value", 0, tickerC, errorC, statusC)
 
 	timer := time.NewTimer(time.Second * 5).C
 
@@ -288,14 +349,14 @@ L:
 
 func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_ServiceListFail() {
 
-	s.clientMock.On("NodeReadyCnt", s.ctx, true).Return(3, nil).
-		On("ServiceList", s.ctx, s.getFilter()).Return([]swarm.Service{}, errors.New("update error"))
+	s.clientMock.On("NodeReadyCnt", s.waitCtx, true).Return(3, nil).
+		On("ServiceList", s.waitCtx, s.getFilter()).Return([]swarm.Service{}, errors.New("update error"))
 
 	tickerC := make(chan time.Time)
 	errorC := make(chan error)
 	statusC := make(chan string)
 
-	s.reschedulerService.RescheduleServicesWaitForNodes(true, 3, "value", tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 3, "value", 0, tickerC, errorC, statusC)
 
 	timer := time.NewTimer(time.Second * 5).C
 	var err error
@@ -319,9 +380,9 @@ L:
 func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_Manager() {
 
 	ts := s.getTestService()
-	s.clientMock.On("NodeReadyCnt", s.ctx, true).Return(3, nil).Return(4, nil).
-		On("ServiceList", s.ctx, s.getFilter()).Return([]swarm.Service{ts}, nil).
-		On("ServiceUpdate", s.ctx, mock.AnythingOfType("string"), mock.AnythingOfType("swarm.Version"),
+	s.clientMock.On("NodeReadyCnt", s.waitCtx, true).Return(3, nil).Return(4, nil).
+		On("ServiceList", s.waitCtx, s.getFilter()).Return([]swarm.Service{ts}, nil).
+		On("ServiceUpdate", s.waitCtx, mock.AnythingOfType("string"), mock.AnythingOfType("swarm.Version"),
 			mock.AnythingOfType("swarm.ServiceSpec")).
 		Return(nil)
 
@@ -329,7 +390,7 @@ func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_Manager() {
 	errorC := make(chan error)
 	statusC := make(chan string)
 
-	s.reschedulerService.RescheduleServicesWaitForNodes(true, 4, "value", tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 4, "value", 0, tickerC, errorC, statusC)
 
 	timer := time.NewTimer(time.Second * 5).C
 	var status string
@@ -358,9 +419,9 @@ func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_Worker() {
 
 	ts := s.getTestService()
 
-	s.clientMock.On("NodeReadyCnt", s.ctx, false).Return(3, nil).Return(4, nil).
-		On("ServiceList", s.ctx, s.getFilter()).Return([]swarm.Service{ts}, nil).
-		On("ServiceUpdate", s.ctx, mock.AnythingOfType("string"), mock.AnythingOfType("swarm.Version"),
+	s.clientMock.On("NodeReadyCnt", s.waitCtx, false).Return(3, nil).Return(4, nil).
+		On("ServiceList", s.waitCtx, s.getFilter()).Return([]swarm.Service{ts}, nil).
+		On("ServiceUpdate", s.waitCtx, mock.AnythingOfType("string"), mock.AnythingOfType("swarm.Version"),
 			mock.AnythingOfType("swarm.ServiceSpec")).
 		Return(nil)
 
@@ -368,7 +429,7 @@ func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_Worker() {
 	errorC := make(chan error)
 	statusC := make(chan string)
 
-	s.reschedulerService.RescheduleServicesWaitForNodes(false, 4, "value", tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, false, 4, "value", 0, tickerC, errorC, statusC)
 
 	timer := time.NewTimer(time.Second * 5).C
 	var status string
@@ -397,13 +458,13 @@ L:
 
 func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_Timeout() {
 
-	s.clientMock.On("NodeReadyCnt", s.ctx, true).Return(3, nil)
+	s.clientMock.On("NodeReadyCnt", s.waitCtx, true).Return(3, nil)
 
 	tickerC := make(chan time.Time)
 	errorC := make(chan error)
 	statusC := make(chan string)
 
-	s.reschedulerService.RescheduleServicesWaitForNodes(true, 4, "value", tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 4, "value", 0, tickerC, errorC, statusC)
 
 	timer := time.NewTimer(time.Second * 5).C
 	var err error
@@ -428,14 +489,30 @@ L:
 	s.clientMock.AssertExpectations(s.T())
 }
 
+func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_GivenTimeout() {
+
+	tickerC := make(chan time.Time)
+	errorC := make(chan error)
+	statusC := make(chan string)
+
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 4, "value", 100*time.Millisecond, tickerC, errorC, statusC)
+
+	select {
+	case err := <-errorC:
+		s.Equal("Timeout: waited 0.100000 seconds for 4 manager nodes to activate", err.Error())
+	case <-time.After(time.Second):
+		s.Fail("Did not time out before the ticker")
+	}
+}
+
 func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_CallsACancel() {
 
 	tickerC := make(chan time.Time)
 	errorC := make(chan error)
 	statusC := make(chan string)
 
-	s.reschedulerService.RescheduleServicesWaitForNodes(true, 4, "value", tickerC, errorC, statusC)
-	s.reschedulerService.RescheduleServicesWaitForNodes(true, 4, "value", tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 4, "value", 0, tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 4, "value", 0, tickerC, errorC, statusC)
 
 	timer := time.NewTimer(time.Second * 5).C
 	var status string
@@ -461,13 +538,13 @@ L:
 
 func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_IsWaitingToRescheduling() {
 
-	s.clientMock.On("NodeReadyCnt", s.ctx, false).Return(4, nil)
+	s.clientMock.On("NodeReadyCnt", s.waitCtx, false).Return(4, nil)
 
 	tickerC := make(chan time.Time)
 	errorC := make(chan error)
 	statusC := make(chan string)
 
-	s.reschedulerService.RescheduleServicesWaitForNodes(false, 3, "value", tickerC, errorC, statusC)
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, false, 3, "value", 0, tickerC, errorC, statusC)
 
 	timer := time.NewTimer(time.Second * 5).C
 	var waiting bool
@@ -490,6 +567,68 @@ L:
 	s.False(waiting)
 }
 
+func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_Stop() {
+
+	tickerC := make(chan time.Time)
+	errorC := make(chan error)
+	statusC := make(chan string)
+
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, false, 3, "value", 0, tickerC, errorC, statusC)
+	s.reschedulerService.Stop()
+
+	timer := time.NewTimer(time.Second * 5).C
+	var err error
+
+L:
+	for {
+		select {
+		case err = <-errorC:
+			break L
+		case <-timer:
+			s.Fail("Timeout")
+			return
+		case <-tickerC:
+		case <-statusC:
+			s.Fail("Status returned")
+			return
+		}
+	}
+	s.Require().Error(err)
+	s.Equal("Interrupted: docker-scaler stopped while waiting for 3 worker nodes to activate", err.Error())
+}
+
+func (s *ReschedulerTestSuite) Test_RescheduleServicesWaitForNodes_CancelWait() {
+
+	tickerC := make(chan time.Time)
+	errorC := make(chan error)
+	statusC := make(chan string)
+
+	s.reschedulerService.RescheduleServicesWaitForNodes(s.ctx, true, 5, "value", 0, tickerC, errorC, statusC)
+	s.False(s.reschedulerService.CancelWait("othervalue"))
+	s.True(s.reschedulerService.CancelWait("value"))
+
+	timer := time.NewTimer(time.Second * 5).C
+	var err error
+
+L:
+	for {
+		select {
+		case err = <-errorC:
+			break L
+		case <-timer:
+			s.Fail("Timeout")
+			return
+		case <-tickerC:
+		case <-statusC:
+			s.Fail("Status returned")
+			return
+		}
+	}
+	s.Require().Error(err)
+	s.Equal("Canceled: stopped waiting for 5 manager nodes to activate", err.Error())
+	s.False(s.reschedulerService.IsWaitingToReschedule())
+}
+
 func (s *ReschedulerTestSuite) getTestService() swarm.Service {
 	labels := map[string]string{
 		"com.df.reschedule": "true",
@@ -11,16 +11,25 @@ import (
 	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/metrics"
 )
 
 // ReschedulerServicer is an interface for rescheduling services
 type ReschedulerServicer interface {
-	RescheduleService(serviceID, value string) error
-	RescheduleServicesWaitForNodes(manager bool, targetNodeCnt int, value string, tickerC chan<- time.Time, errorC chan<- error, statusC chan<- string)
-	RescheduleAll(value string) (string, error)
+	RescheduleService(ctx context.Context, serviceID, value string) error
+	RescheduleServicesWaitForNodes(ctx context.Context, manager bool, targetNodeCnt int, value string, timeOut time.Duration, tickerC chan<- time.Time, errorC chan<- error, statusC chan<- string)
+	RescheduleAll(ctx context.Context, value string) (string, error)
 	IsWaitingToReschedule() bool
+	CancelWait(value string) bool
+	Stop()
 }
 
+var (
+	errCanceledByRescheduler = errors.New("canceled by another rescheduler")
+	errCanceledByRequest     = errors.New("canceled by request")
+	errStopped               = errors.New("rescheduler stopped")
+)
+
 // InfoListUpdaterNodeLister is an interface needd for rescheduling events
 type InfoListUpdaterNodeLister interface {
 	NodeReadyCnt(ctx context.Context, manager bool) (int, error)
@@ -31,45 +40,58 @@ type InfoListUpdaterNodeLister interface {
 
 type reschedulerService struct {
 	c              InfoListUpdaterNodeLister
+	mu             sync.RWMutex
 	filterLabel    string
 	envKey         string
 	tickerInterval time.Duration
 	timeOut        time.Duration
+	freezes        *Freezes
 	cHolder        *cancelHolder
+	ctx            context.Context
+	stop           context.CancelCauseFunc
 }
 
 type cancelHolder struct {
-	funcMap map[string]context.CancelFunc
+	funcMap map[string]context.CancelCauseFunc
 	mux     sync.RWMutex
 }
 
 func newCancelHolder() *cancelHolder {
 	return &cancelHolder{
-		funcMap: map[string]context.CancelFunc{},
+		funcMap: map[string]context.CancelCauseFunc{},
 		mux:     sync.RWMutex{},
 	}
 }
 
-func (h *cancelHolder) CallAndSet(key string, newF context.CancelFunc) {
+func (h *cancelHolder) CallAndSet(key string, newF context.CancelCauseFunc) {
 	h.mux.Lock()
 	defer h.mux.Unlock()
 	for key, cancel := range h.funcMap {
 		if cancel != nil {
-			cancel()
+			cancel(errCanceledByRescheduler)
 		}
 		delete(h.funcMap, key)
 	}
 	h.funcMap[key] = newF
+	metrics.PendingRescheduleWaits.Set(float64(len(h.funcMap)))
 }
 
 func (h *cancelHolder) CallAndDelete(key string) {
+	h.Cancel(key, nil)
+}
+
+// Cancel calls and deletes the cancel function at `key` with `cause`.
+// Returns false when there is nothing to cancel
+func (h *cancelHolder) Cancel(key string, cause error) bool {
 	h.mux.Lock()
 	defer h.mux.Unlock()
 	f, ok := h.funcMap[key]
 	if ok && f != nil {
-		f()
+		f(cause)
 	}
 	delete(h.funcMap, key)
+	metrics.PendingRescheduleWaits.Set(float64(len(h.funcMap)))
+	return ok
 }
 
 func (h *cancelHolder) HasCancel() bool {
@@ -90,6 +112,7 @@ func NewReschedulerService(
 		return nil, fmt.Errorf("%s does not have form key=value", filterLabel)
 	}
 
+	ctx, stop := context.WithCancelCause(context.Background())
 	return &reschedulerService{
 		c:              c,
 		filterLabel:    filterLabel,
@@ -97,38 +120,52 @@ func NewReschedulerService(
 		tickerInterval: tickerInterval,
 		timeOut:        timeOut,
 		cHolder:        newCancelHolder(),
+		ctx:            ctx,
+		stop:           stop,
 	}, nil
 }
 
-func (r *reschedulerService) RescheduleService(serviceID, value string) error {
+func (r *reschedulerService) RescheduleService(ctx context.Context, serviceID, value string) error {
 
-	serviceInfo, err := r.c.ServiceInspect(
-		context.Background(), serviceID)
+	serviceInfo, err := r.c.ServiceInspect(ctx, serviceID)
 	if err != nil {
 		return errors.Wrapf(err, "Unable to inspect service %s", serviceID)
 	}
 
-	kv := strings.Split(r.filterLabel, "=")
+	filterLabel, _, _, _ := r.options()
+	kv := strings.Split(filterLabel, "=")
 	filterValue, ok := serviceInfo.Spec.Labels[kv[0]]
 
 	if !ok {
-		return fmt.Errorf("%s is not labeled with %s (no label)", serviceID, r.filterLabel)
+		return fmt.Errorf("%s is not labeled with %s (no label)", serviceID, filterLabel)
 	}
 
 	if filterValue != kv[1] {
-		return fmt.Errorf("%s is not labeled with %s (%s=%s)", serviceID, r.filterLabel, kv[0], filterValue)
+		return fmt.Errorf("%s is not labeled with %s (%s=%s)", serviceID, filterLabel, kv[0], filterValue)
 	}
 
-	err = r.rescheduleSingleService(serviceInfo, value)
+	err = r.checkFreezes(ctx, serviceInfo)
+	if err != nil {
+		return err
+	}
+
+	err = r.rescheduleSingleService(ctx, serviceInfo, value)
 	if err != nil {
 		return errors.Wrap(err, "Unable to reschedule service")
 	}
 	return nil
 }
 
-func (r *reschedulerService) RescheduleServicesWaitForNodes(manager bool, targetNodeCnt int, value string, tickerC chan<- time.Time, errorC chan<- error, statusC chan<- string) {
+// RescheduleServicesWaitForNodes waits in the background for nodes to come
+// online before rescheduling. It waits at most `timeOut`, or the configured
+// timeout when it is 0. The wait keeps the values of `ctx`, but is only
+// canceled by CancelWait, Stop or another wait
+func (r *reschedulerService) RescheduleServicesWaitForNodes(ctx context.Context, manager bool, targetNodeCnt int, value string, timeOut time.Duration, tickerC chan<- time.Time, errorC chan<- error, statusC chan<- string) {
 
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	stopWait := context.AfterFunc(r.ctx, func() {
+		cancel(context.Cause(r.ctx))
+	})
 	r.cHolder.CallAndSet(value, cancel)
 
 	var typeStr string
@@ -138,16 +175,22 @@ func (r *reschedulerService) RescheduleServicesWaitForNodes(manager bool, target
 		typeStr = "worker"
 	}
 
+	_, _, tickerInterval, configuredTimeOut := r.options()
+	if timeOut == 0 {
+		timeOut = configuredTimeOut
+	}
+
 	go func() {
+		defer stopWait()
 		defer r.cHolder.CallAndDelete(value)
-		tickerChan := time.NewTicker(r.tickerInterval).C
-		timerChan := time.NewTimer(r.timeOut).C
+		tickerChan := time.NewTicker(tickerInterval).C
+		timerChan := time.NewTimer(timeOut).C
 
 		for {
 			select {
 			case tc := <-tickerChan:
 				tickerC <- tc
-				equalTarget, err := r.equalTargetCount(targetNodeCnt, manager)
+				equalTarget, err := r.equalTargetCount(ctx, targetNodeCnt, manager)
 				if err != nil {
 					errorC <- err
 					return
@@ -156,7 +199,7 @@ func (r *reschedulerService) RescheduleServicesWaitForNodes(manager bool, target
 					continue
 				}
 
-				status, err := r.RescheduleAll(value)
+				status, err := r.RescheduleAll(ctx, value)
 				if err != nil {
 					errorC <- err
 					return
@@ -164,10 +207,17 @@ func (r *reschedulerService) RescheduleServicesWaitForNodes(manager bool, target
 				statusC <- fmt.Sprintf("%d %s nodes are up, %s", targetNodeCnt, typeStr, status)
 				return
 			case <-timerChan:
-				errorC <- fmt.Errorf("Timeout: waited %f seconds for %d %s nodes to activate", r.timeOut.Seconds(), targetNodeCnt, typeStr)
+				errorC <- fmt.Errorf("Timeout: waited %f seconds for %d %s nodes to activate", timeOut.Seconds(), targetNodeCnt, typeStr)
 				return
 			case <-ctx.Done():
-				statusC <- "Rescheduling is canceled by another rescheduler"
+				switch context.Cause(ctx) {
+				case errStopped:
+					errorC <- fmt.Errorf("Interrupted: docker-scaler stopped while waiting for %d %s nodes to activate", targetNodeCnt, typeStr)
+				case errCanceledByRequest:
+					errorC <- fmt.Errorf("Canceled: stopped waiting for %d %s nodes to activate", targetNodeCnt, typeStr)
+				default:
+					statusC <- "Rescheduling is canceled by another rescheduler"
+				}
 				return
 			}
 		}
@@ -175,20 +225,91 @@ func (r *reschedulerService) RescheduleServicesWaitForNodes(manager bool, target
 
 }
 
+// SetFreezes skips rescheduling services during the freezes in `f`
+func (r *reschedulerService) SetFreezes(f *Freezes) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.freezes = f
+}
+
+// checkFreezes returns a *FrozenError when a freeze rejects rescheduling
+// `service`
+func (r *reschedulerService) checkFreezes(ctx context.Context, service swarm.Service) error {
+	r.mu.RLock()
+	freezes := r.freezes
+	r.mu.RUnlock()
+	return freezes.Check(FreezeAction{
+		Cluster: ClusterFrom(ctx),
+		Kind:    "reschedule_service",
+		Service: service.Spec.Name,
+		Labels:  service.Spec.Labels,
+	})
+}
+
+// SetOptions changes the label of services to reschedule, the env key
+// updated on them, and how often and how long to wait for nodes. Reschedules
+// already waiting for nodes keep their interval and timeout
+func (r *reschedulerService) SetOptions(filterLabel, envKey string, tickerInterval, timeOut time.Duration) error {
+	if kv := strings.Split(filterLabel, "="); len(kv) != 2 {
+		return fmt.Errorf("%s does not have form key=value", filterLabel)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.filterLabel = filterLabel
+	r.envKey = envKey
+	r.tickerInterval = tickerInterval
+	r.timeOut = timeOut
+	return nil
+}
+
+func (r *reschedulerService) options() (filterLabel, envKey string, tickerInterval, timeOut time.Duration) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.filterLabel, r.envKey, r.tickerInterval, r.timeOut
+}
+
 func (r *reschedulerService) IsWaitingToReschedule() bool {
 	return r.cHolder.HasCancel()
 }
 
-func (r *reschedulerService) RescheduleAll(value string) (string, error) {
+// CancelWait cancels the reschedule waiting for nodes started with `value`.
+// Returns false when no such reschedule is waiting
+func (r *reschedulerService) CancelWait(value string) bool {
+	return r.cHolder.Cancel(value, errCanceledByRequest)
+}
+
+// Stop interrupts every reschedule waiting for nodes. Waits started after
+// Stop are interrupted immediately
+func (r *reschedulerService) Stop() {
+	r.stop(errStopped)
+}
+
+func (r *reschedulerService) RescheduleAll(ctx context.Context, value string) (string, error) {
 	labelFitler := filters.NewArgs()
-	labelFitler.Add("label", r.filterLabel)
+	filterLabel, _, _, _ := r.options()
+	labelFitler.Add("label", filterLabel)
 
-	services, err := r.c.ServiceList(context.Background(), types.ServiceListOptions{Filters: labelFitler})
+	services, err := r.c.ServiceList(ctx, types.ServiceListOptions{Filters: labelFitler})
 	if err != nil {
 		return "", errors.Wrap(err, "Unable to get service list to reschedule")
 	}
 
+	frozenList := []string{}
+	unfrozen := []swarm.Service{}
+	for _, service := range services {
+		if err := r.checkFreezes(ctx, service); err != nil {
+			frozenList = append(frozenList, fmt.Sprintf("%s (%s)", service.Spec.Name, err))
+			continue
+		}
+		unfrozen = append(unfrozen, service)
+	}
+	services = unfrozen
+	frozenStr := strings.Join(frozenList, ", ")
+
 	if len(services) == 0 {
+		if len(frozenList) > 0 {
+			return fmt.Sprintf("No services to reschedule, skipped %s", frozenStr), nil
+		}
 		return "No services to reschedule", nil
 	}
 
@@ -216,7 +337,7 @@ func (r *reschedulerService) RescheduleAll(value string) (string, error) {
 		wg.Add(1)
 		go func(service swarm.Service) {
 			defer wg.Done()
-			err = r.rescheduleSingleService(service, value)
+			err = r.rescheduleSingleService(ctx, service, value)
 			if err != nil {
 				failed <- service.Spec.Name
 				return
@@ -236,12 +357,15 @@ func (r *reschedulerService) RescheduleAll(value string) (string, error) {
 		}
 		return "", fmt.Errorf("%s failed to reschedule", failedStr)
 	}
+	if len(frozenList) > 0 {
+		return fmt.Sprintf("%s rescheduled, skipped %s", successStr, frozenStr), nil
+	}
 	return fmt.Sprintf("%s rescheduled", successStr), nil
 }
 
-func (r *reschedulerService) equalTargetCount(targetNodeCnt int, manager bool) (bool, error) {
+func (r *reschedulerService) equalTargetCount(ctx context.Context, targetNodeCnt int, manager bool) (bool, error) {
 
-	nodeCnt, err := r.c.NodeReadyCnt(context.Background(), manager)
+	nodeCnt, err := r.c.NodeReadyCnt(ctx, manager)
 	if err != nil {
 		return false, errors.Wrap(err, "Unable to get docker node count")
 	}
@@ -249,7 +373,7 @@ func (r *reschedulerService) equalTargetCount(targetNodeCnt int, manager bool) (
 	return nodeCnt == targetNodeCnt, nil
 }
 
-func (r *reschedulerService) rescheduleSingleService(service swarm.Service, value string) error {
+func (r *reschedulerService) rescheduleSingleService(ctx context.Context, service swarm.Service, value string) error {
 	spec := &service.Spec
 	if spec.TaskTemplate.ContainerSpec == nil {
 		spec.TaskTemplate.ContainerSpec = &swarm.ContainerSpec{}
@@ -258,7 +382,8 @@ func (r *reschedulerService) rescheduleSingleService(service swarm.Service, valu
 
 	addedNewEnv := false
 	newEnvs := []string{}
-	envAdd := fmt.Sprintf("%s=%s", r.envKey, value)
+	_, envKey, _, _ := r.options()
+	envAdd := fmt.Sprintf("%s=%s", envKey, value)
 
 	for _, env := range envs {
 
@@ -273,7 +398,7 @@ func (r *reschedulerService) rescheduleSingleService(service swarm.Service, valu
 			continue
 		}
 
-		if envSplit[0] == r.envKey && envSplit[1] != value {
+		if envSplit[0] == envKey && envSplit[1] != value {
 			// env variable updated
 			addedNewEnv = true
 			newEnvs = append(newEnvs, envAdd)
@@ -290,7 +415,7 @@ func (r *reschedulerService) rescheduleSingleService(service swarm.Service, valu
 
 	spec.TaskTemplate.ContainerSpec.Env = newEnvs
 
-	err := r.c.ServiceUpdate(context.Background(), service.ID, service.Version, *spec)
+	err := r.c.ServiceUpdate(ctx, service.ID, service.Version, *spec)
 	if err != nil {
 		return err
 	}
@@ -3,14 +3,29 @@ package service
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/pkg/errors"
+	"github.com/thomasjpfan/docker-scaler/metrics"
 )
 
 // ScalerServicer interface for resizing services
 type ScalerServicer interface {
-	Scale(ctx context.Context, serviceName string, by uint64, direction ScaleDirection) (string, bool, error)
+	Scale(ctx context.Context, serviceName string, by uint64, direction ScaleDirection) (ScaleResult, error)
+}
+
+// ScaleResult describes the outcome of scaling a service
+type ScaleResult struct {
+	Message  string
+	AtBound  bool
+	Previous uint64
+	Current  uint64
+	Min      uint64
+	Max      uint64
+	// Preempted are the services scaled down to make room within the
+	// replica quota
+	Preempted []Preemption
 }
 
 // UpdaterInspector is an interface for scaling services
@@ -21,7 +36,30 @@ type UpdaterInspector interface {
 
 type scalerService struct {
 	c           UpdaterInspector
+	mu          sync.RWMutex
 	resolveOpts ResolveDeltaOptions
+	freezes     *Freezes
+	approvals   *Approvals
+	updateOpts  UpdatePolicyOptions
+	quotaOpts   ReplicaQuotaOptions
+
+	// preserveLabel marks the services whose decided replicas are
+	// restored, and decided are the replicas the scaler last set for each
+	// service. The decided replicas are saved to decidedPath when it is set
+	preserveLabel  string
+	decided        map[string]uint64
+	decidedPath    string
+	decidedVersion uint64
+	onDecidedError func(err error)
+
+	// decidedWrite orders the writes of the replicas file, and
+	// decidedWritten is the version of the decided replicas written last
+	decidedWrite   sync.Mutex
+	decidedWritten uint64
+
+	// quota lets one service be scaled up within the replica quota at a
+	// time, so two services can not both take what is left of it
+	quota sync.Mutex
 }
 
 // NewScalerService creates a New Docker Swarm Client
@@ -32,47 +70,158 @@ func NewScalerService(
 	return &scalerService{
 		c:           c,
 		resolveOpts: resolveOpts,
+		decided:     map[string]uint64{},
 	}
 }
 
-func (s scalerService) Scale(ctx context.Context, serviceName string, by uint64, direction ScaleDirection) (string, bool, error) {
+// SetResolveOptions changes the labels and defaults used to scale
+// services from now on
+func (s *scalerService) SetResolveOptions(resolveOpts ResolveDeltaOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.resolveOpts = resolveOpts
+}
+
+// SetFreezes rejects scaling services during the freezes in `f`
+func (s *scalerService) SetFreezes(f *Freezes) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.freezes = f
+}
+
+// SetApprovals parks the changes that need approval in `a`
+func (s *scalerService) SetApprovals(a *Approvals) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.approvals = a
+}
+
+// SetReplicaQuota changes the replica quota. Services are listed with
+// the docker client of the scaler
+func (s *scalerService) SetReplicaQuota(opts ReplicaQuotaOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.quotaOpts = opts
+}
+
+// SetUpdatePolicy changes what happens to scaling a service while swarm
+// updates or rolls it back
+func (s *scalerService) SetUpdatePolicy(opts UpdatePolicyOptions) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updateOpts = opts
+}
+
+func (s *scalerService) Scale(ctx context.Context, serviceName string, by uint64, direction ScaleDirection) (ScaleResult, error) {
 
 	service, err := s.c.ServiceInspect(ctx, serviceName)
 
 	if err != nil {
-		return "", false, errors.Wrap(err, "docker inspect failed in ScalerService")
+		return ScaleResult{}, errors.Wrap(err, "docker inspect failed in ScalerService")
 	}
 
 	isGlobal, err := s.isGlobal(service)
 	if err != nil {
-		return "", false, err
+		return ScaleResult{}, err
 	}
 	if isGlobal {
-		return "", false, fmt.Errorf(
+		return ScaleResult{}, fmt.Errorf(
 			"%s is a global service (can not be scaled)", serviceName)
 	}
 	currentReplicas, err := s.getReplicas(service)
 	if err != nil {
-		return "", false, err
+		return ScaleResult{}, err
 	}
 
-	minReplicas, maxReplicas, newReplicas := resolveDelta(currentReplicas, by, direction, service.Spec.Labels, s.resolveOpts)
+	s.mu.RLock()
+	resolveOpts := s.resolveOpts
+	freezes := s.freezes
+	approvals := s.approvals
+	updateOpts := s.updateOpts
+	quotaOpts := s.quotaOpts
+	s.mu.RUnlock()
+	minReplicas, maxReplicas, newReplicas := resolveDelta(currentReplicas, by, direction, service.Spec.Labels, resolveOpts)
+	result := ScaleResult{
+		Previous: currentReplicas,
+		Current:  newReplicas,
+		Min:      minReplicas,
+		Max:      maxReplicas,
+	}
 
 	if currentReplicas == newReplicas {
-		message := s.scaledToBoundMessage(serviceName, minReplicas, maxReplicas, newReplicas, direction)
-		return message, true, nil
+		metrics.SetServiceReplicas(serviceName, currentReplicas)
+		result.Message = s.scaledToBoundMessage(serviceName, minReplicas, maxReplicas, newReplicas, direction)
+		result.AtBound = true
+		return result, nil
+	}
+
+	err = freezes.Check(FreezeAction{
+		Cluster:   ClusterFrom(ctx),
+		Kind:      "scale_service",
+		Direction: direction,
+		Service:   serviceName,
+		Labels:    service.Spec.Labels,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if updateInProgress(service.UpdateStatus) {
+		if policy := updateOpts.policy(service.Spec.Labels); policy != UpdateProceed {
+			return result, &UpdateInProgressError{
+				Service:      serviceName,
+				State:        service.UpdateStatus.State,
+				Policy:       policy,
+				QueueTimeout: updateOpts.QueueTimeout,
+			}
+		}
 	}
 
+	err = approvals.Check(ctx, ApprovalChange{
+		Kind:      "scale_service",
+		Target:    serviceName,
+		Direction: direction,
+		By:        by,
+		Previous:  currentReplicas,
+		Requested: newReplicas,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	var capped bool
+	if quotaOpts.Max > 0 && newReplicas > currentReplicas {
+		s.quota.Lock()
+		defer s.quota.Unlock()
+		allowed, preemptions, err := s.reserveQuota(ctx, service, currentReplicas, newReplicas, quotaOpts, resolveOpts, freezes, updateOpts)
+		if err != nil {
+			return result, err
+		}
+		result.Preempted, err = s.preempt(ctx, serviceName, preemptions)
+		if err != nil {
+			return result, err
+		}
+		capped = allowed < newReplicas
+		newReplicas = allowed
+		result.Current = newReplicas
+	}
+
+	undo := s.decide(serviceName, newReplicas)
 	err = s.setReplicas(ctx, service, newReplicas)
 	if err != nil {
-		return "", false, err
+		undo()
+		return result, err
 	}
+	metrics.SetServiceReplicas(serviceName, newReplicas)
 
-	message := fmt.Sprintf("Scaling %s from %d to %d replicas (min: %d, max: %d)", serviceName, currentReplicas, newReplicas, minReplicas, maxReplicas)
-	return message, false, nil
+	result.Message = fmt.Sprintf("Scaling %s from %d to %d replicas (min: %d, max: %d)", serviceName, currentReplicas, newReplicas, minReplicas, maxReplicas)
+	if capped {
+		result.Message = fmt.Sprintf("%s, capped by the replica quota of %d", result.Message, quotaOpts.Max)
+	}
+	return result, nil
 }
 
-func (s scalerService) scaledToBoundMessage(serviceName string,
+func (s *scalerService) scaledToBoundMessage(serviceName string,
 	minReplicas, maxReplicas, newReplicas uint64, direction ScaleDirection) string {
 	if direction == ScaleDownDirection {
 		return fmt.Sprintf("%s is already descaled to the minimum number of %d replicas", serviceName, minReplicas)
@@ -81,7 +230,7 @@ func (s scalerService) scaledToBoundMessage(serviceName string,
 }
 
 // getReplicas Gets Replicas
-func (s scalerService) getReplicas(service swarm.Service) (uint64, error) {
+func (s *scalerService) getReplicas(service swarm.Service) (uint64, error) {
 	if service.Spec.Mode.Replicated.Replicas == nil {
 		return 0, fmt.Errorf("%s does not have a replicas value", service.Spec.Name)
 	}
@@ -90,7 +239,7 @@ func (s scalerService) getReplicas(service swarm.Service) (uint64, error) {
 }
 
 // setReplicas Sets the number of replicas
-func (s scalerService) setReplicas(ctx context.Context, service swarm.Service, count uint64) error {
+func (s *scalerService) setReplicas(ctx context.Context, service swarm.Service, count uint64) error {
 
 	service.Spec.Mode.Replicated.Replicas = &count
 
@@ -99,7 +248,7 @@ func (s scalerService) setReplicas(ctx context.Context, service swarm.Service, c
 	return updateErr
 }
 
-func (s scalerService) isGlobal(service swarm.Service) (bool, error) {
+func (s *scalerService) isGlobal(service swarm.Service) (bool, error) {
 
 	if service.Spec.Mode.Global != nil {
 		return true, nil
@@ -4,9 +4,11 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/api/types/swarm"
 	"github.com/pkg/errors"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -65,7 +67,7 @@ func (s *ScalerTestSuite) Test_Scale_UnrecognizedService() {
 	s.clientMock.On(
 		"ServiceInspect", s.ctx, "wow").
 		Return(ss, nil)
-	_, _, err := s.scaler.Scale(s.ctx, "wow", 0, ScaleUpDirection)
+	_, err := s.scaler.Scale(s.ctx, "wow", 0, ScaleUpDirection)
 	s.Require().Error(err)
 
 	s.Contains(err.Error(), "Unable to recognize service model for: wow")
@@ -87,18 +89,25 @@ func (s *ScalerTestSuite) Test_Scale_UnrecognizedReplicas() {
 	s.clientMock.On(
 		"ServiceInspect", s.ctx, "wow").
 		Return(ss, nil)
-	_, _, err := s.scaler.Scale(s.ctx, "wow", 0, ScaleUpDirection)
+	_, err := s.scaler.Scale(s.ctx, "wow", 0, ScaleUpDirection)
 	s.Require().Error(err)
 
 	s.Contains(err.Error(), "wow does not have a replicas value")
 }
 
+func (s *ScalerTestSuite) Test_SetResolveOptions() {
+	opts := s.opts
+	opts.DefaultMax = 20
+	s.scaler.SetResolveOptions(opts)
+	s.Equal(uint64(20), s.scaler.resolveOpts.DefaultMax)
+}
+
 func (s *ScalerTestSuite) Test_ScaleUp_ServiceDoesNotExist() {
 	expErr := errors.New("Does not exist")
 	s.clientMock.On(
 		"ServiceInspect", s.ctx, "NOT_EXIST").
 		Return(swarm.Service{}, expErr)
-	_, _, err := s.scaler.Scale(s.ctx, "NOT_EXIST", 0, ScaleUpDirection)
+	_, err := s.scaler.Scale(s.ctx, "NOT_EXIST", 0, ScaleUpDirection)
 	s.Require().Error(err)
 
 	s.Contains(err.Error(), "docker inspect failed in ScalerService")
@@ -109,7 +118,7 @@ func (s *ScalerTestSuite) Test_ScaleDown_ServiceDoesNotExist() {
 	s.clientMock.On(
 		"ServiceInspect", s.ctx, "NOT_EXIST").
 		Return(swarm.Service{}, expErr)
-	_, _, err := s.scaler.Scale(s.ctx, "NOT_EXIST", 0, ScaleDownDirection)
+	_, err := s.scaler.Scale(s.ctx, "NOT_EXIST", 0, ScaleDownDirection)
 	s.Require().Error(err)
 
 	s.Contains(err.Error(), "docker inspect failed in ScalerService")
@@ -131,7 +140,7 @@ func (s *ScalerTestSuite) Test_ScaleUp_UpdateError() {
 			newts.Spec).
 		Return(expErr)
 
-	_, _, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	_, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
 	s.Require().Error(err)
 
 	s.Equal("Unable to update", err.Error())
@@ -147,10 +156,10 @@ func (s *ScalerTestSuite) Test_ScaleUp_AlreadyAtMax() {
 		"ServiceInspect", s.ctx, "web_test").
 		Return(ts, nil)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
 	s.Require().NoError(err)
-	s.True(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.True(result.AtBound)
+	s.Equal(expMsg, result.Message)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -164,10 +173,10 @@ func (s *ScalerTestSuite) Test_ScaleDown_AlreadyAtMin() {
 		"ServiceInspect", s.ctx, "web_test").
 		Return(ts, nil)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
 	s.Require().NoError(err)
-	s.True(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.True(result.AtBound)
+	s.Equal(expMsg, result.Message)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -182,10 +191,10 @@ func (s *ScalerTestSuite) Test_ScaleUpBy_PassMax() {
 	newts.Spec.Mode.Replicated.Replicas = &newReplicas
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -202,10 +211,10 @@ func (s *ScalerTestSuite) Test_ScaleUpBy_PassDefaultMax() {
 	delete(newts.Spec.Labels, "com.df.scaleMax")
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -218,10 +227,16 @@ func (s *ScalerTestSuite) Test_ScaleUp() {
 	newts.Spec.Mode.Replicated.Replicas = &newReplicas
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.Equal(ScaleResult{
+		Message:  expMsg,
+		AtBound:  false,
+		Previous: s.replicas,
+		Current:  newReplicas,
+		Min:      s.replicaMin,
+		Max:      s.replicaMax,
+	}, result)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -234,10 +249,10 @@ func (s *ScalerTestSuite) Test_ScaleUp_CustomBy() {
 	newts.Spec.Mode.Replicated.Replicas = &newReplicas
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 1, ScaleUpDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 1, ScaleUpDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -255,10 +270,10 @@ func (s *ScalerTestSuite) Test_ScaleUp_DefaultBy() {
 	delete(newts.Spec.Labels, "com.df.scaleMax")
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 	s.clientMock.AssertExpectations(s.T())
 }
 
@@ -272,10 +287,10 @@ func (s *ScalerTestSuite) Test_ScaleDownBy_PassMin() {
 	newts.Spec.Mode.Replicated.Replicas = &newReplicas
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -292,10 +307,10 @@ func (s *ScalerTestSuite) Test_ScaleDownBy_PassDefaultMin() {
 	delete(newts.Spec.Labels, "com.df.scaleMin")
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 
 	s.clientMock.AssertExpectations(s.T())
 }
@@ -309,10 +324,10 @@ func (s *ScalerTestSuite) Test_ScaleDown() {
 	newts.Spec.Mode.Replicated.Replicas = &newReplicas
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 	s.clientMock.AssertExpectations(s.T())
 }
 
@@ -325,10 +340,10 @@ func (s *ScalerTestSuite) Test_ScaleDown_CustomBy() {
 	newts.Spec.Mode.Replicated.Replicas = &newReplicas
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 2, ScaleDownDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 2, ScaleDownDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 	s.clientMock.AssertExpectations(s.T())
 }
 
@@ -343,10 +358,10 @@ func (s *ScalerTestSuite) Test_ScaleDown_DefaultBy() {
 	delete(newts.Spec.Labels, "com.df.scaleDownBy")
 	s.setClientMock(prevts, newts)
 
-	msg, alreadyBounded, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
 	s.Require().NoError(err)
-	s.False(alreadyBounded)
-	s.Equal(expMsg, msg)
+	s.False(result.AtBound)
+	s.Equal(expMsg, result.Message)
 	s.clientMock.AssertExpectations(s.T())
 }
 
@@ -358,7 +373,7 @@ func (s *ScalerTestSuite) Test_GlobalService_ScaleUp_ReturnsError() {
 		"ServiceInspect", s.ctx, "web_test").
 		Return(ts, nil)
 
-	_, _, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	_, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
 
 	s.Require().Error(err)
 	s.Contains(err.Error(), "web_test is a global service (can not be scaled)")
@@ -373,13 +388,137 @@ func (s *ScalerTestSuite) Test_GlobalService_ScaleDown_ReturnsError() {
 		"ServiceInspect", s.ctx, "web_test").
 		Return(ts, nil)
 
-	_, _, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
+	_, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
 
 	s.Require().Error(err)
 	s.Contains(err.Error(), "web_test is a global service (can not be scaled)")
 	s.clientMock.AssertExpectations(s.T())
 
 }
+func (s *ScalerTestSuite) Test_Scale_Frozen() {
+	freezes := NewFreezes()
+	freezes.SetConfigured([]Freeze{{
+		Name: "migration", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour),
+		Scope: FreezeServices, Selector: "com.df.scaleMin=2", Policy: FreezeAllowScaleUp,
+		Reason: "database migration",
+	}})
+	s.scaler.SetFreezes(freezes)
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(s.getTestService(), nil)
+
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleDownDirection)
+	s.Require().Error(err)
+	s.IsType(&FrozenError{}, err)
+	s.Contains(err.Error(), "Rejected by freeze migration until ")
+	s.Contains(err.Error(), ": database migration")
+	s.Equal(s.replicas, result.Previous)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "web_testID", mock.Anything, mock.Anything)
+}
+
+func (s *ScalerTestSuite) Test_Scale_AwaitsApproval() {
+	approvals := NewApprovals()
+	approvals.SetPolicy("", ApprovalPolicy{MaxReplicaFactor: 1.2, Timeout: time.Hour})
+	s.scaler.SetApprovals(approvals)
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(s.getTestService(), nil)
+
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	s.Require().Error(err)
+	ae, ok := err.(*ApprovalRequiredError)
+	s.Require().True(ok)
+	s.Equal("6 replicas are more than APPROVAL_MAX_REPLICA_FACTOR of 1.2 times 4 replicas", ae.Approval.Reason)
+	s.Equal(s.replicas, result.Previous)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "web_testID", mock.Anything, mock.Anything)
+}
+
+func (s *ScalerTestSuite) Test_Scale_Approved() {
+	approvals := NewApprovals()
+	approvals.SetPolicy("", ApprovalPolicy{MaxReplicaFactor: 1.2, Timeout: time.Hour})
+	s.scaler.SetApprovals(approvals)
+	ctx := WithApproval(s.ctx, "abc")
+	s.clientMock.On("ServiceInspect", ctx, "web_test").Return(s.getTestService(), nil)
+	s.clientMock.On("ServiceUpdate", ctx, "web_testID", mock.Anything, mock.Anything).
+		Return(nil)
+
+	result, err := s.scaler.Scale(ctx, "web_test", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(6), result.Current)
+}
+
+func (s *ScalerTestSuite) Test_Scale_DuringUpdate() {
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{
+		Label: "com.df.scaleDuringUpdate", Default: UpdateReject, QueueTimeout: time.Minute,
+	})
+	ts := s.getTestService()
+	ts.UpdateStatus = &swarm.UpdateStatus{State: swarm.UpdateStateRollbackPaused}
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(ts, nil)
+
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	s.Require().Error(err)
+	ue, ok := err.(*UpdateInProgressError)
+	s.Require().True(ok)
+	s.Equal(UpdateReject, ue.Policy)
+	s.Equal("Scaling web_test is rejected while it is updated, the update is paused while rolling back", err.Error())
+	s.Equal(s.replicas, result.Previous)
+	s.clientMock.AssertNotCalled(s.T(), "ServiceUpdate", s.ctx, "web_testID", mock.Anything, mock.Anything)
+}
+
+func (s *ScalerTestSuite) Test_Scale_DuringUpdate_QueuedByLabel() {
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{
+		Label: "com.df.scaleDuringUpdate", Default: UpdateReject, QueueTimeout: time.Minute,
+	})
+	ts := s.getTestService()
+	ts.Spec.Labels["com.df.scaleDuringUpdate"] = "queue"
+	ts.UpdateStatus = &swarm.UpdateStatus{State: swarm.UpdateStateUpdating}
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(ts, nil)
+
+	_, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	s.Require().Error(err)
+	ue, ok := err.(*UpdateInProgressError)
+	s.Require().True(ok)
+	s.Equal(UpdateQueue, ue.Policy)
+	s.Equal(time.Minute, ue.QueueTimeout)
+	s.Equal("Scaling web_test is queued until its update completes, the update is in progress", err.Error())
+}
+
+func (s *ScalerTestSuite) Test_Scale_DuringUpdate_Frozen() {
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{Label: "com.df.scaleDuringUpdate", Default: UpdateQueue})
+	freezes := NewFreezes()
+	freezes.SetConfigured([]Freeze{{
+		Name: "migration", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour),
+		Scope: FreezeAll, Policy: FreezeReject,
+	}})
+	s.scaler.SetFreezes(freezes)
+	ts := s.getTestService()
+	ts.UpdateStatus = &swarm.UpdateStatus{State: swarm.UpdateStateUpdating}
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(ts, nil)
+
+	_, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	s.IsType(&FrozenError{}, err)
+}
+
+func (s *ScalerTestSuite) Test_Scale_DuringUpdate_Proceeds() {
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{Label: "com.df.scaleDuringUpdate", Default: UpdateReject})
+	ts := s.getTestService()
+	ts.Spec.Labels["com.df.scaleDuringUpdate"] = "proceed"
+	ts.UpdateStatus = &swarm.UpdateStatus{State: swarm.UpdateStateUpdating}
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(ts, nil)
+	s.clientMock.On("ServiceUpdate", s.ctx, "web_testID", mock.Anything, mock.Anything).Return(nil)
+
+	result, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+	s.Equal(uint64(6), result.Current)
+}
+
+func (s *ScalerTestSuite) Test_Scale_AfterUpdateCompleted() {
+	s.scaler.SetUpdatePolicy(UpdatePolicyOptions{Default: UpdateReject})
+	ts := s.getTestService()
+	ts.UpdateStatus = &swarm.UpdateStatus{State: swarm.UpdateStateRollbackCompleted}
+	s.clientMock.On("ServiceInspect", s.ctx, "web_test").Return(ts, nil)
+	s.clientMock.On("ServiceUpdate", s.ctx, "web_testID", mock.Anything, mock.Anything).Return(nil)
+
+	_, err := s.scaler.Scale(s.ctx, "web_test", 0, ScaleUpDirection)
+	s.Require().NoError(err)
+}
+
 func (s *ScalerTestSuite) getTestService() swarm.Service {
 	labels := map[string]string{
 		"com.df.scaleMin":    "2",
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/docker/docker/api/types/swarm"
+)
+
+// UpdatePolicy is what happens to scaling a service while swarm updates or
+// rolls back the service
+type UpdatePolicy string
+
+const (
+	// UpdateProceed scales the service during its update
+	UpdateProceed UpdatePolicy = "proceed"
+	// UpdateReject rejects scaling the service during its update
+	UpdateReject UpdatePolicy = "reject"
+	// UpdateQueue scales the service once its update completes
+	UpdateQueue UpdatePolicy = "queue"
+)
+
+// UpdatePolicies are the valid update policies
+var UpdatePolicies = []UpdatePolicy{UpdateProceed, UpdateReject, UpdateQueue}
+
+// Valid returns true when `p` is a known policy
+func (p UpdatePolicy) Valid() bool {
+	for _, policy := range UpdatePolicies {
+		if p == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdatePolicyOptions select the update policy of a service
+type UpdatePolicyOptions struct {
+	// Label of a service that overrides Default
+	Label   string
+	Default UpdatePolicy
+	// QueueTimeout is how long a queued scale waits for the update to
+	// complete
+	QueueTimeout time.Duration
+}
+
+// policy returns the update policy of a service with `labels`. An unknown
+// label value falls back to the default, like the other scaling labels
+func (o UpdatePolicyOptions) policy(labels map[string]string) UpdatePolicy {
+	if p := UpdatePolicy(labels[o.Label]); len(o.Label) > 0 && p.Valid() {
+		return p
+	}
+	if o.Default.Valid() {
+		return o.Default
+	}
+	return UpdateProceed
+}
+
+// UpdateInProgressError is returned when a service is not scaled, since
+// swarm is updating or rolling it back
+type UpdateInProgressError struct {
+	Service string
+	State   swarm.UpdateState
+	// Policy is UpdateReject or UpdateQueue
+	Policy       UpdatePolicy
+	QueueTimeout time.Duration
+}
+
+func (e *UpdateInProgressError) Error() string {
+	if e.Policy == UpdateQueue {
+		return fmt.Sprintf("Scaling %s is queued until its update completes, the update is %s",
+			e.Service, updateStateDescription(e.State))
+	}
+	return fmt.Sprintf("Scaling %s is rejected while it is updated, the update is %s",
+		e.Service, updateStateDescription(e.State))
+}
+
+// updateInProgress returns true when swarm is updating or rolling back a
+// service with `status`, including when the update or rollback is paused
+func updateInProgress(status *swarm.UpdateStatus) bool {
+	if status == nil {
+		return false
+	}
+	switch status.State {
+	case swarm.UpdateStateUpdating, swarm.UpdateStatePaused,
+		swarm.UpdateStateRollbackStarted, swarm.UpdateStateRollbackPaused:
+		return true
+	}
+	return false
+}
+
+func updateStateDescription(state swarm.UpdateState) string {
+	switch state {
+	case swarm.UpdateStatePaused:
+		return "paused"
+	case swarm.UpdateStateRollbackStarted:
+		return "rolling back"
+	case swarm.UpdateStateRollbackPaused:
+		return "paused while rolling back"
+	}
+	return "in progress"
+}
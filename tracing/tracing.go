@@ -0,0 +1,177 @@
+package tracing
+
+// OpenTelemetry traces of docker-scaler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Exporters of spans
+const (
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+const tracerName = "github.com/thomasjpfan/docker-scaler"
+
+// Setup propagates W3C trace context and exports spans with `exporter`.
+// Spans are not recorded when `exporter` is empty. The stdout exporter
+// writes to `w`, the otlp exporter is configured with the
+// `OTEL_EXPORTER_OTLP_*` env variables. The returned function flushes and
+// stops the exporter
+func Setup(ctx context.Context, exporter string, w io.Writer) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exp sdktrace.SpanExporter
+	var err error
+	switch exporter {
+	case "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exp, err = otlptracehttp.New(ctx)
+	case ExporterStdout:
+		exp, err = stdouttrace.New(stdouttrace.WithWriter(w))
+	default:
+		return nil, fmt.Errorf("Unknown tracing exporter: %s, exporter can only be otlp or stdout", exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName("docker-scaler")),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK())
+	if err != nil {
+		return nil, err
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Start starts a span named `name` as a child of the span in `ctx`
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records `*err` on `span` when it is not nil and ends `span`. It is
+// meant to be deferred with a pointer to a named error result
+func End(span trace.Span, err *error) {
+	if err != nil && *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+	span.End()
+}
+
+// Middleware creates a span for each request, named after its route. It
+// continues the trace of requests with W3C trace context headers
+func Middleware(h http.Handler) http.Handler {
+	withRequestID := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := logging.RequestID(r.Context()); len(id) > 0 {
+			trace.SpanFromContext(r.Context()).SetAttributes(
+				attribute.String("request.id", id))
+		}
+		h.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(withRequestID, "http",
+		otelhttp.WithSpanNameFormatter(spanName))
+}
+
+func spanName(_ string, r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if tpl, err := route.GetPathTemplate(); err == nil {
+			return r.Method + " " + tpl
+		}
+	}
+	return r.Method
+}
+
+// UnaryServerInterceptor creates a span for each gRPC request, named after
+// its method, like Middleware does for http requests. It continues the
+// trace of requests with W3C trace context metadata
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, h grpc.UnaryHandler) (resp interface{}, err error) {
+	ctx, span := startGRPC(ctx, info.FullMethod)
+	defer End(span, &err)
+	return h(ctx, req)
+}
+
+// StreamServerInterceptor creates a span for each gRPC stream, which ends
+// with the stream
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, h grpc.StreamHandler) (err error) {
+	ctx, span := startGRPC(ss.Context(), info.FullMethod)
+	defer End(span, &err)
+	return h(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+func startGRPC(ctx context.Context, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	var attrs []attribute.KeyValue
+	if id := logging.RequestID(ctx); len(id) > 0 {
+		attrs = append(attrs, attribute.String("request.id", id))
+	}
+	return Start(ctx, method, attrs...)
+}
+
+// metadataCarrier reads W3C trace context from gRPC metadata
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// contextStream replaces the context of a gRPC stream
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// Inject adds the trace context of `ctx` to the headers of an outgoing
+// request
+func Inject(ctx context.Context, header http.Header) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(header))
+}
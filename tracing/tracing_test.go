@@ -0,0 +1,125 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/suite"
+	"github.com/thomasjpfan/docker-scaler/logging"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type TracingTestSuite struct {
+	suite.Suite
+	sr *tracetest.SpanRecorder
+}
+
+func TestTracingUnitTestSuite(t *testing.T) {
+	suite.Run(t, new(TracingTestSuite))
+}
+
+func (s *TracingTestSuite) SetupTest() {
+	s.sr = tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(s.sr)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+}
+
+func (s *TracingTestSuite) Test_Middleware_ContinuesTrace() {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.Path("/v1/scale-service").Methods("POST").
+		HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, span := Start(r.Context(), "docker.service_inspect")
+			span.End()
+		})
+
+	req, _ := http.NewRequest("POST", "/v1/scale-service", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req = req.WithContext(logging.WithRequestID(req.Context(), "req-1"))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	spans := s.sr.Ended()
+	s.Require().Len(spans, 2)
+	inspect, server := spans[0], spans[1]
+	s.Equal("POST /v1/scale-service", server.Name())
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	s.Equal("00f067aa0ba902b7", server.Parent().SpanID().String())
+	s.Contains(server.Attributes(), attribute.String("request.id", "req-1"))
+	s.Equal(server.SpanContext().SpanID(), inspect.Parent().SpanID())
+}
+
+func (s *TracingTestSuite) Test_UnaryServerInterceptor_ContinuesTrace() {
+	md := metadata.Pairs("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := logging.WithRequestID(metadata.NewIncomingContext(context.Background(), md), "req-1")
+	info := &grpc.UnaryServerInfo{FullMethod: "/dockerscaler.v1.Scaler/ScaleService"}
+	_, err := UnaryServerInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, errors.New("Unable to scale web")
+	})
+	s.Require().Error(err)
+
+	spans := s.sr.Ended()
+	s.Require().Len(spans, 1)
+	s.Equal("/dockerscaler.v1.Scaler/ScaleService", spans[0].Name())
+	s.Equal("4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	s.Contains(spans[0].Attributes(), attribute.String("request.id", "req-1"))
+	s.Equal(codes.Error, spans[0].Status().Code)
+}
+
+func (s *TracingTestSuite) Test_End_RecordsError() {
+	err := errors.New("Unable to describe-auto-scaling-groups")
+	_, span := Start(context.Background(), "aws.describe_auto_scaling_groups")
+	End(span, &err)
+
+	_, span = Start(context.Background(), "docker.info")
+	err = nil
+	End(span, &err)
+
+	spans := s.sr.Ended()
+	s.Require().Len(spans, 2)
+	s.Equal(codes.Error, spans[0].Status().Code)
+	s.Equal("Unable to describe-auto-scaling-groups", spans[0].Status().Description)
+	s.Equal(codes.Unset, spans[1].Status().Code)
+}
+
+func (s *TracingTestSuite) Test_Inject() {
+	ctx, span := Start(context.Background(), "alertmanager.send")
+	defer span.End()
+
+	header := http.Header{}
+	Inject(ctx, header)
+	s.Contains(header.Get("traceparent"), span.SpanContext().TraceID().String())
+}
+
+func (s *TracingTestSuite) Test_Setup_Stdout() {
+	b := new(bytes.Buffer)
+	shutdown, err := Setup(context.Background(), "stdout", b)
+	s.Require().NoError(err)
+
+	_, span := Start(context.Background(), "docker.node_list")
+	span.End()
+	s.Require().NoError(shutdown(context.Background()))
+	s.Contains(b.String(), `"Name":"docker.node_list"`)
+}
+
+func (s *TracingTestSuite) Test_Setup_Disabled() {
+	shutdown, err := Setup(context.Background(), "", new(bytes.Buffer))
+	s.Require().NoError(err)
+	s.NoError(shutdown(context.Background()))
+}
+
+func (s *TracingTestSuite) Test_Setup_Invalid() {
+	_, err := Setup(context.Background(), "jaeger", new(bytes.Buffer))
+	s.EqualError(err, "Unknown tracing exporter: jaeger, exporter can only be otlp or stdout")
+}